@@ -8,14 +8,27 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/kTowkA/shortener/internal/account"
+	"github.com/kTowkA/shortener/internal/admin"
+	"github.com/kTowkA/shortener/internal/apitoken"
 	"github.com/kTowkA/shortener/internal/app"
+	"github.com/kTowkA/shortener/internal/clientip"
 	"github.com/kTowkA/shortener/internal/config"
+	"github.com/kTowkA/shortener/internal/deletion"
+	"github.com/kTowkA/shortener/internal/events"
 	gapp "github.com/kTowkA/shortener/internal/grpc/app"
+	gserver "github.com/kTowkA/shortener/internal/grpc/server"
+	"github.com/kTowkA/shortener/internal/health"
+	"github.com/kTowkA/shortener/internal/jwtkeys"
 	"github.com/kTowkA/shortener/internal/logger"
+	"github.com/kTowkA/shortener/internal/oidc"
+	"github.com/kTowkA/shortener/internal/ratelimit"
 	"github.com/kTowkA/shortener/internal/storage"
 	"github.com/kTowkA/shortener/internal/storage/memory"
 	"github.com/kTowkA/shortener/internal/storage/postgres"
 	"github.com/kTowkA/shortener/internal/storage/postgres/migrations"
+	"github.com/kTowkA/shortener/internal/threat"
+	"github.com/kTowkA/shortener/internal/webhook"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -44,6 +57,12 @@ func main() {
 		customLog.Error("загрузка конфигурации", slog.String("ошибка", err.Error()))
 		return
 	}
+	// ключи подписи токенов пользователей
+	keys, err := jwtkeys.Load(cfg.JWTKeys(), cfg.SecretKey())
+	if err != nil {
+		customLog.Error("загрузка ключей подписи токенов", slog.String("ошибка", err.Error()))
+		return
+	}
 
 	// хранилище
 	myStorage, err := initStorage(cfg)
@@ -51,15 +70,130 @@ func main() {
 		customLog.Error("инициализация хранилища", slog.String("ошибка", err.Error()))
 	}
 	defer myStorage.Close()
+	// проверки готовности общие для HTTP (/readyz) и gRPC (grpc.health.v1)
+	checker := health.New(health.Options{})
+	checker.Add(health.CheckStorage, myStorage.Ping)
+	if pg, ok := myStorage.(*postgres.PostgresStorage); ok {
+		checker.Add(health.CheckMigrations, pg.CheckMigrations)
+	}
+	// суточная квота ссылок пользователя
+	myStorage = ratelimit.WithDailyQuota(myStorage, cfg.DailyLinkQuota())
+	// запрет администратора на создание ссылок проверяется раньше квоты
+	myStorage = admin.WithUserBlocks(myStorage)
+	// события ссылок передаются через шину подпискам пользователей и потокам событий.
+	// шина и диспетчер работают с исходным хранилищем, а приложение - с публикующим события
+	bus := events.NewBus(myStorage, events.BusOptions{Logger: customLog.Logger})
+	hooks := webhook.New(myStorage, webhook.Options{
+		Timeout:     cfg.WebhookTimeout(),
+		MaxAttempts: cfg.WebhookMaxAttempts(),
+		Retention:   cfg.WebhookRetention(),
+		Logger:      customLog.Logger,
+	})
+	bus.Attach(hooks)
+	myStorage = events.WithEvents(myStorage, bus)
+	// операции администраторов доступны только из доверенной подсети
+	admins := admin.New(myStorage, cfg.AuditRetention(), customLog.Logger)
+	// учетные записи пользователей
+	accounts := account.New(myStorage, customLog.Logger)
+	// персональные API токены учетных записей для скриптов
+	tokens := apitoken.New(myStorage, customLog.Logger)
+
+	// очередь удаления ссылок общая для HTTP и gRPC, запускается сервером приложения
+	deletes := deletion.New(myStorage, deletion.Options{
+		FlushInterval: cfg.DeleteFlushInterval(),
+		BatchSize:     cfg.DeleteBatchSize(),
+		Capacity:      cfg.DeleteQueueCapacity(),
+		Retention:     cfg.DeleteRetention(),
+		Logger:        customLog.Logger,
+	})
+	// ссылки, оставшиеся в очереди с предыдущего запуска, учитываются до приема новых запросов обоими серверами
+	if err := deletes.Restore(context.Background()); err != nil {
+		customLog.Error("получение ожидающих задач удаления", slog.String("ошибка", err.Error()))
+	}
+
+	// ограничение частоты запросов общее для HTTP и gRPC
+	limiter := ratelimit.New(ratelimit.Limits{
+		ratelimit.Create:   cfg.RateLimitCreate(),
+		ratelimit.Redirect: cfg.RateLimitRedirect(),
+		ratelimit.Delete:   cfg.RateLimitDelete(),
+	})
+
+	var (
+		appOpts = []app.Option{
+			app.WithRateLimiter(limiter),
+			app.WithWebhooks(hooks),
+			app.WithEvents(bus),
+			app.WithDeletionQueue(deletes),
+			app.WithAdmin(admins),
+			app.WithAccounts(accounts),
+			app.WithAPITokens(tokens),
+			app.WithJWTKeys(keys),
+			app.WithHealth(checker),
+		}
+		gRPCOpts = []gserver.Option{
+			gserver.WithBaseAddress(cfg.BaseAddress()),
+			gserver.WithIdempotencyWindow(cfg.IdempotencyWindow()),
+			gserver.WithRateLimiter(limiter),
+			gserver.WithWebhooks(hooks),
+			gserver.WithEvents(bus),
+			gserver.WithDeletionQueue(deletes),
+			gserver.WithAdmin(admins, cfg.TrustedSubnets()),
+			gserver.WithTrustedSubnets(cfg.TrustedSubnets()),
+			gserver.WithClientIP(clientip.NewResolver(cfg.TrustedProxies())),
+			gserver.WithHealth(checker),
+			gserver.WithAPITokens(tokens),
+		}
+	)
+	// список угроз
+	if cfg.ThreatList() != "" {
+		threats, err := threat.Load(cfg.ThreatList())
+		if err != nil {
+			customLog.Error("загрузка списка угроз", slog.String("ошибка", err.Error()))
+			return
+		}
+		customLog.Info("загружен список угроз", slog.Int("количество префиксов", threats.Len()))
+		appOpts = append(appOpts, app.WithThreatList(threats))
+		gRPCOpts = append(gRPCOpts, gserver.WithThreatList(threats))
+	}
+	// вход через провайдера входа компании
+	if cfg.OIDCIssuer() != "" {
+		provider, err := oidc.New(oidc.Options{
+			Issuer:         cfg.OIDCIssuer(),
+			ClientID:       cfg.OIDCClientID(),
+			ClientSecret:   cfg.OIDCClientSecret(),
+			RedirectURL:    cfg.OIDCRedirectURL(),
+			AllowedDomains: cfg.OIDCAllowedDomains(),
+			Logger:         customLog.Logger,
+		})
+		if err != nil {
+			customLog.Error("настройка провайдера входа", slog.String("ошибка", err.Error()))
+			return
+		}
+		appOpts = append(appOpts, app.WithOIDC(provider))
+	}
 
 	// приложение
-	srv, err := app.NewServer(cfg, customLog.Logger)
+	srv, err := app.NewServer(cfg, customLog.Logger, appOpts...)
 	if err != nil {
 		customLog.Error("создание сервера приложения", slog.String("ошибка", err.Error()))
 		return
 	}
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	defer cancel()
+	// шина и доставка событий останавливаются после серверов, чтобы сохранить события последних запросов
+	busCtx, busCancel := context.WithCancel(context.Background())
+	busDone := make(chan struct{})
+	go func() {
+		defer close(busDone)
+		bus.Run(busCtx)
+	}()
+	hooksCtx, hooksCancel := context.WithCancel(context.Background())
+	hooksDone := make(chan struct{})
+	go func() {
+		defer close(hooksDone)
+		hooks.Run(hooksCtx)
+	}()
+	go admins.Run(ctx)
 	gr, _ := errgroup.WithContext(ctx)
 	gr.Go(func() error {
 		if err = srv.Run(ctx, myStorage); err != nil {
@@ -72,7 +206,7 @@ func main() {
 		if cfg.GRPC() == "" {
 			return nil
 		}
-		if err = gapp.Run(ctx, myStorage, customLog.Logger, cfg.GRPC()); err != nil {
+		if err = gapp.Run(ctx, myStorage, customLog.Logger, cfg.GRPC(), gRPCOpts...); err != nil {
 			customLog.Error("запуск gRPC-сервера приложения", slog.String("ошибка", err.Error()))
 			return err
 		}
@@ -82,6 +216,10 @@ func main() {
 	if err != nil {
 		customLog.Error("запуск группы", slog.String("ошибка", err.Error()))
 	}
+	busCancel()
+	<-busDone
+	hooksCancel()
+	<-hooksDone
 }
 
 // инициализация логера
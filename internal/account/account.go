@@ -0,0 +1,215 @@
+// пакет account реализует учетные записи пользователей: регистрацию, вход по логину и паролю или через
+// провайдера входа (SSO) и перенос ссылок, созданных анонимно. ID учетной записи совпадает с ID пользователя, поэтому после входа в другом браузере
+// или по истечении срока действия токена пользователь снова получает доступ к своим ссылкам
+package account
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// MinLogin и MaxLogin допустимая длина логина в символах
+	MinLogin = 3
+	MaxLogin = 64
+	// MinPassword и MaxPassword допустимая длина пароля в байтах. bcrypt учитывает только первые 72 байта
+	MinPassword = 8
+	MaxPassword = 72
+	// SSOPrefix начало логина учетных записей провайдера входа. такой логин нельзя зарегистрировать с паролем
+	SSOPrefix = "sso:"
+)
+
+// Service операции с учетными записями
+type Service struct {
+	store  storage.Storager
+	logger *slog.Logger
+	// cost сложность bcrypt
+	cost int
+	// dummyHash хэш, с которым сравнивается пароль для несуществующего логина,
+	// чтобы по времени ответа нельзя было определить, зарегистрирован ли логин
+	dummyHash []byte
+}
+
+// New создает новый экземпляр Service. если logger не задан - slog.Default()
+func New(store storage.Storager, logger *slog.Logger) *Service {
+	return newService(store, logger, bcrypt.DefaultCost)
+}
+
+func newService(store storage.Storager, logger *slog.Logger, cost int) *Service {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("shortener"), cost)
+	return &Service{store: store, logger: logger, cost: cost, dummyHash: dummyHash}
+}
+
+// Register создает учетную запись с логином и паролем creds. учетная запись получает ID текущего пользователя userID,
+// и его ссылки сохраняются. если userID уже принадлежит другой учетной записи - новый ID
+func (s *Service) Register(ctx context.Context, userID uuid.UUID, creds model.Credentials) (model.Account, error) {
+	login, err := validate(creds)
+	if err != nil {
+		return model.Account{}, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), s.cost)
+	if err != nil {
+		return model.Account{}, fmt.Errorf("хэширование пароля. %w", err)
+	}
+	if userID == uuid.Nil || s.isAccount(ctx, userID) {
+		userID = uuid.New()
+	}
+	account := model.Account{
+		ID:           userID,
+		Login:        login,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := s.store.CreateAccount(ctx, account); err != nil {
+		return model.Account{}, err
+	}
+	s.logger.Info("регистрация пользователя", slog.String("логин", login), slog.String("userID", userID.String()))
+	return account, nil
+}
+
+// Login проверяет логин и пароль creds и возвращает учетную запись. ссылки текущего анонимного пользователя
+// anonymousID (uuid.Nil - нет) переносятся в учетную запись
+func (s *Service) Login(ctx context.Context, anonymousID uuid.UUID, creds model.Credentials) (model.LoginResponse, error) {
+	account, err := s.store.AccountByLogin(ctx, normalize(creds.Login))
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(creds.Password))
+		return model.LoginResponse{}, apierror.Wrap(apierror.CodeInvalidCredentials, err)
+	}
+	if err != nil {
+		return model.LoginResponse{}, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(creds.Password)); err != nil {
+		return model.LoginResponse{}, apierror.Wrap(apierror.CodeInvalidCredentials, err)
+	}
+	return s.loggedIn(ctx, anonymousID, account), nil
+}
+
+// LoginOIDC вход пользователя identity, подтвержденного провайдером входа. при первом входе создается учетная запись
+// с ID SSOUserID, поэтому ссылки привязаны к сотруднику, а не к браузеру. ссылки анонимного пользователя anonymousID
+// переносятся в учетную запись, как при Login
+func (s *Service) LoginOIDC(ctx context.Context, anonymousID uuid.UUID, identity model.OIDCIdentity) (model.LoginResponse, error) {
+	userID := SSOUserID(identity.Issuer, identity.Subject)
+	account, err := s.store.Account(ctx, userID)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		account = model.Account{
+			ID:        userID,
+			Login:     SSOPrefix + identity.Subject,
+			CreatedAt: time.Now().UTC(),
+		}
+		err = s.store.CreateAccount(ctx, account)
+		if errors.Is(err, storage.ErrAccountExists) {
+			// одновременный первый вход в другом браузере
+			account, err = s.store.Account(ctx, userID)
+		} else if err == nil {
+			s.logger.Info("регистрация пользователя через провайдера входа",
+				slog.String("логин", account.Login),
+				slog.String("email", identity.Email),
+				slog.String("userID", userID.String()),
+			)
+		}
+	}
+	if err != nil {
+		return model.LoginResponse{}, err
+	}
+	return s.loggedIn(ctx, anonymousID, account), nil
+}
+
+// SSOUserID ID пользователя subject провайдера входа issuer. не меняется между входами и не зависит от хранилища
+func SSOUserID(issuer, subject string) uuid.UUID {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(issuer+"#"+subject))
+}
+
+// loggedIn ответ на вход в учетную запись account с переносом ссылок анонимного пользователя anonymousID (uuid.Nil - нет)
+func (s *Service) loggedIn(ctx context.Context, anonymousID uuid.UUID, account model.Account) model.LoginResponse {
+	resp := model.LoginResponse{Account: account}
+	if anonymousID != uuid.Nil && anonymousID != account.ID && !s.isAccount(ctx, anonymousID) {
+		// вход уже выполнен - ошибка переноса ссылок не мешает ему, ссылки можно перенести позже через Claim
+		var err error
+		resp.Claimed, err = s.store.ClaimURLs(ctx, anonymousID, account.ID)
+		if err != nil {
+			s.logger.Error("перенос ссылок при входе", slog.String("userID", account.ID.String()), slog.String("ошибка", err.Error()))
+		}
+	}
+	return resp
+}
+
+// Claim переносит ссылки анонимного пользователя from в учетную запись accountID.
+// ссылки другой учетной записи перенести нельзя
+func (s *Service) Claim(ctx context.Context, accountID, from uuid.UUID) (model.ClaimResponse, error) {
+	if _, err := s.store.Account(ctx, accountID); err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			return model.ClaimResponse{}, apierror.Wrap(apierror.CodeUnauthorized, err).WithDetails("требуется вход в учетную запись")
+		}
+		return model.ClaimResponse{}, err
+	}
+	resp := model.ClaimResponse{From: from}
+	if from == accountID {
+		return resp, nil
+	}
+	if s.isAccount(ctx, from) {
+		return model.ClaimResponse{}, apierror.New(apierror.CodeForbidden).WithDetails("ссылки принадлежат другой учетной записи")
+	}
+	claimed, err := s.store.ClaimURLs(ctx, from, accountID)
+	if err != nil {
+		return model.ClaimResponse{}, err
+	}
+	resp.Claimed = claimed
+	s.logger.Info("перенос ссылок в учетную запись",
+		slog.String("userID", accountID.String()),
+		slog.String("из", from.String()),
+		slog.Int("ссылок", claimed),
+	)
+	return resp, nil
+}
+
+// IsAccount проверяет, что userID принадлежит учетной записи
+func (s *Service) IsAccount(ctx context.Context, userID uuid.UUID) (bool, error) {
+	_, err := s.store.Account(ctx, userID)
+	if errors.Is(err, storage.ErrAccountNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// isAccount как IsAccount, но при ошибке хранилища считает, что учетная запись есть. так при недоступном
+// хранилище ссылки учетной записи не переносятся и ее ID не выдается повторно
+func (s *Service) isAccount(ctx context.Context, userID uuid.UUID) bool {
+	ok, err := s.IsAccount(ctx, userID)
+	return ok || err != nil
+}
+
+// validate проверяет логин и пароль и возвращает логин в нормальной форме
+func validate(creds model.Credentials) (string, error) {
+	login := normalize(creds.Login)
+	switch n := utf8.RuneCountInString(login); {
+	case n < MinLogin || n > MaxLogin:
+		return "", apierror.New(apierror.CodeInvalidAccount).WithDetails(fmt.Sprintf("логин от %d до %d символов", MinLogin, MaxLogin))
+	case strings.IndexFunc(login, func(r rune) bool { return unicode.IsSpace(r) || !unicode.IsPrint(r) }) >= 0:
+		return "", apierror.New(apierror.CodeInvalidAccount).WithDetails("логин не должен содержать пробелы и служебные символы")
+	case strings.HasPrefix(login, SSOPrefix):
+		return "", apierror.New(apierror.CodeInvalidAccount).WithDetails("логин не должен начинаться с " + SSOPrefix)
+	case len(creds.Password) < MinPassword || len(creds.Password) > MaxPassword:
+		return "", apierror.New(apierror.CodeInvalidAccount).WithDetails(fmt.Sprintf("пароль от %d до %d байт", MinPassword, MaxPassword))
+	}
+	return login, nil
+}
+
+// normalize логин без пробелов по краям и в нижнем регистре
+func normalize(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
@@ -0,0 +1,143 @@
+package account
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	svc := newService(store, nil, bcrypt.MinCost)
+
+	tests := []struct {
+		name     string
+		creds    model.Credentials
+		wantCode apierror.Code
+	}{
+		{name: "короткий логин", creds: model.Credentials{Login: "ab", Password: "password"}, wantCode: apierror.CodeInvalidAccount},
+		{name: "пробел в логине", creds: model.Credentials{Login: "a b c", Password: "password"}, wantCode: apierror.CodeInvalidAccount},
+		{name: "короткий пароль", creds: model.Credentials{Login: "alice", Password: "secret"}, wantCode: apierror.CodeInvalidAccount},
+		{name: "длинный пароль", creds: model.Credentials{Login: "alice", Password: string(make([]byte, MaxPassword+1))}, wantCode: apierror.CodeInvalidAccount},
+	}
+	for _, tt := range tests {
+		_, err := svc.Register(ctx, uuid.New(), tt.creds)
+		assert.Equal(t, tt.wantCode, apierror.From(err).Code, tt.name)
+	}
+
+	// учетная запись получает ID текущего пользователя
+	userID := uuid.New()
+	account, err := svc.Register(ctx, userID, model.Credentials{Login: "  Alice ", Password: "password"})
+	require.NoError(t, err)
+	assert.Equal(t, userID, account.ID)
+	assert.Equal(t, "alice", account.Login)
+	assert.NotEqual(t, "password", account.PasswordHash)
+
+	_, err = svc.Register(ctx, uuid.New(), model.Credentials{Login: "ALICE", Password: "password"})
+	require.ErrorIs(t, err, storage.ErrAccountExists)
+
+	// ID уже занят учетной записью - новый ID
+	other, err := svc.Register(ctx, userID, model.Credentials{Login: "bob", Password: "password"})
+	require.NoError(t, err)
+	assert.NotEqual(t, userID, other.ID)
+}
+
+func TestLoginAndClaim(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	svc := newService(store, nil, bcrypt.MinCost)
+
+	accountID := uuid.New()
+	_, err = svc.Register(ctx, accountID, model.Credentials{Login: "alice", Password: "password"})
+	require.NoError(t, err)
+	otherID := uuid.New()
+	_, err = svc.Register(ctx, otherID, model.Credentials{Login: "bob", Password: "password"})
+	require.NoError(t, err)
+	anonymous := uuid.New()
+	_, err = store.SaveURL(ctx, anonymous, "https://go.dev", "go", model.LinkOptions{})
+	require.NoError(t, err)
+	_, err = store.SaveURL(ctx, otherID, "https://example.com", "bob", model.LinkOptions{})
+	require.NoError(t, err)
+
+	_, err = svc.Login(ctx, anonymous, model.Credentials{Login: "alice", Password: "wrong-password"})
+	assert.Equal(t, apierror.CodeInvalidCredentials, apierror.From(err).Code)
+	_, err = svc.Login(ctx, anonymous, model.Credentials{Login: "nobody", Password: "password"})
+	assert.Equal(t, apierror.CodeInvalidCredentials, apierror.From(err).Code)
+
+	// при входе ссылки анонимной сессии переносятся в учетную запись
+	resp, err := svc.Login(ctx, anonymous, model.Credentials{Login: " Alice", Password: "password"})
+	require.NoError(t, err)
+	assert.Equal(t, accountID, resp.ID)
+	assert.Equal(t, 1, resp.Claimed)
+	link, err := store.Link(ctx, "go")
+	require.NoError(t, err)
+	assert.Equal(t, accountID.String(), link.UserID)
+
+	// вход из другой учетной записи не переносит ее ссылки
+	resp, err = svc.Login(ctx, otherID, model.Credentials{Login: "alice", Password: "password"})
+	require.NoError(t, err)
+	assert.Zero(t, resp.Claimed)
+
+	// перенос по токену другого браузера
+	_, err = store.SaveURL(ctx, anonymous, "https://go.dev/doc", "doc", model.LinkOptions{})
+	require.NoError(t, err)
+	claimed, err := svc.Claim(ctx, accountID, anonymous)
+	require.NoError(t, err)
+	assert.Equal(t, model.ClaimResponse{From: anonymous, Claimed: 1}, claimed)
+	claimed, err = svc.Claim(ctx, accountID, accountID)
+	require.NoError(t, err)
+	assert.Zero(t, claimed.Claimed)
+
+	_, err = svc.Claim(ctx, accountID, otherID)
+	assert.Equal(t, apierror.CodeForbidden, apierror.From(err).Code)
+	_, err = svc.Claim(ctx, anonymous, uuid.New())
+	assert.Equal(t, apierror.CodeUnauthorized, apierror.From(err).Code)
+	link, err = store.Link(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, otherID.String(), link.UserID)
+}
+
+func TestLoginOIDC(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	svc := newService(store, nil, bcrypt.MinCost)
+	identity := model.OIDCIdentity{Issuer: "https://idp.example.com", Subject: "42", Email: "alice@example.com"}
+
+	anonymous := uuid.New()
+	_, err = store.SaveURL(ctx, anonymous, "https://go.dev", "go", model.LinkOptions{})
+	require.NoError(t, err)
+
+	// первый вход создает учетную запись и переносит ссылки анонимной сессии
+	resp, err := svc.LoginOIDC(ctx, anonymous, identity)
+	require.NoError(t, err)
+	assert.Equal(t, SSOUserID(identity.Issuer, identity.Subject), resp.ID)
+	assert.Equal(t, "sso:42", resp.Login)
+	assert.Equal(t, 1, resp.Claimed)
+
+	// повторный вход из другого браузера - та же учетная запись
+	again, err := svc.LoginOIDC(ctx, uuid.New(), identity)
+	require.NoError(t, err)
+	assert.Equal(t, resp.ID, again.ID)
+	assert.Equal(t, resp.CreatedAt, again.CreatedAt)
+
+	// другой провайдер - другой пользователь
+	assert.NotEqual(t, resp.ID, SSOUserID("https://other.example.com", identity.Subject))
+
+	// учетная запись провайдера не доступна по паролю, а ее логин нельзя зарегистрировать
+	_, err = svc.Login(ctx, uuid.Nil, model.Credentials{Login: "sso:42", Password: ""})
+	assert.Equal(t, apierror.CodeInvalidCredentials, apierror.From(err).Code)
+	_, err = svc.Register(ctx, uuid.New(), model.Credentials{Login: "SSO:43", Password: "password"})
+	assert.Equal(t, apierror.CodeInvalidAccount, apierror.From(err).Code)
+}
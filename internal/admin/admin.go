@@ -0,0 +1,187 @@
+// пакет admin реализует операции администраторов над ссылками и пользователями: поиск ссылки и ее владельца,
+// отключение ссылок, просмотр ссылок пользователя, запрет создания ссылок и окончательное удаление.
+// каждая операция, в том числе неудачная, записывается в журнал действий администраторов
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+const (
+	// MaxPurge наибольшее количество ссылок в одном запросе окончательного удаления
+	MaxPurge = 1000
+
+	// resultOK результат успешного действия в журнале
+	resultOK = "ok"
+
+	defaultRetention = 90 * 24 * time.Hour
+	// maxPurgeInterval наибольший интервал удаления устаревших записей журнала
+	maxPurgeInterval = time.Hour
+)
+
+// Service операции администраторов
+type Service struct {
+	store     storage.Storager
+	retention time.Duration
+	logger    *slog.Logger
+}
+
+// New создает новый экземпляр Service, хранящий записи журнала в течение retention.
+// если retention не задан - 90 дней, если logger не задан - slog.Default()
+func New(store storage.Storager, retention time.Duration, logger *slog.Logger) *Service {
+	if retention <= 0 {
+		retention = defaultRetention
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Service{store: store, retention: retention, logger: logger}
+}
+
+// Run периодически удаляет записи журнала старше времени хранения до отмены ctx
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(min(s.retention, maxPurgeInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		count, err := s.store.PurgeAudit(ctx, time.Now().Add(-s.retention))
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Error("удаление журнала действий администраторов", slog.String("ошибка", err.Error()))
+			}
+			continue
+		}
+		if count > 0 {
+			s.logger.Debug("удалены устаревшие записи журнала действий администраторов", slog.Int("количество", count))
+		}
+	}
+}
+
+// Link ссылка short вместе с владельцем, в любом состоянии. actor - кто выполняет действие (для журнала)
+func (s *Service) Link(ctx context.Context, actor, short string) (model.StorageJSONWithUserID, error) {
+	link, err := s.store.Link(ctx, short)
+	s.audit(ctx, actor, model.AuditLinkLookup, short, "", err)
+	return link, err
+}
+
+// SetLinkDisabled отключает (disabled=true) или включает ссылку short независимо от владельца.
+// отключенная ссылка ведет себя как заблокированная
+func (s *Service) SetLinkDisabled(ctx context.Context, actor, short string, disabled bool) (model.StorageJSONWithUserID, error) {
+	action := model.AuditLinkEnable
+	if disabled {
+		action = model.AuditLinkDisable
+	}
+	link, err := s.store.Link(ctx, short)
+	if err == nil {
+		err = s.store.BlockURLs(ctx, []string{short}, disabled)
+		link.IsBlocked = disabled
+	}
+	s.audit(ctx, actor, action, short, "", err)
+	return link, err
+}
+
+// UserLinks все ссылки пользователя userID, включая удаленные и заблокированные
+func (s *Service) UserLinks(ctx context.Context, actor string, userID uuid.UUID) ([]model.StorageJSON, error) {
+	links, err := s.store.UserURLs(ctx, userID)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		links, err = []model.StorageJSON{}, nil
+	}
+	s.audit(ctx, actor, model.AuditUserLinks, userID.String(), "", err)
+	return links, err
+}
+
+// SetUserBlocked запрещает (blocked=true) или разрешает пользователю userID создавать ссылки.
+// уже созданные ссылки пользователя продолжают работать
+func (s *Service) SetUserBlocked(ctx context.Context, actor string, userID uuid.UUID, blocked bool, reason string) error {
+	action := model.AuditUserUnblock
+	if blocked {
+		action = model.AuditUserBlock
+	}
+	err := s.store.SetUserBlocked(ctx, userID, blocked, reason)
+	s.audit(ctx, actor, action, userID.String(), reason, err)
+	return err
+}
+
+// Purge окончательно удаляет ссылки shorts независимо от владельца. в отличие от удаления пользователем
+// ссылки не помечаются удаленными, а стираются из хранилища, и короткий ключ может быть выдан повторно
+func (s *Service) Purge(ctx context.Context, actor string, shorts []string) (model.PurgeResponse, error) {
+	target := strings.Join(shorts, ",")
+	var err error
+	switch {
+	case len(shorts) == 0:
+		err = apierror.New(apierror.CodeEmptyRequest)
+	case len(shorts) > MaxPurge:
+		err = apierror.New(apierror.CodeBadRequest).WithDetails(fmt.Sprintf("не более %d ссылок", MaxPurge))
+		target = fmt.Sprintf("%d ссылок", len(shorts))
+	}
+	if err != nil {
+		s.audit(ctx, actor, model.AuditLinksPurge, target, "", err)
+		return model.PurgeResponse{}, err
+	}
+
+	purged, err := s.store.PurgeURLs(ctx, shorts)
+	if err != nil {
+		s.audit(ctx, actor, model.AuditLinksPurge, target, "", err)
+		return model.PurgeResponse{}, err
+	}
+	resp := model.PurgeResponse{Purged: make([]string, 0, len(purged))}
+	found := make(map[string]bool, len(purged))
+	for _, link := range purged {
+		found[link.ShortURL] = true
+		resp.Purged = append(resp.Purged, link.ShortURL)
+	}
+	for _, short := range shorts {
+		if !found[short] {
+			resp.NotFound = append(resp.NotFound, short)
+		}
+	}
+	s.audit(ctx, actor, model.AuditLinksPurge, target, "purged="+strconv.Itoa(len(purged)), nil)
+	return resp, nil
+}
+
+// AuditLog журнал действий администраторов, начиная с последних, но не более limit
+func (s *Service) AuditLog(ctx context.Context, limit int) ([]model.AuditRecord, error) {
+	return s.store.AuditLog(ctx, limit)
+}
+
+// audit записывает действие action над target в журнал. err - ошибка, с которой действие не выполнено.
+// запись сохраняется и при отмене запроса, ошибка сохранения только логируется
+func (s *Service) audit(ctx context.Context, actor string, action model.AuditAction, target, details string, err error) {
+	result := resultOK
+	if err != nil {
+		result = string(apierror.From(err).Code)
+	}
+	record := model.AuditRecord{
+		ID:        uuid.New(),
+		Action:    action,
+		Actor:     actor,
+		Target:    target,
+		Details:   details,
+		Result:    result,
+		CreatedAt: time.Now().UTC(),
+	}
+	s.logger.Info("действие администратора",
+		slog.String("действие", string(action)),
+		slog.String("кто", actor),
+		slog.String("объект", target),
+		slog.String("результат", result),
+	)
+	if err := s.store.SaveAudit(context.WithoutCancel(ctx), record); err != nil {
+		s.logger.Error("сохранение записи журнала действий администраторов", slog.String("действие", string(action)), slog.String("ошибка", err.Error()))
+	}
+}
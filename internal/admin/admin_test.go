@@ -0,0 +1,137 @@
+package admin
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const actor = "test"
+
+func TestService(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	owner := uuid.New()
+	_, err = store.SaveURL(ctx, owner, "https://go.dev", "go", model.LinkOptions{})
+	require.NoError(t, err)
+	_, err = store.SaveURL(ctx, owner, "https://example.com", "spam", model.LinkOptions{})
+	require.NoError(t, err)
+	svc := New(store, 0, nil)
+
+	link, err := svc.Link(ctx, actor, "go")
+	require.NoError(t, err)
+	assert.Equal(t, owner.String(), link.UserID)
+	_, err = svc.Link(ctx, actor, "missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	link, err = svc.SetLinkDisabled(ctx, actor, "go", true)
+	require.NoError(t, err)
+	assert.True(t, link.IsBlocked)
+	real, err := store.RealURL(ctx, "go")
+	require.NoError(t, err)
+	assert.True(t, real.IsBlocked)
+	_, err = svc.SetLinkDisabled(ctx, actor, "go", false)
+	require.NoError(t, err)
+	real, err = store.RealURL(ctx, "go")
+	require.NoError(t, err)
+	assert.False(t, real.IsBlocked)
+	_, err = svc.SetLinkDisabled(ctx, actor, "missing", true)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	links, err := svc.UserLinks(ctx, actor, owner)
+	require.NoError(t, err)
+	assert.Len(t, links, 2)
+	links, err = svc.UserLinks(ctx, actor, uuid.New())
+	require.NoError(t, err)
+	assert.Empty(t, links)
+
+	resp, err := svc.Purge(ctx, actor, []string{"spam", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spam"}, resp.Purged)
+	assert.Equal(t, []string{"missing"}, resp.NotFound)
+	_, err = store.Link(ctx, "spam")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = svc.Purge(ctx, actor, nil)
+	assert.Equal(t, apierror.CodeEmptyRequest, apierror.From(err).Code)
+	_, err = svc.Purge(ctx, actor, make([]string, MaxPurge+1))
+	assert.Equal(t, apierror.CodeBadRequest, apierror.From(err).Code)
+
+	require.NoError(t, svc.SetUserBlocked(ctx, actor, owner, true, "спам"))
+	blocked, err := store.UserBlocked(ctx, owner)
+	require.NoError(t, err)
+	assert.True(t, blocked)
+
+	// в журнал попадают и неудачные действия, последние - первыми
+	records, err := svc.AuditLog(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 11)
+	assert.Equal(t, model.AuditUserBlock, records[0].Action)
+	assert.Equal(t, owner.String(), records[0].Target)
+	assert.Equal(t, "спам", records[0].Details)
+	assert.Equal(t, resultOK, records[0].Result)
+	assert.Equal(t, actor, records[0].Actor)
+	assert.Equal(t, model.AuditLinksPurge, records[2].Action)
+	assert.Equal(t, string(apierror.CodeEmptyRequest), records[2].Result)
+	assert.Equal(t, "purged=1", records[3].Details)
+	assert.Equal(t, model.AuditLinkLookup, records[len(records)-1].Action)
+
+	records, err = svc.AuditLog(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+}
+
+func TestRetention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	svc := New(store, 50*time.Millisecond, nil)
+	_, err = svc.Link(ctx, actor, "missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	records, err := svc.AuditLog(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	go svc.Run(ctx)
+
+	// записи журнала удаляются по истечении времени хранения
+	require.Eventually(t, func() bool {
+		records, err := svc.AuditLog(ctx, 0)
+		return err == nil && len(records) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestWithUserBlocks(t *testing.T) {
+	ctx := context.Background()
+	mem, err := memory.NewStorage("")
+	require.NoError(t, err)
+	store := WithUserBlocks(mem)
+	user := uuid.New()
+
+	_, err = store.SaveURL(ctx, user, "https://go.dev", "go", model.LinkOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, mem.SetUserBlocked(ctx, user, true, ""))
+	_, err = store.SaveURL(ctx, user, "https://example.com", "ex", model.LinkOptions{})
+	require.ErrorIs(t, err, storage.ErrUserBlocked)
+	_, err = store.Batch(ctx, user, model.BatchRequest{{CorrelationID: "1", OriginalURL: "https://example.com", ShortURL: "ex"}})
+	require.ErrorIs(t, err, storage.ErrUserBlocked)
+	// уже созданные ссылки продолжают работать
+	_, err = store.RealURL(ctx, "go")
+	require.NoError(t, err)
+	// запрет не распространяется на других пользователей
+	_, err = store.SaveURL(ctx, uuid.New(), "https://example.com", "ex", model.LinkOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, mem.SetUserBlocked(ctx, user, false, ""))
+	_, err = store.SaveURL(ctx, user, "https://example.org", "org", model.LinkOptions{})
+	require.NoError(t, err)
+}
@@ -0,0 +1,48 @@
+package admin
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// blockStore хранилище, не сохраняющее ссылки пользователей, которым это запрещено администратором
+type blockStore struct {
+	storage.Storager
+}
+
+// WithUserBlocks возвращает хранилище, которое перед сохранением ссылок проверяет, что пользователю
+// не запрещено их создавать. иначе ни одна ссылка запроса не сохраняется и возвращается storage.ErrUserBlocked
+func WithUserBlocks(store storage.Storager) storage.Storager {
+	return &blockStore{Storager: store}
+}
+
+// SaveURL реализация интерфейса Storager
+func (b *blockStore) SaveURL(ctx context.Context, userID uuid.UUID, real, short string, opts model.LinkOptions) (string, error) {
+	if err := b.allowed(ctx, userID); err != nil {
+		return "", err
+	}
+	return b.Storager.SaveURL(ctx, userID, real, short, opts)
+}
+
+// Batch реализация интерфейса Storager
+func (b *blockStore) Batch(ctx context.Context, userID uuid.UUID, values model.BatchRequest) (model.BatchResponse, error) {
+	if err := b.allowed(ctx, userID); err != nil {
+		return nil, err
+	}
+	return b.Storager.Batch(ctx, userID, values)
+}
+
+// allowed возвращает storage.ErrUserBlocked, если пользователю userID запрещено создавать ссылки
+func (b *blockStore) allowed(ctx context.Context, userID uuid.UUID) error {
+	blocked, err := b.UserBlocked(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return storage.ErrUserBlocked
+	}
+	return nil
+}
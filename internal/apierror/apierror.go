@@ -0,0 +1,240 @@
+// пакет apierror описывает ошибки, возвращаемые клиентам HTTP и gRPC API.
+// каждая ошибка имеет стабильный машиночитаемый код, по которому определяются HTTP статус и код gRPC
+package apierror
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/kTowkA/shortener/internal/i18n"
+	"github.com/kTowkA/shortener/internal/qrcode"
+	"github.com/kTowkA/shortener/internal/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Domain домен ошибок в деталях gRPC статуса (google.rpc.ErrorInfo)
+const Domain = "shortener"
+
+// Code машиночитаемый код ошибки. Значения кодов не меняются
+type Code string
+
+const (
+	CodeBadRequest         Code = "bad_request"
+	CodeEmptyRequest       Code = "empty_request"
+	CodeEmptyBatch         Code = "empty_batch"
+	CodeInvalidURL         Code = "invalid_url"
+	CodeInvalidContentType Code = "invalid_content_type"
+	CodeUnsupportedMedia   Code = "unsupported_media_type"
+	CodeInvalidLinkOptions Code = "invalid_link_options"
+	CodeInvalidQROptions   Code = "invalid_qr_options"
+	CodeInvalidUserID      Code = "invalid_user_id"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeForbidden          Code = "forbidden"
+	CodeThreatURL          Code = "threat_url"
+	CodeURLBlocked         Code = "url_blocked"
+	CodeURLNotFound        Code = "url_not_found"
+	CodeURLDeleted         Code = "url_deleted"
+	CodeURLConflict        Code = "url_conflict"
+	CodeURLExists          Code = "url_exists"
+	CodeJobNotFound        Code = "job_not_found"
+	CodeBadIdemKey         Code = "bad_idempotency_key"
+	CodeIdemKeyReused      Code = "idempotency_key_reused"
+	CodeIdemKeyNoUser      Code = "idempotency_no_user"
+	CodeRateLimited        Code = "rate_limited"
+	CodeQuotaExceeded      Code = "quota_exceeded"
+	CodeDeletionNotFound   Code = "deletion_not_found"
+	CodeDeleteQueueFull    Code = "delete_queue_full"
+	CodeInvalidWebhook     Code = "invalid_webhook"
+	CodeWebhookNotFound    Code = "webhook_not_found"
+	CodeDeliveryNotFound   Code = "delivery_not_found"
+	CodeUserBlocked        Code = "user_blocked"
+	CodeInvalidAccount     Code = "invalid_account"
+	CodeAccountExists      Code = "account_exists"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeInvalidAPIToken    Code = "invalid_api_token"
+	CodeAPITokenNotFound   Code = "api_token_not_found"
+	CodeInsufficientScope  Code = "insufficient_scope"
+	CodeSSOFailed          Code = "sso_failed"
+	CodeUnavailable        Code = "unavailable"
+	CodeInternal           Code = "internal"
+)
+
+// Message возвращает сообщение для кода на языке по умолчанию
+func (c Code) Message() string {
+	return c.Localized(i18n.Default)
+}
+
+// Localized возвращает сообщение для кода на языке lang
+func (c Code) Localized(lang i18n.Lang) string {
+	if !i18n.Has(string(c)) {
+		c = CodeInternal
+	}
+	return i18n.T(lang, string(c))
+}
+
+// HTTPStatus возвращает HTTP статус для кода
+func (c Code) HTTPStatus() int {
+	switch c {
+	case CodeBadRequest, CodeEmptyRequest, CodeEmptyBatch, CodeInvalidURL, CodeInvalidContentType,
+		CodeInvalidLinkOptions, CodeInvalidQROptions, CodeInvalidUserID, CodeBadIdemKey, CodeInvalidWebhook,
+		CodeInvalidAccount, CodeInvalidAPIToken, CodeIdemKeyNoUser:
+		return http.StatusBadRequest
+	case CodeUnsupportedMedia:
+		return http.StatusUnsupportedMediaType
+	case CodeUnauthorized, CodeInvalidCredentials, CodeSSOFailed:
+		return http.StatusUnauthorized
+	case CodeForbidden, CodeThreatURL, CodeURLBlocked, CodeUserBlocked, CodeInsufficientScope:
+		return http.StatusForbidden
+	case CodeURLNotFound, CodeJobNotFound, CodeDeletionNotFound, CodeWebhookNotFound, CodeDeliveryNotFound,
+		CodeAPITokenNotFound:
+		return http.StatusNotFound
+	case CodeURLDeleted:
+		return http.StatusGone
+	case CodeURLConflict, CodeURLExists, CodeAccountExists:
+		return http.StatusConflict
+	case CodeIdemKeyReused:
+		return http.StatusUnprocessableEntity
+	case CodeRateLimited, CodeQuotaExceeded:
+		return http.StatusTooManyRequests
+	case CodeUnavailable, CodeDeleteQueueFull:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode возвращает код gRPC для кода
+func (c Code) GRPCCode() codes.Code {
+	switch c.HTTPStatus() {
+	case http.StatusBadRequest, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound, http.StatusGone:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusUnprocessableEntity:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// Error ошибка API. В ответ клиенту попадают код, сообщение и детали, причина только логируется
+type Error struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details,omitempty"`
+	cause   error
+}
+
+// New создает ошибку с кодом code и сообщением по умолчанию
+func New(code Code) *Error {
+	return &Error{Code: code, Message: code.Message()}
+}
+
+// Wrap создает ошибку с кодом code, причиной которой является err
+func Wrap(code Code, err error) *Error {
+	e := New(code)
+	e.cause = err
+	return e
+}
+
+// WithDetails добавляет к ошибке детали
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+// Localize возвращает копию ошибки с сообщением на языке lang
+func (e *Error) Localize(lang i18n.Lang) *Error {
+	l := *e
+	l.Message = e.Code.Localized(lang)
+	return &l
+}
+
+// Error реализация интерфейса error
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ". " + e.cause.Error()
+	}
+	return e.Message
+}
+
+// Unwrap возвращает причину ошибки
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// GRPCStatus возвращает gRPC статус ошибки. Код ошибки передается в деталях google.rpc.ErrorInfo
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.Code.GRPCCode(), e.Message)
+	info := &errdetails.ErrorInfo{Reason: string(e.Code), Domain: Domain}
+	if details, ok := e.Details.(string); ok {
+		info.Metadata = map[string]string{"details": details}
+	}
+	if withDetails, err := st.WithDetails(info); err == nil {
+		return withDetails
+	}
+	return st
+}
+
+// From приводит произвольную ошибку к ошибке API. Ошибки хранилища и проверок получают свои коды, остальные - внутренняя ошибка
+func From(err error) *Error {
+	var e *Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, storage.ErrURLNotFound):
+		return Wrap(CodeURLNotFound, err)
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return Wrap(CodeQuotaExceeded, err)
+	case errors.Is(err, storage.ErrTaskNotFound):
+		return Wrap(CodeDeletionNotFound, err)
+	case errors.Is(err, storage.ErrUserBlocked):
+		return Wrap(CodeUserBlocked, err)
+	case errors.Is(err, storage.ErrHookNotFound):
+		return Wrap(CodeWebhookNotFound, err)
+	case errors.Is(err, storage.ErrDeliveryNotFound):
+		return Wrap(CodeDeliveryNotFound, err)
+	case errors.Is(err, storage.ErrJobNotFound):
+		return Wrap(CodeJobNotFound, err)
+	case errors.Is(err, storage.ErrURLConflict):
+		return Wrap(CodeURLConflict, err)
+	case errors.Is(err, storage.ErrURLIsExist):
+		return Wrap(CodeURLExists, err)
+	case errors.Is(err, storage.ErrAccountExists):
+		return Wrap(CodeAccountExists, err)
+	case errors.Is(err, storage.ErrAPITokenNotFound):
+		return Wrap(CodeAPITokenNotFound, err)
+	case errors.Is(err, qrcode.ErrOptions):
+		return Wrap(CodeInvalidQROptions, err).WithDetails(err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return Wrap(CodeUnavailable, err)
+	}
+	return Wrap(CodeInternal, err)
+}
+
+// CodeOf возвращает код ошибки API из gRPC ошибки err. Пустая строка - код не передан
+func CodeOf(err error) Code {
+	st, ok := status.FromError(err)
+	if !ok {
+		return ""
+	}
+	for _, d := range st.Details() {
+		if info, ok := d.(*errdetails.ErrorInfo); ok && info.Domain == Domain {
+			return Code(info.Reason)
+		}
+	}
+	return ""
+}
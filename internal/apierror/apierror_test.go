@@ -0,0 +1,126 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kTowkA/shortener/internal/i18n"
+	"github.com/kTowkA/shortener/internal/qrcode"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFrom(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantCode   Code
+		wantStatus int
+		wantGRPC   codes.Code
+	}{
+		{
+			name:       "не найдено",
+			err:        fmt.Errorf("поиск. %w", storage.ErrURLNotFound),
+			wantCode:   CodeURLNotFound,
+			wantStatus: http.StatusNotFound,
+			wantGRPC:   codes.NotFound,
+		},
+		{
+			name:       "конфликт",
+			err:        storage.ErrURLConflict,
+			wantCode:   CodeURLConflict,
+			wantStatus: http.StatusConflict,
+			wantGRPC:   codes.AlreadyExists,
+		},
+		{
+			name:       "уже существует",
+			err:        storage.ErrURLIsExist,
+			wantCode:   CodeURLExists,
+			wantStatus: http.StatusConflict,
+			wantGRPC:   codes.AlreadyExists,
+		},
+		{
+			name:       "логин занят",
+			err:        fmt.Errorf("регистрация. %w", storage.ErrAccountExists),
+			wantCode:   CodeAccountExists,
+			wantStatus: http.StatusConflict,
+			wantGRPC:   codes.AlreadyExists,
+		},
+		{
+			name:       "параметры QR кода",
+			err:        fmt.Errorf("%w: размер", qrcode.ErrOptions),
+			wantCode:   CodeInvalidQROptions,
+			wantStatus: http.StatusBadRequest,
+			wantGRPC:   codes.InvalidArgument,
+		},
+		{
+			name:       "ошибка API",
+			err:        fmt.Errorf("обертка. %w", New(CodeThreatURL)),
+			wantCode:   CodeThreatURL,
+			wantStatus: http.StatusForbidden,
+			wantGRPC:   codes.PermissionDenied,
+		},
+		{
+			name:       "прочие ошибки",
+			err:        errors.New("connection refused"),
+			wantCode:   CodeInternal,
+			wantStatus: http.StatusInternalServerError,
+			wantGRPC:   codes.Internal,
+		},
+	}
+	for _, tt := range tests {
+		e := From(tt.err)
+		assert.Equal(t, tt.wantCode, e.Code, tt.name)
+		assert.Equal(t, tt.wantStatus, e.Code.HTTPStatus(), tt.name)
+		assert.Equal(t, tt.wantGRPC, e.Code.GRPCCode(), tt.name)
+		assert.Equal(t, tt.wantCode.Message(), e.Message, tt.name)
+	}
+	assert.Nil(t, From(nil))
+
+	// причина внутренней ошибки не попадает в сообщение
+	e := From(errors.New("password=secret"))
+	assert.NotContains(t, e.Message, "secret")
+	assert.Contains(t, e.Error(), "secret")
+}
+
+func TestGRPCStatus(t *testing.T) {
+	var err error = New(CodeURLDeleted).WithDetails("abc")
+
+	st, ok := status.FromError(err)
+	assert.True(t, ok)
+	assert.Equal(t, codes.NotFound, st.Code())
+	assert.Equal(t, CodeURLDeleted.Message(), st.Message())
+	assert.Equal(t, CodeURLDeleted, CodeOf(err))
+	assert.Equal(t, CodeURLDeleted, CodeOf(st.Err()), "код сохраняется после передачи по сети")
+
+	assert.Equal(t, Code(""), CodeOf(status.Error(codes.Internal, "без деталей")))
+	assert.Equal(t, Code(""), CodeOf(errors.New("не gRPC ошибка")))
+}
+
+func TestLocalize(t *testing.T) {
+	codes := []Code{
+		CodeBadRequest, CodeEmptyRequest, CodeEmptyBatch, CodeInvalidURL, CodeInvalidContentType, CodeUnsupportedMedia,
+		CodeInvalidLinkOptions, CodeInvalidQROptions, CodeInvalidUserID, CodeUnauthorized, CodeForbidden, CodeThreatURL,
+		CodeURLBlocked, CodeURLNotFound, CodeURLDeleted, CodeURLConflict, CodeURLExists, CodeJobNotFound, CodeUnavailable,
+		CodeBadIdemKey, CodeIdemKeyReused, CodeIdemKeyNoUser, CodeRateLimited, CodeQuotaExceeded,
+		CodeDeletionNotFound, CodeDeleteQueueFull, CodeInvalidWebhook, CodeWebhookNotFound, CodeDeliveryNotFound, CodeUserBlocked, CodeInternal,
+		CodeInvalidAccount, CodeAccountExists, CodeInvalidCredentials, CodeInvalidAPIToken, CodeAPITokenNotFound, CodeInsufficientScope,
+		CodeSSOFailed,
+	}
+	for _, c := range codes {
+		assert.True(t, i18n.Has(string(c)), "нет сообщения для кода %s", c)
+	}
+
+	cause := errors.New("причина")
+	e := Wrap(CodeURLNotFound, cause).WithDetails("abc")
+	en := e.Localize(i18n.EN)
+	assert.Equal(t, "URL not found", en.Message)
+	assert.Equal(t, "abc", en.Details)
+	assert.ErrorIs(t, en, cause)
+	assert.Equal(t, "ссылка не найдена", e.Message, "исходная ошибка не меняется")
+	assert.Equal(t, CodeInternal.Localized(i18n.EN), Code("unknown").Localized(i18n.EN))
+}
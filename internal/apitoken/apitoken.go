@@ -0,0 +1,210 @@
+// пакет apitoken реализует персональные API токены для программного доступа к сервису. токен передается
+// в заголовке Authorization: Bearer (HTTP) или в метаданных authorization (gRPC) и дает только перечисленные
+// в нем права. в хранилище сохраняется хэш токена, само значение показывается пользователю один раз при создании
+package apitoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+const (
+	// Prefix начало значения токена, по которому его легко узнать, например, при поиске утечек в коде
+	Prefix = "shk_"
+	// MaxName наибольшая длина названия токена в символах
+	MaxName = 100
+
+	// tokenBytes количество случайных байт в значении токена
+	tokenBytes = 32
+)
+
+// Scopes права, которые можно выдать токену
+var Scopes = []model.Scope{model.ScopeLinksRead, model.ScopeLinksWrite, model.ScopeLinksDelete, model.ScopeStatsRead}
+
+// Service операции с персональными API токенами
+type Service struct {
+	store  storage.Storager
+	logger *slog.Logger
+}
+
+// New создает новый экземпляр Service. если logger не задан - slog.Default()
+func New(store storage.Storager, logger *slog.Logger) *Service {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Service{store: store, logger: logger}
+}
+
+// Create создает токен пользователя userID по запросу req. токены доступны только пользователям с учетной записью.
+// возвращаемый токен содержит значение, которое больше не будет показано
+func (s *Service) Create(ctx context.Context, userID uuid.UUID, req model.APITokenRequest) (model.APIToken, error) {
+	if _, err := s.store.Account(ctx, userID); err != nil {
+		if errors.Is(err, storage.ErrAccountNotFound) {
+			return model.APIToken{}, apierror.Wrap(apierror.CodeUnauthorized, err).WithDetails("API токены доступны только пользователям с учетной записью")
+		}
+		return model.APIToken{}, err
+	}
+	now := time.Now().UTC()
+	if err := validate(&req, now); err != nil {
+		return model.APIToken{}, apierror.New(apierror.CodeInvalidAPIToken).WithDetails(err.Error())
+	}
+	secret := make([]byte, tokenBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return model.APIToken{}, fmt.Errorf("создание API токена. %w", err)
+	}
+	value := Prefix + base64.RawURLEncoding.EncodeToString(secret)
+	token := model.APIToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Token:     value,
+		Hash:      Hash(value),
+		CreatedAt: now,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.store.SaveAPIToken(ctx, token); err != nil {
+		return model.APIToken{}, fmt.Errorf("сохранение API токена. %w", err)
+	}
+	s.logger.Info("создан API токен", slog.String("userID", userID.String()), slog.String("id", token.ID.String()))
+	return token, nil
+}
+
+// Tokens токены пользователя userID в порядке создания, без значений
+func (s *Service) Tokens(ctx context.Context, userID uuid.UUID) ([]model.APIToken, error) {
+	tokens, err := s.store.UserAPITokens(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("получение API токенов. %w", err)
+	}
+	return tokens, nil
+}
+
+// Revoke отзывает токен id пользователя userID. отозванный токен перестает приниматься сразу
+func (s *Service) Revoke(ctx context.Context, userID, id uuid.UUID) error {
+	if err := s.store.DeleteAPIToken(ctx, userID, id); err != nil {
+		return err
+	}
+	s.logger.Info("отозван API токен", slog.String("userID", userID.String()), slog.String("id", id.String()))
+	return nil
+}
+
+// Authenticate проверяет значение токена value и возвращает токен. неизвестный, отозванный
+// или просроченный токен - ошибка с кодом unauthorized
+func (s *Service) Authenticate(ctx context.Context, value string) (model.APIToken, error) {
+	if !strings.HasPrefix(value, Prefix) {
+		return model.APIToken{}, apierror.New(apierror.CodeUnauthorized).WithDetails("некорректный API токен")
+	}
+	token, err := s.store.APIToken(ctx, Hash(value))
+	if errors.Is(err, storage.ErrAPITokenNotFound) {
+		return model.APIToken{}, apierror.Wrap(apierror.CodeUnauthorized, err).WithDetails("API токен не найден или отозван")
+	}
+	if err != nil {
+		return model.APIToken{}, err
+	}
+	if token.Expired(time.Now()) {
+		return model.APIToken{}, apierror.New(apierror.CodeUnauthorized).WithDetails("срок действия API токена истек")
+	}
+	return token, nil
+}
+
+// Hash хэш SHA-256 значения токена value в hex. токен содержит 256 случайных бит,
+// поэтому медленный хэш, как для паролей, не нужен
+func Hash(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// Bearer значение токена из заголовка Authorization вида "Bearer <токен>". false - заголовок другого вида
+func Bearer(header string) (string, bool) {
+	scheme, value, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	value = strings.TrimSpace(value)
+	return value, value != ""
+}
+
+// tokenKey ключ токена в контексте запроса
+type tokenKey struct{}
+
+// WithToken сохраняет в контексте токен, которым аутентифицирован запрос
+func WithToken(ctx context.Context, token model.APIToken) context.Context {
+	return context.WithValue(ctx, tokenKey{}, token)
+}
+
+// FromContext токен, которым аутентифицирован запрос. false - запрос без API токена
+func FromContext(ctx context.Context) (model.APIToken, bool) {
+	token, ok := ctx.Value(tokenKey{}).(model.APIToken)
+	return token, ok
+}
+
+// Check проверяет, что запрос с контекстом ctx может выполнить операцию с правом scope.
+// запросы без API токена (с cookie) ограничений не имеют
+func Check(ctx context.Context, scope model.Scope) error {
+	token, ok := FromContext(ctx)
+	if !ok || token.Allows(scope) {
+		return nil
+	}
+	return apierror.New(apierror.CodeInsufficientScope).WithDetails(string(scope))
+}
+
+// Deny запрещает запросу с API токеном операцию, недоступную по токенам (например, управление токенами)
+func Deny(ctx context.Context) error {
+	if _, ok := FromContext(ctx); !ok {
+		return nil
+	}
+	return apierror.New(apierror.CodeInsufficientScope).WithDetails("операция недоступна по API токену")
+}
+
+// validate проверяет запрос на создание токена: название, права без повторов и срок действия в будущем
+func validate(req *model.APITokenRequest, now time.Time) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > MaxName {
+		return fmt.Errorf("название токена от 1 до %d символов", MaxName)
+	}
+	if len(req.Scopes) == 0 {
+		return fmt.Errorf("не указаны права токена")
+	}
+	scopes := make([]model.Scope, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !known(scope) {
+			return fmt.Errorf("неизвестное право %s", scope)
+		}
+		if !(model.APIToken{Scopes: scopes}).Allows(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	req.Scopes = scopes
+	if req.ExpiresAt != nil {
+		if !req.ExpiresAt.After(now) {
+			return fmt.Errorf("срок действия токена должен быть в будущем")
+		}
+		expires := req.ExpiresAt.UTC()
+		req.ExpiresAt = &expires
+	}
+	return nil
+}
+
+// known возвращает true, если право scope можно выдать токену
+func known(scope model.Scope) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package apitoken
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	svc := New(store, nil)
+
+	userID := uuid.New()
+	require.NoError(t, store.CreateAccount(ctx, model.Account{ID: userID, Login: "alice", CreatedAt: time.Now()}))
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name     string
+		userID   uuid.UUID
+		req      model.APITokenRequest
+		wantCode apierror.Code
+	}{
+		{name: "без учетной записи", userID: uuid.New(), req: model.APITokenRequest{Name: "ci", Scopes: []model.Scope{model.ScopeLinksRead}}, wantCode: apierror.CodeUnauthorized},
+		{name: "без названия", userID: userID, req: model.APITokenRequest{Name: " ", Scopes: []model.Scope{model.ScopeLinksRead}}, wantCode: apierror.CodeInvalidAPIToken},
+		{name: "длинное название", userID: userID, req: model.APITokenRequest{Name: strings.Repeat("я", MaxName+1), Scopes: []model.Scope{model.ScopeLinksRead}}, wantCode: apierror.CodeInvalidAPIToken},
+		{name: "без прав", userID: userID, req: model.APITokenRequest{Name: "ci"}, wantCode: apierror.CodeInvalidAPIToken},
+		{name: "неизвестное право", userID: userID, req: model.APITokenRequest{Name: "ci", Scopes: []model.Scope{"admin"}}, wantCode: apierror.CodeInvalidAPIToken},
+		{name: "срок в прошлом", userID: userID, req: model.APITokenRequest{Name: "ci", Scopes: []model.Scope{model.ScopeLinksRead}, ExpiresAt: &past}, wantCode: apierror.CodeInvalidAPIToken},
+	}
+	for _, tt := range tests {
+		_, err := svc.Create(ctx, tt.userID, tt.req)
+		assert.Equal(t, tt.wantCode, apierror.From(err).Code, tt.name)
+	}
+
+	token, err := svc.Create(ctx, userID, model.APITokenRequest{Name: " ci ", Scopes: []model.Scope{model.ScopeLinksRead, model.ScopeLinksWrite, model.ScopeLinksRead}})
+	require.NoError(t, err)
+	assert.Equal(t, "ci", token.Name)
+	assert.Equal(t, []model.Scope{model.ScopeLinksRead, model.ScopeLinksWrite}, token.Scopes)
+	assert.True(t, strings.HasPrefix(token.Token, Prefix))
+	assert.Equal(t, Hash(token.Token), token.Hash)
+
+	// значение токена показывается только при создании
+	tokens, err := svc.Tokens(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, token.ID, tokens[0].ID)
+	assert.Empty(t, tokens[0].Token)
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	svc := New(store, nil)
+
+	userID := uuid.New()
+	require.NoError(t, store.CreateAccount(ctx, model.Account{ID: userID, Login: "alice", CreatedAt: time.Now()}))
+	token, err := svc.Create(ctx, userID, model.APITokenRequest{Name: "ci", Scopes: []model.Scope{model.ScopeLinksRead}})
+	require.NoError(t, err)
+
+	got, err := svc.Authenticate(ctx, token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, got.ID)
+	assert.Equal(t, userID, got.UserID)
+
+	for _, value := range []string{"", "secret", Prefix + "unknown"} {
+		_, err = svc.Authenticate(ctx, value)
+		assert.Equal(t, apierror.CodeUnauthorized, apierror.From(err).Code, value)
+	}
+
+	// просроченный токен
+	expired := Prefix + "expired"
+	past := time.Now().Add(-time.Minute)
+	require.NoError(t, store.SaveAPIToken(ctx, model.APIToken{ID: uuid.New(), UserID: userID, Name: "old", Scopes: []model.Scope{model.ScopeLinksRead}, Hash: Hash(expired), ExpiresAt: &past}))
+	_, err = svc.Authenticate(ctx, expired)
+	assert.Equal(t, apierror.CodeUnauthorized, apierror.From(err).Code)
+
+	// отозванный токен
+	require.ErrorIs(t, svc.Revoke(ctx, uuid.New(), token.ID), storage.ErrAPITokenNotFound)
+	require.NoError(t, svc.Revoke(ctx, userID, token.ID))
+	_, err = svc.Authenticate(ctx, token.Token)
+	assert.Equal(t, apierror.CodeUnauthorized, apierror.From(err).Code)
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	// запросы без токена не ограничиваются
+	assert.NoError(t, Check(ctx, model.ScopeStatsRead))
+	assert.NoError(t, Deny(ctx))
+
+	ctx = WithToken(ctx, model.APIToken{Scopes: []model.Scope{model.ScopeLinksRead}})
+	assert.NoError(t, Check(ctx, model.ScopeLinksRead))
+	assert.Equal(t, apierror.CodeInsufficientScope, apierror.From(Check(ctx, model.ScopeLinksDelete)).Code)
+	assert.Equal(t, apierror.CodeInsufficientScope, apierror.From(Deny(ctx)).Code)
+}
+
+func TestBearer(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{header: "Bearer shk_abc", want: "shk_abc", ok: true},
+		{header: "bearer  shk_abc ", want: "shk_abc", ok: true},
+		{header: "Basic dXNlcjpwYXNz"},
+		{header: "Bearer "},
+		{header: ""},
+	}
+	for _, tt := range tests {
+		got, ok := Bearer(tt.header)
+		assert.Equal(t, tt.ok, ok, tt.header)
+		assert.Equal(t, tt.want, got, tt.header)
+	}
+}
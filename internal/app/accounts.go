@@ -0,0 +1,132 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/account"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// WithAccounts устанавливает учетные записи пользователей
+func WithAccounts(svc *account.Service) Option {
+	return func(s *Server) {
+		s.accounts = svc
+	}
+}
+
+// register регистрирует учетную запись. ссылки, уже созданные текущим пользователем, остаются за ним
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	creds, ok := s.credentials(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := r.Context().Value(contextKey("userID")).(uuid.UUID)
+	account, err := s.accounts.Register(r.Context(), userID, creds)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if !s.setAuthCookie(w, r, account.ID) {
+		return
+	}
+	s.writeJSON(w, r, http.StatusCreated, account)
+}
+
+// login вход в учетную запись. ссылки, созданные в текущей анонимной сессии, переносятся в учетную запись
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	creds, ok := s.credentials(w, r)
+	if !ok {
+		return
+	}
+	userID, _ := r.Context().Value(contextKey("userID")).(uuid.UUID)
+	resp, err := s.accounts.Login(r.Context(), userID, creds)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if !s.setAuthCookie(w, r, resp.ID) {
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// logout выход из учетной записи: cookie удаляется, следующий запрос получит нового анонимного пользователя
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	dropAuthCookie(w)
+	http.SetCookie(w, &http.Cookie{Name: authCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// claim переносит в учетную запись ссылки анонимного пользователя по его токену (значению cookie).
+// токен должен быть действующим: старая или утекшая cookie не позволяет забрать ссылки пользователя
+func (s *Server) claim(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
+		return
+	}
+	if s.accounts == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return
+	}
+	req := model.ClaimRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
+		return
+	}
+	_, claims, err := parseToken(req.Token, s.keys)
+	if err == nil && claims.ExpiresAt == nil {
+		err = fmt.Errorf("у токена нет срока действия")
+	}
+	if err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails("token"))
+		return
+	}
+	resp, err := s.accounts.Claim(r.Context(), userID, claims.UserID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// credentials проверяет, что учетные записи доступны, и читает логин и пароль из тела запроса
+func (s *Server) credentials(w http.ResponseWriter, r *http.Request) (model.Credentials, bool) {
+	if s.accounts == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return model.Credentials{}, false
+	}
+	creds := model.Credentials{}
+	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
+		return model.Credentials{}, false
+	}
+	return creds, true
+}
+
+// setAuthCookie выставляет cookie с токеном пользователя userID вместо выставленного withToken
+func (s *Server) setAuthCookie(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	cookie, err := s.newAuthCookie(userID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return false
+	}
+	dropAuthCookie(w)
+	http.SetCookie(w, cookie)
+	return true
+}
+
+// dropAuthCookie убирает из ответа cookie с токеном, выставленную ранее, чтобы клиент не получил две разные cookie
+func dropAuthCookie(w http.ResponseWriter) {
+	cookies := w.Header().Values("Set-Cookie")
+	w.Header().Del("Set-Cookie")
+	for _, c := range cookies {
+		if !strings.HasPrefix(c, authCookie+"=") {
+			w.Header().Add("Set-Cookie", c)
+		}
+	}
+}
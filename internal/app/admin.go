@@ -0,0 +1,164 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kTowkA/shortener/internal/admin"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+const (
+	// defaultAuditLimit количество записей журнала действий администраторов по умолчанию, maxAuditLimit - наибольшее
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// WithAdmin устанавливает операции администраторов, доступные из доверенной подсети
+func WithAdmin(svc *admin.Service) Option {
+	return func(s *Server) {
+		s.admin = svc
+	}
+}
+
+// adminActor проверяет, что операции администраторов доступны, и возвращает автора действия для журнала
+func (s *Server) adminActor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if s.admin == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return "", false
+	}
+	// адрес уже проверен trustedSubnet
+	return "http " + clientIP(r), true
+}
+
+// adminLink ссылка вместе с владельцем в любом состоянии
+func (s *Server) adminLink(w http.ResponseWriter, r *http.Request) {
+	actor, ok := s.adminActor(w, r)
+	if !ok {
+		return
+	}
+	link, err := s.admin.Link(r.Context(), actor, chi.URLParam(r, "short"))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, link)
+}
+
+// adminDisableLink отключает ссылку независимо от владельца
+func (s *Server) adminDisableLink(w http.ResponseWriter, r *http.Request) {
+	s.adminSetLinkDisabled(w, r, true)
+}
+
+// adminEnableLink включает ранее отключенную ссылку
+func (s *Server) adminEnableLink(w http.ResponseWriter, r *http.Request) {
+	s.adminSetLinkDisabled(w, r, false)
+}
+
+func (s *Server) adminSetLinkDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	actor, ok := s.adminActor(w, r)
+	if !ok {
+		return
+	}
+	link, err := s.admin.SetLinkDisabled(r.Context(), actor, chi.URLParam(r, "short"), disabled)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, link)
+}
+
+// adminUserLinks все ссылки пользователя, включая удаленные и заблокированные
+func (s *Server) adminUserLinks(w http.ResponseWriter, r *http.Request) {
+	actor, ok := s.adminActor(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := s.uuidParam(w, r, "id", apierror.CodeBadRequest)
+	if !ok {
+		return
+	}
+	links, err := s.admin.UserLinks(r.Context(), actor, userID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, links)
+}
+
+// adminBlockUser запрещает пользователю создавать ссылки. тело запроса (model.UserBlockRequest) необязательно
+func (s *Server) adminBlockUser(w http.ResponseWriter, r *http.Request) {
+	s.adminSetUserBlocked(w, r, true)
+}
+
+// adminUnblockUser снимает запрет на создание ссылок
+func (s *Server) adminUnblockUser(w http.ResponseWriter, r *http.Request) {
+	s.adminSetUserBlocked(w, r, false)
+}
+
+func (s *Server) adminSetUserBlocked(w http.ResponseWriter, r *http.Request, blocked bool) {
+	actor, ok := s.adminActor(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := s.uuidParam(w, r, "id", apierror.CodeBadRequest)
+	if !ok {
+		return
+	}
+	req := model.UserBlockRequest{}
+	if blocked && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
+			return
+		}
+	}
+	if err := s.admin.SetUserBlocked(r.Context(), actor, userID, blocked, req.Reason); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// adminPurge окончательно удаляет ссылки из тела запроса (JSON-массив коротких ключей) независимо от владельца
+func (s *Server) adminPurge(w http.ResponseWriter, r *http.Request) {
+	actor, ok := s.adminActor(w, r)
+	if !ok {
+		return
+	}
+	var shorts []string
+	if err := json.NewDecoder(r.Body).Decode(&shorts); err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
+		return
+	}
+	resp, err := s.admin.Purge(r.Context(), actor, shorts)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// adminAudit журнал действий администраторов, начиная с последних. limit - количество записей
+func (s *Server) adminAudit(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.adminActor(w, r); !ok {
+		return
+	}
+	limit := defaultAuditLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			s.writeError(w, r, apierror.New(apierror.CodeBadRequest).WithDetails("limit"))
+			return
+		}
+		limit = min(n, maxAuditLimit)
+	}
+	records, err := s.admin.AuditLog(r.Context(), limit)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, records)
+}
@@ -5,7 +5,9 @@ package app
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 
@@ -13,9 +15,25 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/kTowkA/shortener/internal/account"
+	"github.com/kTowkA/shortener/internal/admin"
+	"github.com/kTowkA/shortener/internal/apitoken"
+	"github.com/kTowkA/shortener/internal/bulk"
+	"github.com/kTowkA/shortener/internal/clientip"
 	"github.com/kTowkA/shortener/internal/config"
+	"github.com/kTowkA/shortener/internal/deletion"
+	"github.com/kTowkA/shortener/internal/events"
+	"github.com/kTowkA/shortener/internal/health"
+	"github.com/kTowkA/shortener/internal/idempotency"
+	"github.com/kTowkA/shortener/internal/jobs"
+	"github.com/kTowkA/shortener/internal/jwtkeys"
+	"github.com/kTowkA/shortener/internal/linkcheck"
 	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/oidc"
+	"github.com/kTowkA/shortener/internal/ratelimit"
 	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/threat"
+	"github.com/kTowkA/shortener/internal/webhook"
 	"golang.org/x/crypto/acme/autocert"
 	"golang.org/x/sync/errgroup"
 )
@@ -32,23 +50,66 @@ const (
 // Server структура сервер служит для создания экземпляра запускаемого сервера.
 // содержит только неэкспортируемые поля, включающие в себя хранилище данных, логгер и собственно сам веб-сервер
 type Server struct {
-	db            storage.Storager
-	Config        config.Config
-	deleteMessage chan model.DeleteURLMessage
-	logger        *slog.Logger
-	server        *http.Server
+	db          storage.Storager
+	Config      config.Config
+	logger      *slog.Logger
+	server      *http.Server
+	threats     *threat.List
+	jobs        *jobs.Manager
+	idempotency *idempotency.Guard
+	limiter     *ratelimit.Limiter
+	deletes     *deletion.Queue
+	webhooks    *webhook.Dispatcher
+	events      *events.Bus
+	admin       *admin.Service
+	accounts    *account.Service
+	tokens      *apitoken.Service
+	// keys ключи подписи токенов пользователей
+	keys *jwtkeys.Set
+	// oidc провайдер входа (SSO)
+	oidc   *oidc.Provider
+	health *health.Checker
+	// clientIP определяет адрес клиента с учетом доверенных прокси
+	clientIP *clientip.Resolver
+	// shutdown закрывается при остановке сервера и завершает потоки событий
+	shutdown chan struct{}
+}
+
+// Option дополнительная настройка сервера
+type Option func(*Server)
+
+// WithThreatList устанавливает список угроз, по которому проверяются сокращаемые и сохраненные ссылки
+func WithThreatList(list *threat.List) Option {
+	return func(s *Server) {
+		s.threats = list
+	}
 }
 
 // NewServer создает новый экземпляр сервера с конфигурацией cfg и логером logger.
 // Возвращает сервер и ошибку
-func NewServer(cfg config.Config, logger *slog.Logger) (*Server, error) {
+func NewServer(cfg config.Config, logger *slog.Logger, opts ...Option) (*Server, error) {
 	s := &Server{
 		Config: cfg,
 		logger: logger,
 		server: &http.Server{
 			Addr: cfg.Address(),
 		},
-		deleteMessage: make(chan model.DeleteURLMessage, 100),
+		shutdown: make(chan struct{}),
+		clientIP: clientip.NewResolver(cfg.TrustedProxies()),
+	}
+	s.server.RegisterOnShutdown(func() { close(s.shutdown) })
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.keys == nil {
+		keys, err := jwtkeys.Load("", cfg.SecretKey())
+		if err != nil {
+			return nil, fmt.Errorf("ключи подписи токенов. %w", err)
+		}
+		s.keys = keys
+	}
+	if s.health == nil {
+		s.health = s.defaultHealth()
 	}
 
 	// включен HTTPS
@@ -67,6 +128,32 @@ func NewServer(cfg config.Config, logger *slog.Logger) (*Server, error) {
 // Run запуск сервера с указанием контекста для отмены ctx и хранилища storage
 func (s *Server) Run(ctx context.Context, storage storage.Storager) error {
 	s.db = storage
+	s.jobs = jobs.New(bulk.Shortener{
+		Store:       s.db,
+		Threats:     s.threats,
+		BaseAddress: s.Config.BaseAddress(),
+	}, jobs.Options{
+		Workers:   s.Config.JobWorkers(),
+		Retention: s.Config.JobRetention(),
+		Logger:    s.logger,
+	})
+	s.idempotency = idempotency.New(s.db, s.Config.IdempotencyWindow(), s.logger)
+	if s.deletes == nil {
+		s.deletes = deletion.New(s.db, deletion.Options{
+			FlushInterval: s.Config.DeleteFlushInterval(),
+			BatchSize:     s.Config.DeleteBatchSize(),
+			Capacity:      s.Config.DeleteQueueCapacity(),
+			Retention:     s.Config.DeleteRetention(),
+			Logger:        s.logger,
+		})
+		// ссылки, оставшиеся в очереди с предыдущего запуска, учитываются до приема новых запросов
+		if err := s.deletes.Restore(ctx); err != nil {
+			s.logger.Error("получение ожидающих задач удаления", slog.String("ошибка", err.Error()))
+		}
+	}
+	s.health.Add(health.CheckDeletionQueue, s.deletes.Check)
+	// очередь удаления останавливается после сервера, чтобы удалить ссылки всех принятых запросов
+	deletesCtx, stopDeletes := context.WithCancel(context.Background())
 
 	s.setRoute()
 
@@ -80,11 +167,13 @@ func (s *Server) Run(ctx context.Context, storage storage.Storager) error {
 
 		s.logger.Info("запуск приложения", slog.String("адрес", s.Config.Address()))
 
-		var err error
-		if s.Config.HTTPS() {
-			err = s.server.ListenAndServeTLS("", "")
-		} else {
-			err = s.server.ListenAndServe()
+		l, err := s.listen()
+		switch {
+		case err != nil:
+		case s.Config.HTTPS():
+			err = s.server.ServeTLS(l, "", "")
+		default:
+			err = s.server.Serve(l)
 		}
 
 		if err != nil {
@@ -98,8 +187,7 @@ func (s *Server) Run(ctx context.Context, storage storage.Storager) error {
 
 	// ожидание завершения работы приложения
 	gr.Go(func() error {
-		// закроем канал с сообщениями на удаление
-		defer close(s.deleteMessage)
+		defer stopDeletes()
 
 		// ожидаем отмены
 		<-grCtx.Done()
@@ -116,7 +204,29 @@ func (s *Server) Run(ctx context.Context, storage storage.Storager) error {
 		return nil
 	})
 
-	go s.flushDeleteMessages()
+	gr.Go(func() error {
+		s.deletes.Run(deletesCtx)
+		return nil
+	})
+
+	gr.Go(func() error {
+		s.jobs.Run(grCtx)
+		return nil
+	})
+
+	if s.threats != nil {
+		gr.Go(func() error {
+			s.scanThreats(grCtx)
+			return nil
+		})
+	}
+
+	if s.Config.LinkCheckInterval() > 0 {
+		gr.Go(func() error {
+			s.checkLinks(grCtx)
+			return nil
+		})
+	}
 
 	return gr.Wait()
 }
@@ -124,54 +234,207 @@ func (s *Server) Run(ctx context.Context, storage storage.Storager) error {
 func (s *Server) setRoute() {
 	mux := chi.NewRouter()
 
-	mux.Use(s.withLog, withGZIP, s.withToken)
+	mux.Use(s.withClientIP, s.withLog, s.withGZIP, s.withToken)
 
 	mux.Route("/", func(r chi.Router) {
-		r.Post("/", s.encodeURL)
-		r.Get("/{short}", s.decodeURL)
+		r.With(s.requireScope(model.ScopeLinksWrite), s.rateLimit(ratelimit.Create), s.idempotent).Post("/", s.encodeURL)
+		r.Group(func(r chi.Router) {
+			r.Use(s.rateLimit(ratelimit.Redirect))
+			r.Get("/{short}", s.decodeURL)
+			r.Head("/{short}", s.decodeURL)
+			r.Get("/{short}/*", s.decodeURL)
+			r.Head("/{short}/*", s.decodeURL)
+		})
 		r.Route("/api", func(r chi.Router) {
+			r.Get("/openapi.json", s.openAPI)
+			r.Get("/docs", s.docs)
+			// QR код короткой ссылки. адрес вне /{short}/*, иначе он перекрыл бы путь /qr ссылок с передачей пути
+			r.With(s.rateLimit(ratelimit.Redirect)).Get("/qr/{short}", s.shortQR)
+			// ссылки пользователя, доступные по API токенам с соответствующими правами
 			r.Group(func(r chi.Router) {
-				r.Use(middleware.AllowContentType("application/json", "application/x-gzip"))
+				r.Use(s.allowContentType("application/json", "application/x-gzip"))
 				r.Route("/shorten", func(r chi.Router) {
+					r.Use(s.requireScope(model.ScopeLinksWrite), s.rateLimit(ratelimit.Create), s.idempotent)
 					r.Post("/", s.apiShorten)
 					r.Post("/batch", s.batch)
 				})
-				r.Delete("/user/urls", s.deleteUserURLs)
+				r.With(s.requireScope(model.ScopeLinksDelete), s.rateLimit(ratelimit.Delete)).Delete("/user/urls", s.deleteUserURLs)
+				r.With(s.requireScope(model.ScopeLinksWrite)).Patch("/user/urls/{short}", s.updateUserURL)
+			})
+			r.Group(func(r chi.Router) {
+				r.Use(s.requireScope(model.ScopeLinksWrite))
+				r.With(s.rateLimit(ratelimit.Create)).Post("/shorten/stream", s.bulkShorten)
+				r.Get("/jobs/{id}", s.getJob)
+			})
+			r.With(s.requireScope(model.ScopeLinksDelete)).Get("/user/deletions/{id}", s.getDeletion)
+			r.Group(func(r chi.Router) {
+				r.Use(s.requireScope(model.ScopeLinksRead))
+				r.Get("/user/urls", s.getUserURLs)
+				r.Get("/user/urls/broken", s.getBrokenURLs)
+				r.Get("/user/urls/qr", s.getUserQRArchive)
+				r.Get("/user/events", s.userEvents)
+				r.Get("/user/events/ws", s.userEventsWS)
 			})
-			r.Get("/user/urls", s.getUserURLs)
 
-			r.Route("/internal", func(r chi.Router) {
-				r.Use(s.trustedSubnet)
-				r.Get("/stats", s.stats)
+			// учетная запись, подписки и API токены управляются только с cookie
+			r.Group(func(r chi.Router) {
+				r.Use(s.withoutAPIToken)
+				r.Group(func(r chi.Router) {
+					r.Use(s.allowContentType("application/json", "application/x-gzip"))
+					r.Post("/user/webhooks", s.createWebhook)
+					r.Post("/user/register", s.register)
+					r.Post("/user/login", s.login)
+					r.Post("/user/claim", s.claim)
+					r.Post("/user/tokens", s.createToken)
+				})
+				r.Post("/user/logout", s.logout)
+				r.Get("/user/oidc/login", s.oidcLogin)
+				r.Get("/user/oidc/callback", s.oidcCallback)
+				r.Get("/user/webhooks", s.getWebhooks)
+				r.Delete("/user/webhooks/{id}", s.deleteWebhook)
+				r.Get("/user/webhooks/{id}/deliveries", s.getWebhookDeliveries)
+				r.Post("/user/webhooks/{id}/deliveries/{delivery}/redeliver", s.redeliverWebhook)
+				r.Get("/user/tokens", s.getTokens)
+				r.Delete("/user/tokens/{id}", s.revokeToken)
 			})
 
+			r.Route("/internal", func(r chi.Router) {
+				// статистика доступна только из доверенной подсети, по API токену - с правом stats:read
+				r.With(s.statsAccess).Get("/stats", s.stats)
+				r.Group(func(r chi.Router) {
+					r.Use(s.withoutAPIToken, s.trustedSubnet)
+					r.Get("/links/{short}", s.adminLink)
+					r.Post("/links/{short}/disable", s.adminDisableLink)
+					r.Post("/links/{short}/enable", s.adminEnableLink)
+					r.Get("/users/{id}/links", s.adminUserLinks)
+					r.Post("/users/{id}/block", s.adminBlockUser)
+					r.Delete("/users/{id}/block", s.adminUnblockUser)
+					r.Post("/purge", s.adminPurge)
+					r.Get("/audit", s.adminAudit)
+				})
+			})
 		})
+		r.Get("/.well-known/jwks.json", s.jwks)
 		r.Get("/ping", s.ping)
+		r.Get("/healthz", s.healthz)
+		r.Get("/readyz", s.readyz)
 	})
 	mux.Mount("/debug", middleware.Profiler())
 	s.server.Handler = mux
 }
 
-func (s *Server) flushDeleteMessages() {
-	ticker := time.NewTicker(5 * time.Second)
+// listen открывает порт сервера. от доверенных прокси принимаются заголовки PROXY protocol
+func (s *Server) listen() (net.Listener, error) {
+	addr := s.server.Addr
+	if addr == "" {
+		addr = ":http"
+		if s.Config.HTTPS() {
+			addr = ":https"
+		}
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("открытие порта %s. %w", addr, err)
+	}
+	return s.clientIP.Listener(l), nil
+}
 
-	var messages []model.DeleteURLMessage
+// scanThreats периодически перечитывает список угроз и блокирует сохраненные ссылки, попавшие в него
+func (s *Server) scanThreats(ctx context.Context) {
+	ticker := time.NewTicker(s.Config.ThreatCheckInterval())
+	defer ticker.Stop()
 
+	s.blockThreats(ctx)
 	for {
 		select {
-		case msg := <-s.deleteMessage:
-			messages = append(messages, msg)
+		case <-ctx.Done():
+			return
 		case <-ticker.C:
-			if len(messages) == 0 {
-				continue
+			if _, err := s.threats.Reload(); err != nil {
+				s.logger.Error("обновление списка угроз", slog.String("ошибка", err.Error()))
 			}
-			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			err := s.db.DeleteURLs(ctx, messages)
-			cancel()
-			if err != nil {
-				s.logger.Error("удаление сообщений", slog.String("ошибка", err.Error()))
-			}
-			messages = nil
+			s.blockThreats(ctx)
+		}
+	}
+}
+
+// blockThreats блокирует все сохраненные ссылки, которые находятся в списке угроз
+func (s *Server) blockThreats(ctx context.Context) {
+	links, err := s.db.AllURLs(ctx)
+	if err != nil {
+		s.logger.Error("получение ссылок для проверки по списку угроз", slog.String("ошибка", err.Error()))
+		return
+	}
+	blocked := make([]string, 0)
+	for _, l := range links {
+		if l.IsBlocked || l.IsDeleted {
+			continue
+		}
+		if s.threats.Match(l.OriginalURL) {
+			blocked = append(blocked, l.ShortURL)
+		}
+	}
+	if len(blocked) == 0 {
+		return
+	}
+	if err = s.db.BlockURLs(ctx, blocked, true); err != nil {
+		s.logger.Error("блокировка ссылок из списка угроз", slog.String("ошибка", err.Error()))
+		return
+	}
+	s.logger.Info("заблокированы ссылки из списка угроз", slog.Int("количество", len(blocked)))
+}
+
+// checkLinks периодически проверяет доступность оригинальных ссылок и сохраняет результаты проверки
+func (s *Server) checkLinks(ctx context.Context) {
+	checker := linkcheck.New(linkcheck.Options{
+		Concurrency: s.Config.LinkCheckConcurrency(),
+		HostDelay:   s.Config.LinkCheckHostDelay(),
+		Interval:    s.Config.LinkCheckInterval(),
+	})
+	ticker := time.NewTicker(s.Config.LinkCheckInterval())
+	defer ticker.Stop()
+
+	for {
+		s.checkLinksOnce(ctx, checker)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkLinksOnce выполняет одну проверку доступности всех активных ссылок
+func (s *Server) checkLinksOnce(ctx context.Context, checker *linkcheck.Checker) {
+	links, err := s.db.AllURLs(ctx)
+	if err != nil {
+		s.logger.Error("получение ссылок для проверки доступности", slog.String("ошибка", err.Error()))
+		return
+	}
+	active := make([]model.StorageJSONWithUserID, 0, len(links))
+	for _, l := range links {
+		if l.IsDeleted || l.IsBlocked {
+			continue
+		}
+		active = append(active, l)
+	}
+
+	results := checker.Check(ctx, active)
+	if len(results) == 0 {
+		return
+	}
+	// сохраняем даже если контекст уже отменен, чтобы не потерять выполненные проверки
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	if err = s.db.SaveLinkChecks(saveCtx, results); err != nil {
+		s.logger.Error("сохранение результатов проверки доступности", slog.String("ошибка", err.Error()))
+		return
+	}
+	broken := 0
+	for _, r := range results {
+		if r.Broken() {
+			broken++
 		}
 	}
+	s.logger.Info("проверка доступности ссылок", slog.Int("проверено", len(results)), slog.Int("недоступно", broken))
 }
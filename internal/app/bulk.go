@@ -0,0 +1,91 @@
+package app
+
+import (
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/bulk"
+)
+
+// bulkShorten потоковое массовое сокращение ссылок из загрузки NDJSON или CSV произвольного размера.
+// формат результата задается параметром format или заголовком Accept (по умолчанию - формат загрузки),
+// с параметром download=true результат отдается как файл
+func (s *Server) bulkShorten(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	in, ok := bulk.FormatFromMediaType(mediaType)
+	if !ok {
+		s.writeError(w, r, apierror.New(apierror.CodeUnsupportedMedia).WithDetails(bulk.MediaTypes()))
+		return
+	}
+	out, ok := bulkResultFormat(r, in)
+	if !ok {
+		s.writeError(w, r, apierror.New(apierror.CodeBadRequest).WithDetails("format"))
+		return
+	}
+	userID, ok := r.Context().Value(contextKey("userID")).(uuid.UUID)
+	if !ok {
+		userID = uuid.New()
+	}
+
+	// результат выводится до окончания чтения загрузки
+	rc := http.NewResponseController(w)
+	_ = rc.EnableFullDuplex()
+	w.Header().Set("Content-Type", out.ContentType())
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if download, _ := strconv.ParseBool(r.URL.Query().Get("download")); download {
+		w.Header().Set("Content-Disposition", `attachment; filename="shorten-result.`+string(out)+`"`)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	shortener := bulk.Shortener{
+		Store:       s.db,
+		Threats:     s.threats,
+		BaseAddress: s.Config.BaseAddress(),
+	}
+	summary, err := shortener.Run(r.Context(), userID, bulk.NewReader(in, r.Body), bulk.NewWriter(out, flushWriter{w: w, rc: rc}))
+	if err != nil {
+		// статус уже отправлен, клиент узнает о прерывании по отсутствию результатов для оставшихся строк
+		s.logger.Error("потоковое сокращение ссылок", slog.Int("обработано строк", summary.Total), slog.String("ошибка", err.Error()))
+		return
+	}
+	s.logger.Info("потоковое сокращение ссылок",
+		slog.Int("строк", summary.Total),
+		slog.Int("создано", summary.Created),
+		slog.Int("существующих", summary.Existing),
+		slog.Int("невалидных", summary.Invalid),
+		slog.Int("ошибок", summary.Failed),
+	)
+}
+
+// bulkResultFormat определяет формат результата по параметру format или заголовку Accept
+func bulkResultFormat(r *http.Request, in bulk.Format) (bulk.Format, bool) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		return bulk.ParseFormat(name)
+	}
+	for _, mediaType := range acceptedMediaTypes(r) {
+		if f, ok := bulk.FormatFromMediaType(mediaType); ok {
+			return f, true
+		}
+	}
+	return in, true
+}
+
+// flushWriter передает результат клиенту при каждом Flush
+type flushWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// Write реализация io.Writer
+func (fw flushWriter) Write(p []byte) (int, error) {
+	return fw.w.Write(p)
+}
+
+// Flush отправляет клиенту накопленные данные
+func (fw flushWriter) Flush() error {
+	return fw.rc.Flush()
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/deletion"
+	"github.com/kTowkA/shortener/internal/ratelimit"
+)
+
+// WithDeletionQueue устанавливает очередь удаления ссылок, общую с gRPC сервером. очередь должна работать
+// с хранилищем, переданным в Run, и быть восстановлена (Restore) до запуска серверов; запускает ее сервер.
+// без настройки очередь создается и восстанавливается в Run по конфигурации
+func WithDeletionQueue(q *deletion.Queue) Option {
+	return func(s *Server) {
+		s.deletes = q
+	}
+}
+
+// enqueueDelete ставит удаление ссылок shorts пользователя userID в очередь удаления.
+// в ответ отдается 202 с задачей и адресом, по которому можно узнать результаты по каждой ссылке.
+// если очередь переполнена - 503 с заголовком Retry-After
+func (s *Server) enqueueDelete(w http.ResponseWriter, r *http.Request, userID uuid.UUID, shorts []string) {
+	if s.deletes == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return
+	}
+	task, err := s.deletes.Enqueue(r.Context(), userID, shorts)
+	if err != nil {
+		if apierror.CodeOf(err) == apierror.CodeDeleteQueueFull {
+			w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(s.deletes.FlushInterval())))
+		}
+		s.writeError(w, r, err)
+		return
+	}
+	result, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/user/deletions/"+task.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write(result)
+}
+
+// getDeletion задача удаления ссылок пользователя с результатами по каждой ссылке
+func (s *Server) getDeletion(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
+		return
+	}
+	if s.deletes == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeDeletionNotFound, err))
+		return
+	}
+	task, err := s.deletes.Task(r.Context(), userID, id)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	result, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(result)
+}
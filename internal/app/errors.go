@@ -0,0 +1,95 @@
+package app
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/i18n"
+)
+
+// headerErrorCode заголовок с кодом ошибки, выставляется для ответов в любом формате
+const headerErrorCode = "X-Error-Code"
+
+// writeError выводит ошибку err. Если клиент принимает JSON (заголовок Accept) - в виде {code, message, details},
+// иначе текстом. Сообщение выводится на языке клиента. Внутренние ошибки логируются, их причина клиенту не передается
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e := apierror.From(err)
+	status := e.Code.HTTPStatus()
+	if status >= http.StatusInternalServerError {
+		s.logger.Error("обработка запроса", slog.String("uri", r.RequestURI), slog.String("код", string(e.Code)), slog.String("ошибка", e.Error()))
+	}
+
+	if e.Code == apierror.CodeQuotaExceeded {
+		setQuotaRetryAfter(w)
+	}
+
+	lang := language(w, r)
+	e = e.Localize(lang)
+	w.Header().Set(headerErrorCode, string(e.Code))
+	if !acceptsJSON(r) {
+		http.Error(w, e.Message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// language выбирает язык ответа по заголовку Accept-Language и отмечает это в заголовках ответа
+func language(w http.ResponseWriter, r *http.Request) i18n.Lang {
+	lang := i18n.Parse(r.Header.Get("Accept-Language"))
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", string(lang))
+	return lang
+}
+
+// acceptsJSON проверяет, что клиент явно принимает JSON ответ
+func acceptsJSON(r *http.Request) bool {
+	for _, mediaType := range acceptedMediaTypes(r) {
+		if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedMediaTypes возвращает типы контента из заголовков Accept в порядке перечисления
+func acceptedMediaTypes(r *http.Request) []string {
+	mediaTypes := make([]string, 0)
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+	return mediaTypes
+}
+
+// allowContentType пропускает только запросы с типом контента из contentTypes, остальным отвечает ошибкой
+func (s *Server) allowContentType(contentTypes ...string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength == 0 {
+				// пустое тело проверяется обработчиком
+				h.ServeHTTP(w, r)
+				return
+			}
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			for _, ct := range contentTypes {
+				if strings.EqualFold(mediaType, ct) {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+			s.writeError(w, r, apierror.New(apierror.CodeUnsupportedMedia).WithDetails(contentTypes))
+		})
+	}
+}
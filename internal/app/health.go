@@ -0,0 +1,42 @@
+package app
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kTowkA/shortener/internal/health"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// WithHealth устанавливает проверки готовности сервиса. сервер добавляет к ним проверку очереди удаления.
+// если не задано - готовность определяется доступностью хранилища и очереди удаления
+func WithHealth(c *health.Checker) Option {
+	return func(s *Server) {
+		s.health = c
+	}
+}
+
+// defaultHealth проверки готовности по умолчанию: доступность хранилища
+func (s *Server) defaultHealth() *health.Checker {
+	c := health.New(health.Options{})
+	c.Add(health.CheckStorage, func(ctx context.Context) error {
+		return s.db.Ping(ctx)
+	})
+	return c
+}
+
+// healthz проверка живости: процесс отвечает на запросы. зависимости не проверяются,
+// чтобы недоступность БД не приводила к перезапуску сервиса
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	s.writeJSON(w, r, http.StatusOK, health.Live())
+}
+
+// readyz проверка готовности: 200, если пройдены все проверки, иначе 503. в ответе результат каждой проверки
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	resp := s.health.Ready(r.Context())
+	status := http.StatusOK
+	if resp.Status != model.HealthOK {
+		status = http.StatusServiceUnavailable
+	}
+	s.writeJSON(w, r, status, resp)
+}
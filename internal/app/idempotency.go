@@ -0,0 +1,101 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/idempotency"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// replayedHeaders заголовки ответа, которые сохраняются и повторяются вместе с телом
+var replayedHeaders = []string{"Content-Type", "Content-Language", "Vary", "Location", "X-Error-Code"}
+
+// idempotent повторяет первый ответ на запрос с заголовком Idempotency-Key вместо повторного выполнения.
+// ответы с ошибкой сервера и отказы из-за ограничений не сохраняются, такой запрос можно повторить с тем же ключом.
+// ключи хранятся отдельно для каждого пользователя, поэтому ключ принимается только с cookie пользователя или API токеном:
+// повтор запроса без них выполнялся бы от имени нового пользователя и не нашел бы первый ответ
+func (s *Server) idempotent(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotency.Header)
+		if key == "" || s.idempotency == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if !idempotency.ValidKey(key) {
+			s.writeError(w, r, apierror.New(apierror.CodeBadIdemKey))
+			return
+		}
+		userID, ok := r.Context().Value(contextKey("userID")).(uuid.UUID)
+		if newUser, _ := r.Context().Value(contextKey("newUser")).(bool); !ok || newUser {
+			s.writeError(w, r, apierror.New(apierror.CodeIdemKeyNoUser))
+			return
+		}
+
+		body := []byte{}
+		if r.Body != nil {
+			var err error
+			if body, err = io.ReadAll(r.Body); err != nil {
+				s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		fingerprint := idempotency.Fingerprint([]byte(r.Method), []byte(r.URL.Path), []byte(r.URL.RawQuery), body)
+
+		resp, replayed, err := s.idempotency.Do(r.Context(), userID, key, fingerprint, func() (model.IdempotentResponse, bool) {
+			rec := &responseRecorder{header: http.Header{}, status: http.StatusOK}
+			h.ServeHTTP(rec, r)
+			header := make(map[string]string)
+			for _, name := range replayedHeaders {
+				if v := rec.header.Get(name); v != "" {
+					header[name] = v
+				}
+			}
+			return model.IdempotentResponse{Status: rec.status, Header: header, Body: rec.body.Bytes()}, rec.status < http.StatusInternalServerError && rec.status != http.StatusTooManyRequests
+		})
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		for name, v := range resp.Header {
+			w.Header().Set(name, v)
+		}
+		if replayed {
+			w.Header().Set(idempotency.ReplayedHeader, "true")
+		}
+		w.WriteHeader(resp.Status)
+		_, _ = w.Write(resp.Body)
+	})
+}
+
+// responseRecorder http.ResponseWriter, сохраняющий ответ в памяти
+type responseRecorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+// Header реализация http.ResponseWriter
+func (rr *responseRecorder) Header() http.Header {
+	return rr.header
+}
+
+// Write реализация http.ResponseWriter
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	return rr.body.Write(b)
+}
+
+// WriteHeader реализация http.ResponseWriter
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.wroteHeader {
+		return
+	}
+	rr.wroteHeader = true
+	rr.status = status
+}
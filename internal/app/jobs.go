@@ -0,0 +1,69 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// submitJob ставит массовый запрос req пользователя userID в очередь фоновых заданий.
+// в ответ отдается 202 с заданием и адресом, по которому можно узнать ход обработки
+func (s *Server) submitJob(w http.ResponseWriter, r *http.Request, userID uuid.UUID, req model.BatchRequest) {
+	if s.jobs == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return
+	}
+	job, err := s.jobs.Submit(r.Context(), userID, req)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	result, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/jobs/"+job.ID.String())
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write(result)
+}
+
+// getJob ход обработки фонового задания пользователя. результаты отдаются только после завершения обработки
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeJobNotFound, err))
+		return
+	}
+	job, err := s.db.Job(r.Context(), id)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	// о чужих заданиях не сообщаем, что они существуют
+	if job.UserID != userID {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeJobNotFound, storage.ErrJobNotFound))
+		return
+	}
+	if job.Status != model.JobDone {
+		job.Results = nil
+	}
+	result, err := json.MarshalIndent(job, "", "  ")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(result)
+}
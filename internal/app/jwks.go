@@ -0,0 +1,26 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/kTowkA/shortener/internal/jwtkeys"
+)
+
+// jwksMaxAge время кэширования набора открытых ключей клиентами. после добавления ключа в набор
+// им можно начинать подписывать токены не раньше, чем через это время
+const jwksMaxAge = 5 * time.Minute
+
+// WithJWTKeys устанавливает ключи подписи токенов пользователей. без настройки токены подписываются SECRET_KEY
+func WithJWTKeys(keys *jwtkeys.Set) Option {
+	return func(s *Server) {
+		s.keys = keys
+	}
+}
+
+// jwks открытые ключи проверки токенов пользователей (RS256 и EdDSA)
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(jwksMaxAge.Seconds())))
+	s.writeJSON(w, r, http.StatusOK, s.keys.JWKS())
+}
@@ -1,9 +1,23 @@
 package app
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/config"
+	"github.com/kTowkA/shortener/internal/jwtkeys"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -11,18 +25,143 @@ const (
 	testSecret = "secret_for_test"
 )
 
+// testKeys набор из одного ключа SECRET_KEY, как у сервера без файла ключей
+func testKeys(t require.TestingT, secret string) *jwtkeys.Set {
+	keys, err := jwtkeys.Load("", secret)
+	require.NoError(t, err)
+	return keys
+}
+
 func TestBuildToken(t *testing.T) {
-	_, err := buildJWTString(uuid.New(), testSecret)
+	_, err := buildJWTString(uuid.New(), testKeys(t, testSecret), time.Hour)
 	require.NoError(t, err)
 }
 
 func TestGetToken(t *testing.T) {
 	userID := uuid.New()
-	token, err := buildJWTString(userID, testSecret)
+	token, err := buildJWTString(userID, testKeys(t, testSecret), time.Hour)
 	require.NoError(t, err)
-	userID1, err := getUserIDFromToken(token, testSecret)
+	userID1, err := getUserIDFromToken(token, testKeys(t, testSecret))
 	require.NoError(t, err)
 	require.Equal(t, userID, userID1)
-	_, err = getUserIDFromToken(token, "asdasdasfafssdf")
+	_, err = getUserIDFromToken(token, testKeys(t, "asdasdasfafssdf"))
+	require.Error(t, err)
+
+	// истекший токен не принимается
+	token, err = buildJWTString(userID, testKeys(t, testSecret), -time.Minute)
+	require.NoError(t, err)
+	_, err = getUserIDFromToken(token, testKeys(t, testSecret))
 	require.Error(t, err)
 }
+
+func TestRefreshToken(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cfg := config.DefaultConfig
+	// после смены ключа SECRET_KEY только проверяет токены, выданные ранее
+	keys, err := jwtkeys.New(jwtkeys.Ed25519("2024-06", private), jwtkeys.HMAC(jwtkeys.LegacyID, []byte(cfg.SecretKey())))
+	require.NoError(t, err)
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	srv, err := NewServer(cfg, slog.Default(), WithJWTKeys(keys))
+	require.NoError(t, err)
+	srv.db = store
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	userID := uuid.New()
+	request := func(token string, status int) *http.Cookie {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/api/user/urls", nil)
+		require.NoError(t, err)
+		req.AddCookie(&http.Cookie{Name: authCookie, Value: token})
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode)
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == authCookie {
+				return cookie
+			}
+		}
+		return nil
+	}
+
+	// токен старым ключом принимается и заменяется токеном активного ключа того же пользователя
+	old, err := buildJWTString(userID, testKeys(t, cfg.SecretKey()), cfg.JWTLifetime())
+	require.NoError(t, err)
+	cookie := request(old, http.StatusNoContent)
+	require.NotNil(t, cookie)
+	assert.Equal(t, int(cfg.JWTLifetime().Seconds()), cookie.MaxAge)
+	token, claims, err := parseToken(cookie.Value, keys)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, "2024-06", token.Header["kid"])
+	assert.Equal(t, jwtkeys.AlgEdDSA, token.Method.Alg())
+
+	// свежий токен не продлевается
+	assert.Nil(t, request(cookie.Value, http.StatusNoContent))
+
+	// токен, истекающий в окне продления, продлевается
+	soon, err := buildJWTString(userID, keys, cfg.JWTRefreshWindow()-time.Minute)
+	require.NoError(t, err)
+	cookie = request(soon, http.StatusNoContent)
+	require.NotNil(t, cookie)
+	_, claims, err = parseToken(cookie.Value, keys)
+	require.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.True(t, claims.ExpiresAt.After(time.Now().Add(cfg.JWTRefreshWindow())))
+	assert.True(t, cookie.HttpOnly, "продленная cookie недоступна скриптам")
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+
+	// токен неизвестного ключа не принимается - выдается cookie нового пользователя
+	_, otherPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherKeys, err := jwtkeys.New(jwtkeys.Ed25519("2024-06", otherPrivate))
+	require.NoError(t, err)
+	forged, err := buildJWTString(userID, otherKeys, cfg.JWTLifetime())
+	require.NoError(t, err)
+	cookie = request(forged, http.StatusUnauthorized)
+	require.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+	_, claims, err = parseToken(cookie.Value, keys)
+	require.NoError(t, err)
+	assert.NotEqual(t, userID, claims.UserID)
+
+	// токен без срока действия заменяется токеном со сроком
+	unlimited, err := keys.Sign(Claims{UserID: userID})
+	require.NoError(t, err)
+	assert.NotNil(t, request(unlimited, http.StatusNoContent))
+}
+
+func TestJWKS(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keys, err := jwtkeys.New(jwtkeys.Ed25519("2024-06", private), jwtkeys.HMAC("2023", []byte(testSecret)))
+	require.NoError(t, err)
+	srv, err := NewServer(config.DefaultConfig, slog.Default(), WithJWTKeys(keys))
+	require.NoError(t, err)
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL + "/.well-known/jwks.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Cache-Control"), "public"))
+	jwks := model.JWKS{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&jwks))
+	// общий ключ HS256 не публикуется
+	require.Len(t, jwks.Keys, 1)
+	assert.Equal(t, "2024-06", jwks.Keys[0].Kid)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+
+	// токен проверяется опубликованным ключом
+	token, err := buildJWTString(uuid.New(), keys, time.Hour)
+	require.NoError(t, err)
+	_, err = jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
+		return public, nil
+	})
+	assert.NoError(t, err)
+}
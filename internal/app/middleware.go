@@ -13,15 +13,16 @@ import (
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/apitoken"
+	"github.com/kTowkA/shortener/internal/clientip"
+	"github.com/kTowkA/shortener/internal/jwtkeys"
 )
 
 const (
 	authCookie = "jwt"
 )
 
-// TokenExp12Hours константа для установления время жизни токена в 12 часов
-const TokenExp12Hours = 12 * time.Hour
-
 type contextKey string
 
 // Claims — структура утверждений, которая включает стандартные утверждения и
@@ -54,6 +55,11 @@ func (r *loggingResponseWriter) WriteHeader(statusCode int) {
 	r.responseData.status = statusCode
 }
 
+// Unwrap возвращает исходный http.ResponseWriter (для http.ResponseController)
+func (r *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
 func (s *Server) withLog(h http.Handler) http.Handler {
 
 	logFn := func(w http.ResponseWriter, r *http.Request) {
@@ -72,6 +78,7 @@ func (s *Server) withLog(h http.Handler) http.Handler {
 			"входящий запрос",
 			slog.String("uri", r.RequestURI),
 			slog.String("http метод", r.Method),
+			slog.String("ip", clientIP(r)),
 			slog.Duration("длительность запроса", duration),
 			slog.Int("статус", lw.responseData.status),
 			slog.Int("размер ответа", lw.responseData.size),
@@ -97,6 +104,19 @@ func (gzw *gzipWriter) Write(p []byte) (int, error) {
 	return gzw.gzw.Write(p)
 }
 
+// Flush отправляет клиенту сжатые данные, накопленные к этому моменту
+func (gzw *gzipWriter) Flush() error {
+	if err := gzw.gzw.Flush(); err != nil {
+		return err
+	}
+	return http.NewResponseController(gzw.ResponseWriter).Flush()
+}
+
+// Unwrap возвращает исходный http.ResponseWriter (для http.ResponseController)
+func (gzw *gzipWriter) Unwrap() http.ResponseWriter {
+	return gzw.ResponseWriter
+}
+
 // Read реализация кастомного http.ResponseWriter
 func (gzr *gzipReader) Read(p []byte) (n int, err error) {
 	return gzr.gzr.Read(p)
@@ -110,7 +130,7 @@ func (gzr *gzipReader) Close() error {
 	return gzr.gzr.Close()
 }
 
-func withGZIP(h http.Handler) http.Handler {
+func (s *Server) withGZIP(h http.Handler) http.Handler {
 	zfunc := func(w http.ResponseWriter, r *http.Request) {
 		newWriter := w
 
@@ -128,7 +148,7 @@ func withGZIP(h http.Handler) http.Handler {
 			// оборачиваем тело запроса в io.Reader с поддержкой декомпрессии
 			rzip, err := gzip.NewReader(r.Body)
 			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
+				s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
 				return
 			}
 			gzr := &gzipReader{
@@ -160,114 +180,174 @@ func gzipValidContenType(header http.Header) bool {
 	return false
 }
 
+// withToken определяет пользователя запроса. запрос с заголовком Authorization: Bearer аутентифицируется
+// персональным API токеном, cookie при этом не используется и не выдается
 func (s *Server) withToken(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		userID, err := getUserIDFromCookie(r, s.Config.SecretKey())
+		if value, ok := apitoken.Bearer(r.Header.Get("Authorization")); ok {
+			s.withAPIToken(h, w, r, value)
+			return
+		}
+		token, claims, err := getTokenFromCookie(r, s.keys)
 		if err == nil {
-			// все хорошо, токен валиден и есть userID - продолжаем
-			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey("userID"), userID)))
+			// все хорошо, токен валиден и есть userID - продолжаем. токен, который скоро истечет
+			// или подписан не активным ключом, заменяем новым, чтобы активный пользователь не терял сессию
+			if s.needsRefresh(token, claims) {
+				if cookie, err := s.newAuthCookie(claims.UserID); err == nil {
+					http.SetCookie(w, cookie)
+				} else {
+					s.logger.Error("продление токена", slog.String("ошибка", err.Error()))
+				}
+			}
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey("userID"), claims.UserID)))
 			return
 		}
-		// создаем новый токен
-		// в настоящий момент нет системы авторизации/регистрации - мы генерируем новый userID в таких случаях
-		userID = uuid.New()
-		newTokenString, err := buildJWTString(userID, s.Config.SecretKey())
+		// создаем новый токен анонимного пользователя. пользователь с учетной записью
+		// получает свой userID обратно при входе (POST /api/user/login)
+		userID := uuid.New()
+		cookie, err := s.newAuthCookie(userID)
 		if err != nil {
-			s.logger.Error("создание токена", slog.String("ошибка", err.Error()))
-			w.WriteHeader(http.StatusInternalServerError)
+			s.writeError(w, r, fmt.Errorf("создание токена. %w", err))
 			return
 		}
-		http.SetCookie(w, &http.Cookie{Name: authCookie, Value: newTokenString})
+		http.SetCookie(w, cookie)
 
-		// сохраняем ID пользователя в контекте запроса и передаем дальше
-		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey("userID"), userID)))
+		// сохраняем ID пользователя в контекте запроса и передаем дальше. пользователь новый - повторный запрос
+		// без полученной cookie будет от имени другого пользователя
+		ctx := context.WithValue(r.Context(), contextKey("newUser"), true)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(ctx, contextKey("userID"), userID)))
 	})
 }
 
-// buildJWTString создаёт токен и возвращает его в виде строки.
-func buildJWTString(userID uuid.UUID, secret string) (string, error) {
-	// создаём новый токен с алгоритмом подписи HS256 и утверждениями — Claims
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+// withAPIToken проверяет персональный API токен value и передает запрос дальше от имени владельца токена
+func (s *Server) withAPIToken(h http.Handler, w http.ResponseWriter, r *http.Request, value string) {
+	if s.tokens == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		s.writeError(w, r, apierror.New(apierror.CodeUnauthorized).WithDetails("API токены не настроены"))
+		return
+	}
+	token, err := s.tokens.Authenticate(r.Context(), value)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		s.writeError(w, r, err)
+		return
+	}
+	ctx := apitoken.WithToken(r.Context(), token)
+	h.ServeHTTP(w, r.WithContext(context.WithValue(ctx, contextKey("userID"), token.UserID)))
+}
+
+// newAuthCookie cookie с новым токеном пользователя userID. cookie для всего сайта, чтобы после входа
+// не осталось анонимной cookie с более точным путем, и живет столько же, сколько токен.
+// cookie недоступна скриптам страницы и не отправляется с запросами других сайтов, кроме переходов
+func (s *Server) newAuthCookie(userID uuid.UUID) (*http.Cookie, error) {
+	lifetime := s.Config.JWTLifetime()
+	token, err := buildJWTString(userID, s.keys, lifetime)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Cookie{
+		Name:     authCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(lifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   s.Config.HTTPS(),
+		SameSite: http.SameSiteLaxMode,
+	}, nil
+}
+
+// needsRefresh проверяет, что токен пора заменить: он истекает в течение окна продления,
+// не имеет срока действия или подписан не активным ключом (после смены ключа)
+func (s *Server) needsRefresh(token *jwt.Token, claims *Claims) bool {
+	if kid, _ := jwtkeys.KeyID(token); kid != s.keys.ActiveID() {
+		return true
+	}
+	return claims.ExpiresAt == nil || time.Until(claims.ExpiresAt.Time) < s.Config.JWTRefreshWindow()
+}
+
+// buildJWTString создаёт токен со временем жизни lifetime, подписанный активным ключом набора keys
+func buildJWTString(userID uuid.UUID, keys *jwtkeys.Set, lifetime time.Duration) (string, error) {
+	return keys.Sign(Claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			// когда создан токен
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(TokenExp12Hours)),
+			// когда истекает токен
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(lifetime)),
 		},
 		// собственное утверждение
 		UserID: userID,
 	})
+}
 
-	// создаём строку токена
-	tokenString, err := token.SignedString([]byte(secret))
+// parseToken проверяет подпись и срок действия токена и возвращает его утверждения
+func parseToken(tokenString string, keys *jwtkeys.Set) (*jwt.Token, *Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc)
 	if err != nil {
-		return "", err
+		return nil, nil, err
+	}
+
+	if !token.Valid {
+		return nil, nil, fmt.Errorf("токен не прошел проверку")
 	}
 
-	// возвращаем строку токена
-	return tokenString, nil
+	return token, claims, nil
 }
 
 // getUserIDFromToken - получает ID из JWT токена
-func getUserIDFromToken(tokenString, secret string) (uuid.UUID, error) {
-	claims := &Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims,
-		func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("неожиданный метод подписи: %v", t.Header["alg"])
-			}
-			return []byte(secret), nil
-		})
+func getUserIDFromToken(tokenString string, keys *jwtkeys.Set) (uuid.UUID, error) {
+	_, claims, err := parseToken(tokenString, keys)
 	if err != nil {
 		return uuid.UUID{}, err
 	}
-
-	if !token.Valid {
-		return uuid.UUID{}, fmt.Errorf("токен не прошел проверку")
-	}
-
 	return claims.UserID, nil
 }
 
-// getUserIDFromCookie - получает ID пользователя из куки
-func getUserIDFromCookie(r *http.Request, secret string) (uuid.UUID, error) {
-	token, err := r.Cookie(authCookie)
+// getTokenFromCookie - получает токен пользователя из куки
+func getTokenFromCookie(r *http.Request, keys *jwtkeys.Set) (*jwt.Token, *Claims, error) {
+	cookie, err := r.Cookie(authCookie)
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("не смогли получить cookie. %w", err)
+		return nil, nil, fmt.Errorf("не смогли получить cookie. %w", err)
 	}
-	userID, err := getUserIDFromToken(token.Value, secret)
+	token, claims, err := parseToken(cookie.Value, keys)
 	if err != nil {
-		return uuid.UUID{}, fmt.Errorf("не смогли получить userID из токена. %w", err)
+		return nil, nil, fmt.Errorf("не смогли получить userID из токена. %w", err)
 	}
-	if err := uuid.Validate(userID.String()); err != nil {
-		return uuid.UUID{}, fmt.Errorf("userID не представляет собой UUID. %w", err)
+	if err := uuid.Validate(claims.UserID.String()); err != nil {
+		return nil, nil, fmt.Errorf("userID не представляет собой UUID. %w", err)
 	}
-	return userID, nil
+	return token, claims, nil
 }
 
-// trustedSubnet проверяем что X-Real-IP входит в заданную подсеть
+// withClientIP определяет адрес клиента и сохраняет его в контексте запроса. заголовки X-Forwarded-For и X-Real-IP
+// учитываются только от доверенных прокси
+func (s *Server) withClientIP(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := s.clientIP.FromRequest(r)
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey("clientIP"), ip)))
+	})
+}
+
+// clientIP адрес клиента, с которого пришел запрос
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(contextKey("clientIP")).(string); ok {
+		return ip
+	}
+	return clientip.Host(r.RemoteAddr)
+}
+
+// trustedSubnet проверяем что адрес клиента входит в одну из доверенных подсетей
 // иначе 403
-// если пустое значение trusted_subnet, то тоже запрещено
+// если доверенные подсети не заданы, то тоже запрещено
 func (s *Server) trustedSubnet(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if s.Config.TrustedSubnet().String() == "<nil>" {
+		if len(s.Config.TrustedSubnets()) == 0 {
 			s.logger.Error("попытка доступа к закрытому ресурсу", slog.String("ошибка", "доверенная подсеть не установлена"))
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		ipStr := r.Header.Get("X-Real-IP")
-		if ipStr == "" {
-			s.logger.Error("попытка доступа к закрытому ресурсу", slog.String("ошибка", "X-Real-IP не заполнен"))
-			w.WriteHeader(http.StatusForbidden)
-			return
-		}
-		ip := net.ParseIP(ipStr)
-		if ip == nil {
-			s.logger.Error("попытка доступа к закрытому ресурсу", slog.String("ошибка", "X-Real-IP невалиден"))
-			w.WriteHeader(http.StatusForbidden)
+			s.writeError(w, r, apierror.New(apierror.CodeForbidden))
 			return
 		}
-		if !s.Config.TrustedSubnet().Contains(ip) {
-			s.logger.Error("попытка доступа к закрытому ресурсу", slog.String("ошибка", "ip из другой сети"), slog.String("ip", ip.String()))
-			w.WriteHeader(http.StatusForbidden)
+		ip := clientIP(r)
+		if !s.Config.TrustedSubnets().Contains(net.ParseIP(ip)) {
+			s.logger.Error("попытка доступа к закрытому ресурсу", slog.String("ошибка", "ip из другой сети"), slog.String("ip", ip))
+			s.writeError(w, r, apierror.New(apierror.CodeForbidden))
 			return
 		}
 		h.ServeHTTP(w, r)
@@ -0,0 +1,109 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/oidc"
+)
+
+const (
+	// oidcCookie cookie с параметрами входа через провайдера, живет до возврата от провайдера
+	oidcCookie     = "oidc"
+	oidcCookiePath = "/api/user/oidc"
+	// oidcFlowLifetime время, за которое нужно войти у провайдера
+	oidcFlowLifetime = 10 * time.Minute
+)
+
+// WithOIDC устанавливает провайдера входа (SSO). без настройки вход через провайдера недоступен
+func WithOIDC(provider *oidc.Provider) Option {
+	return func(s *Server) {
+		s.oidc = provider
+	}
+}
+
+// oidcLogin начинает вход через провайдера: параметры входа сохраняются в cookie, браузер перенаправляется к провайдеру
+func (s *Server) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil || s.accounts == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return
+	}
+	flow, err := oidc.NewFlow()
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	authURL, err := s.oidc.AuthURL(r.Context(), flow)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	http.SetCookie(w, s.oidcCookie(flow.String(), int(oidcFlowLifetime.Seconds())))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallback возврат от провайдера: код авторизации обменивается на токен ID, пользователь входит в учетную запись
+// провайдера. ссылки, созданные в текущей анонимной сессии, переносятся в учетную запись
+func (s *Server) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil || s.accounts == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return
+	}
+	// параметры входа одноразовые
+	http.SetCookie(w, s.oidcCookie("", -1))
+	cookie, err := r.Cookie(oidcCookie)
+	if err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeSSOFailed, err).WithDetails("вход не начат или время входа истекло"))
+		return
+	}
+	flow, err := oidc.ParseFlow(cookie.Value)
+	if err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeSSOFailed, err).WithDetails("вход не начат или время входа истекло"))
+		return
+	}
+	q := r.URL.Query()
+	if !flow.CheckState(q.Get("state")) {
+		s.writeError(w, r, apierror.New(apierror.CodeSSOFailed).WithDetails("state"))
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		// пользователь отказался от входа или провайдер отказал в нем
+		s.writeError(w, r, apierror.New(apierror.CodeSSOFailed).WithDetails(e))
+		return
+	}
+	if q.Get("code") == "" {
+		s.writeError(w, r, apierror.New(apierror.CodeBadRequest).WithDetails("code"))
+		return
+	}
+	identity, err := s.oidc.Exchange(r.Context(), q.Get("code"), flow)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	userID, _ := r.Context().Value(contextKey("userID")).(uuid.UUID)
+	resp, err := s.accounts.LoginOIDC(r.Context(), userID, identity)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if !s.setAuthCookie(w, r, resp.ID) {
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, resp)
+}
+
+// oidcCookie cookie параметров входа value со временем жизни maxAge секунд. отправляется браузером
+// при возврате от провайдера, поэтому SameSite=Lax
+func (s *Server) oidcCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcCookie,
+		Value:    value,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   s.Config.HTTPS(),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/i18n"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+var (
+	// openAPIDocument описание маршрутов API. схемы моделей добавляются при формировании спецификации
+	//go:embed openapi.json
+	openAPIDocument []byte
+
+	// docsHTML страница документации, отображающая спецификацию без внешних зависимостей
+	//go:embed docs.html
+	docsHTML string
+
+	docsPage = template.Must(template.New("docs").Funcs(pageFuncs).Parse(docsHTML))
+)
+
+// docsLabels ключи каталога с подписями страницы документации, которые использует скрипт страницы
+var docsLabels = []string{"parameters", "body", "responses", "load_failed"}
+
+// openAPISchemas модели, схемы которых публикуются в спецификации
+var openAPISchemas = map[string]any{
+	"RequestShortURL":       model.RequestShortURL{},
+	"ResponseShortURL":      model.ResponseShortURL{},
+	"BatchRequest":          model.BatchRequest{},
+	"BatchResponse":         model.BatchResponse{},
+	"BulkResult":            model.BulkResult{},
+	"StorageJSON":           model.StorageJSON{},
+	"UpdateLinkRequest":     model.UpdateLinkRequest{},
+	"StatsResponse":         model.StatsResponse{},
+	"Job":                   model.Job{},
+	"DeleteTask":            model.DeleteTask{},
+	"DeleteResult":          model.DeleteResult{},
+	"WebhookRequest":        model.WebhookRequest{},
+	"Webhook":               model.Webhook{},
+	"WebhookDelivery":       model.WebhookDelivery{},
+	"Event":                 model.Event{},
+	"StorageJSONWithUserID": model.StorageJSONWithUserID{},
+	"UserBlockRequest":      model.UserBlockRequest{},
+	"PurgeResponse":         model.PurgeResponse{},
+	"AuditRecord":           model.AuditRecord{},
+	"HealthResponse":        model.HealthResponse{},
+	"Account":               model.Account{},
+	"Credentials":           model.Credentials{},
+	"LoginResponse":         model.LoginResponse{},
+	"ClaimRequest":          model.ClaimRequest{},
+	"ClaimResponse":         model.ClaimResponse{},
+	"APITokenRequest":       model.APITokenRequest{},
+	"APIToken":              model.APIToken{},
+	"JWKS":                  model.JWKS{},
+	"JWK":                   model.JWK{},
+	"Error":                 apierror.Error{},
+}
+
+// openAPISpec формирует спецификацию OpenAPI для сервера с базовым адресом baseAddress
+func openAPISpec(baseAddress string) ([]byte, error) {
+	doc := map[string]any{}
+	if err := json.Unmarshal(openAPIDocument, &doc); err != nil {
+		return nil, fmt.Errorf("чтение описания API. %w", err)
+	}
+	doc["servers"] = []map[string]string{{"url": strings.TrimSuffix(baseAddress, "/")}}
+
+	components, ok := doc["components"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("в описании API отсутствует раздел components")
+	}
+	g := schemaGenerator{names: make(map[reflect.Type]string, len(openAPISchemas))}
+	for name, v := range openAPISchemas {
+		g.names[reflect.TypeOf(v)] = name
+	}
+	schemas := make(map[string]any, len(openAPISchemas))
+	for name, v := range openAPISchemas {
+		schemas[name] = g.define(reflect.TypeOf(v))
+	}
+	components["schemas"] = schemas
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// stringFormats типы, которые кодируются в JSON строкой заданного формата
+var stringFormats = map[reflect.Type]string{
+	reflect.TypeOf(time.Time{}): "date-time",
+	reflect.TypeOf(uuid.UUID{}): "uuid",
+}
+
+// schemaGenerator формирует JSON схемы по типам Go с учетом тегов json.
+// для типов из names вместо вложенной схемы используется ссылка
+type schemaGenerator struct {
+	names map[reflect.Type]string
+}
+
+// define возвращает схему самого типа t (без подстановки ссылки на него)
+func (g schemaGenerator) define(t reflect.Type) map[string]any {
+	if format, ok := stringFormats[t]; ok {
+		return map[string]any{"type": "string", "format": format}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Struct:
+		properties := map[string]any{}
+		g.fields(t, properties)
+		return map[string]any{"type": "object", "properties": properties}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+// schema возвращает ссылку на схему для публикуемых типов и схему типа для остальных
+func (g schemaGenerator) schema(t reflect.Type) map[string]any {
+	if name, ok := g.names[t]; ok {
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return g.define(t)
+}
+
+// fields добавляет в properties поля структуры t. поля встроенных структур без тега json поднимаются на уровень t
+func (g schemaGenerator) fields(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			g.fields(f.Type, properties)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = g.schema(f.Type)
+	}
+}
+
+// openAPI отдает спецификацию OpenAPI
+func (s *Server) openAPI(w http.ResponseWriter, r *http.Request) {
+	spec, err := openAPISpec(s.Config.BaseAddress())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(spec)
+}
+
+// docs отдает страницу документации API
+func (s *Server) docs(w http.ResponseWriter, r *http.Request) {
+	lang := i18n.Parse(r.Header.Get("Accept-Language"))
+	labels := make(map[string]string, len(docsLabels))
+	for _, key := range docsLabels {
+		labels[key] = i18n.T(lang, "page.docs."+key)
+	}
+	s.renderPage(w, r, docsPage, http.StatusOK, labels)
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/kTowkA/shortener/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// specOperations возвращает операции спецификации в виде "METHOD /path"
+func specOperations(t *testing.T, doc map[string]any) []string {
+	t.Helper()
+	paths, ok := doc["paths"].(map[string]any)
+	require.True(t, ok)
+	operations := make([]string, 0)
+	for path, item := range paths {
+		methods, ok := item.(map[string]any)
+		require.True(t, ok, path)
+		for method := range methods {
+			if method == "parameters" {
+				continue
+			}
+			operations = append(operations, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// routeOperations возвращает зарегистрированные в роутере маршруты в виде "METHOD /path"
+func routeOperations(t *testing.T, routes chi.Routes) []string {
+	t.Helper()
+	operations := make([]string, 0)
+	err := chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		// профилировщик не является частью API
+		if strings.HasPrefix(route, "/debug/") {
+			return nil
+		}
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		route = strings.Replace(route, "/*", "/{path}", 1)
+		operations = append(operations, method+" "+route)
+		return nil
+	})
+	require.NoError(t, err)
+	sort.Strings(operations)
+	return operations
+}
+
+// collectRefs собирает все ссылки $ref документа
+func collectRefs(v any, refs map[string]struct{}) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if ref, ok := value.(string); ok && key == "$ref" {
+				refs[ref] = struct{}{}
+				continue
+			}
+			collectRefs(value, refs)
+		}
+	case []any:
+		for _, value := range v {
+			collectRefs(value, refs)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	srv, err := NewServer(config.DefaultConfig, slog.Default())
+	require.NoError(t, err)
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/openapi.json")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	doc := map[string]any{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+
+	// маршруты и спецификация должны совпадать
+	routes, ok := srv.server.Handler.(chi.Routes)
+	require.True(t, ok)
+	assert.Equal(t, routeOperations(t, routes), specOperations(t, doc), "маршруты в Server.setRoute и openapi.json расходятся")
+
+	// все ссылки указывают на существующие элементы
+	refs := make(map[string]struct{})
+	collectRefs(doc, refs)
+	for ref := range refs {
+		var node any = doc
+		for _, key := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			m, ok := node.(map[string]any)
+			require.True(t, ok, ref)
+			node, ok = m[key]
+			require.True(t, ok, "ссылка %s не найдена", ref)
+		}
+	}
+
+	// схемы построены по моделям
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+	request := schemas["RequestShortURL"].(map[string]any)["properties"].(map[string]any)
+	assert.Contains(t, request, "url")
+	assert.Contains(t, request, "redirect_code", "поля встроенных структур")
+	batch := schemas["BatchRequest"].(map[string]any)
+	assert.Equal(t, "array", batch["type"])
+	assert.NotContains(t, batch["items"].(map[string]any)["properties"], "ShortURL", "поля с json:\"-\" пропускаются")
+	storageJSON := schemas["StorageJSON"].(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, storageJSON["last_checked"])
+	apiError := schemas["Error"].(map[string]any)["properties"].(map[string]any)
+	assert.Len(t, apiError, 3, "неэкспортируемая причина ошибки не публикуется")
+	job := schemas["Job"].(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string", "format": "uuid"}, job["id"])
+	assert.Equal(t, map[string]any{"$ref": "#/components/schemas/BatchResponse"}, job["results"])
+	deleteTask := schemas["DeleteTask"].(map[string]any)["properties"].(map[string]any)
+	assert.NotContains(t, deleteTask, "UserID")
+	assert.Equal(t, "#/components/schemas/DeleteResult", deleteTask["results"].(map[string]any)["items"].(map[string]any)["$ref"])
+
+	// страница документации
+	resp, err = http.Get(ts.URL + "/api/docs")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/kTowkA/shortener/internal/i18n"
+)
+
+// pageFuncs функции шаблонов страниц. t переводит сообщение каталога на язык страницы
+var pageFuncs = template.FuncMap{"t": i18n.T}
+
+// warningPage страница, показываемая вместо перенаправления на заблокированный ресурс
+var warningPage = template.Must(template.New("warning").Funcs(pageFuncs).Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{t .Lang "page.warning.title"}}</title>
+</head>
+<body>
+<h1>{{t .Lang "page.warning.header"}}</h1>
+<p>{{t .Lang "page.warning.reason"}}</p>
+<p>{{t .Lang "page.warning.destination"}} <code>{{.Data.OriginalURL}}</code></p>
+</body>
+</html>
+`))
+
+// previewPage страница предпросмотра ссылки: показывает куда ведет ссылка и предлагает продолжить переход
+var previewPage = template.Must(template.New("preview").Funcs(pageFuncs).Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<title>{{if .Data.Title}}{{.Data.Title}}{{else}}{{t .Lang "page.preview.title"}}{{end}}</title>
+</head>
+<body>
+<h1>{{if .Data.Title}}{{.Data.Title}}{{else}}{{t .Lang "page.preview.title"}}{{end}}</h1>
+<p>{{t .Lang "page.preview.short"}} <code>{{.Data.ShortURL}}</code> {{t .Lang "page.preview.leads_to"}}</p>
+<p><code>{{.Data.OriginalURL}}</code></p>
+{{if .Data.Broken}}<p><strong>{{t .Lang "page.preview.attention"}}</strong> {{t .Lang "page.preview.broken"}}</p>
+{{end}}<p><a href="{{.Data.OriginalURL}}" rel="noreferrer">{{t .Lang "page.preview.continue"}}</a></p>
+</body>
+</html>
+`))
+
+// page данные страницы: язык клиента и данные конкретной страницы
+type page struct {
+	Lang i18n.Lang
+	Data any
+}
+
+// previewPageData данные для страницы предпросмотра
+type previewPageData struct {
+	ShortURL    string
+	OriginalURL string
+	Title       string
+	Broken      bool
+}
+
+// warningPageData данные для страницы предупреждения
+type warningPageData struct {
+	OriginalURL string
+}
+
+// renderPage выводит html страницу tmpl на языке клиента с данными data и статусом status
+func (s *Server) renderPage(w http.ResponseWriter, r *http.Request, tmpl *template.Template, status int, data any) {
+	lang := language(w, r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, page{Lang: lang, Data: data}); err != nil {
+		s.logger.Error("вывод страницы", slog.String("страница", tmpl.Name()), slog.String("ошибка", err.Error()))
+	}
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/ratelimit"
+)
+
+// WithRateLimiter устанавливает ограничитель частоты запросов на создание ссылок, переходы и удаление
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(s *Server) {
+		s.limiter = l
+	}
+}
+
+// rateLimit ограничивает частоту запросов группы class для пользователя и IP клиента.
+// при превышении отвечает 429 с заголовком Retry-After
+func (s *Server) rateLimit(class ratelimit.Class) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !s.limiter.Enabled(class) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			userKey := ""
+			if userID, ok := r.Context().Value(contextKey("userID")).(uuid.UUID); ok {
+				userKey = ratelimit.UserKey(userID.String())
+			}
+			ok, wait := s.limiter.Allow(class, userKey, ratelimit.IPKey(clientIP(r)))
+			if !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(wait)))
+				s.writeError(w, r, apierror.New(apierror.CodeRateLimited))
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// setQuotaRetryAfter выставляет заголовок Retry-After на время до восстановления суточной квоты
+func setQuotaRetryAfter(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(ratelimit.UntilNextDay(time.Now()))))
+}
+
+// failedWith проверяет, что элементы массового запроса не сохранены с ошибкой code
+// (например, из-за исчерпанной суточной квоты)
+func failedWith(resp model.BatchResponse, code apierror.Code) bool {
+	for _, v := range resp {
+		if v.Reason == string(code) {
+			return true
+		}
+	}
+	return false
+}
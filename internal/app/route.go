@@ -1,6 +1,7 @@
 package app
 
 import (
+	"archive/zip"
 	"bufio"
 	"bytes"
 	"encoding/json"
@@ -9,25 +10,36 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/apitoken"
+	"github.com/kTowkA/shortener/internal/bulk"
+	"github.com/kTowkA/shortener/internal/events"
 	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/qrcode"
 	"github.com/kTowkA/shortener/internal/storage"
 	"github.com/kTowkA/shortener/internal/utils"
 )
 
+// previewSuffix суффикс короткой ссылки для показа страницы предпросмотра
+const previewSuffix = "+"
+
 // encodeURL обработчик для кодирования входящего урла
 func (s *Server) encodeURL(w http.ResponseWriter, r *http.Request) {
 	// проверяем, что контент тайп нужный
 	if !strings.HasPrefix(r.Header.Get("Content-Type"), "text/plain") && !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-gzip") {
-		http.Error(w, fmt.Sprintf("разрешенные типы контента: %v", []string{"text/plain", "application/x-gzip"}), http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeInvalidContentType).WithDetails([]string{"text/plain", "application/x-gzip"}))
 		return
 	}
 
 	// проверяем, что тело существует
 	if r.Body == nil {
-		http.Error(w, "пустой запрос", http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeEmptyRequest))
 		return
 	}
 	defer r.Body.Close()
@@ -44,14 +56,20 @@ func (s *Server) encodeURL(w http.ResponseWriter, r *http.Request) {
 
 	// проверяем, что запрос не пуст
 	if link == "" {
-		http.Error(w, "пустой запрос", http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeEmptyRequest))
 		return
 	}
 
 	// проверяем, что это ссылка
 	_, err := url.ParseRequestURI(link)
 	if err != nil {
-		http.Error(w, "невалидная ссылка", http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeInvalidURL))
+		return
+	}
+
+	// проверяем, что ссылка не ведет на опасный ресурс
+	if s.threats.Match(link) {
+		s.writeError(w, r, apierror.New(apierror.CodeThreatURL))
 		return
 	}
 
@@ -69,7 +87,7 @@ func (s *Server) encodeURL(w http.ResponseWriter, r *http.Request) {
 			_, _ = w.Write([]byte(s.Config.BaseAddress() + newLink))
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
@@ -83,27 +101,88 @@ func (s *Server) decodeURL(w http.ResponseWriter, r *http.Request) {
 	// проверяем что есть подзапрос
 	short := r.URL.Path
 	if short == "/" {
-		http.Error(w, "пустой подзапрос", http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeEmptyRequest))
 		return
 	}
 
-	short = strings.Trim(short, "/")
+	// все после первого сегмента пути - дополнительный путь для передачи в оригинальную ссылку
+	short, extraPath, _ := strings.Cut(strings.TrimPrefix(short, "/"), "/")
+	// ссылка вида /{short}+ - запрос страницы предпросмотра
+	preview := strings.HasSuffix(short, previewSuffix)
+	short = strings.TrimSuffix(short, previewSuffix)
 	real, err := s.db.RealURL(r.Context(), short)
 	// ничего не нашли
 	if errors.Is(err, storage.ErrURLNotFound) {
-		http.Error(w, storage.ErrURLNotFound.Error(), http.StatusNotFound)
+		s.writeError(w, r, apierror.New(apierror.CodeURLNotFound))
 		return
 	} else if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 	if real.IsDeleted {
-		w.WriteHeader(http.StatusGone)
+		s.writeError(w, r, apierror.New(apierror.CodeURLDeleted))
+		return
+	}
+	if real.Passthrough {
+		real.OriginalURL, err = utils.PassthroughURL(real.OriginalURL, extraPath, r.URL.RawQuery)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+	} else if extraPath != "" {
+		// без передачи пути вложенные адреса не существуют
+		s.writeError(w, r, apierror.New(apierror.CodeURLNotFound))
+		return
+	}
+	// ссылка заблокирована или ведет на ресурс из списка угроз - показываем предупреждение вместо перенаправления
+	if real.IsBlocked || s.threats.Match(real.OriginalURL) {
+		if acceptsJSON(r) {
+			s.writeError(w, r, apierror.New(apierror.CodeURLBlocked))
+			return
+		}
+		s.renderPage(w, r, warningPage, http.StatusForbidden, warningPageData{OriginalURL: real.OriginalURL})
+		return
+	}
+	// запрошен предпросмотр или владелец ссылки включил его принудительно
+	if preview || real.Interstitial {
+		s.renderPage(w, r, previewPage, http.StatusOK, previewPageData{
+			ShortURL:    s.Config.BaseAddress() + short,
+			OriginalURL: real.OriginalURL,
+			Title:       real.Title,
+			Broken:      real.Broken(),
+		})
+		return
+	}
+	// оригинальная ссылка недоступна и настроено перенаправление для таких случаев
+	if real.Broken() && s.Config.DeadLinkFallback() != "" {
+		s.redirect(w, s.Config.DeadLinkFallback(), http.StatusTemporaryRedirect)
 		return
 	}
-	// успешно
-	w.Header().Set("Location", real.OriginalURL)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	// успешно. переход публикуется в шину событий (HEAD запросы не считаются переходами)
+	if r.Method != http.MethodHead {
+		s.events.Publish(events.New(model.EventLinkClicked, uuid.Nil, short, real.OriginalURL))
+	}
+	s.redirect(w, real.OriginalURL, real.RedirectCode)
+}
+
+// redirect перенаправляет на location с кодом code (0 - код из конфигурации).
+// постоянные перенаправления разрешено кэшировать, временные кэшировать запрещено
+func (s *Server) redirect(w http.ResponseWriter, location string, code int) {
+	if code == 0 {
+		code = s.Config.RedirectCode()
+	}
+	if !model.ValidRedirectCode(code) {
+		code = http.StatusTemporaryRedirect
+	}
+	if maxAge := s.Config.RedirectMaxAge(); model.PermanentRedirect(code) && maxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+		w.Header().Set("Expires", time.Now().Add(maxAge).UTC().Format(http.TimeFormat))
+	} else {
+		w.Header().Set("Cache-Control", "private, no-store, max-age=0")
+		w.Header().Set("Expires", time.Unix(0, 0).UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(code)
 }
 
 // apiShorten обработчик для API
@@ -111,7 +190,7 @@ func (s *Server) apiShorten(w http.ResponseWriter, r *http.Request) {
 
 	// проверяем, что тело существует
 	if r.Body == nil {
-		http.Error(w, "пустой запрос", http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeEmptyRequest))
 		return
 	}
 
@@ -119,20 +198,29 @@ func (s *Server) apiShorten(w http.ResponseWriter, r *http.Request) {
 	buf := bytes.Buffer{}
 	_, err := buf.ReadFrom(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
 	req := model.RequestShortURL{}
 	err = json.Unmarshal(buf.Bytes(), &req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
 		return
 	}
 	// проверяем, что это ссылка
 	_, err = url.ParseRequestURI(req.URL)
 	if err != nil {
-		http.Error(w, "невалидная ссылка", http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeInvalidURL))
+		return
+	}
+	if !req.LinkOptions.Valid() {
+		s.writeError(w, r, apierror.New(apierror.CodeInvalidLinkOptions))
+		return
+	}
+	// проверяем, что ссылка не ведет на опасный ресурс
+	if s.threats.Match(req.URL) {
+		s.writeError(w, r, apierror.New(apierror.CodeThreatURL))
 		return
 	}
 	conflict := false
@@ -142,12 +230,12 @@ func (s *Server) apiShorten(w http.ResponseWriter, r *http.Request) {
 		userID = uuid.New()
 	}
 	// newLink, err := s.saveLink(r.Context(), userID, req.URL, attems)
-	newLink, err := utils.SaveLink(r.Context(), s.db, userID, req.URL)
+	newLink, err := utils.SaveLinkWithOptions(r.Context(), s.db, userID, req.URL, req.LinkOptions)
 	if errors.Is(err, storage.ErrURLConflict) {
 		conflict = true
 	}
 	if err != nil && !conflict {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
@@ -156,7 +244,7 @@ func (s *Server) apiShorten(w http.ResponseWriter, r *http.Request) {
 	}
 	resp, err := json.MarshalIndent(res, "", "  ")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 	if w.Header().Get("Content-Type") == "" {
@@ -174,7 +262,7 @@ func (s *Server) apiShorten(w http.ResponseWriter, r *http.Request) {
 func (s *Server) ping(w http.ResponseWriter, r *http.Request) {
 	err := s.db.Ping(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -184,7 +272,7 @@ func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
 
 	// проверяем, что тело существует
 	if r.Body == nil {
-		http.Error(w, "пустой запрос", http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeEmptyRequest))
 		s.logger.Error("пустой запрос")
 		return
 	}
@@ -193,21 +281,20 @@ func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
 	buf := bytes.Buffer{}
 	_, err := buf.ReadFrom(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
 	req := model.BatchRequest{}
 	err = json.Unmarshal(buf.Bytes(), &req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
 		s.logger.Error("Unmarshal")
 		return
 	}
-	req = utils.ValidateAndGenerateBatch(req)
 	// проверяем, что есть запросы
 	if len(req) == 0 {
-		http.Error(w, fmt.Errorf("передали пустой batch").Error(), http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeEmptyBatch))
 		s.logger.Error("пустой batch")
 		return
 	}
@@ -215,43 +302,60 @@ func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		userID = uuid.New()
 	}
-	resp, err := utils.SaveBatch(r.Context(), s.db, userID, req)
+	// большие запросы можно обработать в фоне
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		s.submitJob(w, r, userID, req)
+		return
+	}
+	shortener := bulk.Shortener{
+		Store:       s.db,
+		Threats:     s.threats,
+		BaseAddress: s.Config.BaseAddress(),
+	}
+	resp, summary, err := shortener.Batch(r.Context(), userID, req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
-	for i := range resp {
-		if resp[i].ShortURL != "" {
-			resp[i].ShortURL = s.Config.BaseAddress() + resp[i].ShortURL
+	if summary.Saved() == 0 {
+		for _, code := range []apierror.Code{apierror.CodeUserBlocked, apierror.CodeQuotaExceeded} {
+			if failedWith(resp, code) {
+				s.writeError(w, r, apierror.New(code))
+				return
+			}
 		}
 	}
 
 	result, err := json.MarshalIndent(resp, "", "  ")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 	if w.Header().Get("Content-Type") == "" {
 		w.Header().Set("Content-Type", "application/json")
 	}
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(batchStatus(summary))
 	_, _ = w.Write(result)
 }
+
+// batchStatus статус ответа на массовый запрос: 201 - все ссылки сохранены, 207 - сохранена часть,
+// 400 - ни одного валидного элемента, 500 - ни один валидный элемент не удалось сохранить
+func batchStatus(summary bulk.Summary) int {
+	switch {
+	case summary.Saved() == summary.Total:
+		return http.StatusCreated
+	case summary.Saved() > 0:
+		return http.StatusMultiStatus
+	case summary.Failed == 0:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
 func (s *Server) getUserURLs(w http.ResponseWriter, r *http.Request) {
 
 	// проверяем, что userID записан в cookie
-	token, err := r.Cookie(authCookie)
-	if err != nil && !errors.Is(err, http.ErrNoCookie) {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if errors.Is(err, http.ErrNoCookie) {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return
-	}
-	userID, err := getUserIDFromToken(token.Value, s.Config.SecretKey())
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
 		return
 	}
 	urls, err := s.db.UserURLs(r.Context(), userID)
@@ -265,7 +369,7 @@ func (s *Server) getUserURLs(w http.ResponseWriter, r *http.Request) {
 
 	result, err := json.MarshalIndent(urls, "", "  ")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 	if w.Header().Get("Content-Type") == "" {
@@ -277,61 +381,248 @@ func (s *Server) getUserURLs(w http.ResponseWriter, r *http.Request) {
 func (s *Server) deleteUserURLs(w http.ResponseWriter, r *http.Request) {
 
 	// проверяем, что userID записан в cookie
-	token, err := r.Cookie(authCookie)
-	if err != nil && !errors.Is(err, http.ErrNoCookie) {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	if errors.Is(err, http.ErrNoCookie) {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
-		return
-	}
-	userID, err := getUserIDFromToken(token.Value, s.Config.SecretKey())
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
 		return
 	}
 
 	// проверяем, что тело существует
 	if r.Body == nil {
-		http.Error(w, "пустой запрос", http.StatusBadRequest)
+		s.writeError(w, r, apierror.New(apierror.CodeEmptyRequest))
 		return
 	}
 	defer r.Body.Close()
 
 	// работаем с телом ответа
 	buf := bytes.Buffer{}
-	_, err = buf.ReadFrom(r.Body)
+	_, err := buf.ReadFrom(r.Body)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 
 	req := []string{}
 	err = json.Unmarshal(buf.Bytes(), &req)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
+		return
+	}
+	s.enqueueDelete(w, r, userID, req)
+}
+
+// shortQR возвращает изображение QR кода для короткой ссылки
+func (s *Server) shortQR(w http.ResponseWriter, r *http.Request) {
+	short := chi.URLParam(r, "short")
+	opts, err := qrcode.ParseOptions(r.URL.Query())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	real, err := s.db.RealURL(r.Context(), short)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		s.writeError(w, r, apierror.New(apierror.CodeURLNotFound))
+		return
+	}
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if real.IsDeleted {
+		s.writeError(w, r, apierror.New(apierror.CodeURLDeleted))
+		return
+	}
+	// на заблокированную ссылку или ссылку на ресурс из списка угроз QR код не выдается, как и перенаправление
+	if real.IsBlocked || s.threats.Match(real.OriginalURL) {
+		s.writeError(w, r, apierror.New(apierror.CodeURLBlocked))
+		return
+	}
+	image, err := qrcode.Encode(s.Config.BaseAddress()+short, opts)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", opts.ContentType())
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(image)
+}
+
+// getUserQRArchive возвращает ZIP архив с QR кодами всех неудаленных ссылок пользователя
+func (s *Server) getUserQRArchive(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
+		return
+	}
+	opts, err := qrcode.ParseOptions(r.URL.Query())
+	if err != nil {
+		s.writeError(w, r, err)
 		return
 	}
-	for i := range req {
-		s.deleteMessage <- model.DeleteURLMessage{
-			UserID:   userID.String(),
-			ShortURL: req[i],
+	urls, err := s.db.UserURLs(r.Context(), userID)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// архив собираем в памяти, чтобы при ошибке можно было вернуть корректный статус
+	buf := bytes.Buffer{}
+	archive := zip.NewWriter(&buf)
+	for _, u := range urls {
+		if u.IsDeleted {
+			continue
 		}
+		image, err := qrcode.Encode(s.Config.BaseAddress()+u.ShortURL, opts)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		f, err := archive.Create(u.ShortURL + "." + string(opts.Format))
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		if _, err = f.Write(image); err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+	}
+	if err = archive.Close(); err != nil {
+		s.writeError(w, r, err)
+		return
 	}
-	w.WriteHeader(http.StatusAccepted)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="qr.zip"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
 }
+
+// updateUserURL изменяет настройки ссылки пользователя
+func (s *Server) updateUserURL(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
+		return
+	}
+	short := chi.URLParam(r, "short")
+
+	req := model.UpdateLinkRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
+		return
+	}
+
+	current, err := s.db.RealURL(r.Context(), short)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		s.writeError(w, r, apierror.New(apierror.CodeURLNotFound))
+		return
+	}
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	// хранилище само проверяет принадлежность ссылки пользователю
+	opts := req.Apply(current.LinkOptions)
+	if !opts.Valid() {
+		s.writeError(w, r, apierror.New(apierror.CodeInvalidLinkOptions))
+		return
+	}
+	err = s.db.UpdateURLOptions(r.Context(), userID, short, opts)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		s.writeError(w, r, apierror.New(apierror.CodeURLNotFound))
+		return
+	}
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getBrokenURLs возвращает ссылки пользователя, оригинальные адреса которых недоступны по результатам последней проверки
+func (s *Server) getBrokenURLs(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
+		return
+	}
+	urls, err := s.db.UserURLs(r.Context(), userID)
+	if err != nil && !errors.Is(err, storage.ErrURLNotFound) {
+		s.writeError(w, r, err)
+		return
+	}
+	broken := make([]model.StorageJSON, 0)
+	for _, u := range urls {
+		if u.IsDeleted || !u.Broken() {
+			continue
+		}
+		u.ShortURL = s.Config.BaseAddress() + u.ShortURL
+		broken = append(broken, u)
+	}
+	if len(broken) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	result, err := json.MarshalIndent(broken, "", "  ")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(result)
+}
+
+// authorizedUserID получает ID пользователя из API токена или cookie запроса. При ошибке записывает ответ и возвращает false
+func (s *Server) authorizedUserID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	if token, ok := apitoken.FromContext(r.Context()); ok {
+		return token.UserID, true
+	}
+	token, err := r.Cookie(authCookie)
+	if err != nil && !errors.Is(err, http.ErrNoCookie) {
+		s.writeError(w, r, err)
+		return uuid.UUID{}, false
+	}
+	if errors.Is(err, http.ErrNoCookie) {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeUnauthorized, err))
+		return uuid.UUID{}, false
+	}
+	userID, err := getUserIDFromToken(token.Value, s.keys)
+	if err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeUnauthorized, err))
+		return uuid.UUID{}, false
+	}
+	return userID, true
+}
+
+// stats статистика сервиса. параметры запроса from, to, granularity и top описаны в utils.ParseStatsRequest
 func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
-	stats, err := s.db.Stats(r.Context())
+	query := r.URL.Query()
+	top := 0
+	if v := query.Get("top"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails("top"))
+			return
+		}
+		top = n
+	}
+	req, err := utils.ParseStatsRequest(query.Get("from"), query.Get("to"), query.Get("granularity"), top, time.Now())
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	stats, err := s.db.Stats(r.Context(), req)
 	if err != nil {
 		s.logger.Error("запрос статистики сервиса", slog.String("ошибка", err.Error()))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 	result, err := json.MarshalIndent(stats, "", "  ")
 	if err != nil {
 		s.logger.Error("конвертация в json", slog.String("ошибка", err.Error()))
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.writeError(w, r, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
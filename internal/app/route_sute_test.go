@@ -12,23 +12,50 @@
 package app
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
+	"image/png"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/account"
+	"github.com/kTowkA/shortener/internal/admin"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/apitoken"
+	"github.com/kTowkA/shortener/internal/bulk"
 	"github.com/kTowkA/shortener/internal/config"
+	"github.com/kTowkA/shortener/internal/deletion"
+	"github.com/kTowkA/shortener/internal/events"
+	"github.com/kTowkA/shortener/internal/health"
+	"github.com/kTowkA/shortener/internal/idempotency"
+	"github.com/kTowkA/shortener/internal/jobs"
 	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/oidc"
+	"github.com/kTowkA/shortener/internal/oidc/oidctest"
+	"github.com/kTowkA/shortener/internal/ratelimit"
 	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
 	mocks "github.com/kTowkA/shortener/internal/storage/mocs"
+	"github.com/kTowkA/shortener/internal/threat"
+	"github.com/kTowkA/shortener/internal/webhook"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/net/websocket"
 )
 
 const (
@@ -59,6 +86,7 @@ func (suite *AppSuite) SetupSuite() {
 	suite.Require().NoError(err, "create app")
 
 	srv.db = suite.mockStorage
+	srv.deletes = deletion.New(suite.mockStorage, deletion.Options{})
 
 	// устанавливаем роут в сервере приложения
 	srv.setRoute()
@@ -124,6 +152,73 @@ func (suite *AppSuite) TestPing() {
 		}
 	}
 }
+func (suite *AppSuite) TestHealth() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	// живость не зависит от хранилища
+	live := model.HealthResponse{}
+	resp, err := resty.New().R().SetContext(ctx).SetResult(&live).Get(suite.ts.URL + "/healthz")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Equal(model.HealthResponse{Status: model.HealthOK}, live)
+
+	tests := []struct {
+		name       string
+		pingErr    error
+		wantStatus int
+		wantCheck  model.HealthCheck
+	}{
+		{
+			name:       "хранилище доступно",
+			wantStatus: http.StatusOK,
+			wantCheck:  model.HealthCheck{Name: health.CheckStorage, Status: model.HealthOK},
+		},
+		{
+			name:       "хранилище недоступно",
+			pingErr:    errors.New("нет подключения"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantCheck:  model.HealthCheck{Name: health.CheckStorage, Status: model.HealthFail, Error: "нет подключения"},
+		},
+	}
+	for _, t := range tests {
+		suite.mockStorage.On("Ping", mock.Anything).Return(t.pingErr).Once()
+		ready := model.HealthResponse{}
+		resp, err := resty.New().R().SetContext(ctx).SetResult(&ready).SetError(&ready).Get(suite.ts.URL + "/readyz")
+		suite.Require().NoError(err, t.name)
+		suite.EqualValues(t.wantStatus, resp.StatusCode(), t.name)
+		suite.Require().Len(ready.Checks, 1, t.name)
+		ready.Checks[0].DurationMS = 0
+		suite.Equal(t.wantCheck, ready.Checks[0], t.name)
+	}
+
+	// заполненная очередь удаления
+	mem, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	checker := health.New(health.Options{})
+	srv, err := NewServer(config.DefaultConfig, slog.Default(), WithHealth(checker))
+	suite.Require().NoError(err)
+	srv.db = mem
+	srv.deletes = deletion.New(mem, deletion.Options{FlushInterval: time.Hour, Capacity: 1})
+	checker.Add(health.CheckDeletionQueue, srv.deletes.Check)
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	resp, err = resty.New().R().SetContext(ctx).Get(ts.URL + "/readyz")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	_, err = srv.deletes.Enqueue(ctx, uuid.New(), []string{"a"})
+	suite.Require().NoError(err)
+	ready := model.HealthResponse{}
+	resp, err = resty.New().R().SetContext(ctx).SetError(&ready).Get(ts.URL + "/readyz")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusServiceUnavailable, resp.StatusCode())
+	suite.Equal(model.HealthFail, ready.Status)
+	suite.Require().Len(ready.Checks, 1)
+	suite.Equal(health.CheckDeletionQueue, ready.Checks[0].Name)
+}
+
 func (suite *AppSuite) TestEncode() {
 	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
 	defer cancel()
@@ -167,7 +262,7 @@ func (suite *AppSuite) TestEncode() {
 				return resty.New().R().SetContext(ctx).SetHeader("Content-type", "text/plain").SetBody(link).Post(suite.ts.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything).Return("", nil).Once()
+				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything, mock.Anything).Return("", nil).Once()
 			},
 			wantStatus: http.StatusCreated,
 		},
@@ -177,7 +272,7 @@ func (suite *AppSuite) TestEncode() {
 				return resty.New().R().SetContext(ctx).SetHeader("Content-type", "text/plain").SetBody(link).Post(suite.ts.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything).Return("", storage.ErrURLConflict).Once()
+				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything, mock.Anything).Return("", storage.ErrURLConflict).Once()
 			},
 			wantStatus: http.StatusConflict,
 		},
@@ -187,7 +282,7 @@ func (suite *AppSuite) TestEncode() {
 				return resty.New().R().SetContext(ctx).SetHeader("Content-type", "text/plain").SetBody(link).Post(suite.ts.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything).Return("", errors.New("save error")).Once()
+				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything, mock.Anything).Return("", errors.New("save error")).Once()
 			},
 			wantStatus: http.StatusInternalServerError,
 		},
@@ -197,7 +292,7 @@ func (suite *AppSuite) TestEncode() {
 				return resty.New().R().SetContext(ctx).SetHeader("Content-type", "text/plain").SetBody(link).Post(suite.ts.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything).Return("", storage.ErrURLIsExist)
+				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything, mock.Anything).Return("", storage.ErrURLIsExist)
 			},
 			wantStatus: http.StatusInternalServerError,
 		},
@@ -257,6 +352,16 @@ func (suite *AppSuite) TestDecode() {
 			},
 			wantStatus: http.StatusGone,
 		},
+		{
+			name: "заблокирован",
+			call: func() (*resty.Response, error) {
+				return resty.New().R().SetContext(ctx).Get(suite.ts.URL + path + short)
+			},
+			callStorage: func() *mock.Call {
+				return suite.mockStorage.On("RealURL", mock.Anything, short).Return(model.StorageJSON{OriginalURL: originalURL, IsBlocked: true}, nil).Once()
+			},
+			wantStatus: http.StatusForbidden,
+		},
 	}
 	for _, t := range tests {
 		if t.callStorage != nil {
@@ -301,7 +406,7 @@ func (suite *AppSuite) TestUserURLs() {
 			break
 		}
 	}
-	userID, err := getUserIDFromToken(jwtC, config.DefaultConfig.SecretKey())
+	userID, err := getUserIDFromToken(jwtC, testKeys(suite.T(), config.DefaultConfig.SecretKey()))
 	suite.Require().NoError(err)
 
 	// want когда есть ссылки, их ожидаем
@@ -400,8 +505,23 @@ func (suite *AppSuite) TestUserURLsDelete() {
 			call: func() (*resty.Response, error) {
 				return cl.R().SetContext(ctx).SetBody([]string{"1", "2", "3"}).Delete(suite.ts.URL + path)
 			},
+			callStorage: func() *mock.Call {
+				return suite.mockStorage.On("EnqueueDelete", mock.Anything, mock.MatchedBy(func(task model.DeleteTask) bool {
+					return task.Total == 3 && task.Status == model.JobQueued
+				})).Return(nil).Once()
+			},
 			wantStatus: http.StatusAccepted,
 		},
+		{
+			name: "ошибка очереди удаления",
+			call: func() (*resty.Response, error) {
+				return cl.R().SetContext(ctx).SetBody([]string{"1"}).Delete(suite.ts.URL + path)
+			},
+			callStorage: func() *mock.Call {
+				return suite.mockStorage.On("EnqueueDelete", mock.Anything, mock.Anything).Return(errors.New("enqueue error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
 	}
 
 	for _, t := range tests {
@@ -472,7 +592,7 @@ func (suite *AppSuite) TestAPIShorten() {
 				return cl.R().SetContext(ctx).SetBody(req).SetHeader("Content-type", "application/json").Post(suite.ts.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything).Return(saved, storage.ErrURLConflict).Once()
+				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything, mock.Anything).Return(saved, storage.ErrURLConflict).Once()
 			},
 			wantStatus: http.StatusConflict,
 			wantBody: model.ResponseShortURL{
@@ -485,7 +605,7 @@ func (suite *AppSuite) TestAPIShorten() {
 				return cl.R().SetContext(ctx).SetBody(req).SetHeader("Content-type", "application/json").Post(suite.ts.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything).Return("", errors.New("shorten error")).Once()
+				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything, mock.Anything).Return("", errors.New("shorten error")).Once()
 			},
 			wantStatus: http.StatusInternalServerError,
 		},
@@ -495,7 +615,7 @@ func (suite *AppSuite) TestAPIShorten() {
 				return cl.R().SetContext(ctx).SetBody(req).SetHeader("Content-type", "application/json").Post(suite.ts.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything).Return(saved, nil).Once()
+				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything, mock.Anything).Return(saved, nil).Once()
 			},
 			wantStatus: http.StatusCreated,
 			wantBody: model.ResponseShortURL{
@@ -508,7 +628,7 @@ func (suite *AppSuite) TestAPIShorten() {
 				return cl.R().SetContext(ctx).SetBody(req).SetHeader("Content-type", "application/json").Post(suite.ts.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything).Return("", storage.ErrURLIsExist)
+				return suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, link, mock.Anything, mock.Anything).Return("", storage.ErrURLIsExist)
 			},
 			wantStatus: http.StatusInternalServerError,
 		},
@@ -538,14 +658,17 @@ func (suite *AppSuite) TestAPIBatch() {
 	// создаем клиента
 	cl := resty.New()
 
-	// want для теста ок
-	want := model.BatchResponse{
-		{
-			ShortURL: "one",
-		},
-		{
-			ShortURL: "two",
-		},
+	// хранилище возвращает короткие ссылки по первым буквам домена
+	save := func(ctx context.Context, userID uuid.UUID, values model.BatchRequest) (model.BatchResponse, error) {
+		resp := make(model.BatchResponse, 0, len(values))
+		for _, v := range values {
+			e := model.BatchResponseElement{CorrelationID: v.CorrelationID, OriginalURL: v.OriginalURL, ShortURL: strings.TrimPrefix(v.OriginalURL, "http://")[:3]}
+			if v.OriginalURL == "http://two.com" {
+				e.Error = storage.ErrURLConflict
+			}
+			resp = append(resp, e)
+		}
+		return resp, nil
 	}
 	tests := []Test{
 		{
@@ -582,10 +705,45 @@ func (suite *AppSuite) TestAPIBatch() {
 				return cl.R().SetContext(ctx).SetBody(model.BatchRequest{{OriginalURL: "http://one.com"}, {OriginalURL: "http://two.com"}}).SetHeader("Content-type", "application/json").Post(suite.ts.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return suite.mockStorage.On("Batch", mock.Anything, mock.Anything, mock.AnythingOfType("model.BatchRequest")).Return(want, nil).Once()
+				return suite.mockStorage.On("Batch", mock.Anything, mock.Anything, mock.AnythingOfType("model.BatchRequest")).Return(save).Once()
 			},
 			wantStatus: http.StatusCreated,
-			wantBody:   want,
+			wantBody: model.BatchResponse{
+				{ShortURL: "one", Status: model.BatchStatusCreated},
+				{ShortURL: "two", Status: model.BatchStatusExisting, Reason: "url_conflict"},
+			},
+		},
+		{
+			name: "часть элементов невалидна",
+			call: func() (*resty.Response, error) {
+				return cl.R().SetContext(ctx).SetBody(model.BatchRequest{
+					{CorrelationID: "1", OriginalURL: "http://one.com"},
+					{CorrelationID: "2", OriginalURL: ""},
+					{CorrelationID: "3", OriginalURL: "http://one.com", LinkOptions: model.LinkOptions{RedirectCode: 200}},
+				}).SetHeader("Content-type", "application/json").Post(suite.ts.URL + path)
+			},
+			callStorage: func() *mock.Call {
+				return suite.mockStorage.On("Batch", mock.Anything, mock.Anything, mock.AnythingOfType("model.BatchRequest")).Return(save).Once()
+			},
+			wantStatus: http.StatusMultiStatus,
+			wantBody: model.BatchResponse{
+				{CorrelationID: "1", ShortURL: "one", Status: model.BatchStatusCreated},
+				{CorrelationID: "2", Status: model.BatchStatusInvalid, Reason: "invalid_url"},
+				{CorrelationID: "3", Status: model.BatchStatusInvalid, Reason: "invalid_link_options"},
+			},
+		},
+		{
+			name: "ошибка хранилища",
+			call: func() (*resty.Response, error) {
+				return cl.R().SetContext(ctx).SetBody(model.BatchRequest{{CorrelationID: "1", OriginalURL: "http://one.com"}}).SetHeader("Content-type", "application/json").Post(suite.ts.URL + path)
+			},
+			callStorage: func() *mock.Call {
+				return suite.mockStorage.On("Batch", mock.Anything, mock.Anything, mock.AnythingOfType("model.BatchRequest")).Return(nil, errors.New("batch error")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantBody: model.BatchResponse{
+				{CorrelationID: "1", Status: model.BatchStatusFailed, Reason: "internal"},
+			},
 		},
 	}
 	for _, t := range tests {
@@ -598,7 +756,9 @@ func (suite *AppSuite) TestAPIBatch() {
 		if t.wantBody != nil {
 			wb := (t.wantBody).(model.BatchResponse)
 			for i := range wb {
-				wb[i].ShortURL = config.DefaultConfig.BaseAddress() + wb[i].ShortURL
+				if wb[i].ShortURL != "" {
+					wb[i].ShortURL = config.DefaultConfig.BaseAddress() + wb[i].ShortURL
+				}
 			}
 			result := model.BatchResponse{}
 			err = json.Unmarshal(resp.Body(), &result)
@@ -619,12 +779,23 @@ func (suite *AppSuite) TestStats() {
 	tsV1 := httptest.NewServer(srvV1.server.Handler)
 	defer tsV1.Close()
 
-	// создаем тестовый сервер с подсетью
+	// создаем тестовый сервер с подсетью, но без доверенных прокси: заголовкам клиента не доверяем
 	trustedSubnet := "192.168.1.0/24"
 	os.Setenv("TRUSTED_SUBNET", trustedSubnet)
 	defer os.Unsetenv("TRUSTED_SUBNET")
 	cfg, err := config.ParseConfig(slog.Default())
 	suite.Require().NoError(err, "parse config")
+	srvNoProxy, err := NewServer(cfg, slog.Default())
+	suite.Require().NoError(err, "create server without proxies")
+	srvNoProxy.setRoute()
+	tsNoProxy := httptest.NewServer(srvNoProxy.server.Handler)
+	defer tsNoProxy.Close()
+
+	// тестовый клиент подключается через доверенный прокси
+	os.Setenv("TRUSTED_PROXIES", "127.0.0.1,::1")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	cfg, err = config.ParseConfig(slog.Default())
+	suite.Require().NoError(err, "parse config")
 	srvV2, err := NewServer(cfg, slog.Default())
 	suite.Require().NoError(err, "create server 2")
 	mockStorageV2 := new(mocks.Storager)
@@ -635,9 +806,20 @@ func (suite *AppSuite) TestStats() {
 
 	const path = "/api/internal/stats"
 	cl := resty.New()
+	hour := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
 	want := model.StatsResponse{
-		TotalUsers: 11,
-		TotalURLs:  22,
+		TotalUsers:  11,
+		TotalURLs:   22,
+		ActiveURLs:  20,
+		DeletedURLs: 2,
+		Created: model.StatsSeries{
+			Granularity: model.StatsHour,
+			From:        hour,
+			To:          hour.Add(2 * time.Hour),
+			Points:      []model.StatsPoint{{Time: hour, Links: 1}, {Time: hour.Add(time.Hour), Links: 0}},
+		},
+		TopDomains: []model.StatsCount{{Key: "go.dev", Links: 5}},
+		TopUsers:   []model.StatsCount{{Key: uuid.NewString(), Links: 5}},
 	}
 	tests := []Test{
 		{
@@ -647,6 +829,20 @@ func (suite *AppSuite) TestStats() {
 			},
 			wantStatus: http.StatusForbidden,
 		},
+		{
+			name:       "есть подсеть, X-Real-IP не от доверенного прокси",
+			wantStatus: http.StatusForbidden,
+			call: func() (*resty.Response, error) {
+				return cl.R().SetHeader("X-Real-IP", "192.168.1.15").SetHeader("X-Forwarded-For", "192.168.1.15").SetContext(ctx).Get(tsNoProxy.URL + path)
+			},
+		},
+		{
+			name:       "есть подсеть, X-Forwarded-For подделан клиентом перед прокси",
+			wantStatus: http.StatusForbidden,
+			call: func() (*resty.Response, error) {
+				return cl.R().SetHeader("X-Forwarded-For", "192.168.1.15, 10.0.0.7").SetContext(ctx).Get(tsV2.URL + path)
+			},
+		},
 		{
 			name:       "есть подсеть, X-Real-IP не установлен",
 			wantStatus: http.StatusForbidden,
@@ -675,8 +871,45 @@ func (suite *AppSuite) TestStats() {
 				return cl.R().SetHeader("X-Real-IP", "192.168.1.15").SetContext(ctx).Get(tsV2.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return mockStorageV2.On("Stats", mock.Anything).Return(model.StatsResponse{}, errors.New("!")).Once()
+				return mockStorageV2.On("Stats", mock.Anything, mock.Anything).Return(model.StatsResponse{}, errors.New("!")).Once()
+			},
+		},
+		{
+			name:       "некорректный шаг ряда",
+			wantStatus: http.StatusBadRequest,
+			call: func() (*resty.Response, error) {
+				return cl.R().SetHeader("X-Real-IP", "192.168.1.15").SetContext(ctx).Get(tsV2.URL + path + "?granularity=week")
+			},
+		},
+		{
+			name:       "некорректная длина списков лидеров",
+			wantStatus: http.StatusBadRequest,
+			call: func() (*resty.Response, error) {
+				return cl.R().SetHeader("X-Real-IP", "192.168.1.15").SetContext(ctx).Get(tsV2.URL + path + "?top=many")
+			},
+		},
+		{
+			name:       "начало периода позже конца",
+			wantStatus: http.StatusBadRequest,
+			call: func() (*resty.Response, error) {
+				return cl.R().SetHeader("X-Real-IP", "192.168.1.15").SetContext(ctx).Get(tsV2.URL + path + "?from=2024-05-02&to=2024-05-01")
+			},
+		},
+		{
+			name:       "период и шаг ряда",
+			wantStatus: http.StatusOK,
+			call: func() (*resty.Response, error) {
+				return cl.R().SetHeader("X-Real-IP", "192.168.1.15").SetContext(ctx).Get(tsV2.URL + path + "?granularity=hour&from=2024-05-01T10:15:00Z&to=2024-05-01T11:59:00Z&top=500")
+			},
+			callStorage: func() *mock.Call {
+				return mockStorageV2.On("Stats", mock.Anything, model.StatsRequest{
+					From:        hour,
+					To:          hour.Add(2 * time.Hour),
+					Granularity: model.StatsHour,
+					Top:         100,
+				}).Return(want, nil).Once()
 			},
+			wantBody: want,
 		},
 		{
 			name:       "есть подсеть,X-Real-IP валиден, при запросе из БД все хорошо",
@@ -685,7 +918,7 @@ func (suite *AppSuite) TestStats() {
 				return cl.R().SetHeader("X-Real-IP", "192.168.1.15").SetContext(ctx).Get(tsV2.URL + path)
 			},
 			callStorage: func() *mock.Call {
-				return mockStorageV2.On("Stats", mock.Anything).Return(want, nil).Once()
+				return mockStorageV2.On("Stats", mock.Anything, mock.Anything).Return(want, nil).Once()
 			},
 		},
 	}
@@ -705,6 +938,1611 @@ func (suite *AppSuite) TestStats() {
 		}
 	}
 }
-func TestAppSuite(t *testing.T) {
-	suite.Run(t, new(AppSuite))
+func (suite *AppSuite) TestThreatList() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	const evil = "http://evil.com/login"
+
+	listFile := filepath.Join(suite.T().TempDir(), "threats.txt")
+	err := os.WriteFile(listFile, []byte(threat.Hash("evil.com/")), 0o600)
+	suite.Require().NoError(err)
+	list, err := threat.Load(listFile)
+	suite.Require().NoError(err)
+
+	srv, err := NewServer(config.DefaultConfig, slog.Default(), WithThreatList(list))
+	suite.Require().NoError(err)
+	mockStorage := new(mocks.Storager)
+	srv.db = mockStorage
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	cl := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+
+	// сокращение опасной ссылки запрещено
+	resp, err := cl.R().SetContext(ctx).SetHeader("Content-type", "text/plain").SetBody(evil).Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+
+	resp, err = cl.R().SetContext(ctx).SetHeader("Content-type", "application/json").SetBody(model.RequestShortURL{URL: evil}).Post(ts.URL + "/api/shorten")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+
+	// ссылка, сохраненная до попадания в список, показывает предупреждение
+	mockStorage.On("RealURL", mock.Anything, "short").Return(model.StorageJSON{OriginalURL: evil}, nil).Once()
+	resp, err = cl.R().SetContext(ctx).Get(ts.URL + "/short")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+	suite.Contains(resp.Header().Get("Content-Type"), "text/html")
+	suite.Empty(resp.Header().Get("Location"))
+
+	// фоновая проверка блокирует сохраненные опасные ссылки
+	mockStorage.On("AllURLs", mock.Anything).Return([]model.StorageJSONWithUserID{
+		{StorageJSON: model.StorageJSON{ShortURL: "bad", OriginalURL: evil}},
+		{StorageJSON: model.StorageJSON{ShortURL: "good", OriginalURL: "https://go.dev"}},
+		{StorageJSON: model.StorageJSON{ShortURL: "blocked", OriginalURL: evil, IsBlocked: true}},
+	}, nil).Once()
+	mockStorage.On("BlockURLs", mock.Anything, []string{"bad"}, true).Return(nil).Once()
+	srv.blockThreats(ctx)
+
+	mockStorage.AssertExpectations(suite.T())
+}
+func (suite *AppSuite) TestBrokenURLs() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	const path = "/api/user/urls/broken"
+
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	cl := resty.New()
+
+	checked := time.Now()
+	urls := []model.StorageJSON{
+		{ShortURL: "ok", OriginalURL: "https://go.dev", LastStatus: http.StatusOK, LastChecked: &checked},
+		{ShortURL: "dead", OriginalURL: "https://dead.example", LastStatus: http.StatusNotFound, LastChecked: &checked},
+		{ShortURL: "unchecked", OriginalURL: "https://new.example"},
+		{ShortURL: "deleted", OriginalURL: "https://old.example", IsDeleted: true, LastChecked: &checked},
+	}
+
+	// без авторизации
+	resp, err := cl.R().SetContext(ctx).Get(suite.ts.URL + path)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+
+	// есть недоступные ссылки
+	suite.mockStorage.On("UserURLs", mock.Anything, userID).Return(urls, nil).Once()
+	resp, err = cl.R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: token}).Get(suite.ts.URL + path)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	result := []model.StorageJSON{}
+	suite.Require().NoError(json.Unmarshal(resp.Body(), &result))
+	suite.Require().Len(result, 1)
+	suite.EqualValues(config.DefaultConfig.BaseAddress()+"dead", result[0].ShortURL)
+	suite.EqualValues(http.StatusNotFound, result[0].LastStatus)
+
+	// недоступных ссылок нет
+	suite.mockStorage.On("UserURLs", mock.Anything, userID).Return(urls[:1], nil).Once()
+	resp, err = cl.R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: token}).Get(suite.ts.URL + path)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNoContent, resp.StatusCode())
+}
+
+func (suite *AppSuite) TestDeadLinkFallback() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	const fallback = "https://example.com/link-is-dead"
+	os.Setenv("DEAD_LINK_FALLBACK", fallback)
+	defer os.Unsetenv("DEAD_LINK_FALLBACK")
+	cfg, err := config.ParseConfig(slog.Default())
+	suite.Require().NoError(err)
+
+	srv, err := NewServer(cfg, slog.Default())
+	suite.Require().NoError(err)
+	mockStorage := new(mocks.Storager)
+	srv.db = mockStorage
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	cl := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+	checked := time.Now()
+
+	mockStorage.On("RealURL", mock.Anything, "dead").Return(model.StorageJSON{OriginalURL: "https://dead.example", LastStatus: http.StatusBadGateway, LastChecked: &checked}, nil).Once()
+	resp, err := cl.R().SetContext(ctx).Get(ts.URL + "/dead")
+	suite.ErrorIs(err, resty.ErrAutoRedirectDisabled)
+	suite.EqualValues(http.StatusTemporaryRedirect, resp.StatusCode())
+	suite.EqualValues(fallback, resp.Header().Get("Location"))
+
+	mockStorage.On("RealURL", mock.Anything, "alive").Return(model.StorageJSON{OriginalURL: "https://go.dev", LastStatus: http.StatusOK, LastChecked: &checked}, nil).Once()
+	resp, err = cl.R().SetContext(ctx).Get(ts.URL + "/alive")
+	suite.ErrorIs(err, resty.ErrAutoRedirectDisabled)
+	suite.EqualValues("https://go.dev", resp.Header().Get("Location"))
+
+	mockStorage.AssertExpectations(suite.T())
+}
+
+func (suite *AppSuite) TestPreview() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	cl := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+
+	// предпросмотр по суффиксу +
+	suite.mockStorage.On("RealURL", mock.Anything, "preview").Return(model.StorageJSON{OriginalURL: "https://go.dev", LinkOptions: model.LinkOptions{Title: "Go"}}, nil).Once()
+	resp, err := cl.R().SetContext(ctx).Get(suite.ts.URL + "/preview+")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Contains(resp.Header().Get("Content-Type"), "text/html")
+	suite.Empty(resp.Header().Get("Location"))
+	suite.Contains(resp.String(), "https://go.dev")
+	suite.Contains(resp.String(), "<title>Go</title>")
+
+	// предпросмотр включен для ссылки
+	suite.mockStorage.On("RealURL", mock.Anything, "interstitial").Return(model.StorageJSON{OriginalURL: "https://go.dev", LinkOptions: model.LinkOptions{Interstitial: true}}, nil).Once()
+	resp, err = cl.R().SetContext(ctx).Get(suite.ts.URL + "/interstitial")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Empty(resp.Header().Get("Location"))
+
+	// удаленная ссылка
+	suite.mockStorage.On("RealURL", mock.Anything, "deleted").Return(model.StorageJSON{OriginalURL: "https://go.dev", IsDeleted: true}, nil).Once()
+	resp, err = cl.R().SetContext(ctx).Get(suite.ts.URL + "/deleted+")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusGone, resp.StatusCode())
+}
+
+func (suite *AppSuite) TestLocalization() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	cl := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+
+	// ошибка на английском
+	suite.mockStorage.On("RealURL", mock.Anything, "i18n").Return(model.StorageJSON{}, storage.ErrURLNotFound).Once()
+	resp, err := cl.R().SetContext(ctx).SetHeader("Accept", "application/json").SetHeader("Accept-Language", "en-US,en;q=0.9,ru;q=0.8").Get(suite.ts.URL + "/i18n")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+	suite.EqualValues("en", resp.Header().Get("Content-Language"))
+	suite.Contains(resp.Header().Get("Vary"), "Accept-Language")
+	body := apierror.Error{}
+	suite.Require().NoError(json.Unmarshal(resp.Body(), &body))
+	suite.EqualValues(apierror.CodeURLNotFound, body.Code)
+	suite.EqualValues("URL not found", body.Message)
+
+	// текстовая ошибка на языке по умолчанию для неподдерживаемого языка
+	suite.mockStorage.On("RealURL", mock.Anything, "i18n").Return(model.StorageJSON{}, storage.ErrURLNotFound).Once()
+	resp, err = cl.R().SetContext(ctx).SetHeader("Accept-Language", "de").Get(suite.ts.URL + "/i18n")
+	suite.Require().NoError(err)
+	suite.EqualValues("ru", resp.Header().Get("Content-Language"))
+	suite.Contains(resp.String(), "ссылка не найдена")
+
+	// страницы
+	suite.mockStorage.On("RealURL", mock.Anything, "i18n").Return(model.StorageJSON{OriginalURL: "https://go.dev"}, nil).Once()
+	resp, err = cl.R().SetContext(ctx).SetHeader("Accept-Language", "en").Get(suite.ts.URL + "/i18n+")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Contains(resp.String(), `<html lang="en">`)
+	suite.Contains(resp.String(), "Continue")
+	suite.NotContains(resp.String(), "Продолжить")
+
+	resp, err = cl.R().SetContext(ctx).SetHeader("Accept-Language", "en").Get(suite.ts.URL + "/api/docs")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Contains(resp.String(), "Schemas")
+	suite.Contains(resp.String(), `"parameters":"Parameters"`)
+}
+
+func (suite *AppSuite) TestBulkShorten() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	// хранилище сохраняет все переданные ссылки
+	saveAll := func(ctx context.Context, userID uuid.UUID, values model.BatchRequest) (model.BatchResponse, error) {
+		resp := make(model.BatchResponse, 0, len(values))
+		for _, v := range values {
+			resp = append(resp, model.BatchResponseElement{CorrelationID: v.CorrelationID, OriginalURL: v.OriginalURL, ShortURL: v.ShortURL})
+		}
+		return resp, nil
+	}
+
+	// CSV с результатом в виде файла
+	suite.mockStorage.On("Batch", mock.Anything, mock.Anything, mock.AnythingOfType("model.BatchRequest")).Return(saveAll).Once()
+	resp, err := resty.New().R().SetContext(ctx).
+		SetHeader("Content-Type", "text/csv").
+		SetBody("original_url,correlation_id\nhttps://go.dev,a\nnot url,b\n").
+		Post(suite.ts.URL + "/api/shorten/stream?download=true")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Contains(resp.Header().Get("Content-Type"), "text/csv")
+	suite.Contains(resp.Header().Get("Content-Disposition"), `filename="shorten-result.csv"`)
+	records, err := csv.NewReader(bytes.NewReader(resp.Body())).ReadAll()
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 3)
+	suite.Equal([]string{"2", "a", "https://go.dev"}, records[1][:3])
+	suite.True(strings.HasPrefix(records[1][3], config.DefaultConfig.BaseAddress()), "полная короткая ссылка")
+	suite.Equal("created", records[1][4])
+	suite.Equal([]string{"3", "b", "not url", "", "invalid", "invalid_url"}, records[2])
+
+	// NDJSON с результатом в формате, заданном заголовком Accept
+	suite.mockStorage.On("Batch", mock.Anything, mock.Anything, mock.AnythingOfType("model.BatchRequest")).Return(saveAll).Once()
+	resp, err = resty.New().R().SetContext(ctx).
+		SetHeader("Content-Type", "application/x-ndjson").
+		SetHeader("Accept", "application/x-ndjson").
+		SetBody(`{"original_url":"https://go.dev","correlation_id":"a"}` + "\n").
+		Post(suite.ts.URL + "/api/shorten/stream")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Contains(resp.Header().Get("Content-Type"), "application/x-ndjson")
+	result := model.BulkResult{}
+	suite.Require().NoError(json.Unmarshal(resp.Body(), &result))
+	suite.Equal(model.BatchStatusCreated, result.Status)
+	suite.Equal("a", result.CorrelationID)
+
+	// неподдерживаемый формат загрузки
+	resp, err = resty.New().R().SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(`[]`).
+		Post(suite.ts.URL + "/api/shorten/stream")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnsupportedMediaType, resp.StatusCode())
+
+	// неизвестный формат результата
+	resp, err = resty.New().R().SetContext(ctx).
+		SetHeader("Content-Type", "text/csv").
+		SetBody("https://go.dev").
+		Post(suite.ts.URL + "/api/shorten/stream?format=xml")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+}
+
+func (suite *AppSuite) TestAsyncBatch() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	srv, err := NewServer(config.DefaultConfig, slog.Default())
+	suite.Require().NoError(err)
+	srv.db = store
+	srv.jobs = jobs.New(bulk.Shortener{Store: store, BaseAddress: config.DefaultConfig.BaseAddress()}, jobs.Options{PollInterval: time.Hour})
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+	go srv.jobs.Run(ctx)
+
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	cookie := &http.Cookie{Name: authCookie, Value: token}
+
+	// задание ставится в очередь
+	resp, err := resty.New().R().SetContext(ctx).SetCookie(cookie).
+		SetHeader("Content-Type", "application/json").
+		SetBody(model.BatchRequest{
+			{CorrelationID: "1", OriginalURL: "https://go.dev"},
+			{CorrelationID: "2", OriginalURL: "not url"},
+		}).
+		Post(ts.URL + "/api/shorten/batch?async=true")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusAccepted, resp.StatusCode())
+	job := model.Job{}
+	suite.Require().NoError(json.Unmarshal(resp.Body(), &job))
+	suite.Equal(2, job.Total)
+	location := resp.Header().Get("Location")
+	suite.Equal("/api/jobs/"+job.ID.String(), location)
+
+	// результаты доступны после завершения обработки
+	suite.Require().Eventually(func() bool {
+		resp, err = resty.New().R().SetContext(ctx).SetCookie(cookie).Get(ts.URL + location)
+		suite.Require().NoError(err)
+		suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+		job = model.Job{}
+		suite.Require().NoError(json.Unmarshal(resp.Body(), &job))
+		return job.Status == model.JobDone
+	}, 5*time.Second, 10*time.Millisecond)
+	suite.Equal(2, job.Processed)
+	suite.Equal(1, job.Created)
+	suite.Equal(1, job.Invalid)
+	suite.Require().Len(job.Results, 2)
+	suite.True(strings.HasPrefix(job.Results[0].ShortURL, config.DefaultConfig.BaseAddress()))
+	suite.Equal("invalid_url", job.Results[1].Reason)
+
+	// чужое задание, неверный идентификатор и запрос без авторизации
+	otherToken, err := buildJWTString(uuid.New(), testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	resp, err = resty.New().R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: otherToken}).Get(ts.URL + location)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+	suite.Equal(string(apierror.CodeJobNotFound), resp.Header().Get("X-Error-Code"))
+	resp, err = resty.New().R().SetContext(ctx).SetCookie(cookie).Get(ts.URL + "/api/jobs/123")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+	resp, err = resty.New().R().SetContext(ctx).Get(ts.URL + location)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+
+	// очередь заданий не запущена
+	resp, err = resty.New().R().SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(model.BatchRequest{{OriginalURL: "https://go.dev"}}).
+		Post(suite.ts.URL + "/api/shorten/batch?async=true")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusServiceUnavailable, resp.StatusCode())
+}
+
+func (suite *AppSuite) TestIdempotencyKey() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	srv, err := NewServer(config.DefaultConfig, slog.Default())
+	suite.Require().NoError(err)
+	srv.db = store
+	srv.idempotency = idempotency.New(store, time.Hour, slog.Default())
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	request := func(key string) *resty.Request {
+		return resty.New().R().SetContext(ctx).
+			SetCookie(&http.Cookie{Name: authCookie, Value: token}).
+			SetHeader(idempotency.Header, key)
+	}
+
+	// повтор возвращает первый ответ вместо конфликта
+	first, err := request("text").SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusCreated, first.StatusCode())
+	suite.Empty(first.Header().Get(idempotency.ReplayedHeader))
+	retry, err := request("text").SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusCreated, retry.StatusCode())
+	suite.Equal("true", retry.Header().Get(idempotency.ReplayedHeader))
+	suite.Equal(first.Body(), retry.Body())
+	suite.Contains(retry.Header().Get("Content-Type"), "text/plain")
+
+	// без ключа повтор - конфликт
+	resp, err := request("").SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusConflict, resp.StatusCode())
+
+	// ключ использован для другого запроса
+	resp, err = request("text").SetHeader("Content-Type", "application/json").SetBody(model.RequestShortURL{URL: "https://go.dev"}).Post(ts.URL + "/api/shorten")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnprocessableEntity, resp.StatusCode())
+	suite.Equal(string(apierror.CodeIdemKeyReused), resp.Header().Get("X-Error-Code"))
+
+	// массовый запрос не создает ссылки повторно
+	batch := model.BatchRequest{{CorrelationID: "1", OriginalURL: "https://pkg.go.dev"}}
+	first, err = request("batch").SetHeader("Content-Type", "application/json").SetBody(batch).Post(ts.URL + "/api/shorten/batch")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusCreated, first.StatusCode())
+	retry, err = request("batch").SetHeader("Content-Type", "application/json").SetBody(batch).Post(ts.URL + "/api/shorten/batch")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusCreated, retry.StatusCode())
+	suite.Equal(first.Body(), retry.Body())
+	urls, err := store.UserURLs(ctx, userID)
+	suite.Require().NoError(err)
+	suite.Len(urls, 2)
+
+	// недопустимый ключ
+	resp, err = request(strings.Repeat("k", idempotency.MaxKeyLength+1)).SetHeader("Content-Type", "text/plain").SetBody("https://go.dev/doc").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+	suite.Equal(string(apierror.CodeBadIdemKey), resp.Header().Get("X-Error-Code"))
+
+	// без cookie повтор был бы от имени нового пользователя, поэтому ключ не принимается
+	resp, err = resty.New().R().SetContext(ctx).SetHeader(idempotency.Header, "anonymous").SetHeader("Content-Type", "text/plain").SetBody("https://go.dev/doc").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+	suite.Equal(string(apierror.CodeIdemKeyNoUser), resp.Header().Get("X-Error-Code"))
+	urls, err = store.UserURLs(ctx, userID)
+	suite.Require().NoError(err)
+	suite.Len(urls, 2)
+}
+
+func (suite *AppSuite) TestDeleteQueue() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	srv, err := NewServer(config.DefaultConfig, slog.Default())
+	suite.Require().NoError(err)
+	srv.db = store
+	srv.deletes = deletion.New(store, deletion.Options{FlushInterval: 10 * time.Millisecond})
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+	queueCtx, stopQueue := context.WithCancel(ctx)
+	defer stopQueue()
+	go srv.deletes.Run(queueCtx)
+
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	request := func() *resty.Request {
+		return resty.New().R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: token})
+	}
+
+	resp, err := request().SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	short := strings.TrimPrefix(string(resp.Body()), config.DefaultConfig.BaseAddress())
+
+	resp, err = request().SetHeader("Content-Type", "application/json").SetBody([]string{short, "missing"}).Delete(ts.URL + "/api/user/urls")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusAccepted, resp.StatusCode())
+	task := model.DeleteTask{}
+	suite.Require().NoError(json.Unmarshal(resp.Body(), &task))
+	suite.Equal(model.JobQueued, task.Status)
+	suite.Equal(2, task.Total)
+	location := resp.Header().Get("Location")
+	suite.Equal("/api/user/deletions/"+task.ID.String(), location)
+
+	// результаты по каждой ссылке доступны после удаления
+	suite.Eventually(func() bool {
+		resp, err = request().Get(ts.URL + location)
+		suite.Require().NoError(err)
+		suite.Require().NoError(json.Unmarshal(resp.Body(), &task))
+		return task.Status == model.JobDone
+	}, 5*time.Second, 10*time.Millisecond)
+	suite.Equal(1, task.Deleted)
+	suite.Equal(1, task.NotFound)
+	suite.Equal([]model.DeleteResult{
+		{ShortURL: short, Status: model.DeleteDone},
+		{ShortURL: "missing", Status: model.DeleteNotFound},
+	}, task.Results)
+	link, err := store.RealURL(ctx, short)
+	suite.Require().NoError(err)
+	suite.True(link.IsDeleted)
+
+	// чужая задача
+	resp, err = resty.New().R().SetContext(ctx).Get(ts.URL + location)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	other, err := buildJWTString(uuid.New(), testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	resp, err = resty.New().R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: other}).Get(ts.URL + location)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+	suite.Equal(string(apierror.CodeDeletionNotFound), resp.Header().Get("X-Error-Code"))
+
+	// очередь переполнена
+	srv.deletes = deletion.New(store, deletion.Options{Capacity: 1})
+	resp, err = request().SetHeader("Content-Type", "application/json").SetBody([]string{"a", "b"}).Delete(ts.URL + "/api/user/urls")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusServiceUnavailable, resp.StatusCode())
+	suite.Equal(string(apierror.CodeDeleteQueueFull), resp.Header().Get("X-Error-Code"))
+	suite.Equal("5", resp.Header().Get("Retry-After"))
+}
+
+func (suite *AppSuite) TestRateLimit() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	srv, err := NewServer(config.DefaultConfig, slog.Default(), WithRateLimiter(ratelimit.New(ratelimit.Limits{
+		ratelimit.Create:   1,
+		ratelimit.Redirect: 2,
+	})))
+	suite.Require().NoError(err)
+	srv.db = ratelimit.WithDailyQuota(store, 2)
+	srv.deletes = deletion.New(srv.db, deletion.Options{})
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	request := func() *resty.Request {
+		return resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R().SetContext(ctx).
+			SetCookie(&http.Cookie{Name: authCookie, Value: token})
+	}
+
+	// создание ссылок
+	resp, err := request().SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	short := strings.TrimPrefix(string(resp.Body()), config.DefaultConfig.BaseAddress())
+
+	resp, err = request().SetHeader("Content-Type", "application/json").SetBody(model.RequestShortURL{URL: "https://go.dev/doc"}).Post(ts.URL + "/api/shorten")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusTooManyRequests, resp.StatusCode())
+	suite.Equal(string(apierror.CodeRateLimited), resp.Header().Get("X-Error-Code"))
+	suite.Equal("60", resp.Header().Get("Retry-After"))
+
+	// переходы ограничиваются отдельно от создания
+	for i := 0; i < 2; i++ {
+		resp, err = request().Get(ts.URL + "/" + short)
+		suite.Require().Error(err)
+		suite.EqualValues(http.StatusTemporaryRedirect, resp.StatusCode())
+	}
+	resp, err = request().Get(ts.URL + "/" + short)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusTooManyRequests, resp.StatusCode())
+	suite.Equal("30", resp.Header().Get("Retry-After"))
+
+	// удаление без ограничений
+	resp, err = request().SetHeader("Content-Type", "application/json").SetBody([]string{short}).Delete(ts.URL + "/api/user/urls")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusAccepted, resp.StatusCode())
+
+	// суточная квота: осталась одна ссылка, массовый запрос на две отклоняется целиком
+	srv.limiter = nil
+	batch := model.BatchRequest{
+		{CorrelationID: "1", OriginalURL: "https://pkg.go.dev"},
+		{CorrelationID: "2", OriginalURL: "https://go.dev/blog"},
+	}
+	resp, err = request().SetHeader("Content-Type", "application/json").SetBody(batch).Post(ts.URL + "/api/shorten/batch")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusTooManyRequests, resp.StatusCode())
+	suite.Equal(string(apierror.CodeQuotaExceeded), resp.Header().Get("X-Error-Code"))
+	suite.NotEmpty(resp.Header().Get("Retry-After"))
+
+	resp, err = request().SetHeader("Content-Type", "text/plain").SetBody("https://go.dev/blog").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusCreated, resp.StatusCode())
+	resp, err = request().SetHeader("Content-Type", "text/plain").SetBody("https://go.dev/play").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusTooManyRequests, resp.StatusCode())
+	suite.Equal(string(apierror.CodeQuotaExceeded), resp.Header().Get("X-Error-Code"))
+}
+
+func (suite *AppSuite) TestRedirectCode() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	cl := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+
+	tests := []struct {
+		name         string
+		method       string
+		code         int
+		wantStatus   int
+		wantCacheHdr string
+	}{
+		{
+			name:         "код по умолчанию",
+			method:       http.MethodGet,
+			code:         0,
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantCacheHdr: "private, no-store, max-age=0",
+		},
+		{
+			name:         "временное перенаправление",
+			method:       http.MethodGet,
+			code:         http.StatusFound,
+			wantStatus:   http.StatusFound,
+			wantCacheHdr: "private, no-store, max-age=0",
+		},
+		{
+			name:         "постоянное перенаправление",
+			method:       http.MethodGet,
+			code:         http.StatusMovedPermanently,
+			wantStatus:   http.StatusMovedPermanently,
+			wantCacheHdr: "public, max-age=86400",
+		},
+		{
+			name:         "HEAD запрос",
+			method:       http.MethodHead,
+			code:         http.StatusPermanentRedirect,
+			wantStatus:   http.StatusPermanentRedirect,
+			wantCacheHdr: "public, max-age=86400",
+		},
+	}
+	for _, tt := range tests {
+		suite.mockStorage.On("RealURL", mock.Anything, "redirect").Return(model.StorageJSON{OriginalURL: "https://go.dev", LinkOptions: model.LinkOptions{RedirectCode: tt.code}}, nil).Once()
+		resp, err := cl.R().SetContext(ctx).Execute(tt.method, suite.ts.URL+"/redirect")
+		suite.ErrorIs(err, resty.ErrAutoRedirectDisabled, tt.name)
+		suite.EqualValues(tt.wantStatus, resp.StatusCode(), tt.name)
+		suite.EqualValues("https://go.dev", resp.Header().Get("Location"), tt.name)
+		suite.EqualValues(tt.wantCacheHdr, resp.Header().Get("Cache-Control"), tt.name)
+		suite.NotEmpty(resp.Header().Get("Expires"), tt.name)
+	}
+
+	// недопустимый код при сокращении
+	resp, err := cl.R().SetContext(ctx).SetHeader("Content-type", "application/json").SetBody(model.RequestShortURL{URL: "https://go.dev", LinkOptions: model.LinkOptions{RedirectCode: http.StatusOK}}).Post(suite.ts.URL + "/api/shorten")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+}
+
+func (suite *AppSuite) TestPassthrough() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	cl := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+
+	tests := []struct {
+		name         string
+		original     string
+		passthrough  bool
+		path         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{
+			name:         "путь и параметры",
+			original:     "https://site.example/base?lang=ru",
+			passthrough:  true,
+			path:         "/docs/page?ref=x",
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://site.example/base/docs/page?lang=ru&ref=x",
+		},
+		{
+			name:         "только параметры",
+			original:     "https://site.example/",
+			passthrough:  true,
+			path:         "?utm_source=mail",
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://site.example/?utm_source=mail",
+		},
+		{
+			name:         "выход за пределы пути",
+			original:     "https://site.example/docs/",
+			passthrough:  true,
+			path:         "/../../admin/",
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://site.example/docs/admin/",
+		},
+		{
+			name:         "передача отключена - параметры игнорируются",
+			original:     "https://site.example/base",
+			passthrough:  false,
+			path:         "?ref=x",
+			wantStatus:   http.StatusTemporaryRedirect,
+			wantLocation: "https://site.example/base",
+		},
+		{
+			name:        "передача отключена - вложенный путь не существует",
+			original:    "https://site.example/base",
+			passthrough: false,
+			path:        "/docs",
+			wantStatus:  http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		suite.mockStorage.On("RealURL", mock.Anything, "pass").Return(model.StorageJSON{OriginalURL: tt.original, LinkOptions: model.LinkOptions{Passthrough: tt.passthrough}}, nil).Once()
+		resp, err := cl.R().SetContext(ctx).Get(suite.ts.URL + "/pass" + tt.path)
+		if tt.wantLocation != "" {
+			suite.ErrorIs(err, resty.ErrAutoRedirectDisabled, tt.name)
+		}
+		suite.EqualValues(tt.wantStatus, resp.StatusCode(), tt.name)
+		suite.EqualValues(tt.wantLocation, resp.Header().Get("Location"), tt.name)
+	}
+}
+
+func (suite *AppSuite) TestQRCode() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	cl := resty.New()
+
+	// PNG по умолчанию
+	suite.mockStorage.On("RealURL", mock.Anything, "qr").Return(model.StorageJSON{OriginalURL: "https://go.dev"}, nil).Once()
+	resp, err := cl.R().SetContext(ctx).Get(suite.ts.URL + "/api/qr/qr")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.EqualValues("image/png", resp.Header().Get("Content-Type"))
+	_, err = png.Decode(bytes.NewReader(resp.Body()))
+	suite.NoError(err)
+
+	// SVG с параметрами
+	suite.mockStorage.On("RealURL", mock.Anything, "qr").Return(model.StorageJSON{OriginalURL: "https://go.dev"}, nil).Once()
+	resp, err = cl.R().SetContext(ctx).SetQueryParams(map[string]string{"format": "svg", "size": "512", "level": "H", "margin": "1", "fg": "0000ff"}).Get(suite.ts.URL + "/api/qr/qr")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.EqualValues("image/svg+xml", resp.Header().Get("Content-Type"))
+	suite.Contains(resp.String(), `fill="#0000ff"`)
+
+	// неверные параметры
+	resp, err = cl.R().SetContext(ctx).SetQueryParam("size", "1").Get(suite.ts.URL + "/api/qr/qr")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+
+	// нет такой ссылки
+	suite.mockStorage.On("RealURL", mock.Anything, "notqr").Return(model.StorageJSON{}, storage.ErrURLNotFound).Once()
+	resp, err = cl.R().SetContext(ctx).Get(suite.ts.URL + "/api/qr/notqr")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+
+	// заблокированная ссылка
+	suite.mockStorage.On("RealURL", mock.Anything, "blocked").Return(model.StorageJSON{OriginalURL: "https://go.dev", IsBlocked: true}, nil).Once()
+	resp, err = cl.R().SetContext(ctx).Get(suite.ts.URL + "/api/qr/blocked")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+
+	// путь /qr ссылки с передачей пути перенаправляется, а не отдает QR код
+	suite.mockStorage.On("RealURL", mock.Anything, "pass").Return(model.StorageJSON{OriginalURL: "https://go.dev", LinkOptions: model.LinkOptions{Passthrough: true}}, nil).Once()
+	resp, err = resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R().SetContext(ctx).Get(suite.ts.URL + "/pass/qr")
+	suite.ErrorIs(err, resty.ErrAutoRedirectDisabled)
+	suite.EqualValues(http.StatusTemporaryRedirect, resp.StatusCode())
+	suite.EqualValues("https://go.dev/qr", resp.Header().Get("Location"))
+
+	// архив QR кодов пользователя
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	suite.mockStorage.On("UserURLs", mock.Anything, userID).Return([]model.StorageJSON{
+		{ShortURL: "one", OriginalURL: "https://go.dev"},
+		{ShortURL: "two", OriginalURL: "https://pkg.go.dev"},
+		{ShortURL: "deleted", OriginalURL: "https://old.example", IsDeleted: true},
+	}, nil).Once()
+	resp, err = cl.R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: token}).Get(suite.ts.URL + "/api/user/urls/qr")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	suite.EqualValues("application/zip", resp.Header().Get("Content-Type"))
+	archive, err := zip.NewReader(bytes.NewReader(resp.Body()), int64(len(resp.Body())))
+	suite.Require().NoError(err)
+	names := make([]string, 0, len(archive.File))
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	suite.ElementsMatch([]string{"one.png", "two.png"}, names)
+}
+
+func (suite *AppSuite) TestErrorEnvelope() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	cl := resty.New().SetRedirectPolicy(resty.NoRedirectPolicy())
+
+	tests := []struct {
+		name       string
+		accept     string
+		call       func(req *resty.Request) (*resty.Response, error)
+		mockFunc   func()
+		wantStatus int
+		wantCode   apierror.Code
+		wantJSON   bool
+	}{
+		{
+			name:   "невалидная ссылка",
+			accept: "application/json",
+			call: func(req *resty.Request) (*resty.Response, error) {
+				return req.SetHeader("Content-type", "application/json").SetBody(model.RequestShortURL{URL: "not url"}).Post(suite.ts.URL + "/api/shorten")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.CodeInvalidURL,
+			wantJSON:   true,
+		},
+		{
+			name:   "ссылка не найдена",
+			accept: "text/html, application/json;q=0.9",
+			call: func(req *resty.Request) (*resty.Response, error) {
+				return req.Get(suite.ts.URL + "/envelope")
+			},
+			mockFunc: func() {
+				suite.mockStorage.On("RealURL", mock.Anything, "envelope").Return(model.StorageJSON{}, storage.ErrURLNotFound).Once()
+			},
+			wantStatus: http.StatusNotFound,
+			wantCode:   apierror.CodeURLNotFound,
+			wantJSON:   true,
+		},
+		{
+			name:   "внутренняя ошибка без подробностей",
+			accept: "application/json",
+			call: func(req *resty.Request) (*resty.Response, error) {
+				return req.Get(suite.ts.URL + "/envelope")
+			},
+			mockFunc: func() {
+				suite.mockStorage.On("RealURL", mock.Anything, "envelope").Return(model.StorageJSON{}, errors.New("password=secret")).Once()
+			},
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   apierror.CodeInternal,
+			wantJSON:   true,
+		},
+		{
+			name:   "неверный тип контента",
+			accept: "application/json",
+			call: func(req *resty.Request) (*resty.Response, error) {
+				return req.SetHeader("Content-type", "text/plain").SetBody("https://go.dev").Post(suite.ts.URL + "/api/shorten")
+			},
+			wantStatus: http.StatusUnsupportedMediaType,
+			wantCode:   apierror.CodeUnsupportedMedia,
+			wantJSON:   true,
+		},
+		{
+			name:   "без Accept - текст",
+			accept: "",
+			call: func(req *resty.Request) (*resty.Response, error) {
+				return req.SetHeader("Content-type", "text/plain").SetBody("not url").Post(suite.ts.URL + "/")
+			},
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.CodeInvalidURL,
+			wantJSON:   false,
+		},
+	}
+	for _, tt := range tests {
+		if tt.mockFunc != nil {
+			tt.mockFunc()
+		}
+		req := cl.R().SetContext(ctx)
+		if tt.accept != "" {
+			req.SetHeader("Accept", tt.accept)
+		}
+		resp, err := tt.call(req)
+		suite.Require().NoError(err, tt.name)
+		suite.EqualValues(tt.wantStatus, resp.StatusCode(), tt.name)
+		suite.EqualValues(tt.wantCode, resp.Header().Get("X-Error-Code"), tt.name)
+		if !tt.wantJSON {
+			suite.Contains(resp.Header().Get("Content-Type"), "text/plain", tt.name)
+			suite.Contains(resp.String(), tt.wantCode.Message(), tt.name)
+			continue
+		}
+		suite.Contains(resp.Header().Get("Content-Type"), "application/json", tt.name)
+		body := apierror.Error{}
+		suite.Require().NoError(json.Unmarshal(resp.Body(), &body), tt.name)
+		suite.EqualValues(tt.wantCode, body.Code, tt.name)
+		suite.EqualValues(tt.wantCode.Message(), body.Message, tt.name)
+		suite.NotContains(resp.String(), "secret", tt.name)
+	}
+}
+
+func (suite *AppSuite) TestUpdateUserURL() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	cl := resty.New()
+	interstitial := true
+
+	// без авторизации
+	resp, err := cl.R().SetContext(ctx).SetHeader("Content-type", "application/json").SetBody(model.UpdateLinkRequest{Interstitial: &interstitial}).Patch(suite.ts.URL + "/api/user/urls/short")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+
+	// изменяется только переданная настройка
+	suite.mockStorage.On("RealURL", mock.Anything, "short").Return(model.StorageJSON{OriginalURL: "https://go.dev", LinkOptions: model.LinkOptions{Title: "Go"}}, nil).Once()
+	suite.mockStorage.On("UpdateURLOptions", mock.Anything, userID, "short", model.LinkOptions{Title: "Go", Interstitial: true}).Return(nil).Once()
+	resp, err = cl.R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: token}).SetHeader("Content-type", "application/json").SetBody(model.UpdateLinkRequest{Interstitial: &interstitial}).Patch(suite.ts.URL + "/api/user/urls/short")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNoContent, resp.StatusCode())
+
+	// ссылка другого пользователя
+	suite.mockStorage.On("RealURL", mock.Anything, "alien").Return(model.StorageJSON{OriginalURL: "https://go.dev"}, nil).Once()
+	suite.mockStorage.On("UpdateURLOptions", mock.Anything, userID, "alien", model.LinkOptions{Interstitial: true}).Return(storage.ErrURLNotFound).Once()
+	resp, err = cl.R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: token}).SetHeader("Content-type", "application/json").SetBody(model.UpdateLinkRequest{Interstitial: &interstitial}).Patch(suite.ts.URL + "/api/user/urls/alien")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+
+	// недопустимый код перенаправления
+	code := http.StatusOK
+	suite.mockStorage.On("RealURL", mock.Anything, "short").Return(model.StorageJSON{OriginalURL: "https://go.dev"}, nil).Once()
+	resp, err = cl.R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: token}).SetHeader("Content-type", "application/json").SetBody(model.UpdateLinkRequest{RedirectCode: &code}).Patch(suite.ts.URL + "/api/user/urls/short")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+}
+
+func (suite *AppSuite) TestWebhooks() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	// получатель событий
+	received := make(chan model.Event, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := model.Event{}
+		suite.NoError(json.NewDecoder(r.Body).Decode(&e))
+		suite.Equal(string(e.Type), r.Header.Get(webhook.HeaderEvent))
+		received <- e
+	}))
+	defer receiver.Close()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	hooks := webhook.New(store, webhook.Options{PollInterval: 10 * time.Millisecond, AllowPrivate: true})
+	bus := events.NewBus(store, events.BusOptions{})
+	bus.Attach(hooks)
+	srv, err := NewServer(config.DefaultConfig, slog.Default(), WithWebhooks(hooks), WithEvents(bus))
+	suite.Require().NoError(err)
+	srv.db = events.WithEvents(store, bus)
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+	hooksCtx, stopHooks := context.WithCancel(ctx)
+	defer stopHooks()
+	go bus.Run(hooksCtx)
+	go hooks.Run(hooksCtx)
+
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	request := func() *resty.Request {
+		return resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: token})
+	}
+
+	resp, err := request().Get(ts.URL + "/api/user/webhooks")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNoContent, resp.StatusCode())
+
+	resp, err = request().SetHeader("Content-Type", "application/json").SetBody(model.WebhookRequest{URL: "/hook"}).Post(ts.URL + "/api/user/webhooks")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+	suite.Equal(string(apierror.CodeInvalidWebhook), resp.Header().Get("X-Error-Code"))
+
+	resp, err = request().SetHeader("Content-Type", "application/json").SetBody(model.WebhookRequest{
+		URL:            receiver.URL,
+		Events:         []model.EventType{model.EventLinkCreated, model.EventClickThreshold},
+		ClickThreshold: 1,
+	}).Post(ts.URL + "/api/user/webhooks")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	hook := model.Webhook{}
+	suite.Require().NoError(json.Unmarshal(resp.Body(), &hook))
+	suite.NotEmpty(hook.Secret)
+	suite.Equal("/api/user/webhooks/"+hook.ID.String(), resp.Header().Get("Location"))
+
+	// создание ссылки и первый переход по ней
+	resp, err = request().SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	short := strings.TrimPrefix(string(resp.Body()), config.DefaultConfig.BaseAddress())
+	resp, err = request().Get(ts.URL + "/" + short)
+	suite.ErrorIs(err, resty.ErrAutoRedirectDisabled)
+	suite.EqualValues(http.StatusTemporaryRedirect, resp.StatusCode())
+
+	got := map[model.EventType]model.Event{}
+	for len(got) < 2 {
+		select {
+		case e := <-received:
+			got[e.Type] = e
+		case <-ctx.Done():
+			suite.FailNow("события не доставлены")
+		}
+	}
+	suite.Equal(short, got[model.EventLinkCreated].ShortURL)
+	suite.Equal("https://go.dev", got[model.EventLinkCreated].OriginalURL)
+	suite.EqualValues(1, got[model.EventClickThreshold].Clicks)
+
+	// журнал доставок
+	var deliveries []model.WebhookDelivery
+	suite.Eventually(func() bool {
+		resp, err = request().SetQueryParam("status", string(model.DeliveryDelivered)).Get(ts.URL + "/api/user/webhooks/" + hook.ID.String() + "/deliveries")
+		suite.Require().NoError(err)
+		suite.Require().NoError(json.Unmarshal(resp.Body(), &deliveries))
+		return len(deliveries) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	resp, err = request().SetQueryParam("status", "unknown").Get(ts.URL + "/api/user/webhooks/" + hook.ID.String() + "/deliveries")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+
+	// повторная отправка
+	resp, err = request().Post(ts.URL + "/api/user/webhooks/" + hook.ID.String() + "/deliveries/" + deliveries[0].ID.String() + "/redeliver")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusAccepted, resp.StatusCode())
+	select {
+	case e := <-received:
+		suite.Equal(deliveries[0].Event.ID, e.ID)
+	case <-ctx.Done():
+		suite.FailNow("повторная доставка не выполнена")
+	}
+	resp, err = request().Post(ts.URL + "/api/user/webhooks/" + hook.ID.String() + "/deliveries/" + uuid.NewString() + "/redeliver")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+	suite.Equal(string(apierror.CodeDeliveryNotFound), resp.Header().Get("X-Error-Code"))
+
+	// список без ключей подписи и удаление
+	resp, err = request().Get(ts.URL + "/api/user/webhooks")
+	suite.Require().NoError(err)
+	var list []model.Webhook
+	suite.Require().NoError(json.Unmarshal(resp.Body(), &list))
+	suite.Require().Len(list, 1)
+	suite.Empty(list[0].Secret)
+	resp, err = request().Delete(ts.URL + "/api/user/webhooks/" + hook.ID.String())
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNoContent, resp.StatusCode())
+	resp, err = request().Delete(ts.URL + "/api/user/webhooks/" + hook.ID.String())
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+	suite.Equal(string(apierror.CodeWebhookNotFound), resp.Header().Get("X-Error-Code"))
+}
+
+func (suite *AppSuite) TestUserEvents() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	bus := events.NewBus(store, events.BusOptions{})
+	srv, err := NewServer(config.DefaultConfig, slog.Default(), WithEvents(bus))
+	suite.Require().NoError(err)
+	srv.db = events.WithEvents(store, bus)
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+	busCtx, stopBus := context.WithCancel(ctx)
+	defer stopBus()
+	go bus.Run(busCtx)
+
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), config.DefaultConfig.SecretKey()), config.DefaultConfig.JWTLifetime())
+	suite.Require().NoError(err)
+	cookie := &http.Cookie{Name: authCookie, Value: token}
+
+	// без авторизации
+	resp, err := resty.New().R().SetContext(ctx).Get(ts.URL + "/api/user/events")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+
+	// SSE
+	streamCtx, closeStream := context.WithCancel(ctx)
+	defer closeStream()
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, ts.URL+"/api/user/events", nil)
+	suite.Require().NoError(err)
+	req.AddCookie(cookie)
+	stream, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer stream.Body.Close()
+	suite.Require().EqualValues(http.StatusOK, stream.StatusCode)
+	suite.Equal("text/event-stream", stream.Header.Get("Content-Type"))
+
+	// WebSocket
+	wsConfig, err := websocket.NewConfig("ws"+strings.TrimPrefix(ts.URL, "http")+"/api/user/events/ws", ts.URL)
+	suite.Require().NoError(err)
+	wsConfig.Header.Set("Cookie", cookie.String())
+	ws, err := websocket.DialConfig(wsConfig)
+	suite.Require().NoError(err)
+	defer ws.Close()
+	// чужая страница не может подключиться с cookie пользователя
+	foreign, err := websocket.NewConfig(wsConfig.Location.String(), "http://example.com")
+	suite.Require().NoError(err)
+	foreign.Header = wsConfig.Header
+	_, err = websocket.DialConfig(foreign)
+	suite.Error(err)
+
+	// события чужих ссылок пользователю не передаются
+	bus.Publish(events.New(model.EventLinkDeleted, uuid.New(), "other", ""))
+	request := func() *resty.Request {
+		return resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R().SetContext(ctx).SetCookie(cookie)
+	}
+	resp, err = request().SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	short := strings.TrimPrefix(string(resp.Body()), config.DefaultConfig.BaseAddress())
+	_, err = request().Get(ts.URL + "/" + short)
+	suite.ErrorIs(err, resty.ErrAutoRedirectDisabled)
+
+	// события SSE
+	reader := bufio.NewReader(stream.Body)
+	readEvent := func() (string, model.Event) {
+		var name string
+		e := model.Event{}
+		for {
+			line, err := reader.ReadString('\n')
+			suite.Require().NoError(err)
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				suite.Require().NoError(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e))
+			case line == "" && name != "":
+				return name, e
+			}
+		}
+	}
+	name, e := readEvent()
+	suite.Equal(string(model.EventLinkCreated), name)
+	suite.Equal(short, e.ShortURL)
+	name, e = readEvent()
+	suite.Equal(string(model.EventLinkClicked), name)
+	suite.EqualValues(1, e.Clicks)
+
+	// события WebSocket
+	suite.Require().NoError(ws.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	suite.Require().NoError(websocket.JSON.Receive(ws, &e))
+	suite.Equal(model.EventLinkCreated, e.Type)
+	suite.Require().NoError(websocket.JSON.Receive(ws, &e))
+	suite.Equal(model.EventLinkClicked, e.Type)
+	suite.Equal(short, e.ShortURL)
+
+	// потоки завершаются при остановке сервера
+	closeStream()
+	suite.Require().NoError(srv.server.Shutdown(ctx))
+	_, err = ws.Read(make([]byte, 1))
+	suite.Error(err)
+}
+
+func (suite *AppSuite) TestAdmin() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	os.Setenv("TRUSTED_SUBNET", "192.168.1.0/24")
+	defer os.Unsetenv("TRUSTED_SUBNET")
+	os.Setenv("TRUSTED_PROXIES", "127.0.0.1,::1")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	cfg, err := config.ParseConfig(slog.Default())
+	suite.Require().NoError(err)
+	mem, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	store := admin.WithUserBlocks(mem)
+
+	// операции администраторов не настроены
+	srvNoAdmin, err := NewServer(cfg, slog.Default())
+	suite.Require().NoError(err)
+	srvNoAdmin.db = store
+	srvNoAdmin.setRoute()
+	tsNoAdmin := httptest.NewServer(srvNoAdmin.server.Handler)
+	defer tsNoAdmin.Close()
+	resp, err := resty.New().R().SetContext(ctx).SetHeader("X-Real-IP", "192.168.1.10").Get(tsNoAdmin.URL + "/api/internal/audit")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusServiceUnavailable, resp.StatusCode())
+
+	srv, err := NewServer(cfg, slog.Default(), WithAdmin(admin.New(store, 0, nil)))
+	suite.Require().NoError(err)
+	srv.db = store
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	userID := uuid.New()
+	token, err := buildJWTString(userID, testKeys(suite.T(), cfg.SecretKey()), cfg.JWTLifetime())
+	suite.Require().NoError(err)
+	user := func() *resty.Request {
+		return resty.New().SetRedirectPolicy(resty.NoRedirectPolicy()).R().SetContext(ctx).SetCookie(&http.Cookie{Name: authCookie, Value: token})
+	}
+	adminReq := func() *resty.Request {
+		return resty.New().R().SetContext(ctx).SetHeader("X-Real-IP", "192.168.1.10")
+	}
+
+	resp, err = user().SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	short := filepath.Base(resp.String())
+
+	// запросы не из доверенной подсети
+	resp, err = resty.New().R().SetContext(ctx).SetHeader("X-Real-IP", "10.0.0.1").Get(ts.URL + "/api/internal/links/" + short)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+
+	link := model.StorageJSONWithUserID{}
+	resp, err = adminReq().SetResult(&link).Get(ts.URL + "/api/internal/links/" + short)
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Equal(userID.String(), link.UserID)
+	suite.Equal("https://go.dev", link.OriginalURL)
+	resp, err = adminReq().Get(ts.URL + "/api/internal/links/missing")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+
+	// отключение и включение ссылки
+	resp, err = adminReq().Post(ts.URL + "/api/internal/links/" + short + "/disable")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	resp, err = user().Get(ts.URL + "/" + short)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode(), "отключенная ссылка ведет себя как заблокированная")
+	resp, err = adminReq().Post(ts.URL + "/api/internal/links/" + short + "/enable")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+	resp, _ = user().Get(ts.URL + "/" + short)
+	suite.EqualValues(http.StatusTemporaryRedirect, resp.StatusCode())
+
+	var links []model.StorageJSON
+	resp, err = adminReq().SetResult(&links).Get(ts.URL + "/api/internal/users/" + userID.String() + "/links")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Len(links, 1)
+	resp, err = adminReq().Get(ts.URL + "/api/internal/users/bad/links")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+
+	// запрет создания ссылок
+	resp, err = adminReq().SetHeader("Content-Type", "application/json").SetBody(model.UserBlockRequest{Reason: "спам"}).Post(ts.URL + "/api/internal/users/" + userID.String() + "/block")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNoContent, resp.StatusCode())
+	resp, err = user().SetHeader("Content-Type", "text/plain").SetBody("https://example.com").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+	suite.Equal(string(apierror.CodeUserBlocked), resp.Header().Get("X-Error-Code"))
+	resp, err = user().SetHeader("Content-Type", "application/json").SetBody(model.BatchRequest{{CorrelationID: "1", OriginalURL: "https://example.com"}}).Post(ts.URL + "/api/shorten/batch")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+	suite.Equal(string(apierror.CodeUserBlocked), resp.Header().Get("X-Error-Code"))
+	resp, err = adminReq().Delete(ts.URL + "/api/internal/users/" + userID.String() + "/block")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNoContent, resp.StatusCode())
+	resp, err = user().SetHeader("Content-Type", "text/plain").SetBody("https://example.com").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusCreated, resp.StatusCode())
+
+	// окончательное удаление
+	purged := model.PurgeResponse{}
+	resp, err = adminReq().SetHeader("Content-Type", "application/json").SetBody([]string{short, "missing"}).SetResult(&purged).Post(ts.URL + "/api/internal/purge")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Equal([]string{short}, purged.Purged)
+	suite.Equal([]string{"missing"}, purged.NotFound)
+	resp, err = user().Get(ts.URL + "/" + short)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+	resp, err = adminReq().SetHeader("Content-Type", "application/json").SetBody([]string{}).Post(ts.URL + "/api/internal/purge")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+
+	// журнал действий
+	var records []model.AuditRecord
+	resp, err = adminReq().SetResult(&records).Get(ts.URL + "/api/internal/audit?limit=2")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Require().Len(records, 2)
+	suite.Equal(model.AuditLinksPurge, records[0].Action)
+	suite.Equal(string(apierror.CodeEmptyRequest), records[0].Result)
+	suite.Equal("http 192.168.1.10", records[1].Actor)
+	suite.Equal("purged=1", records[1].Details)
+	resp, err = adminReq().Get(ts.URL + "/api/internal/audit?limit=0")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+}
+
+func TestAppSuite(t *testing.T) {
+	suite.Run(t, new(AppSuite))
+}
+
+func (suite *AppSuite) TestAccounts() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	cfg := config.DefaultConfig
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	srv, err := NewServer(cfg, slog.Default(), WithAccounts(account.New(store, nil)))
+	suite.Require().NoError(err)
+	srv.db = store
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	// учетные записи не настроены
+	srvNoAccounts, err := NewServer(cfg, slog.Default())
+	suite.Require().NoError(err)
+	srvNoAccounts.db = store
+	srvNoAccounts.setRoute()
+	tsNoAccounts := httptest.NewServer(srvNoAccounts.server.Handler)
+	defer tsNoAccounts.Close()
+	resp, err := resty.New().R().SetContext(ctx).SetHeader("Content-Type", "application/json").SetBody(model.Credentials{Login: "alice", Password: "password"}).Post(tsNoAccounts.URL + "/api/user/register")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusServiceUnavailable, resp.StatusCode())
+
+	// клиенты с cookie, как браузеры
+	browser, other := resty.New(), resty.New()
+	shorten := func(c *resty.Client, url string) {
+		resp, err := c.R().SetContext(ctx).SetHeader("Content-Type", "text/plain").SetBody(url).Post(ts.URL + "/")
+		suite.Require().NoError(err)
+		suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	}
+	userURLs := func(c *resty.Client) int {
+		var links []model.StorageJSON
+		_, err := c.R().SetContext(ctx).SetResult(&links).Get(ts.URL + "/api/user/urls")
+		suite.Require().NoError(err)
+		return len(links)
+	}
+	auth := func(c *resty.Client, path string, creds model.Credentials, result any) *resty.Response {
+		resp, err := c.R().SetContext(ctx).SetHeader("Content-Type", "application/json").SetBody(creds).SetResult(result).Post(ts.URL + path)
+		suite.Require().NoError(err)
+		return resp
+	}
+	token := func(c *resty.Client) string {
+		u, _ := url.Parse(ts.URL)
+		for _, cookie := range c.GetClient().Jar.Cookies(u) {
+			if cookie.Name == authCookie {
+				return cookie.Value
+			}
+		}
+		return ""
+	}
+
+	// регистрация сохраняет ссылки текущего пользователя
+	shorten(browser, "https://go.dev")
+	anonymous, err := getUserIDFromToken(token(browser), testKeys(suite.T(), cfg.SecretKey()))
+	suite.Require().NoError(err)
+	registered := model.Account{}
+	resp = auth(browser, "/api/user/register", model.Credentials{Login: "Alice", Password: "password"}, &registered)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	suite.Equal(anonymous, registered.ID)
+	suite.Equal("alice", registered.Login)
+	suite.NotContains(resp.String(), "password")
+	suite.Len(resp.Header().Values("Set-Cookie"), 1)
+	suite.Equal(1, userURLs(browser))
+
+	resp = auth(other, "/api/user/register", model.Credentials{Login: "alice", Password: "password"}, nil)
+	suite.EqualValues(http.StatusConflict, resp.StatusCode())
+	suite.Equal(string(apierror.CodeAccountExists), resp.Header().Get("X-Error-Code"))
+	resp = auth(other, "/api/user/register", model.Credentials{Login: "bob", Password: "short"}, nil)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+	suite.Equal(string(apierror.CodeInvalidAccount), resp.Header().Get("X-Error-Code"))
+
+	// после выхода пользователь анонимный, при входе его новые ссылки переносятся в учетную запись
+	resp, err = browser.R().SetContext(ctx).Post(ts.URL + "/api/user/logout")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNoContent, resp.StatusCode())
+	shorten(browser, "https://go.dev/doc")
+	suite.Equal(1, userURLs(browser))
+	resp = auth(browser, "/api/user/login", model.Credentials{Login: "alice", Password: "wrong-password"}, nil)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	suite.Equal(string(apierror.CodeInvalidCredentials), resp.Header().Get("X-Error-Code"))
+	login := model.LoginResponse{}
+	resp = auth(browser, "/api/user/login", model.Credentials{Login: "alice", Password: "password"}, &login)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Equal(registered.ID, login.ID)
+	suite.Equal(1, login.Claimed)
+	suite.Equal(2, userURLs(browser))
+
+	// перенос ссылок другого браузера по действующему токену
+	shorten(other, "https://example.com")
+	otherID, err := getUserIDFromToken(token(other), testKeys(suite.T(), cfg.SecretKey()))
+	suite.Require().NoError(err)
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour))},
+		UserID:           otherID,
+	}).SignedString([]byte(cfg.SecretKey()))
+	suite.Require().NoError(err)
+	endless, err := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{UserID: otherID}).SignedString([]byte(cfg.SecretKey()))
+	suite.Require().NoError(err)
+	forged, err := buildJWTString(otherID, testKeys(suite.T(), "другой ключ"), cfg.JWTLifetime())
+	suite.Require().NoError(err)
+	claim := func(c *resty.Client, token string, result any) *resty.Response {
+		resp, err := c.R().SetContext(ctx).SetHeader("Content-Type", "application/json").SetBody(model.ClaimRequest{Token: token}).SetResult(result).Post(ts.URL + "/api/user/claim")
+		suite.Require().NoError(err)
+		return resp
+	}
+	resp = claim(browser, forged, nil)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+	resp = claim(other, token(browser), nil)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode(), "анонимный пользователь не может переносить ссылки")
+	// истекший токен и токен без срока действия не подходят, даже с верной подписью
+	resp = claim(browser, expired, nil)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+	resp = claim(browser, endless, nil)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+	suite.Equal(2, userURLs(browser))
+	claimed := model.ClaimResponse{}
+	resp = claim(browser, token(other), &claimed)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Equal(model.ClaimResponse{From: otherID, Claimed: 1}, claimed)
+	suite.Equal(3, userURLs(browser))
+	suite.Equal(0, userURLs(other))
+
+	// ссылки другой учетной записи перенести нельзя
+	resp = auth(other, "/api/user/register", model.Credentials{Login: "bob", Password: "password"}, nil)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	resp = claim(browser, token(other), nil)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+}
+
+func (suite *AppSuite) TestAPITokens() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	cfg := config.DefaultConfig
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	tokens := apitoken.New(store, nil)
+	srv, err := NewServer(cfg, slog.Default(), WithAccounts(account.New(store, nil)), WithAPITokens(tokens))
+	suite.Require().NoError(err)
+	srv.db = store
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	browser := resty.New()
+	createToken := func(req model.APITokenRequest, result any) *resty.Response {
+		resp, err := browser.R().SetContext(ctx).SetHeader("Content-Type", "application/json").SetBody(req).SetResult(result).Post(ts.URL + "/api/user/tokens")
+		suite.Require().NoError(err)
+		return resp
+	}
+
+	// токены выдаются только учетной записи
+	resp := createToken(model.APITokenRequest{Name: "ci", Scopes: []model.Scope{model.ScopeLinksRead}}, nil)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	resp, err = browser.R().SetContext(ctx).SetHeader("Content-Type", "application/json").SetBody(model.Credentials{Login: "alice", Password: "password"}).Post(ts.URL + "/api/user/register")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	registered := model.Account{}
+	suite.Require().NoError(json.Unmarshal(resp.Body(), &registered))
+
+	resp = createToken(model.APITokenRequest{Name: "ci", Scopes: []model.Scope{"admin"}}, nil)
+	suite.EqualValues(http.StatusBadRequest, resp.StatusCode())
+	suite.Equal(string(apierror.CodeInvalidAPIToken), resp.Header().Get("X-Error-Code"))
+	reader, writer := model.APIToken{}, model.APIToken{}
+	resp = createToken(model.APITokenRequest{Name: "reader", Scopes: []model.Scope{model.ScopeLinksRead}}, &reader)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	suite.Equal("/api/user/tokens/"+reader.ID.String(), resp.Header().Get("Location"))
+	resp = createToken(model.APITokenRequest{Name: "writer", Scopes: []model.Scope{model.ScopeLinksWrite, model.ScopeStatsRead}}, &writer)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+
+	withToken := func(value string) *resty.Request {
+		return resty.New().R().SetContext(ctx).SetAuthToken(value)
+	}
+
+	// ссылка создается от имени владельца токена без выдачи cookie
+	resp, err = withToken(writer.Token).SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	suite.Empty(resp.Header().Values("Set-Cookie"))
+	var links []model.StorageJSON
+	resp, err = withToken(reader.Token).SetResult(&links).Get(ts.URL + "/api/user/urls")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Len(links, 1)
+	links, err = store.UserURLs(ctx, registered.ID)
+	suite.Require().NoError(err)
+	suite.Len(links, 1)
+
+	// права токена
+	resp, err = withToken(reader.Token).SetHeader("Content-Type", "text/plain").SetBody("https://go.dev/doc").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+	suite.Equal(string(apierror.CodeInsufficientScope), resp.Header().Get("X-Error-Code"))
+	resp, err = withToken(writer.Token).SetHeader("Content-Type", "application/json").SetBody([]string{links[0].ShortURL}).Delete(ts.URL + "/api/user/urls")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+	resp, err = withToken(writer.Token).Get(ts.URL + "/api/internal/stats")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode(), "право stats:read не дает доступа к статистике не из доверенной подсети")
+
+	// из доверенной подсети статистика доступна только токену с правом stats:read
+	os.Setenv("TRUSTED_SUBNET", "127.0.0.0/8,::1/128")
+	defer os.Unsetenv("TRUSTED_SUBNET")
+	trustedCfg, err := config.ParseConfig(slog.Default())
+	suite.Require().NoError(err)
+	trusted, err := NewServer(trustedCfg, slog.Default(), WithAccounts(account.New(store, nil)), WithAPITokens(tokens))
+	suite.Require().NoError(err)
+	trusted.db = store
+	trusted.setRoute()
+	tsTrusted := httptest.NewServer(trusted.server.Handler)
+	defer tsTrusted.Close()
+	resp, err = withToken(reader.Token).Get(tsTrusted.URL + "/api/internal/stats")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+	suite.Equal(string(apierror.CodeInsufficientScope), resp.Header().Get("X-Error-Code"))
+	resp, err = withToken(writer.Token).Get(tsTrusted.URL + "/api/internal/stats")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusOK, resp.StatusCode())
+
+	// токенами и подписками нельзя управлять по токену
+	resp, err = withToken(writer.Token).Get(ts.URL + "/api/user/tokens")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+	resp, err = withToken(writer.Token).Get(ts.URL + "/api/user/webhooks")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+
+	// в списке токенов нет значений
+	var list []model.APIToken
+	resp, err = browser.R().SetContext(ctx).SetResult(&list).Get(ts.URL + "/api/user/tokens")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Require().Len(list, 2)
+	suite.NotContains(resp.String(), reader.Token)
+
+	// неизвестный, просроченный и отозванный токены
+	resp, err = withToken(apitoken.Prefix + "unknown").Get(ts.URL + "/api/user/urls")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	suite.Contains(resp.Header().Get("WWW-Authenticate"), "invalid_token")
+	expired := apitoken.Prefix + "expired"
+	past := time.Now().Add(-time.Minute)
+	suite.Require().NoError(store.SaveAPIToken(ctx, model.APIToken{ID: uuid.New(), UserID: registered.ID, Name: "old", Scopes: []model.Scope{model.ScopeLinksRead}, Hash: apitoken.Hash(expired), ExpiresAt: &past}))
+	resp, err = withToken(expired).Get(ts.URL + "/api/user/urls")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+
+	resp, err = browser.R().SetContext(ctx).Delete(ts.URL + "/api/user/tokens/" + uuid.New().String())
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusNotFound, resp.StatusCode())
+	resp, err = browser.R().SetContext(ctx).Delete(ts.URL + "/api/user/tokens/" + reader.ID.String())
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusNoContent, resp.StatusCode())
+	resp, err = withToken(reader.Token).Get(ts.URL + "/api/user/urls")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+}
+
+func (suite *AppSuite) TestOIDC() {
+	ctx, cancel := context.WithTimeout(context.Background(), waitCtxTest)
+	defer cancel()
+
+	issuer, err := oidctest.New("shortener")
+	suite.Require().NoError(err)
+	defer issuer.Close()
+	cfg := config.DefaultConfig
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	srv, err := NewServer(cfg, slog.Default(), WithAccounts(account.New(store, nil)))
+	suite.Require().NoError(err)
+	srv.db = store
+	srv.setRoute()
+	ts := httptest.NewServer(srv.server.Handler)
+	defer ts.Close()
+
+	// вход через провайдера не настроен
+	resp, err := resty.New().R().SetContext(ctx).Get(ts.URL + "/api/user/oidc/login")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusServiceUnavailable, resp.StatusCode())
+
+	provider, err := oidc.New(oidc.Options{
+		Issuer:         issuer.URL,
+		ClientID:       issuer.ClientID,
+		RedirectURL:    ts.URL + "/api/user/oidc/callback",
+		AllowedDomains: []string{"example.com"},
+	})
+	suite.Require().NoError(err)
+	srv.oidc = provider
+
+	// клиенты с cookie, как браузеры. перенаправления к провайдеру и обратно выполняются клиентом
+	login := func(c *resty.Client) (model.LoginResponse, *resty.Response) {
+		result := model.LoginResponse{}
+		resp, err := c.R().SetContext(ctx).SetResult(&result).Get(ts.URL + "/api/user/oidc/login")
+		suite.Require().NoError(err)
+		return result, resp
+	}
+	userURLs := func(c *resty.Client) int {
+		var links []model.StorageJSON
+		_, err := c.R().SetContext(ctx).SetResult(&links).Get(ts.URL + "/api/user/urls")
+		suite.Require().NoError(err)
+		return len(links)
+	}
+
+	// первый вход переносит ссылки анонимной сессии в учетную запись сотрудника
+	browser := resty.New()
+	resp, err = browser.R().SetContext(ctx).SetHeader("Content-Type", "text/plain").SetBody("https://go.dev").Post(ts.URL + "/")
+	suite.Require().NoError(err)
+	suite.Require().EqualValues(http.StatusCreated, resp.StatusCode())
+	issuer.SetUser(oidctest.User{Subject: "42", Email: "alice@example.com", EmailVerified: true})
+	loggedIn, resp := login(browser)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode(), resp.String())
+	suite.Equal(account.SSOUserID(issuer.URL, "42"), loggedIn.ID)
+	suite.Equal("sso:42", loggedIn.Login)
+	suite.Equal(1, loggedIn.Claimed)
+	u, err := url.Parse(ts.URL + "/api/user/oidc/callback")
+	suite.Require().NoError(err)
+	for _, cookie := range browser.GetClient().Jar.Cookies(u) {
+		suite.NotEqual(oidcCookie, cookie.Name, "параметры входа удаляются после возврата")
+	}
+
+	// вход в другом браузере - та же учетная запись и ее ссылки
+	other := resty.New()
+	again, resp := login(other)
+	suite.Require().EqualValues(http.StatusOK, resp.StatusCode())
+	suite.Equal(loggedIn.ID, again.ID)
+	suite.Zero(again.Claimed)
+	suite.Equal(1, userURLs(other))
+
+	// почта неразрешенного домена
+	issuer.SetUser(oidctest.User{Subject: "43", Email: "eve@example.org", EmailVerified: true})
+	_, resp = login(resty.New())
+	suite.EqualValues(http.StatusForbidden, resp.StatusCode())
+
+	// возврат без начала входа и с чужим state
+	resp, err = resty.New().R().SetContext(ctx).Get(ts.URL + "/api/user/oidc/callback?code=abc&state=abc")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+	suite.Equal(string(apierror.CodeSSOFailed), resp.Header().Get("X-Error-Code"))
+	flow, err := oidc.NewFlow()
+	suite.Require().NoError(err)
+	resp, err = resty.New().R().SetContext(ctx).
+		SetCookie(&http.Cookie{Name: oidcCookie, Value: flow.String()}).
+		Get(ts.URL + "/api/user/oidc/callback?code=abc&state=abc")
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
+
+	// пользователь отказался от входа у провайдера
+	resp, err = resty.New().R().SetContext(ctx).
+		SetCookie(&http.Cookie{Name: oidcCookie, Value: flow.String()}).
+		Get(ts.URL + "/api/user/oidc/callback?error=access_denied&state=" + flow.State)
+	suite.Require().NoError(err)
+	suite.EqualValues(http.StatusUnauthorized, resp.StatusCode())
 }
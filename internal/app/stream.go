@@ -0,0 +1,168 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/events"
+	"golang.org/x/net/websocket"
+)
+
+// streamHeartbeat периодичность комментария, поддерживающего соединение потока событий открытым
+const streamHeartbeat = 15 * time.Second
+
+// WithEvents устанавливает шину событий ссылок, из которой пользователям передаются потоки событий
+func WithEvents(bus *events.Bus) Option {
+	return func(s *Server) {
+		s.events = bus
+	}
+}
+
+// streamUser проверяет, что потоки событий доступны, и возвращает авторизованного пользователя
+func (s *Server) streamUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
+		return uuid.UUID{}, false
+	}
+	if s.events == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return uuid.UUID{}, false
+	}
+	return userID, true
+}
+
+// userEvents поток событий ссылок пользователя (создание, удаление, переходы) в формате Server-Sent Events.
+// каждое событие передается с полями id (идентификатор события), event (тип) и data (model.Event в JSON)
+func (s *Server) userEvents(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.streamUser(w, r)
+	if !ok {
+		return
+	}
+	sub := s.events.Subscribe(userID, 0)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		s.logger.Error("открытие потока событий", slog.String("ошибка", err.Error()))
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.shutdown:
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				s.logger.Error("кодирование события", slog.String("ошибка", err.Error()))
+				continue
+			}
+			if _, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// userEventsWS поток событий ссылок пользователя через WebSocket. каждое событие передается
+// текстовым сообщением с model.Event в JSON. сообщения клиента не обрабатываются
+func (s *Server) userEventsWS(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.streamUser(w, r)
+	if !ok {
+		return
+	}
+	// подписка создается до установки соединения, чтобы клиент получил все события после подключения
+	sub := s.events.Subscribe(userID, 0)
+	defer sub.Close()
+	ws := websocket.Server{
+		Handshake: sameOrigin,
+		Handler: func(conn *websocket.Conn) {
+			s.streamWS(conn, sub)
+		},
+	}
+	ws.ServeHTTP(hijackWriter{ResponseWriter: w}, r)
+}
+
+// streamWS передает события подписки sub в соединение conn, пока оно не будет закрыто
+func (s *Server) streamWS(conn *websocket.Conn, sub *events.Subscription) {
+	// чтение нужно для обработки управляющих сообщений и обнаружения закрытия соединения клиентом
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var msg []byte
+		for {
+			if err := websocket.Message.Receive(conn, &msg); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-s.shutdown:
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := websocket.JSON.Send(conn, e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// sameOrigin разрешает подключение WebSocket без заголовка Origin или с Origin этого же сервера,
+// чтобы чужие страницы не могли читать события пользователя с его cookie
+func sameOrigin(cfg *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("разбор Origin. %w", err)
+	}
+	if u.Host != r.Host {
+		return fmt.Errorf("источник запроса %s не совпадает с адресом сервера", origin)
+	}
+	return nil
+}
+
+// hijackWriter позволяет перехватить соединение через обертки http.ResponseWriter,
+// поддерживающие http.ResponseController
+type hijackWriter struct {
+	http.ResponseWriter
+}
+
+// Hijack реализация http.Hijacker
+func (hw hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(hw.ResponseWriter).Hijack()
+}
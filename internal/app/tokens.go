@@ -0,0 +1,122 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/apitoken"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// WithAPITokens устанавливает персональные API токены, принимаемые в заголовке Authorization: Bearer
+func WithAPITokens(svc *apitoken.Service) Option {
+	return func(s *Server) {
+		s.tokens = svc
+	}
+}
+
+// requireScope пропускает запросы с API токеном, только если токен дает право scope. запросы с cookie не ограничиваются
+func (s *Server) requireScope(scope model.Scope) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := apitoken.Check(r.Context(), scope); err != nil {
+				s.writeError(w, r, err)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// withoutAPIToken запрещает запросы с API токеном: учетная запись, подписки и сами токены управляются только с cookie
+func (s *Server) withoutAPIToken(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := apitoken.Deny(r.Context()); err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// statsAccess пропускает к статистике только запросы из доверенной подсети. запросы с API токеном
+// дополнительно должны иметь право stats:read: токен ограничивает доступ, но не заменяет проверку подсети
+func (s *Server) statsAccess(h http.Handler) http.Handler {
+	return s.trustedSubnet(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := apitoken.Check(r.Context(), model.ScopeStatsRead); err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+}
+
+// tokenUser проверяет, что API токены доступны, и возвращает авторизованного пользователя
+func (s *Server) tokenUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
+		return uuid.UUID{}, false
+	}
+	if s.tokens == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return uuid.UUID{}, false
+	}
+	return userID, true
+}
+
+// createToken создает персональный API токен. значение токена возвращается только в этом ответе
+func (s *Server) createToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.tokenUser(w, r)
+	if !ok {
+		return
+	}
+	req := model.APITokenRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
+		return
+	}
+	token, err := s.tokens.Create(r.Context(), userID, req)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/api/user/tokens/"+token.ID.String())
+	s.writeJSON(w, r, http.StatusCreated, token)
+}
+
+// getTokens API токены пользователя без значений
+func (s *Server) getTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.tokenUser(w, r)
+	if !ok {
+		return
+	}
+	tokens, err := s.tokens.Tokens(r.Context(), userID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if len(tokens) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, tokens)
+}
+
+// revokeToken отзывает API токен пользователя
+func (s *Server) revokeToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.tokenUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := s.uuidParam(w, r, "id", apierror.CodeAPITokenNotFound)
+	if !ok {
+		return
+	}
+	if err := s.tokens.Revoke(r.Context(), userID, id); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
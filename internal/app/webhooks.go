@@ -0,0 +1,173 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/webhook"
+)
+
+const (
+	// defaultDeliveriesLimit количество записей журнала доставок по умолчанию, maxDeliveriesLimit - наибольшее
+	defaultDeliveriesLimit = 100
+	maxDeliveriesLimit     = 1000
+)
+
+// WithWebhooks устанавливает диспетчер подписок на события ссылок
+func WithWebhooks(d *webhook.Dispatcher) Option {
+	return func(s *Server) {
+		s.webhooks = d
+	}
+}
+
+// webhookUser проверяет, что подписки доступны, и возвращает авторизованного пользователя
+func (s *Server) webhookUser(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, ok := s.authorizedUserID(w, r)
+	if !ok {
+		return uuid.UUID{}, false
+	}
+	if s.webhooks == nil {
+		s.writeError(w, r, apierror.New(apierror.CodeUnavailable))
+		return uuid.UUID{}, false
+	}
+	return userID, true
+}
+
+// createWebhook создает подписку пользователя на события его ссылок. ключ подписи возвращается только в этом ответе
+func (s *Server) createWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.webhookUser(w, r)
+	if !ok {
+		return
+	}
+	req := model.WebhookRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, r, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails(err.Error()))
+		return
+	}
+	hook, err := s.webhooks.Subscribe(r.Context(), userID, req)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", "/api/user/webhooks/"+hook.ID.String())
+	s.writeJSON(w, r, http.StatusCreated, hook)
+}
+
+// getWebhooks подписки пользователя без ключей подписи
+func (s *Server) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.webhookUser(w, r)
+	if !ok {
+		return
+	}
+	hooks, err := s.webhooks.Webhooks(r.Context(), userID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if len(hooks) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, hooks)
+}
+
+// deleteWebhook удаляет подписку пользователя вместе с журналом доставок
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.webhookUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := s.uuidParam(w, r, "id", apierror.CodeWebhookNotFound)
+	if !ok {
+		return
+	}
+	if err := s.webhooks.Unsubscribe(r.Context(), userID, id); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getWebhookDeliveries журнал доставок подписки, начиная с последних.
+// параметр status отбирает доставки в одном состоянии (status=dead - список недоставленных), limit - количество записей
+func (s *Server) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.webhookUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := s.uuidParam(w, r, "id", apierror.CodeWebhookNotFound)
+	if !ok {
+		return
+	}
+	status := model.DeliveryStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		s.writeError(w, r, apierror.New(apierror.CodeBadRequest).WithDetails("status"))
+		return
+	}
+	limit := defaultDeliveriesLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			s.writeError(w, r, apierror.New(apierror.CodeBadRequest).WithDetails("limit"))
+			return
+		}
+		limit = min(n, maxDeliveriesLimit)
+	}
+	deliveries, err := s.webhooks.Deliveries(r.Context(), userID, id, status, limit)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusOK, deliveries)
+}
+
+// redeliverWebhook ставит доставку подписки (например, из списка недоставленных) в очередь на повторную отправку
+func (s *Server) redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, ok := s.webhookUser(w, r)
+	if !ok {
+		return
+	}
+	id, ok := s.uuidParam(w, r, "id", apierror.CodeWebhookNotFound)
+	if !ok {
+		return
+	}
+	deliveryID, ok := s.uuidParam(w, r, "delivery", apierror.CodeDeliveryNotFound)
+	if !ok {
+		return
+	}
+	delivery, err := s.webhooks.Redeliver(r.Context(), userID, id, deliveryID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	s.writeJSON(w, r, http.StatusAccepted, delivery)
+}
+
+// uuidParam идентификатор из параметра пути name. некорректный идентификатор ничего не находит - ошибка с кодом notFound
+func (s *Server) uuidParam(w http.ResponseWriter, r *http.Request, name string, notFound apierror.Code) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, name))
+	if err != nil {
+		s.writeError(w, r, apierror.Wrap(notFound, err))
+		return uuid.UUID{}, false
+	}
+	return id, true
+}
+
+// writeJSON отправляет v в формате JSON со статусом status
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	result, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(result)
+}
@@ -0,0 +1,244 @@
+// пакет bulk реализует потоковое массовое сокращение ссылок: строки загрузки в формате NDJSON или CSV
+// читаются по мере поступления, сохраняются частями через utils.SaveBatch, результат выводится по каждой строке
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/threat"
+	"github.com/kTowkA/shortener/internal/utils"
+)
+
+// DefaultChunkSize количество строк, сохраняемых за одно обращение к хранилищу по умолчанию
+const DefaultChunkSize = 1000
+
+// Shortener потоковое сокращение ссылок
+type Shortener struct {
+	Store storage.Storager
+	// Threats список угроз, ссылки из которого не сокращаются
+	Threats *threat.List
+	// BaseAddress добавляется к коротким ссылкам в результате
+	BaseAddress string
+	// ChunkSize количество строк в одной части. если не задано - DefaultChunkSize
+	ChunkSize int
+}
+
+// Summary количество строк по статусам обработки
+type Summary struct {
+	Total    int
+	Created  int
+	Existing int
+	Invalid  int
+	Failed   int
+}
+
+// Saved количество строк, по которым получена короткая ссылка
+func (s Summary) Saved() int {
+	return s.Created + s.Existing
+}
+
+// add учитывает результат обработки строки
+func (s *Summary) add(res model.BulkResult) {
+	s.Total++
+	switch res.Status {
+	case model.BatchStatusCreated:
+		s.Created++
+	case model.BatchStatusExisting:
+		s.Existing++
+	case model.BatchStatusInvalid:
+		s.Invalid++
+	default:
+		s.Failed++
+	}
+}
+
+// Run читает строки из r, сохраняет корректные ссылки пользователя userID частями и выводит результат по каждой строке в w
+// в порядке строк загрузки. После каждой части вызывается w.Flush. Ошибка сохранения части не прерывает обработку -
+// строки части получают статус failed. Обработка прерывается при ошибке чтения, вывода или отмене контекста
+func (s Shortener) Run(ctx context.Context, userID uuid.UUID, r Reader, w Writer) (Summary, error) {
+	size := s.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	summary := Summary{}
+	chunk := make([]model.BulkResult, 0, size)
+	elements := make([]model.BatchRequestElement, 0, size)
+
+	flushChunk := func() error {
+		s.save(ctx, userID, chunk, elements)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for _, res := range chunk {
+			summary.add(res)
+			if err := w.Write(res); err != nil {
+				return fmt.Errorf("вывод результата. %w", err)
+			}
+		}
+		chunk, elements = chunk[:0], elements[:0]
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("вывод результата. %w", err)
+		}
+		return nil
+	}
+
+	for {
+		row, err := r.Read()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				return summary, fmt.Errorf("чтение строки %d. %w", row.Line, err)
+			}
+			break
+		}
+		res := model.BulkResult{
+			Line:          row.Line,
+			CorrelationID: row.Element.CorrelationID,
+			OriginalURL:   strings.TrimSpace(row.Element.OriginalURL),
+		}
+		code := s.check(row)
+		if code != "" {
+			res.Status = model.BatchStatusInvalid
+			res.Reason = string(code)
+		} else {
+			e := row.Element
+			e.OriginalURL = res.OriginalURL
+			// по correlation_id результат сохранения сопоставляется со строкой части
+			e.CorrelationID = strconv.Itoa(len(chunk))
+			e.ShortURL = ""
+			elements = append(elements, e)
+		}
+		chunk = append(chunk, res)
+		if len(chunk) == size {
+			if err := flushChunk(); err != nil {
+				return summary, err
+			}
+		}
+	}
+	if err := flushChunk(); err != nil {
+		return summary, err
+	}
+	return summary, nil
+}
+
+// Batch сокращает ссылки массового запроса batch за одно обращение к хранилищу и возвращает результат
+// по каждому элементу в порядке запроса
+func (s Shortener) Batch(ctx context.Context, userID uuid.UUID, batch model.BatchRequest) (model.BatchResponse, Summary, error) {
+	if len(batch) == 0 {
+		return model.BatchResponse{}, Summary{}, nil
+	}
+	s.ChunkSize = len(batch)
+	results := &collector{results: make(model.BatchResponse, 0, len(batch))}
+	summary, err := s.Run(ctx, userID, NewBatchReader(batch), results)
+	if err != nil {
+		return nil, summary, err
+	}
+	return results.results, summary, nil
+}
+
+// NewBatchReader возвращает Reader элементов массового запроса batch. номер строки - порядковый номер элемента
+func NewBatchReader(batch model.BatchRequest) Reader {
+	return &sliceReader{batch: batch}
+}
+
+// sliceReader Reader элементов массового запроса
+type sliceReader struct {
+	batch model.BatchRequest
+	next  int
+}
+
+// Read реализация Reader
+func (sr *sliceReader) Read() (Row, error) {
+	if sr.next >= len(sr.batch) {
+		return Row{}, io.EOF
+	}
+	sr.next++
+	return Row{Line: sr.next, Element: sr.batch[sr.next-1]}, nil
+}
+
+// collector Writer, собирающий результаты в ответ на массовый запрос
+type collector struct {
+	results model.BatchResponse
+}
+
+// Write реализация Writer
+func (c *collector) Write(res model.BulkResult) error {
+	c.results = append(c.results, res.BatchResponseElement())
+	return nil
+}
+
+// Flush реализация Writer
+func (c *collector) Flush() error {
+	return nil
+}
+
+// check проверяет строку и возвращает код причины отказа. пустой код - строка корректна
+func (s Shortener) check(row Row) apierror.Code {
+	if row.Err != nil {
+		return apierror.From(row.Err).Code
+	}
+	original := strings.TrimSpace(row.Element.OriginalURL)
+	if original == "" {
+		return apierror.CodeInvalidURL
+	}
+	if _, err := url.ParseRequestURI(original); err != nil {
+		return apierror.CodeInvalidURL
+	}
+	if !row.Element.LinkOptions.Valid() {
+		return apierror.CodeInvalidLinkOptions
+	}
+	if s.Threats.Match(original) {
+		return apierror.CodeThreatURL
+	}
+	return ""
+}
+
+// save сохраняет элементы части и заполняет результаты соответствующих строк
+func (s Shortener) save(ctx context.Context, userID uuid.UUID, chunk []model.BulkResult, elements []model.BatchRequestElement) {
+	if len(elements) == 0 {
+		return
+	}
+	// строки, которые не будут отмечены ниже, сохранить не удалось
+	for _, e := range elements {
+		i, _ := strconv.Atoi(e.CorrelationID)
+		chunk[i].Status = model.BatchStatusFailed
+		chunk[i].Reason = string(apierror.CodeInternal)
+	}
+	resp, err := utils.SaveBatch(ctx, s.Store, userID, elements)
+	if err != nil {
+		code := apierror.From(err).Code
+		for _, e := range elements {
+			i, _ := strconv.Atoi(e.CorrelationID)
+			chunk[i].Reason = string(code)
+		}
+		return
+	}
+	for _, v := range resp {
+		i, err := strconv.Atoi(v.CorrelationID)
+		if err != nil || i < 0 || i >= len(chunk) {
+			continue
+		}
+		if v.ShortURL == "" {
+			if v.Error != nil {
+				chunk[i].Reason = string(apierror.From(v.Error).Code)
+			}
+			continue
+		}
+		chunk[i].ShortURL = s.BaseAddress + v.ShortURL
+		chunk[i].Status = model.BatchStatusCreated
+		chunk[i].Reason = ""
+		if errors.Is(v.Error, storage.ErrURLConflict) {
+			chunk[i].Status = model.BatchStatusExisting
+			chunk[i].Reason = string(apierror.CodeURLConflict)
+		}
+	}
+}
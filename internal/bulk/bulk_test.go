@@ -0,0 +1,188 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readAll читает все строки загрузки
+func readAll(t *testing.T, r Reader) []Row {
+	t.Helper()
+	rows := make([]Row, 0)
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestNDJSONReader(t *testing.T) {
+	data := `{"correlation_id":"1","original_url":"https://go.dev"}
+
+{"original_url":"https://pkg.go.dev","title":"pkg"}
+не json
+{"original_url":"https://go.dev/doc"}`
+	rows := readAll(t, NewReader(NDJSON, strings.NewReader(data)))
+	require.Len(t, rows, 4)
+	assert.Equal(t, Row{Line: 1, Element: model.BatchRequestElement{CorrelationID: "1", OriginalURL: "https://go.dev"}}, rows[0])
+	assert.Equal(t, 3, rows[1].Line, "пустые строки учитываются в нумерации")
+	assert.Equal(t, "pkg", rows[1].Element.Title)
+	assert.Equal(t, 4, rows[2].Line)
+	assert.Equal(t, apierror.CodeBadRequest, apierror.From(rows[2].Err).Code)
+	assert.Equal(t, "https://go.dev/doc", rows[3].Element.OriginalURL, "последняя строка без перевода строки")
+}
+
+func TestCSVReader(t *testing.T) {
+	t.Run("с заголовком", func(t *testing.T) {
+		data := "correlation_id,original_url,interstitial,redirect_code,unknown\n" +
+			"a,https://go.dev,true,301,x\n" +
+			"b,https://pkg.go.dev,maybe,,\n" +
+			"c,\"https://go.dev/doc\n"
+		rows := readAll(t, NewReader(CSV, strings.NewReader(data)))
+		require.Len(t, rows, 3)
+		assert.Equal(t, 2, rows[0].Line)
+		assert.Equal(t, model.BatchRequestElement{
+			CorrelationID: "a",
+			OriginalURL:   "https://go.dev",
+			LinkOptions:   model.LinkOptions{Interstitial: true, RedirectCode: 301},
+		}, rows[0].Element)
+		assert.NoError(t, rows[0].Err)
+		assert.Equal(t, 3, rows[1].Line)
+		assert.Error(t, rows[1].Err, "недопустимое значение колонки")
+		assert.Equal(t, 4, rows[2].Line)
+		assert.Error(t, rows[2].Err, "незакрытые кавычки")
+	})
+	t.Run("без заголовка", func(t *testing.T) {
+		rows := readAll(t, NewReader(CSV, strings.NewReader("https://go.dev,1\nhttps://pkg.go.dev\n")))
+		require.Len(t, rows, 2)
+		assert.Equal(t, model.BatchRequestElement{CorrelationID: "1", OriginalURL: "https://go.dev"}, rows[0].Element)
+		assert.Equal(t, 1, rows[0].Line)
+		assert.Equal(t, "https://pkg.go.dev", rows[1].Element.OriginalURL)
+	})
+}
+
+func TestWriter(t *testing.T) {
+	res := model.BulkResult{Line: 2, CorrelationID: "a", OriginalURL: "https://go.dev", ShortURL: "http://localhost/abc", Status: model.BatchStatusCreated}
+
+	buf := bytes.Buffer{}
+	w := NewWriter(NDJSON, &buf)
+	require.NoError(t, w.Write(res))
+	require.NoError(t, w.Flush())
+	got := model.BulkResult{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+	assert.Equal(t, res, got)
+
+	buf.Reset()
+	w = NewWriter(CSV, &buf)
+	require.NoError(t, w.Write(res))
+	require.NoError(t, w.Flush())
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{csvResultColumns, {"2", "a", "https://go.dev", "http://localhost/abc", "created", ""}}, records)
+
+	buf.Reset()
+	require.NoError(t, NewWriter(CSV, &buf).Flush())
+	assert.Equal(t, strings.Join(csvResultColumns, ",")+"\n", buf.String(), "заголовок без результатов")
+}
+
+// recorder сохраняет результаты и количество вызовов Flush
+type recorder struct {
+	results []model.BulkResult
+	flushes int
+}
+
+func (r *recorder) Write(res model.BulkResult) error {
+	r.results = append(r.results, res)
+	return nil
+}
+
+func (r *recorder) Flush() error {
+	r.flushes++
+	return nil
+}
+
+func TestShortenerRun(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	userID := uuid.New()
+
+	lines := []string{
+		`{"correlation_id":"dup","original_url":"https://go.dev"}`,
+		`{"original_url":""}`,
+		`{"original_url":"not url"}`,
+		`{"original_url":"https://go.dev/doc","redirect_code":200}`,
+		`{"correlation_id":"dup","original_url":"https://go.dev"}`,
+	}
+	for i := 0; i < 5; i++ {
+		lines = append(lines, fmt.Sprintf(`{"original_url":"https://go.dev/%d"}`, i))
+	}
+
+	rec := &recorder{}
+	s := Shortener{Store: store, BaseAddress: "http://localhost/", ChunkSize: 3}
+	summary, err := s.Run(context.Background(), userID, NewReader(NDJSON, strings.NewReader(strings.Join(lines, "\n"))), rec)
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Total: 10, Created: 6, Existing: 1, Invalid: 3}, summary)
+	assert.Equal(t, 4, rec.flushes, "по одному разу на каждую часть")
+
+	require.Len(t, rec.results, 10)
+	for i, res := range rec.results {
+		assert.Equal(t, i+1, res.Line, "порядок строк сохраняется")
+	}
+	assert.Equal(t, model.BatchStatusCreated, rec.results[0].Status)
+	assert.Equal(t, "dup", rec.results[0].CorrelationID, "correlation_id клиента возвращается в результате")
+	assert.True(t, strings.HasPrefix(rec.results[0].ShortURL, "http://localhost/"))
+	assert.Equal(t, model.BulkResult{Line: 2, Status: model.BatchStatusInvalid, Reason: string(apierror.CodeInvalidURL)}, rec.results[1])
+	assert.Equal(t, string(apierror.CodeInvalidURL), rec.results[2].Reason)
+	assert.Equal(t, string(apierror.CodeInvalidLinkOptions), rec.results[3].Reason)
+	assert.Equal(t, model.BatchStatusExisting, rec.results[4].Status)
+	assert.Equal(t, rec.results[0].ShortURL, rec.results[4].ShortURL)
+
+	// ссылки сохранены для пользователя
+	urls, err := store.UserURLs(context.Background(), userID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 6)
+}
+
+func TestShortenerBatch(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	s := Shortener{Store: store}
+
+	resp, summary, err := s.Batch(context.Background(), uuid.New(), model.BatchRequest{
+		{CorrelationID: "1", OriginalURL: " https://go.dev "},
+		{CorrelationID: "2", OriginalURL: "go.dev"},
+		{CorrelationID: "3", OriginalURL: "https://go.dev"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Summary{Total: 3, Created: 1, Existing: 1, Invalid: 1}, summary)
+	require.Len(t, resp, 3)
+	assert.Equal(t, "1", resp[0].CorrelationID)
+	assert.Equal(t, model.BatchStatusCreated, resp[0].Status)
+	assert.Equal(t, "https://go.dev", resp[0].OriginalURL)
+	assert.NotEmpty(t, resp[0].ShortURL)
+	assert.Equal(t, model.BatchResponseElement{CorrelationID: "2", OriginalURL: "go.dev", Status: model.BatchStatusInvalid, Reason: string(apierror.CodeInvalidURL)}, resp[1])
+	assert.Equal(t, model.BatchStatusExisting, resp[2].Status)
+	assert.Equal(t, resp[0].ShortURL, resp[2].ShortURL)
+
+	resp, summary, err = s.Batch(context.Background(), uuid.New(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, resp)
+	assert.Zero(t, summary.Total)
+}
@@ -0,0 +1,278 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// Format формат загрузки и результата
+type Format string
+
+const (
+	// NDJSON по одному JSON объекту model.BatchRequestElement в строке
+	NDJSON Format = "ndjson"
+	// CSV таблица с колонками, названными как поля model.BatchRequestElement.
+	// без строки заголовка колонки считаются в порядке original_url, correlation_id
+	CSV Format = "csv"
+)
+
+// mediaTypes типы контента, соответствующие форматам
+var mediaTypes = map[string]Format{
+	"application/x-ndjson": NDJSON,
+	"application/ndjson":   NDJSON,
+	"application/jsonl":    NDJSON,
+	"text/csv":             CSV,
+}
+
+// MediaTypes поддерживаемые типы контента
+func MediaTypes() []string {
+	return []string{"application/x-ndjson", "text/csv"}
+}
+
+// FormatFromMediaType возвращает формат по типу контента
+func FormatFromMediaType(mediaType string) (Format, bool) {
+	f, ok := mediaTypes[strings.ToLower(mediaType)]
+	return f, ok
+}
+
+// ParseFormat возвращает формат по названию (ndjson или csv)
+func ParseFormat(name string) (Format, bool) {
+	switch f := Format(strings.ToLower(name)); f {
+	case NDJSON, CSV:
+		return f, true
+	}
+	return "", false
+}
+
+// ContentType тип контента для формата
+func (f Format) ContentType() string {
+	if f == CSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Row строка загрузки. Err - строку не удалось разобрать
+type Row struct {
+	Line    int
+	Element model.BatchRequestElement
+	Err     error
+}
+
+// Reader последовательно читает строки загрузки. По окончании данных возвращает io.EOF
+type Reader interface {
+	Read() (Row, error)
+}
+
+// NewReader создает Reader формата f
+func NewReader(f Format, r io.Reader) Reader {
+	if f == CSV {
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.TrimLeadingSpace = true
+		return &csvReader{r: cr}
+	}
+	return &ndjsonReader{r: bufio.NewReader(r)}
+}
+
+type ndjsonReader struct {
+	r    *bufio.Reader
+	line int
+}
+
+// Read реализация Reader. пустые строки пропускаются, длина строки не ограничена
+func (nr *ndjsonReader) Read() (Row, error) {
+	for {
+		data, err := nr.r.ReadBytes('\n')
+		if len(data) == 0 && err != nil {
+			return Row{}, err
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Row{}, err
+		}
+		nr.line++
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+		row := Row{Line: nr.line}
+		if err := json.Unmarshal(data, &row.Element); err != nil {
+			row.Err = apierror.Wrap(apierror.CodeBadRequest, err)
+		}
+		return row, nil
+	}
+}
+
+// csvColumns колонки CSV по умолчанию (без строки заголовка)
+var csvColumns = []string{"original_url", "correlation_id"}
+
+type csvReader struct {
+	r       *csv.Reader
+	columns []string
+}
+
+// Read реализация Reader. первая строка считается заголовком, если в ней есть колонка original_url
+func (cr *csvReader) Read() (Row, error) {
+	for {
+		record, err := cr.r.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return Row{Line: parseErr.StartLine, Err: apierror.Wrap(apierror.CodeBadRequest, err)}, nil
+		}
+		if err != nil {
+			return Row{}, err
+		}
+		line, _ := cr.r.FieldPos(0)
+		if cr.columns == nil {
+			cr.columns = csvColumns
+			if header := csvHeader(record); header != nil {
+				cr.columns = header
+				continue
+			}
+		}
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		row := Row{Line: line}
+		row.Element, row.Err = cr.element(record)
+		return row, nil
+	}
+}
+
+// csvHeader возвращает названия колонок, если record - строка заголовка
+func csvHeader(record []string) []string {
+	header := make([]string, len(record))
+	found := false
+	for i, v := range record {
+		header[i] = strings.ToLower(strings.TrimSpace(v))
+		found = found || header[i] == "original_url"
+	}
+	if !found {
+		return nil
+	}
+	return header
+}
+
+// element разбирает строку CSV. неизвестные колонки пропускаются
+func (cr *csvReader) element(record []string) (model.BatchRequestElement, error) {
+	e := model.BatchRequestElement{}
+	var err error
+	for i, v := range record {
+		if i >= len(cr.columns) {
+			break
+		}
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		switch cr.columns[i] {
+		case "original_url":
+			e.OriginalURL = v
+		case "correlation_id":
+			e.CorrelationID = v
+		case "title":
+			e.Title = v
+		case "interstitial":
+			e.Interstitial, err = strconv.ParseBool(v)
+		case "passthrough":
+			e.Passthrough, err = strconv.ParseBool(v)
+		case "redirect_code":
+			e.RedirectCode, err = strconv.Atoi(v)
+		}
+		if err != nil {
+			return e, apierror.Wrap(apierror.CodeBadRequest, fmt.Errorf("колонка %s. %w", cr.columns[i], err))
+		}
+	}
+	return e, nil
+}
+
+// Writer выводит результаты обработки строк
+type Writer interface {
+	Write(model.BulkResult) error
+	// Flush передает накопленные результаты получателю
+	Flush() error
+}
+
+// NewWriter создает Writer формата f. Если w реализует Flush() error, он вызывается при каждом Flush
+func NewWriter(f Format, w io.Writer) Writer {
+	if f == CSV {
+		return &csvWriter{dst: w, w: csv.NewWriter(w)}
+	}
+	bw := bufio.NewWriter(w)
+	return &ndjsonWriter{dst: w, w: bw, enc: json.NewEncoder(bw)}
+}
+
+// flush передает данные дальше, если получатель это поддерживает
+func flush(dst io.Writer) error {
+	if f, ok := dst.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+type ndjsonWriter struct {
+	dst io.Writer
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// Write реализация Writer
+func (nw *ndjsonWriter) Write(res model.BulkResult) error {
+	return nw.enc.Encode(res)
+}
+
+// Flush реализация Writer
+func (nw *ndjsonWriter) Flush() error {
+	if err := nw.w.Flush(); err != nil {
+		return err
+	}
+	return flush(nw.dst)
+}
+
+// csvResultColumns колонки результата в формате CSV
+var csvResultColumns = []string{"line", "correlation_id", "original_url", "short_url", "status", "reason"}
+
+type csvWriter struct {
+	dst    io.Writer
+	w      *csv.Writer
+	header bool
+}
+
+// Write реализация Writer. перед первой строкой выводится заголовок
+func (cw *csvWriter) Write(res model.BulkResult) error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	return cw.w.Write([]string{strconv.Itoa(res.Line), res.CorrelationID, res.OriginalURL, res.ShortURL, string(res.Status), res.Reason})
+}
+
+// writeHeader выводит заголовок, если он еще не был выведен
+func (cw *csvWriter) writeHeader() error {
+	if cw.header {
+		return nil
+	}
+	cw.header = true
+	return cw.w.Write(csvResultColumns)
+}
+
+// Flush реализация Writer. заголовок выводится даже если результатов нет
+func (cw *csvWriter) Flush() error {
+	if err := cw.writeHeader(); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	if err := cw.w.Error(); err != nil {
+		return err
+	}
+	return flush(cw.dst)
+}
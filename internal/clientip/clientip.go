@@ -0,0 +1,130 @@
+// пакет clientip определяет IP адрес клиента. адрес берется из соединения, а заголовки X-Forwarded-For,
+// X-Real-IP и заголовок PROXY protocol учитываются только если соединение пришло от доверенного прокси.
+// иначе любой клиент мог бы подставить чужой адрес и, например, получить доступ к внутреннему API
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Subnets список подсетей IPv4 и IPv6
+type Subnets []*net.IPNet
+
+// ParseSubnets разбирает список подсетей в формате CIDR, разделенных запятыми.
+// одиночный адрес означает подсеть из одного адреса. пустая строка - пустой список
+func ParseSubnets(list string) (Subnets, error) {
+	subnets := Subnets{}
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("невалидный адрес %q", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			subnets = append(subnets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("конвертация CIDR. %w", err)
+		}
+		subnets = append(subnets, ipnet)
+	}
+	return subnets, nil
+}
+
+// Contains проверяет, что ip входит в одну из подсетей
+func (s Subnets) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, subnet := range s {
+		if subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// String подсети через запятую
+func (s Subnets) String() string {
+	values := make([]string, 0, len(s))
+	for _, subnet := range s {
+		values = append(values, subnet.String())
+	}
+	return strings.Join(values, ",")
+}
+
+// Resolver определяет адрес клиента с учетом доверенных прокси. nil - доверенных прокси нет
+type Resolver struct {
+	proxies Subnets
+}
+
+// NewResolver создает новый экземпляр Resolver, доверяющий заголовкам прокси из подсетей proxies
+func NewResolver(proxies Subnets) *Resolver {
+	return &Resolver{proxies: proxies}
+}
+
+// Trusted проверяет, что адрес remote (с портом или без) принадлежит доверенному прокси
+func (r *Resolver) Trusted(remote string) bool {
+	return r != nil && r.proxies.Contains(net.ParseIP(Host(remote)))
+}
+
+// Resolve адрес клиента запроса, пришедшего с адреса remote. если remote - доверенный прокси, адрес берется
+// из цепочки forwardedFor (значения X-Forwarded-For): справа налево до первого адреса, не являющегося
+// доверенным прокси. без цепочки используется realIP (X-Real-IP). некорректные значения заголовков
+// не учитываются, и в этом случае адрес клиента - remote
+func (r *Resolver) Resolve(remote string, forwardedFor []string, realIP string) string {
+	remote = Host(remote)
+	if !r.Trusted(remote) {
+		return remote
+	}
+	chain := make([]net.IP, 0)
+	for _, header := range forwardedFor {
+		for _, v := range strings.Split(header, ",") {
+			ip := net.ParseIP(strings.TrimSpace(v))
+			if ip == nil {
+				// подделанная или поврежденная цепочка - доверяем только прокси
+				return remote
+			}
+			chain = append(chain, ip)
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !r.proxies.Contains(chain[i]) {
+			return chain[i].String()
+		}
+	}
+	if len(chain) > 0 {
+		// все адреса цепочки - доверенные прокси, клиент - первый из них
+		return chain[0].String()
+	}
+	if ip := net.ParseIP(strings.TrimSpace(realIP)); ip != nil {
+		return ip.String()
+	}
+	return remote
+}
+
+// FromRequest адрес клиента HTTP запроса
+func (r *Resolver) FromRequest(req *http.Request) string {
+	return r.Resolve(req.RemoteAddr, req.Header.Values("X-Forwarded-For"), req.Header.Get("X-Real-IP"))
+}
+
+// Host адрес addr без порта
+func Host(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,73 @@
+package clientip
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubnets(t *testing.T) {
+	subnets, err := ParseSubnets(" 192.168.1.0/24, 10.0.0.1 ,fd00::/8,::1,")
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.0/24,10.0.0.1/32,fd00::/8,::1/128", subnets.String())
+	assert.True(t, subnets.Contains(net.ParseIP("192.168.1.200")))
+	assert.True(t, subnets.Contains(net.ParseIP("10.0.0.1")))
+	assert.False(t, subnets.Contains(net.ParseIP("10.0.0.2")))
+	assert.True(t, subnets.Contains(net.ParseIP("fd12::1")))
+	assert.True(t, subnets.Contains(net.ParseIP("::1")))
+	assert.False(t, subnets.Contains(nil))
+
+	subnets, err = ParseSubnets("")
+	require.NoError(t, err)
+	assert.Empty(t, subnets)
+
+	for _, bad := range []string{"192.168.1.0/33", "host", "10.0.0.0/8,bad/8"} {
+		_, err = ParseSubnets(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	proxies, err := ParseSubnets("10.0.0.0/8,fd00::/8")
+	require.NoError(t, err)
+	r := NewResolver(proxies)
+
+	tests := []struct {
+		name         string
+		resolver     *Resolver
+		remote       string
+		forwardedFor []string
+		realIP       string
+		want         string
+	}{
+		{name: "без прокси", resolver: r, remote: "203.0.113.5:5000", want: "203.0.113.5"},
+		{name: "заголовки не от прокси", resolver: r, remote: "203.0.113.5:5000", forwardedFor: []string{"192.168.1.1"}, realIP: "192.168.1.1", want: "203.0.113.5"},
+		{name: "нет доверенных прокси", resolver: nil, remote: "10.0.0.1:5000", realIP: "192.168.1.1", want: "10.0.0.1"},
+		{name: "X-Real-IP от прокси", resolver: r, remote: "10.0.0.1:5000", realIP: "192.168.1.1", want: "192.168.1.1"},
+		{name: "некорректный X-Real-IP", resolver: r, remote: "10.0.0.1:5000", realIP: "bad", want: "10.0.0.1"},
+		{name: "X-Forwarded-For важнее X-Real-IP", resolver: r, remote: "10.0.0.1:5000", forwardedFor: []string{"192.168.1.1"}, realIP: "192.168.1.2", want: "192.168.1.1"},
+		{
+			name:         "цепочка прокси",
+			resolver:     r,
+			remote:       "10.0.0.1:5000",
+			forwardedFor: []string{"192.168.1.1, 203.0.113.7", "10.0.0.2"},
+			want:         "203.0.113.7",
+		},
+		{name: "все адреса цепочки - прокси", resolver: r, remote: "10.0.0.1:5000", forwardedFor: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "поврежденная цепочка", resolver: r, remote: "10.0.0.1:5000", forwardedFor: []string{"192.168.1.1, unknown"}, want: "10.0.0.1"},
+		{name: "IPv6", resolver: r, remote: "[fd00::1]:5000", forwardedFor: []string{"2001:db8::1"}, want: "2001:db8::1"},
+		{name: "адрес без порта", resolver: r, remote: "203.0.113.5", want: "203.0.113.5"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.resolver.Resolve(tt.remote, tt.forwardedFor, tt.realIP), tt.name)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Add("X-Forwarded-For", "192.168.1.1")
+	req.Header.Add("X-Forwarded-For", "203.0.113.7")
+	assert.Equal(t, "203.0.113.7", r.FromRequest(req))
+}
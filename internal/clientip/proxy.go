@@ -0,0 +1,200 @@
+package clientip
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headerTimeout время ожидания начала данных от доверенного прокси. прокси отправляет заголовок
+// PROXY protocol сразу после соединения, поэтому соединения без данных дольше этого времени закрываются
+const headerTimeout = 5 * time.Second
+
+var (
+	// v1Prefix начало заголовка PROXY protocol версии 1 (текстового)
+	v1Prefix = []byte("PROXY ")
+	// v2Signature начало заголовка PROXY protocol версии 2 (двоичного)
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// ErrProxyHeader некорректный заголовок PROXY protocol
+var ErrProxyHeader = errors.New("некорректный заголовок PROXY protocol")
+
+// Listener возвращает net.Listener, принимающий заголовки PROXY protocol версий 1 и 2 от доверенных прокси.
+// для соединений с заголовком RemoteAddr возвращает адрес клиента из заголовка. соединения без заголовка
+// и соединения не от доверенных прокси не изменяются
+func (r *Resolver) Listener(l net.Listener) net.Listener {
+	if r == nil || len(r.proxies) == 0 {
+		return l
+	}
+	return &proxyListener{Listener: l, resolver: r}
+}
+
+// proxyListener net.Listener с поддержкой PROXY protocol
+type proxyListener struct {
+	net.Listener
+	resolver *Resolver
+}
+
+// Accept реализация net.Listener. заголовок читается при первом обращении к соединению,
+// чтобы медленный клиент не задерживал прием остальных соединений
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.resolver.Trusted(conn.RemoteAddr().String()) {
+		return conn, nil
+	}
+	return &proxyConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// proxyConn соединение от доверенного прокси, которое может начинаться с заголовка PROXY protocol
+type proxyConn struct {
+	net.Conn
+	reader *bufio.Reader
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+// Read реализация net.Conn
+func (c *proxyConn) Read(p []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+// RemoteAddr реализация net.Conn: адрес клиента из заголовка или адрес прокси
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readHeader читает заголовок, если соединение начинается с него
+func (c *proxyConn) readHeader() {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(headerTimeout))
+	defer func() {
+		_ = c.Conn.SetReadDeadline(time.Time{})
+	}()
+
+	c.remote, c.err = readProxyHeader(c.reader)
+	if c.err != nil {
+		c.err = fmt.Errorf("чтение заголовка от %s. %w", c.Conn.RemoteAddr(), c.err)
+		_ = c.Conn.Close()
+	}
+}
+
+// readProxyHeader читает заголовок PROXY protocol из r и возвращает адрес клиента. если данные не начинаются
+// с заголовка, ничего не читается. nil адрес без ошибки - заголовок без адреса (UNKNOWN, LOCAL)
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	start, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			// данных меньше, чем длина заголовка - это не заголовок
+			return nil, nil
+		}
+		return nil, err
+	}
+	if bytes.Equal(start, v1Prefix) {
+		return readV1(r)
+	}
+	if !bytes.Equal(start, v2Signature[:len(v1Prefix)]) {
+		return nil, nil
+	}
+	sig, err := r.Peek(len(v2Signature))
+	if err != nil || !bytes.Equal(sig, v2Signature) {
+		return nil, nil
+	}
+	return readV2(r)
+}
+
+// readV1 читает текстовый заголовок вида "PROXY TCP4 адрес_клиента адрес_сервера порт_клиента порт_сервера\r\n"
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	// по спецификации заголовок не длиннее 107 байт
+	line := make([]byte, 0, 107)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w. %w", ErrProxyHeader, err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) == cap(line) {
+			return nil, fmt.Errorf("%w. слишком длинный заголовок", ErrProxyHeader)
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, ErrProxyHeader
+	}
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("%w. %q", ErrProxyHeader, line)
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("%w. %q", ErrProxyHeader, line)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2 читает двоичный заголовок: подпись, версия и команда, семейство адресов, длина и адреса
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(v2Signature)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w. %w", ErrProxyHeader, err)
+	}
+	verCmd, family := header[12], header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("%w. %w", ErrProxyHeader, err)
+	}
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("%w. версия %d", ErrProxyHeader, verCmd>>4)
+	}
+	switch verCmd & 0x0f {
+	case 0x0:
+		// LOCAL - соединение самого прокси (например, проверка доступности)
+		return nil, nil
+	case 0x1:
+		// PROXY
+	default:
+		return nil, fmt.Errorf("%w. команда %d", ErrProxyHeader, verCmd&0x0f)
+	}
+	// адреса клиента и сервера, затем порты клиента и сервера
+	switch family >> 4 {
+	case 0x1:
+		if len(payload) < 12 {
+			return nil, fmt.Errorf("%w. короткий адрес IPv4", ErrProxyHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 0x2:
+		if len(payload) < 36 {
+			return nil, fmt.Errorf("%w. короткий адрес IPv6", ErrProxyHeader)
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		// UNSPEC и сокеты UNIX - адрес клиента неизвестен
+		return nil, nil
+	}
+}
@@ -0,0 +1,122 @@
+package clientip
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// v2Header заголовок PROXY protocol версии 2 с командой cmd и адресом клиента ip:port
+func v2Header(cmd byte, ip net.IP, port uint16) []byte {
+	header := append([]byte{}, v2Signature...)
+	if ip4 := ip.To4(); ip4 != nil {
+		header = append(header, 0x20|cmd, 0x11, 0, 12)
+		header = append(header, ip4...)
+		header = append(header, 127, 0, 0, 1)
+		header = binary.BigEndian.AppendUint16(header, port)
+		return binary.BigEndian.AppendUint16(header, 8080)
+	}
+	header = append(header, 0x20|cmd, 0x21, 0, 36)
+	header = append(header, ip.To16()...)
+	header = append(header, net.IPv6loopback...)
+	header = binary.BigEndian.AppendUint16(header, port)
+	return binary.BigEndian.AppendUint16(header, 8080)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	const body = "GET / HTTP/1.1\r\n"
+	tests := []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{name: "без заголовка", data: body},
+		{name: "короткие данные", data: "GET"},
+		{name: "v1 IPv4", data: "PROXY TCP4 192.168.1.1 10.0.0.1 56324 443\r\n" + body, want: "192.168.1.1:56324"},
+		{name: "v1 IPv6", data: "PROXY TCP6 2001:db8::1 ::1 56324 443\r\n" + body, want: "[2001:db8::1]:56324"},
+		{name: "v1 UNKNOWN", data: "PROXY UNKNOWN\r\n" + body},
+		{name: "v1 семейство не совпадает с адресом", data: "PROXY TCP4 2001:db8::1 ::1 56324 443\r\n" + body, wantErr: true},
+		{name: "v1 неполный", data: "PROXY TCP4 192.168.1.1\r\n" + body, wantErr: true},
+		{name: "v1 без конца строки", data: "PROXY TCP4 192.168.1.1 10.0.0.1 56324 443" + strings.Repeat(" ", 100), wantErr: true},
+		{name: "v2 IPv4", data: string(v2Header(0x1, net.ParseIP("192.168.1.1"), 56324)) + body, want: "192.168.1.1:56324"},
+		{name: "v2 IPv6", data: string(v2Header(0x1, net.ParseIP("2001:db8::1"), 56324)) + body, want: "[2001:db8::1]:56324"},
+		{name: "v2 LOCAL", data: string(v2Header(0x0, net.ParseIP("192.168.1.1"), 56324)) + body},
+		{name: "v2 обрезан", data: string(v2Header(0x1, net.ParseIP("192.168.1.1"), 56324)[:20]), wantErr: true},
+	}
+	for _, tt := range tests {
+		r := bufio.NewReader(strings.NewReader(tt.data))
+		addr, err := readProxyHeader(r)
+		if tt.wantErr {
+			assert.ErrorIs(t, err, ErrProxyHeader, tt.name)
+			continue
+		}
+		require.NoError(t, err, tt.name)
+		if tt.want == "" {
+			assert.Nil(t, addr, tt.name)
+		} else {
+			require.NotNil(t, addr, tt.name)
+			assert.Equal(t, tt.want, addr.String(), tt.name)
+		}
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err, tt.name)
+		if strings.HasSuffix(tt.data, body) {
+			assert.Equal(t, body, string(rest), "данные после заголовка не изменяются: "+tt.name)
+		}
+	}
+}
+
+func TestListener(t *testing.T) {
+	proxies, err := ParseSubnets("127.0.0.1")
+	require.NoError(t, err)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	assert.Same(t, l, (*Resolver)(nil).Listener(l), "без доверенных прокси соединения не изменяются")
+	pl := NewResolver(proxies).Listener(l)
+
+	send := func(data string) {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte(data))
+		require.NoError(t, err)
+	}
+
+	go send("PROXY TCP4 192.168.1.1 127.0.0.1 56324 80\r\nping")
+	conn, err := pl.Accept()
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.1:56324", conn.RemoteAddr().String())
+	data, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(data))
+	conn.Close()
+
+	go send("ping")
+	conn, err = pl.Accept()
+	require.NoError(t, err)
+	data, err = io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(data))
+	assert.Equal(t, "127.0.0.1", Host(conn.RemoteAddr().String()))
+	conn.Close()
+
+	// заголовок от недоверенного прокси не учитывается
+	untrusted, err := ParseSubnets("10.0.0.1")
+	require.NoError(t, err)
+	pl = NewResolver(untrusted).Listener(l)
+	go send("PROXY TCP4 192.168.1.1 127.0.0.1 56324 80\r\n")
+	conn, err = pl.Accept()
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", Host(conn.RemoteAddr().String()))
+	data, err = io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "PROXY TCP4 192.168.1.1 127.0.0.1 56324 80\r\n", string(data))
+	conn.Close()
+}
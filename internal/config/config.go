@@ -6,11 +6,14 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
-	"net"
+	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
+	"github.com/kTowkA/shortener/internal/clientip"
+	"github.com/kTowkA/shortener/internal/model"
 )
 
 const (
@@ -19,6 +22,35 @@ const (
 	defaultAddress         = "localhost:8080"
 	defaultBaseAddress     = "http://localhost:8080/"
 	defaultStorageFilePath = "/tmp/short-url-db.json"
+
+	defaultThreatCheckInterval = time.Hour
+
+	defaultLinkCheckConcurrency = 4
+	defaultLinkCheckHostDelay   = time.Second
+
+	defaultRedirectCode   = http.StatusTemporaryRedirect
+	defaultRedirectMaxAge = 24 * time.Hour
+
+	defaultJobWorkers   = 2
+	defaultJobRetention = 24 * time.Hour
+
+	defaultIdempotencyWindow = 24 * time.Hour
+
+	defaultAuditRetention = 90 * 24 * time.Hour
+
+	defaultDeleteFlushInterval = 5 * time.Second
+	defaultDeleteBatchSize     = 100
+	defaultDeleteQueueCapacity = 10000
+	defaultDeleteRetention     = 24 * time.Hour
+
+	defaultWebhookTimeout     = 10 * time.Second
+	defaultWebhookMaxAttempts = 8
+	defaultWebhookRetention   = 7 * 24 * time.Hour
+
+	defaultJWTLifetime = 12 * time.Hour
+
+	// oidcCallbackPath путь возврата от провайдера входа относительно базового адреса
+	oidcCallbackPath = "api/user/oidc/callback"
 )
 
 var (
@@ -29,8 +61,50 @@ var (
 	flagDomainName      string
 	flagConfig          string
 	flagTrustedSubnet   string
+	flagTrustedProxies  string
 	flagGRPC            string
 	flagEnableHTTPS     bool
+
+	flagThreatList          string
+	flagThreatCheckInterval time.Duration
+
+	flagLinkCheckInterval    time.Duration
+	flagLinkCheckConcurrency int
+	flagLinkCheckHostDelay   time.Duration
+	flagDeadLinkFallback     string
+
+	flagRedirectCode   int
+	flagRedirectMaxAge time.Duration
+
+	flagJobWorkers   int
+	flagJobRetention time.Duration
+
+	flagIdempotencyWindow time.Duration
+
+	flagAuditRetention time.Duration
+
+	flagRateLimitCreate   int
+	flagRateLimitRedirect int
+	flagRateLimitDelete   int
+	flagDailyLinkQuota    int
+
+	flagDeleteFlushInterval time.Duration
+	flagDeleteBatchSize     int
+	flagDeleteQueueCapacity int
+	flagDeleteRetention     time.Duration
+
+	flagWebhookTimeout     time.Duration
+	flagWebhookMaxAttempts int
+	flagWebhookRetention   time.Duration
+
+	flagJWTKeys          string
+	flagJWTLifetime      time.Duration
+	flagJWTRefreshWindow time.Duration
+
+	flagOIDCIssuer         string
+	flagOIDCClientID       string
+	flagOIDCRedirectURL    string
+	flagOIDCAllowedDomains string
 )
 
 // Config конфигурация приложения
@@ -41,8 +115,20 @@ type Config struct {
 	databaseDSN     string
 	secretKey       string
 	gRPC            string
-	trustedSubnet   *net.IPNet
+	trustedSubnet   clientip.Subnets
+	trustedProxies  clientip.Subnets
 	configHTTPS
+	configThreat
+	configLinkCheck
+	configRedirect
+	configJobs
+	idempotencyWindow time.Duration
+	auditRetention    time.Duration
+	configRateLimit
+	configDeletion
+	configWebhook
+	configJWT
+	configOIDC
 }
 
 type configHTTPS struct {
@@ -50,6 +136,62 @@ type configHTTPS struct {
 	domain string
 }
 
+type configThreat struct {
+	list          string
+	checkInterval time.Duration
+}
+
+type configLinkCheck struct {
+	interval    time.Duration
+	concurrency int
+	hostDelay   time.Duration
+	fallback    string
+}
+
+type configRedirect struct {
+	code   int
+	maxAge time.Duration
+}
+
+type configJobs struct {
+	workers   int
+	retention time.Duration
+}
+
+type configDeletion struct {
+	flushInterval time.Duration
+	batchSize     int
+	capacity      int
+	retention     time.Duration
+}
+
+type configWebhook struct {
+	timeout     time.Duration
+	maxAttempts int
+	retention   time.Duration
+}
+
+type configJWT struct {
+	keys          string
+	lifetime      time.Duration
+	refreshWindow time.Duration
+}
+
+type configOIDC struct {
+	issuer         string
+	clientID       string
+	clientSecret   string
+	redirectURL    string
+	allowedDomains []string
+}
+
+type configRateLimit struct {
+	create     int
+	redirect   int
+	delete     int
+	dailyQuota int
+}
+
 // Domain возвращает доменное имя, если оно было установлено
 func (c *Config) Domain() string {
 	return c.configHTTPS.domain
@@ -90,11 +232,172 @@ func (c *Config) SecretKey() string {
 	return c.secretKey
 }
 
-// TrustedSubnet возвращает строковое представление бесклассовой адресации (CIDR)
-func (c *Config) TrustedSubnet() *net.IPNet {
+// TrustedSubnets возвращает подсети, клиентам из которых доступно внутреннее API. пустой список - доступ закрыт
+func (c *Config) TrustedSubnets() clientip.Subnets {
 	return c.trustedSubnet
 }
 
+// TrustedProxies возвращает подсети доверенных прокси, заголовки X-Forwarded-For, X-Real-IP
+// и PROXY protocol которых учитываются при определении адреса клиента
+func (c *Config) TrustedProxies() clientip.Subnets {
+	return c.trustedProxies
+}
+
+// ThreatList возвращает путь к файлу со списком угроз. Пустая строка - проверка отключена
+func (c *Config) ThreatList() string {
+	return c.configThreat.list
+}
+
+// ThreatCheckInterval возвращает периодичность проверки сохраненных ссылок по списку угроз
+func (c *Config) ThreatCheckInterval() time.Duration {
+	return c.configThreat.checkInterval
+}
+
+// LinkCheckInterval возвращает периодичность проверки доступности оригинальных ссылок. 0 - проверка отключена
+func (c *Config) LinkCheckInterval() time.Duration {
+	return c.configLinkCheck.interval
+}
+
+// LinkCheckConcurrency возвращает максимальное количество одновременных запросов при проверке доступности ссылок
+func (c *Config) LinkCheckConcurrency() int {
+	return c.configLinkCheck.concurrency
+}
+
+// LinkCheckHostDelay возвращает минимальный интервал между запросами к одному хосту при проверке доступности ссылок
+func (c *Config) LinkCheckHostDelay() time.Duration {
+	return c.configLinkCheck.hostDelay
+}
+
+// DeadLinkFallback возвращает адрес, на который перенаправляются переходы по недоступным ссылкам. Пустая строка - не перенаправлять
+func (c *Config) DeadLinkFallback() string {
+	return c.configLinkCheck.fallback
+}
+
+// RedirectCode возвращает код перенаправления для ссылок, у которых он не задан
+func (c *Config) RedirectCode() int {
+	return c.configRedirect.code
+}
+
+// RedirectMaxAge возвращает время, на которое браузеру разрешено кэшировать постоянные перенаправления
+func (c *Config) RedirectMaxAge() time.Duration {
+	return c.configRedirect.maxAge
+}
+
+// JobWorkers возвращает количество обработчиков фоновых заданий массового сокращения ссылок
+func (c *Config) JobWorkers() int {
+	return c.configJobs.workers
+}
+
+// JobRetention возвращает время хранения завершенных заданий массового сокращения ссылок
+func (c *Config) JobRetention() time.Duration {
+	return c.configJobs.retention
+}
+
+// IdempotencyWindow возвращает время, в течение которого повторный запрос с тем же ключом идемпотентности получает первый ответ
+func (c *Config) IdempotencyWindow() time.Duration {
+	return c.idempotencyWindow
+}
+
+// AuditRetention возвращает время хранения записей журнала действий администраторов
+func (c *Config) AuditRetention() time.Duration {
+	return c.auditRetention
+}
+
+// RateLimitCreate возвращает допустимое количество запросов на создание ссылок в минуту (0 - без ограничений)
+func (c *Config) RateLimitCreate() int {
+	return c.configRateLimit.create
+}
+
+// RateLimitRedirect возвращает допустимое количество переходов по коротким ссылкам в минуту (0 - без ограничений)
+func (c *Config) RateLimitRedirect() int {
+	return c.configRateLimit.redirect
+}
+
+// RateLimitDelete возвращает допустимое количество запросов на удаление ссылок в минуту (0 - без ограничений)
+func (c *Config) RateLimitDelete() int {
+	return c.configRateLimit.delete
+}
+
+// DailyLinkQuota возвращает количество ссылок, которое пользователь может создать за сутки (0 - без ограничений)
+func (c *Config) DailyLinkQuota() int {
+	return c.configRateLimit.dailyQuota
+}
+
+// DeleteFlushInterval возвращает периодичность удаления ссылок из очереди удаления
+func (c *Config) DeleteFlushInterval() time.Duration {
+	return c.configDeletion.flushInterval
+}
+
+// DeleteBatchSize возвращает количество ссылок, удаляемых за одно обращение к хранилищу
+func (c *Config) DeleteBatchSize() int {
+	return c.configDeletion.batchSize
+}
+
+// DeleteQueueCapacity возвращает наибольшее количество ссылок, ожидающих удаления
+func (c *Config) DeleteQueueCapacity() int {
+	return c.configDeletion.capacity
+}
+
+// DeleteRetention возвращает время хранения выполненных задач удаления
+func (c *Config) DeleteRetention() time.Duration {
+	return c.configDeletion.retention
+}
+
+// WebhookTimeout возвращает время ожидания ответа на доставку события подписке
+func (c *Config) WebhookTimeout() time.Duration {
+	return c.configWebhook.timeout
+}
+
+// WebhookMaxAttempts возвращает количество попыток доставки события, после которых оно попадает в список недоставленных
+func (c *Config) WebhookMaxAttempts() int {
+	return c.configWebhook.maxAttempts
+}
+
+// WebhookRetention возвращает время хранения доставленных и недоставленных событий подписок
+func (c *Config) WebhookRetention() time.Duration {
+	return c.configWebhook.retention
+}
+
+// JWTKeys возвращает путь к файлу ключей подписи токенов пользователей. Пустая строка - токены подписываются SECRET_KEY
+func (c *Config) JWTKeys() string {
+	return c.configJWT.keys
+}
+
+// JWTLifetime возвращает время жизни токена пользователя
+func (c *Config) JWTLifetime() time.Duration {
+	return c.configJWT.lifetime
+}
+
+// JWTRefreshWindow возвращает время до истечения токена пользователя, в течение которого токен продлевается при запросе
+func (c *Config) JWTRefreshWindow() time.Duration {
+	return c.configJWT.refreshWindow
+}
+
+// OIDCIssuer возвращает адрес провайдера входа OpenID Connect. Пустая строка - вход через провайдера отключен
+func (c *Config) OIDCIssuer() string {
+	return c.configOIDC.issuer
+}
+
+// OIDCClientID возвращает идентификатор клиента у провайдера входа
+func (c *Config) OIDCClientID() string {
+	return c.configOIDC.clientID
+}
+
+// OIDCClientSecret возвращает секрет клиента у провайдера входа. Пустая строка - публичный клиент
+func (c *Config) OIDCClientSecret() string {
+	return c.configOIDC.clientSecret
+}
+
+// OIDCRedirectURL возвращает адрес возврата от провайдера входа, зарегистрированный у провайдера
+func (c *Config) OIDCRedirectURL() string {
+	return c.configOIDC.redirectURL
+}
+
+// OIDCAllowedDomains возвращает домены почты, пользователям которых разрешен вход через провайдера. пустой список - любые
+func (c *Config) OIDCAllowedDomains() []string {
+	return c.configOIDC.allowedDomains
+}
+
 // DefaultConfig конфигурация по умолчанию для быстрой настройки
 var DefaultConfig = Config{
 	address:         defaultAddress,
@@ -102,11 +405,52 @@ var DefaultConfig = Config{
 	fileStoragePath: defaultStorageFilePath,
 	secretKey:       defaultSecretKey,
 	gRPC:            "",
-	trustedSubnet:   &net.IPNet{},
+	trustedSubnet:   clientip.Subnets{},
+	trustedProxies:  clientip.Subnets{},
 	configHTTPS: configHTTPS{
 		enable: false,
 		domain: "",
 	},
+	configThreat: configThreat{
+		list:          "",
+		checkInterval: defaultThreatCheckInterval,
+	},
+	configLinkCheck: configLinkCheck{
+		interval:    0,
+		concurrency: defaultLinkCheckConcurrency,
+		hostDelay:   defaultLinkCheckHostDelay,
+		fallback:    "",
+	},
+	configRedirect: configRedirect{
+		code:   defaultRedirectCode,
+		maxAge: defaultRedirectMaxAge,
+	},
+	configJobs: configJobs{
+		workers:   defaultJobWorkers,
+		retention: defaultJobRetention,
+	},
+	idempotencyWindow: defaultIdempotencyWindow,
+	auditRetention:    defaultAuditRetention,
+	configRateLimit:   configRateLimit{},
+	configDeletion: configDeletion{
+		flushInterval: defaultDeleteFlushInterval,
+		batchSize:     defaultDeleteBatchSize,
+		capacity:      defaultDeleteQueueCapacity,
+		retention:     defaultDeleteRetention,
+	},
+	configWebhook: configWebhook{
+		timeout:     defaultWebhookTimeout,
+		maxAttempts: defaultWebhookMaxAttempts,
+		retention:   defaultWebhookRetention,
+	},
+	configJWT: configJWT{
+		keys:          "",
+		lifetime:      defaultJWTLifetime,
+		refreshWindow: defaultJWTLifetime / 2,
+	},
+	configOIDC: configOIDC{
+		redirectURL: defaultBaseAddress + oidcCallbackPath,
+	},
 }
 
 func init() {
@@ -116,9 +460,40 @@ func init() {
 	flag.StringVar(&flagStorageFilePath, "f", "", "file on disk with db")
 	flag.StringVar(&flagDomainName, "dn", "", "domain name")
 	flag.StringVar(&flagConfig, "c", "", "config file(only JSON)")
-	flag.StringVar(&flagTrustedSubnet, "t", "", "trusted subnet")
+	flag.StringVar(&flagTrustedSubnet, "t", "", "trusted subnets for internal API (comma separated CIDR)")
+	flag.StringVar(&flagTrustedProxies, "tp", "", "trusted proxies (comma separated CIDR)")
 	flag.StringVar(&flagGRPC, "g", "", "address gRPC")
 	flag.BoolVar(&flagEnableHTTPS, "s", false, "enable https")
+	flag.StringVar(&flagThreatList, "tl", "", "file with threat list (hex SHA256 prefixes)")
+	flag.DurationVar(&flagThreatCheckInterval, "ti", 0, "threat list check interval")
+	flag.DurationVar(&flagLinkCheckInterval, "lci", 0, "dead link check interval (0 - disabled)")
+	flag.IntVar(&flagLinkCheckConcurrency, "lcc", 0, "dead link check concurrency")
+	flag.DurationVar(&flagLinkCheckHostDelay, "lcd", 0, "dead link check delay between requests to one host")
+	flag.StringVar(&flagDeadLinkFallback, "dlf", "", "fallback redirect for dead links")
+	flag.IntVar(&flagRedirectCode, "rc", 0, "default redirect code (301, 302, 307 or 308)")
+	flag.DurationVar(&flagRedirectMaxAge, "rma", 0, "cache lifetime for permanent redirects")
+	flag.IntVar(&flagJobWorkers, "jw", 0, "async batch job workers")
+	flag.DurationVar(&flagJobRetention, "jrt", 0, "how long finished batch jobs are kept")
+	flag.DurationVar(&flagIdempotencyWindow, "iw", 0, "how long idempotency keys are kept")
+	flag.DurationVar(&flagAuditRetention, "ar", 0, "how long admin audit records are kept")
+	flag.IntVar(&flagRateLimitCreate, "rlc", 0, "create requests per minute per user and per IP (0 - unlimited)")
+	flag.IntVar(&flagRateLimitRedirect, "rlr", 0, "redirects per minute per user and per IP (0 - unlimited)")
+	flag.IntVar(&flagRateLimitDelete, "rld", 0, "delete requests per minute per user and per IP (0 - unlimited)")
+	flag.IntVar(&flagDailyLinkQuota, "dlq", 0, "links per user per day (0 - unlimited)")
+	flag.DurationVar(&flagDeleteFlushInterval, "dfi", 0, "deletion queue flush interval")
+	flag.IntVar(&flagDeleteBatchSize, "dbs", 0, "links deleted per storage call")
+	flag.IntVar(&flagDeleteQueueCapacity, "dqc", 0, "max links waiting for deletion")
+	flag.DurationVar(&flagDeleteRetention, "drt", 0, "how long finished deletion tasks are kept")
+	flag.DurationVar(&flagWebhookTimeout, "wht", 0, "webhook delivery timeout")
+	flag.IntVar(&flagWebhookMaxAttempts, "wha", 0, "webhook delivery attempts before dead-letter")
+	flag.DurationVar(&flagWebhookRetention, "whr", 0, "how long finished webhook deliveries are kept")
+	flag.StringVar(&flagJWTKeys, "jk", "", "JSON file with JWT signing keys")
+	flag.DurationVar(&flagJWTLifetime, "jl", 0, "user token lifetime")
+	flag.DurationVar(&flagJWTRefreshWindow, "jr", 0, "renew user token when it expires within this window (default - half of lifetime)")
+	flag.StringVar(&flagOIDCIssuer, "oi", "", "OpenID Connect issuer for single sign-on")
+	flag.StringVar(&flagOIDCClientID, "oc", "", "OpenID Connect client ID")
+	flag.StringVar(&flagOIDCRedirectURL, "or", "", "OpenID Connect redirect URL (default - base URL + "+oidcCallbackPath+")")
+	flag.StringVar(&flagOIDCAllowedDomains, "od", "", "email domains allowed to sign in (comma separated)")
 }
 
 // ParseConfig запускает создание конфигурации читая значения переменных окружения и флагов командной строки
@@ -135,7 +510,50 @@ func ParseConfig(logger *slog.Logger) (Config, error) {
 		DomainName      string `env:"DOMAIN" json:"domain_name"`
 		GRPC            string `env:"GRPC" json:"grpc"`
 		TrustedSubnet   string `env:"TRUSTED_SUBNET" json:"trusted_subnet"`
+		TrustedProxies  string `env:"TRUSTED_PROXIES" json:"trusted_proxies"`
 		EnableHTTPS     bool   `env:"ENABLE_HTTPS" json:"enable_https"`
+
+		ThreatList          string        `env:"THREAT_LIST" json:"threat_list"`
+		ThreatCheckInterval time.Duration `env:"THREAT_CHECK_INTERVAL" json:"threat_check_interval"`
+
+		LinkCheckInterval    time.Duration `env:"LINK_CHECK_INTERVAL" json:"link_check_interval"`
+		LinkCheckConcurrency int           `env:"LINK_CHECK_CONCURRENCY" json:"link_check_concurrency"`
+		LinkCheckHostDelay   time.Duration `env:"LINK_CHECK_HOST_DELAY" json:"link_check_host_delay"`
+		DeadLinkFallback     string        `env:"DEAD_LINK_FALLBACK" json:"dead_link_fallback"`
+
+		RedirectCode   int           `env:"REDIRECT_CODE" json:"redirect_code"`
+		RedirectMaxAge time.Duration `env:"REDIRECT_MAX_AGE" json:"redirect_max_age"`
+
+		JobWorkers   int           `env:"JOB_WORKERS" json:"job_workers"`
+		JobRetention time.Duration `env:"JOB_RETENTION" json:"job_retention"`
+
+		IdempotencyWindow time.Duration `env:"IDEMPOTENCY_WINDOW" json:"idempotency_window"`
+
+		AuditRetention time.Duration `env:"AUDIT_RETENTION" json:"audit_retention"`
+
+		RateLimitCreate   int `env:"RATE_LIMIT_CREATE" json:"rate_limit_create"`
+		RateLimitRedirect int `env:"RATE_LIMIT_REDIRECT" json:"rate_limit_redirect"`
+		RateLimitDelete   int `env:"RATE_LIMIT_DELETE" json:"rate_limit_delete"`
+		DailyLinkQuota    int `env:"DAILY_LINK_QUOTA" json:"daily_link_quota"`
+
+		DeleteFlushInterval time.Duration `env:"DELETE_FLUSH_INTERVAL" json:"delete_flush_interval"`
+		DeleteBatchSize     int           `env:"DELETE_BATCH_SIZE" json:"delete_batch_size"`
+		DeleteQueueCapacity int           `env:"DELETE_QUEUE_CAPACITY" json:"delete_queue_capacity"`
+		DeleteRetention     time.Duration `env:"DELETE_RETENTION" json:"delete_retention"`
+
+		WebhookTimeout     time.Duration `env:"WEBHOOK_TIMEOUT" json:"webhook_timeout"`
+		WebhookMaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" json:"webhook_max_attempts"`
+		WebhookRetention   time.Duration `env:"WEBHOOK_RETENTION" json:"webhook_retention"`
+
+		JWTKeys          string        `env:"JWT_KEYS" json:"jwt_keys"`
+		JWTLifetime      time.Duration `env:"JWT_LIFETIME" json:"jwt_lifetime"`
+		JWTRefreshWindow time.Duration `env:"JWT_REFRESH_WINDOW" json:"jwt_refresh_window"`
+
+		OIDCIssuer         string `env:"OIDC_ISSUER" json:"oidc_issuer"`
+		OIDCClientID       string `env:"OIDC_CLIENT_ID" json:"oidc_client_id"`
+		OIDCClientSecret   string `env:"OIDC_CLIENT_SECRET"`
+		OIDCRedirectURL    string `env:"OIDC_REDIRECT_URL" json:"oidc_redirect_url"`
+		OIDCAllowedDomains string `env:"OIDC_ALLOWED_DOMAINS" json:"oidc_allowed_domains"`
 	}
 
 	cfg := PublicConfig{}
@@ -170,13 +588,60 @@ func ParseConfig(logger *slog.Logger) (Config, error) {
 	cfg.DomainName = getConfigValue(cfg.DomainName, flagDomainName, cfgFromFile.DomainName, "", "")
 	cfg.EnableHTTPS = getConfigValue(cfg.EnableHTTPS, flagEnableHTTPS, cfgFromFile.EnableHTTPS, false, false)
 	cfg.GRPC = getConfigValue(cfg.GRPC, flagGRPC, cfgFromFile.GRPC, "", "")
+	cfg.ThreatList = getConfigValue(cfg.ThreatList, flagThreatList, cfgFromFile.ThreatList, "", "")
+	cfg.ThreatCheckInterval = getConfigValue(cfg.ThreatCheckInterval, flagThreatCheckInterval, cfgFromFile.ThreatCheckInterval, defaultThreatCheckInterval, 0)
+	cfg.LinkCheckInterval = getConfigValue(cfg.LinkCheckInterval, flagLinkCheckInterval, cfgFromFile.LinkCheckInterval, 0, 0)
+	cfg.LinkCheckConcurrency = getConfigValue(cfg.LinkCheckConcurrency, flagLinkCheckConcurrency, cfgFromFile.LinkCheckConcurrency, defaultLinkCheckConcurrency, 0)
+	cfg.LinkCheckHostDelay = getConfigValue(cfg.LinkCheckHostDelay, flagLinkCheckHostDelay, cfgFromFile.LinkCheckHostDelay, defaultLinkCheckHostDelay, 0)
+	cfg.DeadLinkFallback = getConfigValue(cfg.DeadLinkFallback, flagDeadLinkFallback, cfgFromFile.DeadLinkFallback, "", "")
+	cfg.RedirectCode = getConfigValue(cfg.RedirectCode, flagRedirectCode, cfgFromFile.RedirectCode, defaultRedirectCode, 0)
+	cfg.RedirectMaxAge = getConfigValue(cfg.RedirectMaxAge, flagRedirectMaxAge, cfgFromFile.RedirectMaxAge, defaultRedirectMaxAge, 0)
+	cfg.JobWorkers = getConfigValue(cfg.JobWorkers, flagJobWorkers, cfgFromFile.JobWorkers, defaultJobWorkers, 0)
+	cfg.JobRetention = getConfigValue(cfg.JobRetention, flagJobRetention, cfgFromFile.JobRetention, defaultJobRetention, 0)
+	cfg.IdempotencyWindow = getConfigValue(cfg.IdempotencyWindow, flagIdempotencyWindow, cfgFromFile.IdempotencyWindow, defaultIdempotencyWindow, 0)
+	cfg.AuditRetention = getConfigValue(cfg.AuditRetention, flagAuditRetention, cfgFromFile.AuditRetention, defaultAuditRetention, 0)
+	cfg.RateLimitCreate = getConfigValue(cfg.RateLimitCreate, flagRateLimitCreate, cfgFromFile.RateLimitCreate, 0, 0)
+	cfg.RateLimitRedirect = getConfigValue(cfg.RateLimitRedirect, flagRateLimitRedirect, cfgFromFile.RateLimitRedirect, 0, 0)
+	cfg.RateLimitDelete = getConfigValue(cfg.RateLimitDelete, flagRateLimitDelete, cfgFromFile.RateLimitDelete, 0, 0)
+	cfg.DailyLinkQuota = getConfigValue(cfg.DailyLinkQuota, flagDailyLinkQuota, cfgFromFile.DailyLinkQuota, 0, 0)
+	cfg.DeleteFlushInterval = getConfigValue(cfg.DeleteFlushInterval, flagDeleteFlushInterval, cfgFromFile.DeleteFlushInterval, defaultDeleteFlushInterval, 0)
+	cfg.DeleteBatchSize = getConfigValue(cfg.DeleteBatchSize, flagDeleteBatchSize, cfgFromFile.DeleteBatchSize, defaultDeleteBatchSize, 0)
+	cfg.DeleteQueueCapacity = getConfigValue(cfg.DeleteQueueCapacity, flagDeleteQueueCapacity, cfgFromFile.DeleteQueueCapacity, defaultDeleteQueueCapacity, 0)
+	cfg.DeleteRetention = getConfigValue(cfg.DeleteRetention, flagDeleteRetention, cfgFromFile.DeleteRetention, defaultDeleteRetention, 0)
+	cfg.WebhookTimeout = getConfigValue(cfg.WebhookTimeout, flagWebhookTimeout, cfgFromFile.WebhookTimeout, defaultWebhookTimeout, 0)
+	cfg.WebhookMaxAttempts = getConfigValue(cfg.WebhookMaxAttempts, flagWebhookMaxAttempts, cfgFromFile.WebhookMaxAttempts, defaultWebhookMaxAttempts, 0)
+	cfg.WebhookRetention = getConfigValue(cfg.WebhookRetention, flagWebhookRetention, cfgFromFile.WebhookRetention, defaultWebhookRetention, 0)
+	cfg.JWTKeys = getConfigValue(cfg.JWTKeys, flagJWTKeys, cfgFromFile.JWTKeys, "", "")
+	cfg.JWTLifetime = getConfigValue(cfg.JWTLifetime, flagJWTLifetime, cfgFromFile.JWTLifetime, defaultJWTLifetime, 0)
+	cfg.JWTRefreshWindow = getConfigValue(cfg.JWTRefreshWindow, flagJWTRefreshWindow, cfgFromFile.JWTRefreshWindow, cfg.JWTLifetime/2, 0)
+	if cfg.JWTRefreshWindow >= cfg.JWTLifetime {
+		return Config{}, fmt.Errorf("окно продления токена %s должно быть меньше времени жизни %s", cfg.JWTRefreshWindow, cfg.JWTLifetime)
+	}
+	cfg.OIDCIssuer = getConfigValue(cfg.OIDCIssuer, flagOIDCIssuer, cfgFromFile.OIDCIssuer, "", "")
+	cfg.OIDCClientID = getConfigValue(cfg.OIDCClientID, flagOIDCClientID, cfgFromFile.OIDCClientID, "", "")
+	cfg.OIDCRedirectURL = getConfigValue(cfg.OIDCRedirectURL, flagOIDCRedirectURL, cfgFromFile.OIDCRedirectURL, cfg.BaseAddress+oidcCallbackPath, "")
+	cfg.OIDCAllowedDomains = getConfigValue(cfg.OIDCAllowedDomains, flagOIDCAllowedDomains, cfgFromFile.OIDCAllowedDomains, "", "")
+	if cfg.OIDCIssuer != "" && cfg.OIDCClientID == "" {
+		return Config{}, fmt.Errorf("для входа через провайдера %s не задан идентификатор клиента", cfg.OIDCIssuer)
+	}
+	oidcDomains := splitList(cfg.OIDCAllowedDomains)
+	if !model.ValidRedirectCode(cfg.RedirectCode) {
+		return Config{}, fmt.Errorf("недопустимый код перенаправления %d", cfg.RedirectCode)
+	}
 
 	cfg.TrustedSubnet = getConfigValue(cfg.TrustedSubnet, flagTrustedSubnet, cfgFromFile.TrustedSubnet, "", "")
-	_, ipnet, err := net.ParseCIDR(cfg.TrustedSubnet)
-	// не стал выходить из функции, просто выведем ошибку
+	trustedSubnet, err := clientip.ParseSubnets(cfg.TrustedSubnet)
+	// не стал выходить из функции, просто выведем ошибку. доступ к внутреннему API при этом закрыт
 	if err != nil {
-		logger.Error("конвертация CIDR", slog.String("ошибка", err.Error()))
-		ipnet = &net.IPNet{}
+		logger.Error("разбор доверенных подсетей", slog.String("ошибка", err.Error()))
+		trustedSubnet = clientip.Subnets{}
+	}
+	cfg.TrustedProxies = getConfigValue(cfg.TrustedProxies, flagTrustedProxies, cfgFromFile.TrustedProxies, "", "")
+	trustedProxies, err := clientip.ParseSubnets(cfg.TrustedProxies)
+	// заголовки прокси при этом не учитываются
+	if err != nil {
+		logger.Error("разбор доверенных прокси", slog.String("ошибка", err.Error()))
+		trustedProxies = clientip.Subnets{}
 	}
 
 	if cfg.EnableHTTPS {
@@ -192,7 +657,38 @@ func ParseConfig(logger *slog.Logger) (Config, error) {
 		slog.Bool("статус https", cfg.EnableHTTPS),
 		slog.String("доменное имя", cfg.DomainName),
 		slog.String("gRPC", cfg.GRPC),
-		slog.String("CIDR", cfg.TrustedSubnet),
+		slog.String("CIDR", trustedSubnet.String()),
+		slog.String("доверенные прокси", trustedProxies.String()),
+		slog.String("список угроз", cfg.ThreatList),
+		slog.Duration("периодичность проверки по списку угроз", cfg.ThreatCheckInterval),
+		slog.Duration("периодичность проверки доступности ссылок", cfg.LinkCheckInterval),
+		slog.Int("одновременных проверок доступности", cfg.LinkCheckConcurrency),
+		slog.Duration("пауза между запросами к хосту", cfg.LinkCheckHostDelay),
+		slog.String("перенаправление для недоступных ссылок", cfg.DeadLinkFallback),
+		slog.Int("код перенаправления", cfg.RedirectCode),
+		slog.Duration("кэширование постоянных перенаправлений", cfg.RedirectMaxAge),
+		slog.Int("обработчиков фоновых заданий", cfg.JobWorkers),
+		slog.Duration("хранение завершенных заданий", cfg.JobRetention),
+		slog.Duration("хранение ключей идемпотентности", cfg.IdempotencyWindow),
+		slog.Duration("хранение журнала действий администраторов", cfg.AuditRetention),
+		slog.Int("создание ссылок в минуту", cfg.RateLimitCreate),
+		slog.Int("переходов в минуту", cfg.RateLimitRedirect),
+		slog.Int("удалений в минуту", cfg.RateLimitDelete),
+		slog.Int("суточная квота ссылок", cfg.DailyLinkQuota),
+		slog.Duration("периодичность удаления ссылок", cfg.DeleteFlushInterval),
+		slog.Int("ссылок за одно удаление", cfg.DeleteBatchSize),
+		slog.Int("размер очереди удаления", cfg.DeleteQueueCapacity),
+		slog.Duration("хранение выполненных задач удаления", cfg.DeleteRetention),
+		slog.Duration("ожидание ответа подписки", cfg.WebhookTimeout),
+		slog.Int("попыток доставки события", cfg.WebhookMaxAttempts),
+		slog.Duration("хранение завершенных доставок событий", cfg.WebhookRetention),
+		slog.String("файл ключей JWT", cfg.JWTKeys),
+		slog.Duration("время жизни токена", cfg.JWTLifetime),
+		slog.Duration("окно продления токена", cfg.JWTRefreshWindow),
+		slog.String("провайдер входа", cfg.OIDCIssuer),
+		slog.String("клиент провайдера входа", cfg.OIDCClientID),
+		slog.String("адрес возврата от провайдера входа", cfg.OIDCRedirectURL),
+		slog.String("разрешенные домены почты", strings.Join(oidcDomains, ",")),
 	)
 	return Config{
 		address:         cfg.Address,
@@ -201,11 +697,61 @@ func ParseConfig(logger *slog.Logger) (Config, error) {
 		databaseDSN:     cfg.DatabaseDSN,
 		secretKey:       cfg.SecretKey,
 		gRPC:            cfg.GRPC,
-		trustedSubnet:   ipnet,
+		trustedSubnet:   trustedSubnet,
+		trustedProxies:  trustedProxies,
 		configHTTPS: configHTTPS{
 			enable: cfg.EnableHTTPS,
 			domain: cfg.DomainName,
 		},
+		configThreat: configThreat{
+			list:          cfg.ThreatList,
+			checkInterval: cfg.ThreatCheckInterval,
+		},
+		configLinkCheck: configLinkCheck{
+			interval:    cfg.LinkCheckInterval,
+			concurrency: cfg.LinkCheckConcurrency,
+			hostDelay:   cfg.LinkCheckHostDelay,
+			fallback:    cfg.DeadLinkFallback,
+		},
+		configRedirect: configRedirect{
+			code:   cfg.RedirectCode,
+			maxAge: cfg.RedirectMaxAge,
+		},
+		configJobs: configJobs{
+			workers:   cfg.JobWorkers,
+			retention: cfg.JobRetention,
+		},
+		idempotencyWindow: cfg.IdempotencyWindow,
+		auditRetention:    cfg.AuditRetention,
+		configRateLimit: configRateLimit{
+			create:     cfg.RateLimitCreate,
+			redirect:   cfg.RateLimitRedirect,
+			delete:     cfg.RateLimitDelete,
+			dailyQuota: cfg.DailyLinkQuota,
+		},
+		configDeletion: configDeletion{
+			flushInterval: cfg.DeleteFlushInterval,
+			batchSize:     cfg.DeleteBatchSize,
+			capacity:      cfg.DeleteQueueCapacity,
+			retention:     cfg.DeleteRetention,
+		},
+		configWebhook: configWebhook{
+			timeout:     cfg.WebhookTimeout,
+			maxAttempts: cfg.WebhookMaxAttempts,
+			retention:   cfg.WebhookRetention,
+		},
+		configJWT: configJWT{
+			keys:          cfg.JWTKeys,
+			lifetime:      cfg.JWTLifetime,
+			refreshWindow: cfg.JWTRefreshWindow,
+		},
+		configOIDC: configOIDC{
+			issuer:         cfg.OIDCIssuer,
+			clientID:       cfg.OIDCClientID,
+			clientSecret:   cfg.OIDCClientSecret,
+			redirectURL:    cfg.OIDCRedirectURL,
+			allowedDomains: oidcDomains,
+		},
 	}, nil
 }
 
@@ -249,3 +795,14 @@ func addressForHTTPS(address string) string {
 	}
 	return address
 }
+
+// splitList разбирает список значений через запятую. пустые значения пропускаются
+func splitList(list string) []string {
+	values := []string{}
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,7 +16,51 @@ func TestParseConfig(t *testing.T) {
 	assert.EqualValues(t, defaultAddress, cfg.Address())
 	assert.EqualValues(t, defaultBaseAddress, cfg.BaseAddress())
 	assert.EqualValues(t, defaultStorageFilePath, cfg.FileStoragePath())
-	assert.EqualValues(t, "<nil>", cfg.TrustedSubnet().String())
+	assert.Empty(t, cfg.TrustedSubnets())
+	assert.EqualValues(t, defaultRedirectCode, cfg.RedirectCode())
+	assert.EqualValues(t, defaultRedirectMaxAge, cfg.RedirectMaxAge())
+	assert.EqualValues(t, defaultJobWorkers, cfg.JobWorkers())
+	assert.EqualValues(t, defaultIdempotencyWindow, cfg.IdempotencyWindow())
+	assert.EqualValues(t, defaultDeleteFlushInterval, cfg.DeleteFlushInterval())
+	assert.EqualValues(t, defaultDeleteBatchSize, cfg.DeleteBatchSize())
+	assert.EqualValues(t, defaultDeleteQueueCapacity, cfg.DeleteQueueCapacity())
+	assert.EqualValues(t, defaultWebhookTimeout, cfg.WebhookTimeout())
+	assert.EqualValues(t, defaultWebhookMaxAttempts, cfg.WebhookMaxAttempts())
+}
+
+func TestRedirectCode(t *testing.T) {
+	defer os.Unsetenv("REDIRECT_CODE")
+
+	os.Setenv("REDIRECT_CODE", "301")
+	cfg, err := ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.EqualValues(t, 301, cfg.RedirectCode())
+
+	os.Setenv("REDIRECT_CODE", "200")
+	_, err = ParseConfig(slog.Default())
+	assert.Error(t, err)
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg, err := ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.Zero(t, cfg.RateLimitCreate())
+	assert.Zero(t, cfg.DailyLinkQuota())
+
+	defer os.Unsetenv("RATE_LIMIT_CREATE")
+	defer os.Unsetenv("RATE_LIMIT_REDIRECT")
+	defer os.Unsetenv("RATE_LIMIT_DELETE")
+	defer os.Unsetenv("DAILY_LINK_QUOTA")
+	os.Setenv("RATE_LIMIT_CREATE", "10")
+	os.Setenv("RATE_LIMIT_REDIRECT", "600")
+	os.Setenv("RATE_LIMIT_DELETE", "5")
+	os.Setenv("DAILY_LINK_QUOTA", "1000")
+	cfg, err = ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.EqualValues(t, 10, cfg.RateLimitCreate())
+	assert.EqualValues(t, 600, cfg.RateLimitRedirect())
+	assert.EqualValues(t, 5, cfg.RateLimitDelete())
+	assert.EqualValues(t, 1000, cfg.DailyLinkQuota())
 }
 
 func TestConfigEnv(t *testing.T) {
@@ -58,7 +103,7 @@ func TestConfigEnv(t *testing.T) {
 	assert.EqualValues(t, database, cfg.DatabaseDSN())
 	assert.EqualValues(t, secretKey, cfg.SecretKey())
 	assert.EqualValues(t, gRPC, cfg.GRPC())
-	assert.EqualValues(t, "<nil>", cfg.TrustedSubnet().String())
+	assert.Empty(t, cfg.TrustedSubnets())
 	assert.True(t, cfg.HTTPS())
 
 	os.Setenv("ENABLE_HTTPS", "")
@@ -95,7 +140,26 @@ func TestConfigEnv(t *testing.T) {
 	os.Setenv("TRUSTED_SUBNET", trustedSubnet)
 	cfg, err = ParseConfig(slog.Default())
 	require.NoError(t, err)
-	assert.EqualValues(t, trustedSubnet, cfg.TrustedSubnet().String())
+	assert.EqualValues(t, trustedSubnet, cfg.TrustedSubnets().String())
+}
+
+func TestTrustedSubnets(t *testing.T) {
+	defer os.Unsetenv("TRUSTED_SUBNET")
+	defer os.Unsetenv("TRUSTED_PROXIES")
+	os.Setenv("TRUSTED_SUBNET", "192.168.1.0/24, fd00::/8")
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.1,10.1.0.0/16")
+	cfg, err := ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.EqualValues(t, "192.168.1.0/24,fd00::/8", cfg.TrustedSubnets().String())
+	assert.EqualValues(t, "10.0.0.1/32,10.1.0.0/16", cfg.TrustedProxies().String())
+
+	// при ошибке в списке доступ закрыт, а заголовки прокси не учитываются
+	os.Setenv("TRUSTED_SUBNET", "192.168.1.0/24,bad")
+	os.Setenv("TRUSTED_PROXIES", "10.0.0.1/99")
+	cfg, err = ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.Empty(t, cfg.TrustedSubnets())
+	assert.Empty(t, cfg.TrustedProxies())
 }
 
 func TestHTTPS(t *testing.T) {
@@ -154,6 +218,65 @@ func TestConfigFile(t *testing.T) {
 	assert.EqualValues(t, fileStorage, cfg.FileStoragePath())
 	assert.EqualValues(t, database, cfg.DatabaseDSN())
 	assert.EqualValues(t, gRPC, cfg.GRPC())
-	assert.EqualValues(t, trustedSubnet, cfg.TrustedSubnet().String())
+	assert.EqualValues(t, trustedSubnet, cfg.TrustedSubnets().String())
 	assert.True(t, cfg.HTTPS())
 }
+
+func TestJWT(t *testing.T) {
+	cfg, err := ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.Empty(t, cfg.JWTKeys())
+	assert.EqualValues(t, defaultJWTLifetime, cfg.JWTLifetime())
+	assert.EqualValues(t, defaultJWTLifetime/2, cfg.JWTRefreshWindow())
+
+	defer os.Unsetenv("JWT_KEYS")
+	defer os.Unsetenv("JWT_LIFETIME")
+	defer os.Unsetenv("JWT_REFRESH_WINDOW")
+	os.Setenv("JWT_KEYS", "/etc/shortener/jwt.json")
+	os.Setenv("JWT_LIFETIME", "720h")
+	cfg, err = ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.EqualValues(t, "/etc/shortener/jwt.json", cfg.JWTKeys())
+	assert.EqualValues(t, 720*time.Hour, cfg.JWTLifetime())
+	assert.EqualValues(t, 360*time.Hour, cfg.JWTRefreshWindow())
+
+	os.Setenv("JWT_REFRESH_WINDOW", "24h")
+	cfg, err = ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.EqualValues(t, 24*time.Hour, cfg.JWTRefreshWindow())
+
+	// продлевать токен на всем времени жизни нельзя
+	os.Setenv("JWT_REFRESH_WINDOW", "720h")
+	_, err = ParseConfig(slog.Default())
+	assert.Error(t, err)
+}
+
+func TestOIDC(t *testing.T) {
+	cfg, err := ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.Empty(t, cfg.OIDCIssuer())
+	assert.Equal(t, "http://localhost:8080/api/user/oidc/callback", cfg.OIDCRedirectURL())
+	assert.Empty(t, cfg.OIDCAllowedDomains())
+
+	defer os.Unsetenv("OIDC_ISSUER")
+	defer os.Unsetenv("OIDC_CLIENT_ID")
+	defer os.Unsetenv("OIDC_CLIENT_SECRET")
+	defer os.Unsetenv("OIDC_ALLOWED_DOMAINS")
+	defer os.Unsetenv("BASE_URL")
+	os.Setenv("OIDC_ISSUER", "https://idp.example.com")
+	// без идентификатора клиента вход через провайдера не настроить
+	_, err = ParseConfig(slog.Default())
+	assert.Error(t, err)
+
+	os.Setenv("OIDC_CLIENT_ID", "shortener")
+	os.Setenv("OIDC_CLIENT_SECRET", "secret")
+	os.Setenv("OIDC_ALLOWED_DOMAINS", "example.com, corp.example.com,")
+	os.Setenv("BASE_URL", "https://s.example.com")
+	cfg, err = ParseConfig(slog.Default())
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com", cfg.OIDCIssuer())
+	assert.Equal(t, "shortener", cfg.OIDCClientID())
+	assert.Equal(t, "secret", cfg.OIDCClientSecret())
+	assert.Equal(t, "https://s.example.com/api/user/oidc/callback", cfg.OIDCRedirectURL())
+	assert.Equal(t, []string{"example.com", "corp.example.com"}, cfg.OIDCAllowedDomains())
+}
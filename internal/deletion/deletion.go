@@ -0,0 +1,264 @@
+// пакет deletion реализует очередь удаления ссылок пользователей: запрос на удаление сохраняется в хранилище
+// задачей и подтверждается сразу, ссылки удаляются частями по расписанию или при накоплении части.
+// ожидающие задачи переживают перезапуск при хранении в БД (хранилище в памяти их не сохраняет),
+// при остановке сервиса очередь удаляет все, что успела принять. выполненные задачи удаляются по истечении времени хранения
+package deletion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+const (
+	defaultFlushInterval = 5 * time.Second
+	defaultBatchSize     = 100
+	defaultCapacity      = 10000
+	defaultRetention     = 24 * time.Hour
+	// maxPurgeInterval наибольшая периодичность удаления выполненных задач
+	maxPurgeInterval = time.Hour
+
+	// drainTimeout время на удаление принятых ссылок при остановке сервиса
+	drainTimeout = 30 * time.Second
+)
+
+// ErrQueueFull в очереди нет места для ссылок запроса
+var ErrQueueFull = errors.New("очередь удаления переполнена")
+
+// Options настройки очереди удаления
+type Options struct {
+	// FlushInterval периодичность удаления накопившихся ссылок
+	FlushInterval time.Duration
+	// BatchSize количество ссылок, удаляемых за одно обращение к хранилищу. при накоплении такого количества
+	// удаление начинается, не дожидаясь FlushInterval
+	BatchSize int
+	// Capacity наибольшее количество ожидающих удаления ссылок. запросы сверх него отклоняются
+	Capacity int
+	// Retention время хранения выполненной задачи, после него задача удаляется
+	Retention time.Duration
+	// Logger логгер ошибок удаления. если не задан - slog.Default()
+	Logger *slog.Logger
+}
+
+// Queue очередь удаления ссылок
+type Queue struct {
+	store   storage.Storager
+	opts    Options
+	pending atomic.Int64
+	wake    chan struct{}
+}
+
+// New создает новый экземпляр Queue, хранящий задачи в store.
+// Незаполненные настройки заменяются значениями по умолчанию
+func New(store storage.Storager, opts Options) *Queue {
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.Capacity <= 0 {
+		opts.Capacity = defaultCapacity
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Queue{
+		store: store,
+		opts:  opts,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// FlushInterval периодичность удаления - через столько стоит повторить отклоненный запрос
+func (q *Queue) FlushInterval() time.Duration {
+	return q.opts.FlushInterval
+}
+
+// Pending количество ожидающих удаления ссылок
+func (q *Queue) Pending() int {
+	return int(q.pending.Load())
+}
+
+// Check проверка готовности: ошибка, если очередь заполнена и новые запросы на удаление отклоняются
+func (q *Queue) Check(ctx context.Context) error {
+	if pending := q.Pending(); pending >= q.opts.Capacity {
+		return fmt.Errorf("в очереди %d ссылок из %d. %w", pending, q.opts.Capacity, ErrQueueFull)
+	}
+	return nil
+}
+
+// Enqueue сохраняет задачу удаления ссылок shorts пользователя userID.
+// если в очереди нет места - ошибка с кодом apierror.CodeDeleteQueueFull
+func (q *Queue) Enqueue(ctx context.Context, userID uuid.UUID, shorts []string) (model.DeleteTask, error) {
+	n := int64(len(shorts))
+	if q.pending.Add(n) > int64(q.opts.Capacity) {
+		q.pending.Add(-n)
+		return model.DeleteTask{}, apierror.Wrap(apierror.CodeDeleteQueueFull, ErrQueueFull)
+	}
+
+	now := time.Now().UTC()
+	task := model.DeleteTask{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Status:    model.JobQueued,
+		Total:     len(shorts),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Results:   make([]model.DeleteResult, 0, len(shorts)),
+	}
+	for _, short := range shorts {
+		task.Results = append(task.Results, model.DeleteResult{ShortURL: short, Status: model.DeletePending})
+	}
+	if err := q.store.EnqueueDelete(ctx, task); err != nil {
+		q.pending.Add(-n)
+		return model.DeleteTask{}, fmt.Errorf("постановка в очередь удаления. %w", err)
+	}
+	if q.pending.Load() >= int64(q.opts.BatchSize) {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return task, nil
+}
+
+// Task задача удаления id пользователя userID. о чужих задачах не сообщается, что они существуют
+func (q *Queue) Task(ctx context.Context, userID uuid.UUID, id uuid.UUID) (model.DeleteTask, error) {
+	task, err := q.store.DeleteTask(ctx, id)
+	if err != nil {
+		return model.DeleteTask{}, err
+	}
+	if task.UserID != userID {
+		return model.DeleteTask{}, storage.ErrTaskNotFound
+	}
+	return task, nil
+}
+
+// Restore учитывает ссылки задач, оставшихся в очереди с предыдущего запуска.
+// вызывается до приема новых запросов, иначе их задачи будут учтены дважды
+func (q *Queue) Restore(ctx context.Context) error {
+	tasks, err := q.store.PendingDeletes(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("получение ожидающих задач удаления. %w", err)
+	}
+	links := 0
+	for _, task := range tasks {
+		links += task.Total
+	}
+	q.pending.Add(int64(links))
+	if links > 0 {
+		q.opts.Logger.Info("в очереди удаления остались ссылки с предыдущего запуска", slog.Int("количество", links))
+	}
+	return nil
+}
+
+// Run удаляет ссылки ожидающих задач до отмены ctx, включая оставшиеся с предыдущего запуска.
+// после отмены ctx удаляет все принятые к этому моменту ссылки
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(q.opts.FlushInterval)
+	defer ticker.Stop()
+	purge := time.NewTicker(min(q.opts.Retention, maxPurgeInterval))
+	defer purge.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			q.drain()
+			return
+		case <-purge.C:
+			q.purge(ctx)
+			continue
+		case <-ticker.C:
+		case <-q.wake:
+		}
+		if err := q.flushAll(ctx); err != nil && ctx.Err() == nil {
+			q.opts.Logger.Error("удаление ссылок", slog.String("ошибка", err.Error()))
+		}
+	}
+}
+
+// purge удаляет выполненные задачи старше времени хранения
+func (q *Queue) purge(ctx context.Context) {
+	count, err := q.store.PurgeDeletes(ctx, time.Now().Add(-q.opts.Retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			q.opts.Logger.Error("удаление выполненных задач удаления", slog.String("ошибка", err.Error()))
+		}
+		return
+	}
+	if count > 0 {
+		q.opts.Logger.Debug("удалены выполненные задачи удаления", slog.Int("количество", count))
+	}
+}
+
+// drain удаляет все ожидающие ссылки при остановке сервиса
+func (q *Queue) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	if err := q.flushAll(ctx); err != nil {
+		q.opts.Logger.Error("удаление ссылок при остановке", slog.String("ошибка", err.Error()), slog.Int("осталось", q.Pending()))
+	}
+}
+
+// flushAll удаляет ожидающие ссылки частями, пока очередь не опустеет
+func (q *Queue) flushAll(ctx context.Context) error {
+	for {
+		n, err := q.flush(ctx)
+		if err != nil || n == 0 {
+			return err
+		}
+	}
+}
+
+// flush удаляет ссылки очередной части задач и сохраняет результаты. возвращает количество обработанных задач.
+// если хранилище не смогло выполнить удаление целиком - задачи остаются в очереди до следующей попытки
+func (q *Queue) flush(ctx context.Context) (int, error) {
+	tasks, err := q.store.PendingDeletes(ctx, q.opts.BatchSize)
+	if err != nil || len(tasks) == 0 {
+		return 0, err
+	}
+	messages := make([]model.DeleteURLMessage, 0, q.opts.BatchSize)
+	for _, task := range tasks {
+		messages = append(messages, task.Messages()...)
+	}
+	failures, err := storage.LinkErrors(q.store.DeleteURLs(ctx, messages))
+	if err != nil {
+		return 0, fmt.Errorf("удаление ссылок. %w", err)
+	}
+
+	now := time.Now()
+	for _, task := range tasks {
+		results := make([]model.DeleteResult, 0, len(task.Results))
+		for _, msg := range task.Messages() {
+			results = append(results, result(msg, failures[msg]))
+		}
+		if err = q.store.CompleteDelete(ctx, task.ID, results); err != nil {
+			return 0, fmt.Errorf("сохранение результатов удаления. %w", err)
+		}
+		q.pending.Add(-int64(task.Total))
+		q.opts.Logger.Debug("задача удаления выполнена", slog.String("задача", task.ID.String()), slog.Duration("ожидание", now.Sub(task.CreatedAt)))
+	}
+	return len(tasks), nil
+}
+
+// result результат удаления ссылки msg с ошибкой err
+func result(msg model.DeleteURLMessage, err error) model.DeleteResult {
+	switch {
+	case err == nil:
+		return model.DeleteResult{ShortURL: msg.ShortURL, Status: model.DeleteDone}
+	case errors.Is(err, storage.ErrURLNotFound):
+		return model.DeleteResult{ShortURL: msg.ShortURL, Status: model.DeleteNotFound}
+	}
+	return model.DeleteResult{ShortURL: msg.ShortURL, Status: model.DeleteFailed, Reason: string(apierror.From(err).Code)}
+}
@@ -0,0 +1,188 @@
+package deletion
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveLinks сохраняет ссылки пользователя userID и возвращает их короткие варианты
+func saveLinks(t *testing.T, store storage.Storager, userID uuid.UUID, shorts ...string) {
+	t.Helper()
+	for _, short := range shorts {
+		_, err := store.SaveURL(context.Background(), userID, "https://go.dev/"+short, short, model.LinkOptions{})
+		require.NoError(t, err)
+	}
+}
+
+func TestQueue(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	q := New(store, Options{FlushInterval: time.Hour, BatchSize: 2, Capacity: 5})
+	ctx := context.Background()
+	user, other := uuid.New(), uuid.New()
+	saveLinks(t, store, user, "a", "b")
+	saveLinks(t, store, other, "c")
+
+	first, err := q.Enqueue(ctx, user, []string{"a", "c"})
+	require.NoError(t, err)
+	second, err := q.Enqueue(ctx, user, []string{"b", "x", "a"})
+	require.NoError(t, err)
+	assert.Equal(t, 5, q.Pending())
+
+	// места для новых ссылок нет
+	_, err = q.Enqueue(ctx, user, []string{"y"})
+	assert.Equal(t, apierror.CodeDeleteQueueFull, apierror.CodeOf(err))
+	assert.Equal(t, 5, q.Pending())
+	assert.ErrorIs(t, q.Check(ctx), ErrQueueFull)
+
+	// за один раз удаляется не меньше одной задачи, пока не наберется BatchSize ссылок
+	n, err := q.flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 3, q.Pending())
+	task, err := q.Task(ctx, user, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.JobDone, task.Status)
+	assert.Equal(t, []model.DeleteResult{
+		{ShortURL: "a", Status: model.DeleteDone},
+		{ShortURL: "c", Status: model.DeleteNotFound},
+	}, task.Results)
+
+	require.NoError(t, q.flushAll(ctx))
+	assert.Zero(t, q.Pending())
+	assert.NoError(t, q.Check(ctx))
+	task, err = q.Task(ctx, user, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, task.Deleted)
+	assert.Equal(t, 1, task.NotFound)
+
+	link, err := store.RealURL(ctx, "c")
+	require.NoError(t, err)
+	assert.False(t, link.IsDeleted, "ссылка другого пользователя не удаляется")
+
+	// чужая задача
+	_, err = q.Task(ctx, other, second.ID)
+	assert.ErrorIs(t, err, storage.ErrTaskNotFound)
+}
+
+func TestRunDrain(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	ctx := context.Background()
+	user := uuid.New()
+	saveLinks(t, store, user, "a", "b")
+
+	// задача, оставшаяся с предыдущего запуска
+	left, err := New(store, Options{}).Enqueue(ctx, user, []string{"a"})
+	require.NoError(t, err)
+
+	q := New(store, Options{FlushInterval: time.Hour})
+	require.NoError(t, q.Restore(ctx))
+	assert.Equal(t, 1, q.Pending())
+	runCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		q.Run(runCtx)
+		close(stopped)
+	}()
+	task, err := q.Enqueue(ctx, user, []string{"b"})
+	require.NoError(t, err)
+
+	// при остановке удаляется все принятое
+	cancel()
+	<-stopped
+	assert.Zero(t, q.Pending())
+	for _, id := range []uuid.UUID{left.ID, task.ID} {
+		task, err = q.Task(ctx, user, id)
+		require.NoError(t, err)
+		assert.Equal(t, model.JobDone, task.Status)
+		assert.Equal(t, 1, task.Deleted)
+	}
+}
+
+func TestRunPurge(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	ctx := context.Background()
+	user := uuid.New()
+	saveLinks(t, store, user, "a")
+
+	q := New(store, Options{FlushInterval: time.Millisecond, Retention: 50 * time.Millisecond})
+	runCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		q.Run(runCtx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+	task, err := q.Enqueue(ctx, user, []string{"a"})
+	require.NoError(t, err)
+
+	// выполненная задача удаляется по истечении времени хранения
+	require.Eventually(t, func() bool {
+		_, err := q.Task(ctx, user, task.ID)
+		return errors.Is(err, storage.ErrTaskNotFound)
+	}, 5*time.Second, 10*time.Millisecond)
+	link, err := store.RealURL(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, link.IsDeleted)
+}
+
+// failingStore хранилище, которое не может выполнить удаление
+type failingStore struct {
+	*memory.Storage
+}
+
+// DeleteURLs реализация интерфейса Storager
+func (fs failingStore) DeleteURLs(ctx context.Context, deleteLinks []model.DeleteURLMessage) error {
+	return errors.New("соединение потеряно")
+}
+
+func TestFlushStorageError(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	q := New(failingStore{store}, Options{})
+	ctx := context.Background()
+
+	task, err := q.Enqueue(ctx, uuid.New(), []string{"a"})
+	require.NoError(t, err)
+	_, err = q.flush(ctx)
+	assert.Error(t, err)
+
+	// задача остается в очереди до следующей попытки
+	assert.Equal(t, 1, q.Pending())
+	got, err := store.DeleteTask(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.JobQueued, got.Status)
+}
+
+func TestLinkErrors(t *testing.T) {
+	a := model.DeleteURLMessage{UserID: "u", ShortURL: "a"}
+	b := model.DeleteURLMessage{UserID: "u", ShortURL: "b"}
+	failures, err := storage.LinkErrors(errors.Join(
+		&storage.DeleteError{Link: a, Err: storage.ErrURLNotFound},
+		&storage.DeleteError{Link: b, Err: errors.New("ошибка")},
+	))
+	require.NoError(t, err)
+	assert.Len(t, failures, 2)
+	assert.Equal(t, model.DeleteNotFound, result(a, failures[a]).Status)
+	res := result(b, failures[b])
+	assert.Equal(t, model.DeleteFailed, res.Status)
+	assert.Equal(t, string(apierror.CodeInternal), res.Reason)
+
+	_, err = storage.LinkErrors(errors.New("ошибка транзакции"))
+	assert.Error(t, err)
+}
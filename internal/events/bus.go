@@ -0,0 +1,170 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+const (
+	defaultBusBuffer          = 1000
+	defaultSubscriptionBuffer = 100
+
+	// drainTimeout время на обработку событий, полученных до остановки
+	drainTimeout = 10 * time.Second
+)
+
+// BusOptions настройки шины событий
+type BusOptions struct {
+	// Buffer количество событий, ожидающих обработки. события сверх буфера отбрасываются
+	Buffer int
+	// Logger логгер ошибок. если не задан - slog.Default()
+	Logger *slog.Logger
+}
+
+// Bus шина событий внутри процесса. обработчики публикуют в нее события, а шина передает их
+// получателям всех событий (Attach) и подпискам пользователей на события своих ссылок (Subscribe).
+// переходы по ссылкам учитываются в хранилище, и получатели видят владельца ссылки и количество переходов
+type Bus struct {
+	store  storage.Storager
+	opts   BusOptions
+	events chan model.Event
+
+	mu    sync.Mutex
+	sinks []Publisher
+	subs  map[*Subscription]struct{}
+}
+
+// Subscription подписка пользователя на события его ссылок
+type Subscription struct {
+	bus    *Bus
+	userID uuid.UUID
+	events chan model.Event
+	closed bool
+}
+
+// NewBus создает новый экземпляр Bus, учитывающий переходы в хранилище store
+func NewBus(store storage.Storager, opts BusOptions) *Bus {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultBusBuffer
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Bus{
+		store:  store,
+		opts:   opts,
+		events: make(chan model.Event, opts.Buffer),
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish ставит событие e в очередь шины. не блокирует: если очередь заполнена, событие отбрасывается.
+// для nil ничего не делает
+func (b *Bus) Publish(e model.Event) {
+	if b == nil {
+		return
+	}
+	select {
+	case b.events <- e:
+	default:
+		b.opts.Logger.Warn("очередь шины событий заполнена, событие пропущено", slog.String("тип", string(e.Type)), slog.String("short", e.ShortURL))
+	}
+}
+
+// Attach добавляет получателя всех событий шины
+func (b *Bus) Attach(p Publisher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sinks = append(b.sinks, p)
+}
+
+// Subscribe подписывает на события ссылок пользователя userID. если подписчик не успевает читать
+// и в буфере подписки накопилось buffer событий (по умолчанию 100), новые события ему не передаются.
+// подписку нужно закрыть вызовом Close
+func (b *Bus) Subscribe(userID uuid.UUID, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = defaultSubscriptionBuffer
+	}
+	sub := &Subscription{bus: b, userID: userID, events: make(chan model.Event, buffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Events канал событий подписки. закрывается после Close
+func (sub *Subscription) Events() <-chan model.Event {
+	return sub.events
+}
+
+// Close отменяет подписку. повторный вызов ничего не делает
+func (sub *Subscription) Close() {
+	sub.bus.mu.Lock()
+	defer sub.bus.mu.Unlock()
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(sub.bus.subs, sub)
+	close(sub.events)
+}
+
+// Run передает события получателям до отмены ctx. события, полученные до отмены, также передаются
+func (b *Bus) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			b.drain()
+			return
+		case e := <-b.events:
+			b.handle(context.WithoutCancel(ctx), e)
+		}
+	}
+}
+
+// drain передает события, оставшиеся в очереди
+func (b *Bus) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	for {
+		select {
+		case e := <-b.events:
+			b.handle(ctx, e)
+		default:
+			return
+		}
+	}
+}
+
+// handle учитывает переход по ссылке и передает событие e получателям
+func (b *Bus) handle(ctx context.Context, e model.Event) {
+	if e.Type == model.EventLinkClicked {
+		clicks, err := b.store.CountClick(ctx, e.ShortURL)
+		if err != nil {
+			b.opts.Logger.Error("учет перехода по ссылке", slog.String("short", e.ShortURL), slog.String("ошибка", err.Error()))
+			return
+		}
+		e.UserID, e.Clicks = clicks.UserID, clicks.Clicks
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sink := range b.sinks {
+		sink.Publish(e)
+	}
+	for sub := range b.subs {
+		if sub.userID != e.UserID {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receive следующее событие подписки
+func receive(t *testing.T, sub *Subscription) model.Event {
+	t.Helper()
+	select {
+	case e, ok := <-sub.Events():
+		require.True(t, ok, "подписка закрыта")
+		return e
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "событие не получено")
+	}
+	return model.Event{}
+}
+
+func TestBus(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	user, other := uuid.New(), uuid.New()
+	_, err = store.SaveURL(ctx, user, "https://go.dev", "go", model.LinkOptions{})
+	require.NoError(t, err)
+
+	bus := NewBus(store, BusOptions{})
+	all := &recorder{}
+	bus.Attach(all)
+	sub := bus.Subscribe(user, 0)
+	defer sub.Close()
+	otherSub := bus.Subscribe(other, 0)
+	go bus.Run(ctx)
+
+	// события чужих ссылок подписке не передаются
+	bus.Publish(New(model.EventLinkCreated, other, "other", "https://example.com"))
+	bus.Publish(New(model.EventLinkCreated, user, "go", "https://go.dev"))
+	bus.Publish(New(model.EventLinkClicked, uuid.Nil, "go", "https://go.dev"))
+	bus.Publish(New(model.EventLinkClicked, uuid.Nil, "go", "https://go.dev"))
+	// переход по несуществующей ссылке не учитывается
+	bus.Publish(New(model.EventLinkClicked, uuid.Nil, "missing", ""))
+	bus.Publish(New(model.EventLinkDeleted, user, "go", ""))
+
+	assert.Equal(t, model.EventLinkCreated, receive(t, sub).Type)
+	for i := int64(1); i <= 2; i++ {
+		e := receive(t, sub)
+		assert.Equal(t, model.EventLinkClicked, e.Type)
+		assert.Equal(t, user, e.UserID, "владелец ссылки определяется при учете перехода")
+		assert.Equal(t, i, e.Clicks)
+	}
+	assert.Equal(t, model.EventLinkDeleted, receive(t, sub).Type)
+	assert.Equal(t, "other", receive(t, otherSub).ShortURL)
+
+	otherSub.Close()
+	otherSub.Close()
+	_, ok := <-otherSub.Events()
+	assert.False(t, ok)
+	cancel()
+	assert.Len(t, *all, 5)
+}
+
+func TestBusSlowSubscriber(t *testing.T) {
+	bus := NewBus(nil, BusOptions{})
+	user := uuid.New()
+	sub := bus.Subscribe(user, 1)
+	defer sub.Close()
+
+	// подписчик, не успевающий читать, не задерживает шину
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus.Publish(New(model.EventLinkCreated, user, "first", ""))
+	bus.Publish(New(model.EventLinkCreated, user, "second", ""))
+	bus.Run(ctx)
+	assert.Equal(t, "first", receive(t, sub).ShortURL)
+	select {
+	case e := <-sub.Events():
+		assert.Fail(t, "лишнее событие", e.ShortURL)
+	default:
+	}
+}
+
+func TestBusPublishNil(t *testing.T) {
+	var bus *Bus
+	assert.NotPanics(t, func() { bus.Publish(New(model.EventLinkCreated, uuid.New(), "go", "")) })
+}
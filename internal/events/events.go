@@ -0,0 +1,106 @@
+// пакет events описывает публикацию событий жизненного цикла ссылок: создание, удаление и переходы.
+// события создания и удаления публикует хранилище-обертка WithEvents, поэтому они возникают на всех путях
+// сохранения (utils.SaveLink, utils.SaveBatch и все их вызовы) и удаления (очередь удаления, gRPC).
+// события передаются через шину Bus доставке подписок и потокам событий пользователей
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// Publisher получатель событий. Publish не должен блокировать вызывающего
+type Publisher interface {
+	Publish(e model.Event)
+}
+
+// New создает событие типа t по ссылке short пользователя userID
+func New(t model.EventType, userID uuid.UUID, short, original string) model.Event {
+	return model.Event{
+		ID:          uuid.New(),
+		Type:        t,
+		UserID:      userID,
+		ShortURL:    short,
+		OriginalURL: original,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// eventStore хранилище, публикующее события по сохраненным и удаленным ссылкам
+type eventStore struct {
+	storage.Storager
+	publisher Publisher
+}
+
+// WithEvents возвращает хранилище, которое после успешного сохранения новых ссылок публикует в publisher события
+// link.created, а после удаления (в том числе окончательного) - link.deleted. Ссылки, сокращенные ранее (конфликт), событий не порождают.
+// publisher == nil - события не публикуются
+func WithEvents(store storage.Storager, publisher Publisher) storage.Storager {
+	if publisher == nil {
+		return store
+	}
+	return &eventStore{Storager: store, publisher: publisher}
+}
+
+// SaveURL реализация интерфейса Storager
+func (s *eventStore) SaveURL(ctx context.Context, userID uuid.UUID, real, short string, opts model.LinkOptions) (string, error) {
+	saved, err := s.Storager.SaveURL(ctx, userID, real, short, opts)
+	if err == nil {
+		s.publisher.Publish(New(model.EventLinkCreated, userID, saved, real))
+	}
+	return saved, err
+}
+
+// Batch реализация интерфейса Storager
+func (s *eventStore) Batch(ctx context.Context, userID uuid.UUID, values model.BatchRequest) (model.BatchResponse, error) {
+	resp, err := s.Storager.Batch(ctx, userID, values)
+	if err != nil {
+		return resp, err
+	}
+	for _, v := range resp {
+		if v.ShortURL != "" && v.Error == nil {
+			s.publisher.Publish(New(model.EventLinkCreated, userID, v.ShortURL, v.OriginalURL))
+		}
+	}
+	return resp, nil
+}
+
+// DeleteURLs реализация интерфейса Storager
+func (s *eventStore) DeleteURLs(ctx context.Context, deleteLinks []model.DeleteURLMessage) error {
+	err := s.Storager.DeleteURLs(ctx, deleteLinks)
+	failures, linkErr := storage.LinkErrors(err)
+	if linkErr != nil {
+		return err
+	}
+	for _, msg := range deleteLinks {
+		if _, failed := failures[msg]; failed {
+			continue
+		}
+		userID, parseErr := uuid.Parse(msg.UserID)
+		if parseErr != nil {
+			continue
+		}
+		s.publisher.Publish(New(model.EventLinkDeleted, userID, msg.ShortURL, ""))
+	}
+	return err
+}
+
+// PurgeURLs реализация интерфейса Storager
+func (s *eventStore) PurgeURLs(ctx context.Context, shorts []string) ([]model.StorageJSONWithUserID, error) {
+	purged, err := s.Storager.PurgeURLs(ctx, shorts)
+	if err != nil {
+		return purged, err
+	}
+	for _, link := range purged {
+		userID, parseErr := uuid.Parse(link.UserID)
+		if parseErr != nil {
+			continue
+		}
+		s.publisher.Publish(New(model.EventLinkDeleted, userID, link.ShortURL, link.OriginalURL))
+	}
+	return purged, nil
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder запоминает опубликованные события
+type recorder []model.Event
+
+func (r *recorder) Publish(e model.Event) {
+	*r = append(*r, e)
+}
+
+func TestWithEvents(t *testing.T) {
+	ctx := context.Background()
+	mem, err := memory.NewStorage("")
+	require.NoError(t, err)
+	assert.Same(t, mem, WithEvents(mem, nil))
+
+	published := &recorder{}
+	store := WithEvents(mem, published)
+	user := uuid.New()
+
+	// новая ссылка
+	_, err = store.SaveURL(ctx, user, "https://go.dev", "go", model.LinkOptions{})
+	require.NoError(t, err)
+	// уже сокращенная ссылка события не порождает
+	_, err = store.SaveURL(ctx, user, "https://go.dev", "go2", model.LinkOptions{})
+	require.ErrorIs(t, err, storage.ErrURLConflict)
+	require.Len(t, *published, 1)
+	assert.Equal(t, model.EventLinkCreated, (*published)[0].Type)
+	assert.Equal(t, user, (*published)[0].UserID)
+	assert.Equal(t, "go", (*published)[0].ShortURL)
+	assert.Equal(t, "https://go.dev", (*published)[0].OriginalURL)
+
+	*published = nil
+	_, err = store.Batch(ctx, user, model.BatchRequest{
+		{CorrelationID: "1", OriginalURL: "https://go.dev/doc", ShortURL: "doc"},
+		{CorrelationID: "2", OriginalURL: "https://go.dev", ShortURL: "go3"},
+		{CorrelationID: "3", OriginalURL: "https://go.dev/blog", ShortURL: "go"},
+	})
+	require.NoError(t, err)
+	require.Len(t, *published, 1, "конфликты и коллизии событий не порождают")
+	assert.Equal(t, "doc", (*published)[0].ShortURL)
+
+	*published = nil
+	err = store.DeleteURLs(ctx, []model.DeleteURLMessage{
+		{UserID: user.String(), ShortURL: "go"},
+		{UserID: user.String(), ShortURL: "missing"},
+	})
+	require.True(t, errors.Is(err, storage.ErrURLNotFound))
+	require.Len(t, *published, 1, "события только по удаленным ссылкам")
+	assert.Equal(t, model.EventLinkDeleted, (*published)[0].Type)
+	assert.Equal(t, "go", (*published)[0].ShortURL)
+}
+
+func TestWithEventsPurge(t *testing.T) {
+	ctx := context.Background()
+	mem, err := memory.NewStorage("")
+	require.NoError(t, err)
+	user := uuid.New()
+	_, err = mem.SaveURL(ctx, user, "https://go.dev", "go", model.LinkOptions{})
+	require.NoError(t, err)
+
+	published := &recorder{}
+	store := WithEvents(mem, published)
+	purged, err := store.PurgeURLs(ctx, []string{"go", "missing"})
+	require.NoError(t, err)
+	require.Len(t, purged, 1)
+	require.Len(t, *published, 1, "события только по удаленным ссылкам")
+	assert.Equal(t, model.EventLinkDeleted, (*published)[0].Type)
+	assert.Equal(t, user, (*published)[0].UserID)
+}
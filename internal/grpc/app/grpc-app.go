@@ -2,43 +2,98 @@ package app
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"net"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/recovery"
+	"github.com/kTowkA/shortener/internal/apierror"
 	pb "github.com/kTowkA/shortener/internal/grpc/proto"
 	"github.com/kTowkA/shortener/internal/grpc/server"
+	"github.com/kTowkA/shortener/internal/health"
+	"github.com/kTowkA/shortener/internal/i18n"
+	"github.com/kTowkA/shortener/internal/model"
 	"github.com/kTowkA/shortener/internal/storage"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/metadata"
 )
 
-// Run запуск gRPC сервера
-func Run(ctx context.Context, db storage.Storager, log *slog.Logger, address string) error {
+// healthAll имя сервиса в grpc.health.v1 для состояния сервера в целом
+const healthAll = ""
 
+// keyLanguage ключ метаданных с предпочитаемыми языками клиента (в формате заголовка Accept-Language)
+const keyLanguage = "accept-language"
+
+// Run запуск gRPC сервера. opts дополнительные настройки сервиса Shortener.
+// вместе с сервисами приложения регистрируется стандартный сервис grpc.health.v1. если заданы проверки
+// готовности (server.WithHealth), к ним добавляется проверка работы gRPC сервера, а состояние сервисов
+// периодически обновляется по их результату. иначе сервисы готовы, пока сервер запущен
+func Run(ctx context.Context, db storage.Storager, log *slog.Logger, address string, opts ...server.Option) error {
+
+	s := server.NewGRPCServer(db, log, opts...)
 	gRPCServer := grpc.NewServer(grpc.ChainUnaryInterceptor(
 		recovery.UnaryServerInterceptor(),
+		localize,
+		s.Authenticate,
 		userID,
+		s.RateLimit,
+		s.Idempotent,
 	))
 
+	services := []string{healthAll, pb.Shortener_ServiceDesc.ServiceName}
+	pb.RegisterShortenerServer(gRPCServer, s)
+	if admin := s.Admin(); admin != nil {
+		pb.RegisterAdminServer(gRPCServer, admin)
+		services = append(services, pb.Admin_ServiceDesc.ServiceName)
+	}
+	// до начала прослушивания порта сервисы не готовы
+	healthServer := grpchealth.NewServer()
+	setServingStatus(healthServer, services, healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(gRPCServer, healthServer)
+	listening := health.NewFlag("gRPC сервер не запущен")
+	checker := s.Health()
+	if checker != nil {
+		checker.Add(health.CheckGRPC, listening.Check)
+	}
+
 	gr, grCtx := errgroup.WithContext(ctx)
 
 	gr.Go(func() error {
 		defer log.Info("остановили сервер")
 		<-grCtx.Done()
+		listening.Set(false)
+		// клиенты проверки состояния узнают об остановке раньше, чем закроются соединения
+		healthServer.Shutdown()
 		gRPCServer.GracefulStop()
 		return nil
 	})
 	gr.Go(func() error {
-		s := server.NewGRPCServer(db, log)
-		pb.RegisterShortenerServer(gRPCServer, s)
-
 		l, err := net.Listen("tcp", address)
 		if err != nil {
 			return err
 		}
+		// от доверенных прокси принимаются заголовки PROXY protocol
+		l = s.ClientIP().Listener(l)
+		listening.Set(true)
+		if checker == nil {
+			setServingStatus(healthServer, services, healthpb.HealthCheckResponse_SERVING)
+		} else {
+			gr.Go(func() error {
+				checker.Watch(grCtx, func(resp model.HealthResponse) {
+					status := healthpb.HealthCheckResponse_SERVING
+					if resp.Status != model.HealthOK {
+						status = healthpb.HealthCheckResponse_NOT_SERVING
+					}
+					setServingStatus(healthServer, services, status)
+				})
+				return nil
+			})
+		}
 
 		log.Info("gRPC server started")
 
@@ -50,15 +105,39 @@ func Run(ctx context.Context, db storage.Storager, log *slog.Logger, address str
 	return gr.Wait()
 }
 
+// setServingStatus выставляет состояние status всем сервисам services
+func setServingStatus(hs *grpchealth.Server, services []string, status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range services {
+		hs.SetServingStatus(service, status)
+	}
+}
+
 // userID такой искуственный пример перехватчика. если нет id пользователя, то генерируем новый и сохраняем в контексте
 // можно было вынести в общий код работу с jwt токеном, но он что там был бесполезен, так как он созхдавался и в resp api автоматом, поэтому быстрый вариант показать что умею и перехватчики
 func userID(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
-		values := md.Get("userid")
-		if _, err := uuid.Parse(values[0]); err == nil {
-			return handler(ctx, req)
+		// вызовы без пользователя (например, сервиса Admin) тоже получают новый id
+		if values := md.Get("userid"); len(values) > 0 {
+			if _, err := uuid.Parse(values[0]); err == nil {
+				return handler(ctx, req)
+			}
 		}
 	}
 	ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs("userid", uuid.New().String()))
 	return handler(ctx, req)
 }
+
+// localize выбирает язык клиента по метаданным accept-language, сохраняет его в контексте
+// и переводит сообщения ошибок API на этот язык
+func localize(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+	lang := i18n.Default
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		lang = i18n.Parse(strings.Join(md.Get(keyLanguage), ","))
+	}
+	resp, err = handler(i18n.WithLang(ctx, lang), req)
+	var e *apierror.Error
+	if errors.As(err, &e) {
+		return resp, e.Localize(lang)
+	}
+	return resp, err
+}
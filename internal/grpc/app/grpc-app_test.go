@@ -2,11 +2,23 @@ package app
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"testing"
 	"time"
 
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/grpc/server"
+	"github.com/kTowkA/shortener/internal/health"
+	"github.com/kTowkA/shortener/internal/i18n"
+	"github.com/kTowkA/shortener/internal/model"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // очень коротко. просто проверяем что запускается
@@ -16,3 +28,81 @@ func TestRun(t *testing.T) {
 	err := Run(ctx, nil, slog.Default(), ":8181")
 	require.NoError(t, err)
 }
+
+func TestHealth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	checker := health.New(health.Options{Interval: 10 * time.Millisecond})
+	storageUp := health.NewFlag("хранилище недоступно")
+	checker.Add(health.CheckStorage, storageUp.Check)
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, nil, slog.Default(), ":8182", server.WithHealth(checker))
+	}()
+
+	conn, err := grpc.NewClient("localhost:8182", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	serving := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN
+		}
+		return resp.Status
+	}
+
+	// сервер запущен, но хранилище недоступно
+	require.Eventually(t, func() bool {
+		return serving(healthAll) == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, model.HealthFail, checker.Ready(ctx).Status)
+
+	storageUp.Set(true)
+	require.Eventually(t, func() bool {
+		return serving(healthAll) == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, healthpb.HealthCheckResponse_SERVING, serving("shortener.Shortener"))
+	require.Equal(t, model.HealthOK, checker.Ready(ctx).Status, "проверка gRPC сервера добавлена и пройдена")
+
+	cancel()
+	require.NoError(t, <-done)
+	require.Equal(t, model.HealthFail, checker.Ready(context.Background()).Status, "сервер остановлен")
+}
+
+func TestLocalize(t *testing.T) {
+	handler := func(ctx context.Context, req any) (any, error) {
+		if i18n.FromContext(ctx) != i18n.EN {
+			return nil, errors.New("язык не сохранен в контексте")
+		}
+		return nil, apierror.New(apierror.CodeURLNotFound)
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(keyLanguage, "en-GB"))
+	_, err := localize(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	require.Error(t, err)
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.NotFound, st.Code())
+	require.Equal(t, "URL not found", st.Message())
+	require.Equal(t, apierror.CodeURLNotFound, apierror.CodeOf(err))
+
+	// без метаданных - язык по умолчанию
+	_, err = localize(context.Background(), nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		return nil, apierror.New(apierror.CodeURLNotFound)
+	})
+	require.Equal(t, apierror.CodeURLNotFound.Message(), status.Convert(err).Message())
+}
+
+func TestUserIDWithoutUser(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(keyLanguage, "en"))
+	called := false
+	require.NotPanics(t, func() {
+		_, err := userID(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+			called = true
+			return nil, nil
+		})
+		require.NoError(t, err)
+	})
+	require.True(t, called)
+}
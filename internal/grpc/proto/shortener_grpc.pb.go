@@ -19,13 +19,20 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Shortener_EncodeURL_FullMethodName      = "/shortener.Shortener/EncodeURL"
-	Shortener_DecodeURL_FullMethodName      = "/shortener.Shortener/DecodeURL"
-	Shortener_Batch_FullMethodName          = "/shortener.Shortener/Batch"
-	Shortener_UserURLs_FullMethodName       = "/shortener.Shortener/UserURLs"
-	Shortener_DeleteUserURLs_FullMethodName = "/shortener.Shortener/DeleteUserURLs"
-	Shortener_Stats_FullMethodName          = "/shortener.Shortener/Stats"
-	Shortener_Ping_FullMethodName           = "/shortener.Shortener/Ping"
+	Shortener_EncodeURL_FullMethodName         = "/shortener.Shortener/EncodeURL"
+	Shortener_DecodeURL_FullMethodName         = "/shortener.Shortener/DecodeURL"
+	Shortener_Batch_FullMethodName             = "/shortener.Shortener/Batch"
+	Shortener_UserURLs_FullMethodName          = "/shortener.Shortener/UserURLs"
+	Shortener_DeleteUserURLs_FullMethodName    = "/shortener.Shortener/DeleteUserURLs"
+	Shortener_Deletion_FullMethodName          = "/shortener.Shortener/Deletion"
+	Shortener_Stats_FullMethodName             = "/shortener.Shortener/Stats"
+	Shortener_Ping_FullMethodName              = "/shortener.Shortener/Ping"
+	Shortener_QRCode_FullMethodName            = "/shortener.Shortener/QRCode"
+	Shortener_CreateWebhook_FullMethodName     = "/shortener.Shortener/CreateWebhook"
+	Shortener_ListWebhooks_FullMethodName      = "/shortener.Shortener/ListWebhooks"
+	Shortener_DeleteWebhook_FullMethodName     = "/shortener.Shortener/DeleteWebhook"
+	Shortener_WebhookDeliveries_FullMethodName = "/shortener.Shortener/WebhookDeliveries"
+	Shortener_RedeliverWebhook_FullMethodName  = "/shortener.Shortener/RedeliverWebhook"
 )
 
 // ShortenerClient is the client API for Shortener service.
@@ -37,8 +44,15 @@ type ShortenerClient interface {
 	Batch(ctx context.Context, in *BatchRequest, opts ...grpc.CallOption) (*BatchResponse, error)
 	UserURLs(ctx context.Context, in *UserURLsRequest, opts ...grpc.CallOption) (*UserURLsResponse, error)
 	DeleteUserURLs(ctx context.Context, in *DelUserRequest, opts ...grpc.CallOption) (*DeleteUserURLsResponse, error)
+	Deletion(ctx context.Context, in *DeletionRequest, opts ...grpc.CallOption) (*DeletionResponse, error)
 	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
 	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+	QRCode(ctx context.Context, in *QRCodeRequest, opts ...grpc.CallOption) (*QRCodeResponse, error)
+	CreateWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*CreateWebhookResponse, error)
+	ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*ListWebhooksResponse, error)
+	DeleteWebhook(ctx context.Context, in *DeleteWebhookRequest, opts ...grpc.CallOption) (*DeleteWebhookResponse, error)
+	WebhookDeliveries(ctx context.Context, in *WebhookDeliveriesRequest, opts ...grpc.CallOption) (*WebhookDeliveriesResponse, error)
+	RedeliverWebhook(ctx context.Context, in *RedeliverWebhookRequest, opts ...grpc.CallOption) (*RedeliverWebhookResponse, error)
 }
 
 type shortenerClient struct {
@@ -99,6 +113,16 @@ func (c *shortenerClient) DeleteUserURLs(ctx context.Context, in *DelUserRequest
 	return out, nil
 }
 
+func (c *shortenerClient) Deletion(ctx context.Context, in *DeletionRequest, opts ...grpc.CallOption) (*DeletionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeletionResponse)
+	err := c.cc.Invoke(ctx, Shortener_Deletion_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *shortenerClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(StatsResponse)
@@ -119,6 +143,66 @@ func (c *shortenerClient) Ping(ctx context.Context, in *PingRequest, opts ...grp
 	return out, nil
 }
 
+func (c *shortenerClient) QRCode(ctx context.Context, in *QRCodeRequest, opts ...grpc.CallOption) (*QRCodeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QRCodeResponse)
+	err := c.cc.Invoke(ctx, Shortener_QRCode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) CreateWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*CreateWebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateWebhookResponse)
+	err := c.cc.Invoke(ctx, Shortener_CreateWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*ListWebhooksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListWebhooksResponse)
+	err := c.cc.Invoke(ctx, Shortener_ListWebhooks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) DeleteWebhook(ctx context.Context, in *DeleteWebhookRequest, opts ...grpc.CallOption) (*DeleteWebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteWebhookResponse)
+	err := c.cc.Invoke(ctx, Shortener_DeleteWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) WebhookDeliveries(ctx context.Context, in *WebhookDeliveriesRequest, opts ...grpc.CallOption) (*WebhookDeliveriesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(WebhookDeliveriesResponse)
+	err := c.cc.Invoke(ctx, Shortener_WebhookDeliveries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *shortenerClient) RedeliverWebhook(ctx context.Context, in *RedeliverWebhookRequest, opts ...grpc.CallOption) (*RedeliverWebhookResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RedeliverWebhookResponse)
+	err := c.cc.Invoke(ctx, Shortener_RedeliverWebhook_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ShortenerServer is the server API for Shortener service.
 // All implementations must embed UnimplementedShortenerServer
 // for forward compatibility.
@@ -128,8 +212,15 @@ type ShortenerServer interface {
 	Batch(context.Context, *BatchRequest) (*BatchResponse, error)
 	UserURLs(context.Context, *UserURLsRequest) (*UserURLsResponse, error)
 	DeleteUserURLs(context.Context, *DelUserRequest) (*DeleteUserURLsResponse, error)
+	Deletion(context.Context, *DeletionRequest) (*DeletionResponse, error)
 	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
 	Ping(context.Context, *PingRequest) (*PingResponse, error)
+	QRCode(context.Context, *QRCodeRequest) (*QRCodeResponse, error)
+	CreateWebhook(context.Context, *CreateWebhookRequest) (*CreateWebhookResponse, error)
+	ListWebhooks(context.Context, *ListWebhooksRequest) (*ListWebhooksResponse, error)
+	DeleteWebhook(context.Context, *DeleteWebhookRequest) (*DeleteWebhookResponse, error)
+	WebhookDeliveries(context.Context, *WebhookDeliveriesRequest) (*WebhookDeliveriesResponse, error)
+	RedeliverWebhook(context.Context, *RedeliverWebhookRequest) (*RedeliverWebhookResponse, error)
 	mustEmbedUnimplementedShortenerServer()
 }
 
@@ -155,12 +246,33 @@ func (UnimplementedShortenerServer) UserURLs(context.Context, *UserURLsRequest)
 func (UnimplementedShortenerServer) DeleteUserURLs(context.Context, *DelUserRequest) (*DeleteUserURLsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteUserURLs not implemented")
 }
+func (UnimplementedShortenerServer) Deletion(context.Context, *DeletionRequest) (*DeletionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Deletion not implemented")
+}
 func (UnimplementedShortenerServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Stats not implemented")
 }
 func (UnimplementedShortenerServer) Ping(context.Context, *PingRequest) (*PingResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Ping not implemented")
 }
+func (UnimplementedShortenerServer) QRCode(context.Context, *QRCodeRequest) (*QRCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QRCode not implemented")
+}
+func (UnimplementedShortenerServer) CreateWebhook(context.Context, *CreateWebhookRequest) (*CreateWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateWebhook not implemented")
+}
+func (UnimplementedShortenerServer) ListWebhooks(context.Context, *ListWebhooksRequest) (*ListWebhooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhooks not implemented")
+}
+func (UnimplementedShortenerServer) DeleteWebhook(context.Context, *DeleteWebhookRequest) (*DeleteWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteWebhook not implemented")
+}
+func (UnimplementedShortenerServer) WebhookDeliveries(context.Context, *WebhookDeliveriesRequest) (*WebhookDeliveriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method WebhookDeliveries not implemented")
+}
+func (UnimplementedShortenerServer) RedeliverWebhook(context.Context, *RedeliverWebhookRequest) (*RedeliverWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RedeliverWebhook not implemented")
+}
 func (UnimplementedShortenerServer) mustEmbedUnimplementedShortenerServer() {}
 func (UnimplementedShortenerServer) testEmbeddedByValue()                   {}
 
@@ -272,6 +384,24 @@ func _Shortener_DeleteUserURLs_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shortener_Deletion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeletionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).Deletion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_Deletion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).Deletion(ctx, req.(*DeletionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Shortener_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(StatsRequest)
 	if err := dec(in); err != nil {
@@ -308,6 +438,114 @@ func _Shortener_Ping_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Shortener_QRCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QRCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).QRCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_QRCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).QRCode(ctx, req.(*QRCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_CreateWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).CreateWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_CreateWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).CreateWebhook(ctx, req.(*CreateWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_ListWebhooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWebhooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).ListWebhooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_ListWebhooks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).ListWebhooks(ctx, req.(*ListWebhooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_DeleteWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).DeleteWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_DeleteWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).DeleteWebhook(ctx, req.(*DeleteWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_WebhookDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookDeliveriesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).WebhookDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_WebhookDeliveries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).WebhookDeliveries(ctx, req.(*WebhookDeliveriesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Shortener_RedeliverWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RedeliverWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ShortenerServer).RedeliverWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Shortener_RedeliverWebhook_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ShortenerServer).RedeliverWebhook(ctx, req.(*RedeliverWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Shortener_ServiceDesc is the grpc.ServiceDesc for Shortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -335,6 +573,10 @@ var Shortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteUserURLs",
 			Handler:    _Shortener_DeleteUserURLs_Handler,
 		},
+		{
+			MethodName: "Deletion",
+			Handler:    _Shortener_Deletion_Handler,
+		},
 		{
 			MethodName: "Stats",
 			Handler:    _Shortener_Stats_Handler,
@@ -343,6 +585,322 @@ var Shortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Ping",
 			Handler:    _Shortener_Ping_Handler,
 		},
+		{
+			MethodName: "QRCode",
+			Handler:    _Shortener_QRCode_Handler,
+		},
+		{
+			MethodName: "CreateWebhook",
+			Handler:    _Shortener_CreateWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhooks",
+			Handler:    _Shortener_ListWebhooks_Handler,
+		},
+		{
+			MethodName: "DeleteWebhook",
+			Handler:    _Shortener_DeleteWebhook_Handler,
+		},
+		{
+			MethodName: "WebhookDeliveries",
+			Handler:    _Shortener_WebhookDeliveries_Handler,
+		},
+		{
+			MethodName: "RedeliverWebhook",
+			Handler:    _Shortener_RedeliverWebhook_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "internal/grpc/proto/shortener.proto",
+}
+
+const (
+	Admin_Link_FullMethodName            = "/shortener.Admin/Link"
+	Admin_SetLinkDisabled_FullMethodName = "/shortener.Admin/SetLinkDisabled"
+	Admin_UserLinks_FullMethodName       = "/shortener.Admin/UserLinks"
+	Admin_SetUserBlocked_FullMethodName  = "/shortener.Admin/SetUserBlocked"
+	Admin_Purge_FullMethodName           = "/shortener.Admin/Purge"
+	Admin_AuditLog_FullMethodName        = "/shortener.Admin/AuditLog"
+)
+
+// AdminClient is the client API for Admin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminClient interface {
+	Link(ctx context.Context, in *AdminLinkRequest, opts ...grpc.CallOption) (*AdminLinkResponse, error)
+	SetLinkDisabled(ctx context.Context, in *SetLinkDisabledRequest, opts ...grpc.CallOption) (*SetLinkDisabledResponse, error)
+	UserLinks(ctx context.Context, in *AdminUserLinksRequest, opts ...grpc.CallOption) (*AdminUserLinksResponse, error)
+	SetUserBlocked(ctx context.Context, in *SetUserBlockedRequest, opts ...grpc.CallOption) (*SetUserBlockedResponse, error)
+	Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*PurgeResponse, error)
+	AuditLog(ctx context.Context, in *AuditLogRequest, opts ...grpc.CallOption) (*AuditLogResponse, error)
+}
+
+type adminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminClient(cc grpc.ClientConnInterface) AdminClient {
+	return &adminClient{cc}
+}
+
+func (c *adminClient) Link(ctx context.Context, in *AdminLinkRequest, opts ...grpc.CallOption) (*AdminLinkResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminLinkResponse)
+	err := c.cc.Invoke(ctx, Admin_Link_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetLinkDisabled(ctx context.Context, in *SetLinkDisabledRequest, opts ...grpc.CallOption) (*SetLinkDisabledResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetLinkDisabledResponse)
+	err := c.cc.Invoke(ctx, Admin_SetLinkDisabled_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) UserLinks(ctx context.Context, in *AdminUserLinksRequest, opts ...grpc.CallOption) (*AdminUserLinksResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AdminUserLinksResponse)
+	err := c.cc.Invoke(ctx, Admin_UserLinks_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetUserBlocked(ctx context.Context, in *SetUserBlockedRequest, opts ...grpc.CallOption) (*SetUserBlockedResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetUserBlockedResponse)
+	err := c.cc.Invoke(ctx, Admin_SetUserBlocked_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) Purge(ctx context.Context, in *PurgeRequest, opts ...grpc.CallOption) (*PurgeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PurgeResponse)
+	err := c.cc.Invoke(ctx, Admin_Purge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) AuditLog(ctx context.Context, in *AuditLogRequest, opts ...grpc.CallOption) (*AuditLogResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuditLogResponse)
+	err := c.cc.Invoke(ctx, Admin_AuditLog_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServer is the server API for Admin service.
+// All implementations must embed UnimplementedAdminServer
+// for forward compatibility.
+type AdminServer interface {
+	Link(context.Context, *AdminLinkRequest) (*AdminLinkResponse, error)
+	SetLinkDisabled(context.Context, *SetLinkDisabledRequest) (*SetLinkDisabledResponse, error)
+	UserLinks(context.Context, *AdminUserLinksRequest) (*AdminUserLinksResponse, error)
+	SetUserBlocked(context.Context, *SetUserBlockedRequest) (*SetUserBlockedResponse, error)
+	Purge(context.Context, *PurgeRequest) (*PurgeResponse, error)
+	AuditLog(context.Context, *AuditLogRequest) (*AuditLogResponse, error)
+	mustEmbedUnimplementedAdminServer()
+}
+
+// UnimplementedAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAdminServer struct{}
+
+func (UnimplementedAdminServer) Link(context.Context, *AdminLinkRequest) (*AdminLinkResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Link not implemented")
+}
+func (UnimplementedAdminServer) SetLinkDisabled(context.Context, *SetLinkDisabledRequest) (*SetLinkDisabledResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetLinkDisabled not implemented")
+}
+func (UnimplementedAdminServer) UserLinks(context.Context, *AdminUserLinksRequest) (*AdminUserLinksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UserLinks not implemented")
+}
+func (UnimplementedAdminServer) SetUserBlocked(context.Context, *SetUserBlockedRequest) (*SetUserBlockedResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetUserBlocked not implemented")
+}
+func (UnimplementedAdminServer) Purge(context.Context, *PurgeRequest) (*PurgeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Purge not implemented")
+}
+func (UnimplementedAdminServer) AuditLog(context.Context, *AuditLogRequest) (*AuditLogResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AuditLog not implemented")
+}
+func (UnimplementedAdminServer) mustEmbedUnimplementedAdminServer() {}
+func (UnimplementedAdminServer) testEmbeddedByValue()               {}
+
+// UnsafeAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServer will
+// result in compilation errors.
+type UnsafeAdminServer interface {
+	mustEmbedUnimplementedAdminServer()
+}
+
+func RegisterAdminServer(s grpc.ServiceRegistrar, srv AdminServer) {
+	// If the following call pancis, it indicates UnimplementedAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Admin_ServiceDesc, srv)
+}
+
+func _Admin_Link_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminLinkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Link(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_Link_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Link(ctx, req.(*AdminLinkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SetLinkDisabled_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetLinkDisabledRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SetLinkDisabled(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_SetLinkDisabled_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SetLinkDisabled(ctx, req.(*SetLinkDisabledRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_UserLinks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AdminUserLinksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).UserLinks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_UserLinks_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).UserLinks(ctx, req.(*AdminUserLinksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_SetUserBlocked_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetUserBlockedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).SetUserBlocked(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_SetUserBlocked_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).SetUserBlocked(ctx, req.(*SetUserBlockedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_Purge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PurgeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).Purge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_Purge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).Purge(ctx, req.(*PurgeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Admin_AuditLog_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditLogRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServer).AuditLog(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Admin_AuditLog_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServer).AuditLog(ctx, req.(*AuditLogRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Admin_ServiceDesc is the grpc.ServiceDesc for Admin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Admin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "shortener.Admin",
+	HandlerType: (*AdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Link",
+			Handler:    _Admin_Link_Handler,
+		},
+		{
+			MethodName: "SetLinkDisabled",
+			Handler:    _Admin_SetLinkDisabled_Handler,
+		},
+		{
+			MethodName: "UserLinks",
+			Handler:    _Admin_UserLinks_Handler,
+		},
+		{
+			MethodName: "SetUserBlocked",
+			Handler:    _Admin_SetUserBlocked_Handler,
+		},
+		{
+			MethodName: "Purge",
+			Handler:    _Admin_Purge_Handler,
+		},
+		{
+			MethodName: "AuditLog",
+			Handler:    _Admin_AuditLog_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/grpc/proto/shortener.proto",
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/admin"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/clientip"
+	pb "github.com/kTowkA/shortener/internal/grpc/proto"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// maxAuditLimit наибольшее количество записей журнала действий администраторов в ответе AuditLog
+const maxAuditLimit = 1000
+
+// AdminServer реализация gRPC сервиса Admin
+type AdminServer struct {
+	pb.UnimplementedAdminServer
+	svc    *admin.Service
+	logger *slog.Logger
+	// trustedSubnets подсети, клиентам из которых доступен сервис
+	trustedSubnets clientip.Subnets
+	// clientIP определяет адрес клиента с учетом доверенных прокси
+	clientIP *clientip.Resolver
+}
+
+// NewAdminServer создает gRPC сервис Admin, доступный клиентам из подсетей trustedSubnets.
+// если подсети не заданы, в доступе отказывается всем
+func NewAdminServer(svc *admin.Service, trustedSubnets clientip.Subnets, logger *slog.Logger) *AdminServer {
+	return &AdminServer{svc: svc, logger: logger, trustedSubnets: trustedSubnets}
+}
+
+// WithAdmin включает gRPC сервис Admin с операциями администраторов, доступный клиентам из подсетей trustedSubnets.
+// адрес клиента определяется так же, как в сервисе Shortener (WithClientIP)
+func WithAdmin(svc *admin.Service, trustedSubnets clientip.Subnets) Option {
+	return func(s *ShortenerServer) {
+		s.admin = NewAdminServer(svc, trustedSubnets, s.logger)
+	}
+}
+
+// Admin gRPC сервис Admin или nil, если он не включен
+func (s *ShortenerServer) Admin() *AdminServer {
+	return s.admin
+}
+
+// actor проверяет, что клиент из доверенной подсети, и возвращает автора действия для журнала
+func (s *AdminServer) actor(ctx context.Context) (string, error) {
+	ip, err := trustedClient(ctx, s.clientIP, s.trustedSubnets, s.logger)
+	if err != nil {
+		return "", err
+	}
+	return "grpc " + ip.String(), nil
+}
+
+// trustedClient проверяет, что адрес клиента, определенный r, из подсетей subnets, и возвращает его
+func trustedClient(ctx context.Context, r *clientip.Resolver, subnets clientip.Subnets, logger *slog.Logger) (net.IP, error) {
+	addr := clientIP(ctx, r)
+	ip := net.ParseIP(addr)
+	if !subnets.Contains(ip) {
+		logger.Error("попытка доступа к закрытому ресурсу", slog.String("ошибка", "ip не из доверенной подсети"), slog.String("ip", addr))
+		return nil, apierror.New(apierror.CodeForbidden)
+	}
+	return ip, nil
+}
+
+// Link реализация gRPC сервиса Admin
+func (s *AdminServer) Link(ctx context.Context, r *pb.AdminLinkRequest) (*pb.AdminLinkResponse, error) {
+	actor, err := s.actor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.svc.Link(ctx, actor, r.ShortUrl)
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	return &pb.AdminLinkResponse{Link: modelLinkToAdminLink(link.StorageJSON, link.UserID)}, nil
+}
+
+// SetLinkDisabled реализация gRPC сервиса Admin
+func (s *AdminServer) SetLinkDisabled(ctx context.Context, r *pb.SetLinkDisabledRequest) (*pb.SetLinkDisabledResponse, error) {
+	actor, err := s.actor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	link, err := s.svc.SetLinkDisabled(ctx, actor, r.ShortUrl, r.Disabled)
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	return &pb.SetLinkDisabledResponse{Link: modelLinkToAdminLink(link.StorageJSON, link.UserID)}, nil
+}
+
+// UserLinks реализация gRPC сервиса Admin
+func (s *AdminServer) UserLinks(ctx context.Context, r *pb.AdminUserLinksRequest) (*pb.AdminUserLinksResponse, error) {
+	actor, err := s.actor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := uuid.Parse(r.UserId)
+	if err != nil {
+		return nil, apierror.New(apierror.CodeInvalidUserID).WithDetails(r.UserId)
+	}
+	links, err := s.svc.UserLinks(ctx, actor, userID)
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	resp := &pb.AdminUserLinksResponse{Links: make([]*pb.AdminLink, 0, len(links))}
+	for _, link := range links {
+		resp.Links = append(resp.Links, modelLinkToAdminLink(link, r.UserId))
+	}
+	return resp, nil
+}
+
+// SetUserBlocked реализация gRPC сервиса Admin
+func (s *AdminServer) SetUserBlocked(ctx context.Context, r *pb.SetUserBlockedRequest) (*pb.SetUserBlockedResponse, error) {
+	actor, err := s.actor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := uuid.Parse(r.UserId)
+	if err != nil {
+		return nil, apierror.New(apierror.CodeInvalidUserID).WithDetails(r.UserId)
+	}
+	if err = s.svc.SetUserBlocked(ctx, actor, userID, r.Blocked, r.Reason); err != nil {
+		return nil, apierror.From(err)
+	}
+	return &pb.SetUserBlockedResponse{}, nil
+}
+
+// Purge реализация gRPC сервиса Admin
+func (s *AdminServer) Purge(ctx context.Context, r *pb.PurgeRequest) (*pb.PurgeResponse, error) {
+	actor, err := s.actor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.svc.Purge(ctx, actor, r.ShortUrls)
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	return &pb.PurgeResponse{Purged: resp.Purged, NotFound: resp.NotFound}, nil
+}
+
+// AuditLog реализация gRPC сервиса Admin
+func (s *AdminServer) AuditLog(ctx context.Context, r *pb.AuditLogRequest) (*pb.AuditLogResponse, error) {
+	if _, err := s.actor(ctx); err != nil {
+		return nil, err
+	}
+	limit := maxAuditLimit
+	if r.Limit > 0 {
+		limit = min(int(r.Limit), maxAuditLimit)
+	}
+	records, err := s.svc.AuditLog(ctx, limit)
+	if err != nil {
+		s.logger.Error("журнал действий администраторов", slog.String("ошибка", err.Error()))
+		return nil, apierror.From(err)
+	}
+	resp := &pb.AuditLogResponse{Records: make([]*pb.AuditRecord, 0, len(records))}
+	for _, record := range records {
+		resp.Records = append(resp.Records, modelAuditRecordToAuditRecord(record))
+	}
+	return resp, nil
+}
+
+func modelLinkToAdminLink(link model.StorageJSON, userID string) *pb.AdminLink {
+	return &pb.AdminLink{
+		ShortUrl:    link.ShortURL,
+		OriginalUrl: link.OriginalURL,
+		UserId:      userID,
+		IsDeleted:   link.IsDeleted,
+		IsBlocked:   link.IsBlocked,
+	}
+}
+
+func modelAuditRecordToAuditRecord(record model.AuditRecord) *pb.AuditRecord {
+	return &pb.AuditRecord{
+		Id:        record.ID.String(),
+		Action:    string(record.Action),
+		Actor:     record.Actor,
+		Target:    record.Target,
+		Details:   record.Details,
+		Result:    record.Result,
+		CreatedAt: record.CreatedAt.Format(time.RFC3339),
+	}
+}
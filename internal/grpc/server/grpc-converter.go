@@ -1,9 +1,12 @@
 package server
 
 import (
+	"strings"
+	"time"
+
 	pb "github.com/kTowkA/shortener/internal/grpc/proto"
 	"github.com/kTowkA/shortener/internal/model"
-	"github.com/kTowkA/shortener/internal/utils"
+	"github.com/kTowkA/shortener/internal/qrcode"
 )
 
 func batchRequestToModelBatchRequest(r *pb.BatchRequest) model.BatchRequest {
@@ -14,7 +17,7 @@ func batchRequestToModelBatchRequest(r *pb.BatchRequest) model.BatchRequest {
 			OriginalURL:   value.OriginalUrl,
 		})
 	}
-	return utils.ValidateAndGenerateBatch(batch)
+	return batch
 }
 func modelBatchResponseToBatchResponse(r model.BatchResponse) *pb.BatchResponse {
 	batch := make([]*pb.BatchResponse_Result, 0, len(r))
@@ -22,6 +25,8 @@ func modelBatchResponseToBatchResponse(r model.BatchResponse) *pb.BatchResponse
 		batch = append(batch, &pb.BatchResponse_Result{
 			CorrelationId: r[i].CorrelationID,
 			ShortUrl:      r[i].ShortURL,
+			Status:        string(r[i].Status),
+			Reason:        r[i].Reason,
 		})
 	}
 	return &pb.BatchResponse{Result: batch}
@@ -39,13 +44,53 @@ func modelStorageJSONToUserURLsResponse(r []model.StorageJSON) *pb.UserURLsRespo
 	return &pb.UserURLsResponse{Result: result}
 }
 
-func delUserRequestToModelDeleteURLMessage(userID string, r *pb.DelUserRequest) []model.DeleteURLMessage {
-	result := make([]model.DeleteURLMessage, 0, len(r.ShortUrls))
-	for i := range r.ShortUrls {
-		result = append(result, model.DeleteURLMessage{
-			UserID:   userID,
-			ShortURL: r.ShortUrls[i],
-		})
+func qrCodeRequestToOptions(r *pb.QRCodeRequest) (qrcode.Options, error) {
+	opts := qrcode.DefaultOptions
+	if r.Format != "" {
+		opts.Format = qrcode.Format(strings.ToLower(r.Format))
+	}
+	if r.Size != 0 {
+		opts.Size = int(r.Size)
+	}
+	if r.Level != "" {
+		opts.Level = strings.ToUpper(r.Level)
+	}
+	if r.Margin != 0 {
+		opts.Margin = int(r.Margin)
+	}
+	var err error
+	if r.Foreground != "" {
+		if opts.Foreground, err = qrcode.ParseColor(r.Foreground); err != nil {
+			return qrcode.Options{}, err
+		}
+	}
+	if r.Background != "" {
+		if opts.Background, err = qrcode.ParseColor(r.Background); err != nil {
+			return qrcode.Options{}, err
+		}
+	}
+	return opts, opts.Validate()
+}
+func modelStatsToStats(r model.StatsResponse) *pb.StatsResponse {
+	resp := &pb.StatsResponse{
+		Users:       int32(r.TotalUsers),
+		Urls:        int32(r.TotalURLs),
+		ActiveUrls:  int64(r.ActiveURLs),
+		DeletedUrls: int64(r.DeletedURLs),
+		Granularity: string(r.Created.Granularity),
+		Created:     make([]*pb.StatsPoint, 0, len(r.Created.Points)),
+		TopDomains:  modelStatsCountsToStatsCounts(r.TopDomains),
+		TopUsers:    modelStatsCountsToStatsCounts(r.TopUsers),
+	}
+	for _, p := range r.Created.Points {
+		resp.Created = append(resp.Created, &pb.StatsPoint{Time: p.Time.Format(time.RFC3339), Links: int64(p.Links)})
+	}
+	return resp
+}
+func modelStatsCountsToStatsCounts(r []model.StatsCount) []*pb.StatsCount {
+	result := make([]*pb.StatsCount, 0, len(r))
+	for _, c := range r {
+		result = append(result, &pb.StatsCount{Key: c.Key, Links: int64(c.Links)})
 	}
 	return result
 }
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/deletion"
+	pb "github.com/kTowkA/shortener/internal/grpc/proto"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// WithDeletionQueue устанавливает очередь удаления ссылок. очередь общая с HTTP сервером, который ее и запускает,
+// поэтому вызовы DeleteUserURLs подчиняются тому же ограничению емкости, а задачи доступны и через /api/user/deletions/{id}
+func WithDeletionQueue(q *deletion.Queue) Option {
+	return func(s *ShortenerServer) {
+		s.deletes = q
+	}
+}
+
+// deletionUser проверяет, что очередь удаления доступна, и возвращает пользователя запроса
+func (s *ShortenerServer) deletionUser(ctx context.Context) (uuid.UUID, error) {
+	if s.deletes == nil {
+		return uuid.UUID{}, apierror.New(apierror.CodeUnavailable)
+	}
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		s.logger.Error("получение ID пользователя", slog.String("ошибка", err.Error()))
+		return uuid.UUID{}, apierror.From(err)
+	}
+	return userID, nil
+}
+
+// DeleteUserURLs реализация gRPC сервиса Shortener. ссылки удаляются очередью удаления: ответ содержит принятую задачу,
+// результат по каждой ссылке возвращает Deletion. если очередь заполнена - Unavailable с заголовком retry-after
+func (s *ShortenerServer) DeleteUserURLs(ctx context.Context, r *pb.DelUserRequest) (*pb.DeleteUserURLsResponse, error) {
+	userID, err := s.deletionUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	task, err := s.deletes.Enqueue(ctx, userID, r.ShortUrls)
+	if err != nil {
+		if apierror.CodeOf(err) == apierror.CodeDeleteQueueFull {
+			_ = grpc.SetHeader(ctx, metadata.Pairs(keyRetryAfter, strconv.Itoa(ratelimit.RetryAfter(s.deletes.FlushInterval()))))
+		}
+		s.logger.Error("удаление ссылок пользователя", slog.String("ошибка", err.Error()))
+		return nil, apierror.From(err)
+	}
+	return &pb.DeleteUserURLsResponse{Task: modelDeleteTaskToDeleteTask(task)}, nil
+}
+
+// Deletion реализация gRPC сервиса Shortener
+func (s *ShortenerServer) Deletion(ctx context.Context, r *pb.DeletionRequest) (*pb.DeletionResponse, error) {
+	userID, err := s.deletionUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(r.Id)
+	if err != nil {
+		return nil, apierror.Wrap(apierror.CodeDeletionNotFound, err)
+	}
+	task, err := s.deletes.Task(ctx, userID, id)
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	return &pb.DeletionResponse{Task: modelDeleteTaskToDeleteTask(task)}, nil
+}
+
+func modelDeleteTaskToDeleteTask(t model.DeleteTask) *pb.DeleteTask {
+	results := make([]*pb.DeleteResult, 0, len(t.Results))
+	for _, res := range t.Results {
+		results = append(results, &pb.DeleteResult{
+			ShortUrl: res.ShortURL,
+			Status:   string(res.Status),
+			Reason:   res.Reason,
+		})
+	}
+	return &pb.DeleteTask{
+		Id:        t.ID.String(),
+		Status:    string(t.Status),
+		Total:     int32(t.Total),
+		Deleted:   int32(t.Deleted),
+		NotFound:  int32(t.NotFound),
+		Failed:    int32(t.Failed),
+		CreatedAt: t.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: t.UpdatedAt.Format(time.RFC3339),
+		Results:   results,
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/kTowkA/shortener/internal/apierror"
+	pb "github.com/kTowkA/shortener/internal/grpc/proto"
+	"github.com/kTowkA/shortener/internal/idempotency"
+	"github.com/kTowkA/shortener/internal/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// idempotentMethods методы, повторный вызов которых с ключом идемпотентности возвращает первый ответ,
+// и конструкторы их ответов
+var idempotentMethods = map[string]func() proto.Message{
+	pb.Shortener_EncodeURL_FullMethodName: func() proto.Message { return &pb.EncodeURLResponse{} },
+	pb.Shortener_Batch_FullMethodName:     func() proto.Message { return &pb.BatchResponse{} },
+}
+
+// WithIdempotencyWindow включает поддержку ключей идемпотентности (метаданные idempotency-key):
+// ответы хранятся в течение window
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(s *ShortenerServer) {
+		s.idempotencyWindow = window
+	}
+}
+
+// Idempotent перехватчик, возвращающий на повторный вызов EncodeURL или Batch с тем же ключом идемпотентности первый ответ.
+// сохраняются только успешные ответы. повторенный ответ отмечается заголовком idempotent-replayed
+func (s *ShortenerServer) Idempotent(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	newResponse, ok := idempotentMethods[info.FullMethod]
+	if !ok || s.idempotency == nil {
+		return handler(ctx, req)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	keys := md.Get(idempotency.MetadataKey)
+	if len(keys) == 0 {
+		return handler(ctx, req)
+	}
+	key := keys[0]
+	if !idempotency.ValidKey(key) {
+		return nil, apierror.New(apierror.CodeBadIdemKey)
+	}
+	// ключи хранятся отдельно для каждого пользователя, без переданного userid повтор не найдет первый ответ
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		return nil, apierror.Wrap(apierror.CodeIdemKeyNoUser, err)
+	}
+	message, ok := req.(proto.Message)
+	if !ok {
+		return handler(ctx, req)
+	}
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	fingerprint := idempotency.Fingerprint([]byte(info.FullMethod), body)
+
+	var (
+		resp       any
+		handlerErr error
+	)
+	stored, replayed, err := s.idempotency.Do(ctx, userID, key, fingerprint, func() (model.IdempotentResponse, bool) {
+		resp, handlerErr = handler(ctx, req)
+		if handlerErr != nil {
+			return model.IdempotentResponse{}, false
+		}
+		out, err := proto.Marshal(resp.(proto.Message))
+		if err != nil {
+			return model.IdempotentResponse{}, false
+		}
+		return model.IdempotentResponse{Body: out}, true
+	})
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	if !replayed {
+		return resp, handlerErr
+	}
+	out := newResponse()
+	if err = proto.Unmarshal(stored.Body, out); err != nil {
+		return nil, apierror.From(err)
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(idempotency.ReplayedHeader), "true"))
+	return out, nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/clientip"
+	pb "github.com/kTowkA/shortener/internal/grpc/proto"
+	"github.com/kTowkA/shortener/internal/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	// keyRetryAfter ключ метаданных ответа со временем в секундах, через которое можно повторить запрос
+	keyRetryAfter = "retry-after"
+	// keyForwardedFor и keyRealIP ключи метаданных с адресом клиента, которые передает прокси
+	keyForwardedFor = "x-forwarded-for"
+	keyRealIP       = "x-real-ip"
+)
+
+// rateLimitedMethods группы ограничений методов сервиса
+var rateLimitedMethods = map[string]ratelimit.Class{
+	pb.Shortener_EncodeURL_FullMethodName:      ratelimit.Create,
+	pb.Shortener_Batch_FullMethodName:          ratelimit.Create,
+	pb.Shortener_DecodeURL_FullMethodName:      ratelimit.Redirect,
+	pb.Shortener_QRCode_FullMethodName:         ratelimit.Redirect,
+	pb.Shortener_DeleteUserURLs_FullMethodName: ratelimit.Delete,
+}
+
+// WithRateLimiter устанавливает ограничитель частоты запросов на создание ссылок, переходы и удаление
+func WithRateLimiter(l *ratelimit.Limiter) Option {
+	return func(s *ShortenerServer) {
+		s.limiter = l
+	}
+}
+
+// RateLimit перехватчик, ограничивающий частоту вызовов для пользователя и IP клиента.
+// при превышении возвращает ResourceExhausted и заголовок retry-after
+func (s *ShortenerServer) RateLimit(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	class, ok := rateLimitedMethods[info.FullMethod]
+	if !ok || !s.limiter.Enabled(class) {
+		return handler(ctx, req)
+	}
+	userKey := ""
+	if userID, err := userIDFromContext(ctx); err == nil {
+		userKey = ratelimit.UserKey(userID.String())
+	}
+	allowed, wait := s.limiter.Allow(class, userKey, ratelimit.IPKey(clientIP(ctx, s.clientIP)))
+	if !allowed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(keyRetryAfter, strconv.Itoa(ratelimit.RetryAfter(wait))))
+		return nil, apierror.New(apierror.CodeRateLimited)
+	}
+	return handler(ctx, req)
+}
+
+// WithClientIP устанавливает определение адреса клиента с учетом доверенных прокси. без настройки
+// адрес клиента - адрес соединения
+func WithClientIP(r *clientip.Resolver) Option {
+	return func(s *ShortenerServer) {
+		s.clientIP = r
+	}
+}
+
+// ClientIP определение адреса клиента (используется и для приема заголовков PROXY protocol)
+func (s *ShortenerServer) ClientIP() *clientip.Resolver {
+	return s.clientIP
+}
+
+// clientIP IP адрес клиента. метаданные x-forwarded-for и x-real-ip учитываются только от доверенных прокси
+func clientIP(ctx context.Context, r *clientip.Resolver) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	realIP := ""
+	if values := md.Get(keyRealIP); len(values) > 0 {
+		realIP = values[0]
+	}
+	return r.Resolve(p.Addr.String(), md.Get(keyForwardedFor), realIP)
+}
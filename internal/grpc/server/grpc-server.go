@@ -3,30 +3,124 @@ package server
 import (
 	"context"
 	"errors"
-	"fmt"
 	"log/slog"
 	"net/url"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/apitoken"
+	"github.com/kTowkA/shortener/internal/bulk"
+	"github.com/kTowkA/shortener/internal/clientip"
+	"github.com/kTowkA/shortener/internal/deletion"
+	"github.com/kTowkA/shortener/internal/events"
 	pb "github.com/kTowkA/shortener/internal/grpc/proto"
+	"github.com/kTowkA/shortener/internal/health"
+	"github.com/kTowkA/shortener/internal/i18n"
+	"github.com/kTowkA/shortener/internal/idempotency"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/qrcode"
+	"github.com/kTowkA/shortener/internal/ratelimit"
 	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/threat"
 	"github.com/kTowkA/shortener/internal/utils"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/kTowkA/shortener/internal/webhook"
 )
 
 // ShortenerServer наше приложение для реализации gRPC сервиса Shortener
 type ShortenerServer struct {
 	pb.UnimplementedShortenerServer
-	db     storage.Storager
-	logger *slog.Logger
+	db      storage.Storager
+	logger  *slog.Logger
+	threats *threat.List
+	// baseAddress базовый адрес коротких ссылок
+	baseAddress string
+
+	idempotencyWindow time.Duration
+	idempotency       *idempotency.Guard
+
+	limiter *ratelimit.Limiter
+
+	webhooks *webhook.Dispatcher
+	// deletes очередь удаления ссылок, общая с HTTP сервером
+	deletes *deletion.Queue
+	// events шина событий ссылок, в которую публикуются переходы
+	events *events.Bus
+
+	// admin gRPC сервис Admin, регистрируется вместе с сервисом Shortener
+	admin *AdminServer
+
+	health *health.Checker
+
+	// clientIP определяет адрес клиента с учетом доверенных прокси
+	clientIP *clientip.Resolver
+	// trustedSubnets подсети, клиентам из которых доступна статистика
+	trustedSubnets clientip.Subnets
+
+	tokens *apitoken.Service
+}
+
+// Option дополнительная настройка gRPC сервиса
+type Option func(*ShortenerServer)
+
+// WithThreatList устанавливает список угроз, по которому проверяются сокращаемые ссылки
+func WithThreatList(list *threat.List) Option {
+	return func(s *ShortenerServer) {
+		s.threats = list
+	}
+}
+
+// WithBaseAddress устанавливает базовый адрес коротких ссылок (используется в QR кодах)
+func WithBaseAddress(address string) Option {
+	return func(s *ShortenerServer) {
+		s.baseAddress = address
+	}
+}
+
+// WithHealth устанавливает проверки готовности, по которым выставляется состояние сервиса grpc.health.v1
+func WithHealth(c *health.Checker) Option {
+	return func(s *ShortenerServer) {
+		s.health = c
+	}
+}
+
+// WithEvents устанавливает шину событий ссылок, в которую публикуются переходы по ссылкам (DecodeURL),
+// как и при переходе по HTTP
+func WithEvents(bus *events.Bus) Option {
+	return func(s *ShortenerServer) {
+		s.events = bus
+	}
+}
+
+// WithTrustedSubnets открывает статистику сервиса клиентам из подсетей subnets. без настройки статистика недоступна.
+// адрес клиента определяется так же, как для сервиса Admin (WithClientIP)
+func WithTrustedSubnets(subnets clientip.Subnets) Option {
+	return func(s *ShortenerServer) {
+		s.trustedSubnets = subnets
+	}
+}
+
+// Health проверки готовности или nil, если они не заданы
+func (s *ShortenerServer) Health() *health.Checker {
+	return s.health
 }
 
 // CreategRPCServer создает структуру реализующую gRPC сервис Shortener которую будем регистрировать
-func NewGRPCServer(db storage.Storager, logger *slog.Logger) *ShortenerServer {
-	return &ShortenerServer{
+func NewGRPCServer(db storage.Storager, logger *slog.Logger, opts ...Option) *ShortenerServer {
+	s := &ShortenerServer{
 		db:     db,
 		logger: logger,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.admin != nil {
+		s.admin.clientIP = s.clientIP
+	}
+	if s.idempotencyWindow > 0 {
+		s.idempotency = idempotency.New(db, s.idempotencyWindow, logger)
+	}
+	return s
 }
 
 // DecodeURL реализация gRPC сервиса Shortener
@@ -35,14 +129,19 @@ func (s *ShortenerServer) DecodeURL(ctx context.Context, r *pb.DecodeURLRequest)
 	switch {
 	case errors.Is(err, storage.ErrURLNotFound):
 		s.logger.Debug("поиск оригинального URL. ничего не найдено", slog.String("short", r.ShortUrl))
-		return nil, status.Error(codes.NotFound, fmt.Sprintf("по запросу \"%s\" ничего не найдено", r.ShortUrl))
+		return nil, apierror.New(apierror.CodeURLNotFound).WithDetails(r.ShortUrl)
 	case err != nil:
 		s.logger.Error("поиск оригинального URL", slog.String("short", r.ShortUrl), slog.String("ошибка", err.Error()))
-		return nil, err
+		return nil, apierror.From(err)
 	case resp.IsDeleted:
 		s.logger.Debug("поиск оригинального URL. ресурс удален", slog.String("short", r.ShortUrl))
-		return nil, fmt.Errorf("ресурс \"%s\" уже был удален", r.ShortUrl)
+		return nil, apierror.New(apierror.CodeURLDeleted).WithDetails(r.ShortUrl)
+	case resp.IsBlocked || s.threats.Match(resp.OriginalURL):
+		s.logger.Debug("поиск оригинального URL. ресурс заблокирован", slog.String("short", r.ShortUrl))
+		return nil, apierror.New(apierror.CodeURLBlocked).WithDetails(r.ShortUrl)
 	}
+	// успешно. переход публикуется в шину событий
+	s.events.Publish(events.New(model.EventLinkClicked, uuid.Nil, r.ShortUrl, resp.OriginalURL))
 	return &pb.DecodeURLResponse{OriginalUrl: resp.OriginalURL}, nil
 }
 
@@ -50,23 +149,27 @@ func (s *ShortenerServer) DecodeURL(ctx context.Context, r *pb.DecodeURLRequest)
 func (s *ShortenerServer) EncodeURL(ctx context.Context, r *pb.EncodeURLRequest) (*pb.EncodeURLResponse, error) {
 	if _, err := url.Parse(r.OriginalUrl); err != nil {
 		s.logger.Error("сокращение URL", slog.String("short", r.OriginalUrl), slog.String("ошибка", err.Error()))
-		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("переданное значение \"%s\" не явялется валидной ссылкой", r.OriginalUrl))
+		return nil, apierror.New(apierror.CodeInvalidURL).WithDetails(r.OriginalUrl)
+	}
+	if s.threats.Match(r.OriginalUrl) {
+		s.logger.Debug("сокращение URL. ссылка из списка угроз", slog.String("short", r.OriginalUrl))
+		return nil, apierror.New(apierror.CodeThreatURL).WithDetails(r.OriginalUrl)
 	}
 	// здесь можно было обойтись без выхода в случае отсутствия userID, но пусть будет так. С новой сокращенной ссылкой всегда должен быть создавший ее пользователь
 	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		s.logger.Error("получение ID пользователя", slog.String("ошибка", err.Error()))
-		return nil, err
+		return nil, apierror.From(err)
 	}
 
 	short, err := utils.SaveLink(ctx, s.db, userID, r.OriginalUrl)
 	if errors.Is(err, storage.ErrURLConflict) {
 		s.logger.Debug("сокращение URL. конфликт", slog.String("short", r.OriginalUrl))
-		return &pb.EncodeURLResponse{SavedLink: short, Error: storage.ErrURLConflict.Error()}, nil
+		return &pb.EncodeURLResponse{SavedLink: short, Error: apierror.CodeURLConflict.Localized(i18n.FromContext(ctx))}, nil
 	}
 	if err != nil {
 		s.logger.Error("сокращение URL", slog.String("short", r.OriginalUrl), slog.String("ошибка", err.Error()))
-		return nil, err
+		return nil, apierror.From(err)
 	}
 	return &pb.EncodeURLResponse{
 		SavedLink: short,
@@ -78,13 +181,16 @@ func (s *ShortenerServer) Batch(ctx context.Context, r *pb.BatchRequest) (*pb.Ba
 	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		s.logger.Error("получение ID пользователя", slog.String("ошибка", err.Error()))
-		return nil, err
+		return nil, apierror.From(err)
+	}
+	shortener := bulk.Shortener{
+		Store:   s.db,
+		Threats: s.threats,
 	}
-	batch := batchRequestToModelBatchRequest(r)
-	resp, err := utils.SaveBatch(ctx, s.db, userID, batch)
+	resp, _, err := shortener.Batch(ctx, userID, batchRequestToModelBatchRequest(r))
 	if err != nil {
 		s.logger.Error("сохранение массива значений", slog.String("ошибка", err.Error()))
-		return nil, err
+		return nil, apierror.From(err)
 	}
 	return modelBatchResponseToBatchResponse(resp), nil
 }
@@ -94,43 +200,35 @@ func (s *ShortenerServer) UserURLs(ctx context.Context, r *pb.UserURLsRequest) (
 	userID, err := userIDFromContext(ctx)
 	if err != nil {
 		s.logger.Error("получение ID пользователя", slog.String("ошибка", err.Error()))
-		return nil, err
+		return nil, apierror.From(err)
 	}
 	resp, err := s.db.UserURLs(ctx, userID)
 	if errors.Is(err, storage.ErrURLNotFound) {
 		s.logger.Debug("получение ссылок пользователя. ничего не найдено")
-		return nil, status.Error(codes.NotFound, storage.ErrURLNotFound.Error())
+		return nil, apierror.New(apierror.CodeURLNotFound)
 	}
 	if err != nil {
 		s.logger.Error("получение ссылок пользователя", slog.String("ошибка", err.Error()))
-		return nil, err
+		return nil, apierror.From(err)
 	}
 	return modelStorageJSONToUserURLsResponse(resp), nil
 }
 
-// DeleteUserURLs реализация gRPC сервиса Shortener
-func (s *ShortenerServer) DeleteUserURLs(ctx context.Context, r *pb.DelUserRequest) (*pb.DeleteUserURLsResponse, error) {
-	userID, err := userIDFromContext(ctx)
-	if err != nil {
-		s.logger.Error("получение ID пользователя", slog.String("ошибка", err.Error()))
+// Stats реализация gRPC сервиса Shortener. доступна только клиентам из доверенной подсети
+func (s *ShortenerServer) Stats(ctx context.Context, r *pb.StatsRequest) (*pb.StatsResponse, error) {
+	if _, err := trustedClient(ctx, s.clientIP, s.trustedSubnets, s.logger); err != nil {
 		return nil, err
 	}
-	err = s.db.DeleteURLs(ctx, delUserRequestToModelDeleteURLMessage(userID.String(), r))
+	req, err := utils.ParseStatsRequest(r.GetFrom(), r.GetTo(), r.GetGranularity(), int(r.GetTop()), time.Now())
 	if err != nil {
-		s.logger.Error("удаление ссылок пользователя", slog.String("ошибка", err.Error()))
-		return nil, err
+		return nil, apierror.From(err)
 	}
-	return &pb.DeleteUserURLsResponse{}, nil
-}
-
-// Stats реализация gRPC сервиса Shortener
-func (s *ShortenerServer) Stats(ctx context.Context, r *pb.StatsRequest) (*pb.StatsResponse, error) {
-	stats, err := s.db.Stats(ctx)
+	stats, err := s.db.Stats(ctx, req)
 	if err != nil {
 		s.logger.Error("получение статистики", slog.String("ошибка", err.Error()))
-		return nil, err
+		return nil, apierror.From(err)
 	}
-	return &pb.StatsResponse{Users: int32(stats.TotalUsers), Urls: int32(stats.TotalURLs)}, nil
+	return modelStatsToStats(stats), nil
 }
 
 // Ping реализация gRPC сервиса Shortener
@@ -138,7 +236,33 @@ func (s *ShortenerServer) Ping(ctx context.Context, r *pb.PingRequest) (*pb.Ping
 	err := s.db.Ping(ctx)
 	if err != nil {
 		s.logger.Error("проверка доступности сервиса", slog.String("ошибка", err.Error()))
-		return nil, status.Error(codes.Unavailable, "в настоящее время сервис не доступен")
+		return nil, apierror.Wrap(apierror.CodeUnavailable, err)
 	}
 	return &pb.PingResponse{Status: &pb.PingResponse_Status{Ok: true}}, nil
 }
+
+// QRCode реализация gRPC сервиса Shortener
+func (s *ShortenerServer) QRCode(ctx context.Context, r *pb.QRCodeRequest) (*pb.QRCodeResponse, error) {
+	opts, err := qrCodeRequestToOptions(r)
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	resp, err := s.db.RealURL(ctx, r.ShortUrl)
+	switch {
+	case errors.Is(err, storage.ErrURLNotFound):
+		return nil, apierror.New(apierror.CodeURLNotFound).WithDetails(r.ShortUrl)
+	case err != nil:
+		s.logger.Error("поиск оригинального URL", slog.String("short", r.ShortUrl), slog.String("ошибка", err.Error()))
+		return nil, apierror.From(err)
+	case resp.IsDeleted:
+		return nil, apierror.New(apierror.CodeURLDeleted).WithDetails(r.ShortUrl)
+	case resp.IsBlocked || s.threats.Match(resp.OriginalURL):
+		return nil, apierror.New(apierror.CodeURLBlocked).WithDetails(r.ShortUrl)
+	}
+	image, err := qrcode.Encode(s.baseAddress+r.ShortUrl, opts)
+	if err != nil {
+		s.logger.Error("формирование QR кода", slog.String("short", r.ShortUrl), slog.String("ошибка", err.Error()))
+		return nil, apierror.From(err)
+	}
+	return &pb.QRCodeResponse{ContentType: opts.ContentType(), Image: image}, nil
+}
@@ -4,19 +4,33 @@ import (
 	"context"
 	"errors"
 	"log/slog"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/admin"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/apitoken"
+	"github.com/kTowkA/shortener/internal/clientip"
+	"github.com/kTowkA/shortener/internal/deletion"
+	"github.com/kTowkA/shortener/internal/events"
 	pb "github.com/kTowkA/shortener/internal/grpc/proto"
+	"github.com/kTowkA/shortener/internal/idempotency"
 	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/ratelimit"
 	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
 	mocks "github.com/kTowkA/shortener/internal/storage/mocs"
+	"github.com/kTowkA/shortener/internal/webhook"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 var ctxDuration = time.Second * 10
@@ -45,6 +59,7 @@ func (suite *GRPCSuite) SetupSuite() {
 	suite.gs = new(ShortenerServer)
 	suite.gs.db = suite.mockStorage
 	suite.gs.logger = slog.Default()
+	suite.gs.trustedSubnets, _ = clientip.ParseSubnets("10.0.0.0/8")
 }
 func (suite *GRPCSuite) TearDownSuite() {
 	//заканчиваем работу с тестовым сценарием
@@ -118,9 +133,9 @@ func (suite *GRPCSuite) TestEncodeURL() {
 			},
 			ctxReq:       ctxWithUserID,
 			wantError:    false,
-			wantResponse: &pb.EncodeURLResponse{Error: storage.ErrURLConflict.Error()},
+			wantResponse: &pb.EncodeURLResponse{Error: apierror.CodeURLConflict.Message()},
 			mockFunc: func() {
-				suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, "http://foo.com", mock.AnythingOfType("string")).Return("123", storage.ErrURLConflict)
+				suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, "http://foo.com", mock.AnythingOfType("string"), mock.Anything).Return("123", storage.ErrURLConflict)
 			},
 		},
 		{
@@ -131,7 +146,7 @@ func (suite *GRPCSuite) TestEncodeURL() {
 			ctxReq:    ctxWithUserID,
 			wantError: true,
 			mockFunc: func() {
-				suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, "http://foo1.com", mock.AnythingOfType("string")).Return("", errors.New("encode error"))
+				suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, "http://foo1.com", mock.AnythingOfType("string"), mock.Anything).Return("", errors.New("encode error"))
 			},
 		},
 		{
@@ -143,7 +158,7 @@ func (suite *GRPCSuite) TestEncodeURL() {
 			wantError:    false,
 			wantResponse: &pb.EncodeURLResponse{},
 			mockFunc: func() {
-				suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, "http://foo2.com", mock.AnythingOfType("string")).Return("123", nil)
+				suite.mockStorage.On("SaveURL", mock.Anything, mock.Anything, "http://foo2.com", mock.AnythingOfType("string"), mock.Anything).Return("123", nil)
 			},
 		},
 	}
@@ -203,6 +218,15 @@ func (suite *GRPCSuite) TestDecode() {
 				suite.mockStorage.On("RealURL", mock.Anything, "345").Return(model.StorageJSON{IsDeleted: true}, nil)
 			},
 		},
+		{
+			name:            "заблокировано",
+			wantError:       true,
+			wantErrorStatus: codes.PermissionDenied,
+			req:             &pb.DecodeURLRequest{ShortUrl: "456"},
+			mockFunc: func() {
+				suite.mockStorage.On("RealURL", mock.Anything, "456").Return(model.StorageJSON{OriginalURL: "666", IsBlocked: true}, nil)
+			},
+		},
 		{
 			name:      "все хорошо",
 			wantError: false,
@@ -237,21 +261,65 @@ func (suite *GRPCSuite) TestStats() {
 	ctx, cancel := context.WithTimeout(context.Background(), ctxDuration)
 	defer cancel()
 
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	stats := model.StatsResponse{
+		TotalUsers:  1,
+		TotalURLs:   3,
+		ActiveURLs:  2,
+		DeletedURLs: 1,
+		Created: model.StatsSeries{
+			Granularity: model.StatsDay,
+			Points:      []model.StatsPoint{{Time: day, Links: 3}},
+		},
+		TopDomains: []model.StatsCount{{Key: "go.dev", Links: 3}},
+		TopUsers:   []model.StatsCount{{Key: model.StatsUserKey("user"), Links: 3}},
+	}
+	// клиент не из доверенной подсети
+	_, err := suite.gs.Stats(peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 50000}}), &pb.StatsRequest{})
+	suite.Equal(codes.PermissionDenied, status.Code(err))
+	_, err = suite.gs.Stats(ctx, &pb.StatsRequest{})
+	suite.Equal(codes.PermissionDenied, status.Code(err))
+	_, err = NewGRPCServer(suite.mockStorage, slog.Default()).Stats(ctx, &pb.StatsRequest{})
+	suite.Equal(codes.PermissionDenied, status.Code(err), "подсеть не задана")
+
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50000}})
 	tests := []Test{
 		{
-			name:      "ошибка хранилища",
-			req:       &pb.StatsRequest{},
-			wantError: true,
+			name:            "некорректные параметры",
+			req:             &pb.StatsRequest{Granularity: "week"},
+			wantError:       true,
+			wantErrorStatus: codes.InvalidArgument,
+		},
+		{
+			name:            "ошибка хранилища",
+			req:             &pb.StatsRequest{},
+			wantError:       true,
+			wantErrorStatus: codes.Internal,
 			mockFunc: func() {
-				suite.mockStorage.On("Stats", mock.Anything).Return(model.StatsResponse{}, errors.New("stats error")).Once()
+				suite.mockStorage.On("Stats", mock.Anything, mock.Anything).Return(model.StatsResponse{}, errors.New("stats error")).Once()
 			},
 		},
 		{
 			name:      "все хорошо",
-			req:       &pb.StatsRequest{},
+			req:       &pb.StatsRequest{From: "2024-05-01", To: "2024-05-01", Top: 5},
 			wantError: false,
 			mockFunc: func() {
-				suite.mockStorage.On("Stats", mock.Anything).Return(model.StatsResponse{TotalUsers: 1, TotalURLs: 2}, nil).Once()
+				suite.mockStorage.On("Stats", mock.Anything, model.StatsRequest{
+					From:        day,
+					To:          day.Add(24 * time.Hour),
+					Granularity: model.StatsDay,
+					Top:         5,
+				}).Return(stats, nil).Once()
+			},
+			wantResponse: &pb.StatsResponse{
+				Users:       1,
+				Urls:        3,
+				ActiveUrls:  2,
+				DeletedUrls: 1,
+				Granularity: "day",
+				Created:     []*pb.StatsPoint{{Time: "2024-05-01T00:00:00Z", Links: 3}},
+				TopDomains:  []*pb.StatsCount{{Key: "go.dev", Links: 3}},
+				TopUsers:    []*pb.StatsCount{{Key: model.StatsUserKey("user"), Links: 3}},
 			},
 		},
 	}
@@ -261,10 +329,12 @@ func (suite *GRPCSuite) TestStats() {
 		}
 		resp, err := suite.gs.Stats(ctx, (t.req).(*pb.StatsRequest))
 		if !t.wantError {
-			suite.EqualValues(&pb.StatsResponse{Users: 1, Urls: 2}, resp)
+			suite.Require().NoError(err, t.name)
+			suite.True(proto.Equal(t.wantResponse.(*pb.StatsResponse), resp), t.name)
 			continue
 		}
-		suite.Error(err)
+		suite.Error(err, t.name)
+		suite.Equal(t.wantErrorStatus, status.Code(err), t.name)
 	}
 }
 func (suite *GRPCSuite) TestBatch() {
@@ -297,25 +367,34 @@ func (suite *GRPCSuite) TestBatch() {
 			wantErrorStatus: codes.InvalidArgument,
 		},
 		{
-			name:      "ошибка при сохранении",
-			req:       &pb.BatchRequest{},
+			name: "ошибка при сохранении",
+			req: &pb.BatchRequest{
+				Elements: []*pb.BatchRequest_BatchRequestElement{{CorrelationId: "1", OriginalUrl: "https://go.dev/1"}},
+			},
 			ctxReq:    ctxWithUserID,
-			wantError: true,
+			wantError: false,
 			mockFunc: func() {
 				suite.mockStorage.On("Batch", mock.Anything, mock.Anything, mock.Anything).Return(model.BatchResponse{}, errors.New("batch error")).Once()
 			},
+			wantResponse: &pb.BatchResponse{Result: []*pb.BatchResponse_Result{{CorrelationId: "1", Status: "failed", Reason: "internal"}}},
 		},
 		{
 			name: "все хорошо",
 			req: &pb.BatchRequest{
-				Elements: []*pb.BatchRequest_BatchRequestElement{{OriginalUrl: "333"}},
+				Elements: []*pb.BatchRequest_BatchRequestElement{{CorrelationId: "1", OriginalUrl: "https://go.dev/333"}, {CorrelationId: "2", OriginalUrl: "333"}},
 			},
 			ctxReq:    ctxWithUserID,
 			wantError: false,
 			mockFunc: func() {
-				suite.mockStorage.On("Batch", mock.Anything, mock.Anything, mock.Anything).Return(model.BatchResponse{model.BatchResponseElement{OriginalURL: "333", ShortURL: "3"}}, nil).Once()
+				suite.mockStorage.On("Batch", mock.Anything, mock.Anything, mock.Anything).Return(func(ctx context.Context, userID uuid.UUID, values model.BatchRequest) (model.BatchResponse, error) {
+					suite.Require().Len(values, 1, "невалидные ссылки не сохраняются")
+					return model.BatchResponse{{CorrelationID: values[0].CorrelationID, OriginalURL: values[0].OriginalURL, ShortURL: "3"}}, nil
+				}).Once()
 			},
-			wantResponse: &pb.BatchResponse{Result: []*pb.BatchResponse_Result{{ShortUrl: "3"}}},
+			wantResponse: &pb.BatchResponse{Result: []*pb.BatchResponse_Result{
+				{CorrelationId: "1", ShortUrl: "3", Status: "created"},
+				{CorrelationId: "2", Status: "invalid", Reason: "invalid_url"},
+			}},
 		},
 	}
 	for _, t := range tests {
@@ -324,8 +403,15 @@ func (suite *GRPCSuite) TestBatch() {
 		}
 		resp, err := suite.gs.Batch(t.ctxReq, (t.req).(*pb.BatchRequest))
 		if !t.wantError {
+			suite.Require().NoError(err, t.name)
 			wr := (t.wantResponse).(*pb.BatchResponse)
-			suite.EqualValues(wr.Result[0].ShortUrl, resp.Result[0].ShortUrl)
+			suite.Require().Len(resp.Result, len(wr.Result), t.name)
+			for i := range wr.Result {
+				suite.EqualValues(wr.Result[i].CorrelationId, resp.Result[i].CorrelationId, t.name)
+				suite.EqualValues(wr.Result[i].ShortUrl, resp.Result[i].ShortUrl, t.name)
+				suite.EqualValues(wr.Result[i].Status, resp.Result[i].Status, t.name)
+				suite.EqualValues(wr.Result[i].Reason, resp.Result[i].Reason, t.name)
+			}
 			continue
 		}
 		suite.Error(err, t.name)
@@ -420,6 +506,19 @@ func (suite *GRPCSuite) TestDeleteUserURLs() {
 	userID := uuid.New()
 	ctxWithUserID := metadata.NewIncomingContext(ctx, metadata.New(map[string]string{keyUserID: userID.String()}))
 
+	// очередь удаления не настроена
+	_, err := suite.gs.DeleteUserURLs(ctxWithUserID, &pb.DelUserRequest{ShortUrls: []string{"1"}})
+	suite.Equal(codes.Unavailable, status.Code(err))
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	for _, short := range []string{"1", "2"} {
+		_, err = store.SaveURL(ctx, userID, "https://go.dev/"+short, short, model.LinkOptions{})
+		suite.Require().NoError(err)
+	}
+	queue := deletion.New(store, deletion.Options{Capacity: 3})
+	gs := NewGRPCServer(store, slog.Default(), WithDeletionQueue(queue))
+
 	tests := []Test{
 		{
 			name:            "в запросе не было uuid пользователя",
@@ -435,22 +534,91 @@ func (suite *GRPCSuite) TestDeleteUserURLs() {
 			wantError:       true,
 			wantErrorStatus: codes.InvalidArgument,
 		},
+	}
+	for _, t := range tests {
+		_, err := gs.DeleteUserURLs(t.ctxReq, (t.req).(*pb.DelUserRequest))
+		suite.Error(err, t.name)
+		suite.EqualValues(t.wantErrorStatus, status.Code(err), t.name)
+	}
+
+	// удаление принимается в очередь, ссылки удаляются при ее работе
+	resp, err := gs.DeleteUserURLs(ctxWithUserID, &pb.DelUserRequest{ShortUrls: []string{"1", "2", "3"}})
+	suite.Require().NoError(err)
+	suite.Equal(string(model.JobQueued), resp.Task.Status)
+	suite.EqualValues(3, resp.Task.Total)
+	real, err := store.RealURL(ctx, "1")
+	suite.Require().NoError(err)
+	suite.False(real.IsDeleted, "ссылки удаляются очередью, а не при вызове")
+
+	// в очереди нет места для ссылок запроса
+	_, err = gs.DeleteUserURLs(ctxWithUserID, &pb.DelUserRequest{ShortUrls: []string{"1"}})
+	suite.Equal(apierror.CodeDeleteQueueFull, apierror.CodeOf(err))
+	suite.Equal(codes.Unavailable, status.Code(err))
+
+	queueCtx, stopQueue := context.WithCancel(ctx)
+	stopQueue()
+	queue.Run(queueCtx)
+	deleted, err := gs.Deletion(ctxWithUserID, &pb.DeletionRequest{Id: resp.Task.Id})
+	suite.Require().NoError(err)
+	suite.Equal(string(model.JobDone), deleted.Task.Status)
+	suite.EqualValues(2, deleted.Task.Deleted)
+	suite.EqualValues(1, deleted.Task.NotFound)
+	suite.Require().Len(deleted.Task.Results, 3)
+	suite.Equal(string(model.DeleteNotFound), deleted.Task.Results[2].Status)
+	real, err = store.RealURL(ctx, "1")
+	suite.Require().NoError(err)
+	suite.True(real.IsDeleted)
+
+	// чужие и несуществующие задачи не найдены
+	otherCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(keyUserID, uuid.New().String()))
+	_, err = gs.Deletion(otherCtx, &pb.DeletionRequest{Id: resp.Task.Id})
+	suite.Equal(codes.NotFound, status.Code(err))
+	_, err = gs.Deletion(ctxWithUserID, &pb.DeletionRequest{Id: "bad"})
+	suite.Equal(apierror.CodeDeletionNotFound, apierror.CodeOf(err))
+}
+func (suite *GRPCSuite) TestQRCode() {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxDuration)
+	defer cancel()
+
+	tests := []Test{
+		{
+			name:            "неверные параметры",
+			wantError:       true,
+			wantErrorStatus: codes.InvalidArgument,
+			req:             &pb.QRCodeRequest{ShortUrl: "qr1", Level: "X"},
+		},
 		{
-			name:      "ошибка при запросе",
-			req:       &pb.DelUserRequest{ShortUrls: []string{"1", "2"}},
-			ctxReq:    ctxWithUserID,
-			wantError: true,
+			name:            "ничего не найдено",
+			wantError:       true,
+			wantErrorStatus: codes.NotFound,
+			req:             &pb.QRCodeRequest{ShortUrl: "qr2"},
 			mockFunc: func() {
-				suite.mockStorage.On("DeleteURLs", mock.Anything, []model.DeleteURLMessage{{UserID: userID.String(), ShortURL: "1"}, {UserID: userID.String(), ShortURL: "2"}}).Return(errors.New("delete user urls error")).Once()
+				suite.mockStorage.On("RealURL", mock.Anything, "qr2").Return(model.StorageJSON{}, storage.ErrURLNotFound).Once()
 			},
 		},
 		{
-			name:      "все хорошо",
-			req:       &pb.DelUserRequest{ShortUrls: []string{"1", "2"}},
-			ctxReq:    ctxWithUserID,
-			wantError: false,
+			name:            "было удалено",
+			wantError:       true,
+			wantErrorStatus: codes.NotFound,
+			req:             &pb.QRCodeRequest{ShortUrl: "qr3"},
 			mockFunc: func() {
-				suite.mockStorage.On("DeleteURLs", mock.Anything, []model.DeleteURLMessage{{UserID: userID.String(), ShortURL: "1"}, {UserID: userID.String(), ShortURL: "2"}}).Return(nil).Once()
+				suite.mockStorage.On("RealURL", mock.Anything, "qr3").Return(model.StorageJSON{IsDeleted: true}, nil).Once()
+			},
+		},
+		{
+			name:            "заблокировано",
+			wantError:       true,
+			wantErrorStatus: codes.PermissionDenied,
+			req:             &pb.QRCodeRequest{ShortUrl: "qr5"},
+			mockFunc: func() {
+				suite.mockStorage.On("RealURL", mock.Anything, "qr5").Return(model.StorageJSON{OriginalURL: "https://go.dev", IsBlocked: true}, nil).Once()
+			},
+		},
+		{
+			name: "все хорошо",
+			req:  &pb.QRCodeRequest{ShortUrl: "qr4", Format: "svg", Foreground: "ff0000"},
+			mockFunc: func() {
+				suite.mockStorage.On("RealURL", mock.Anything, "qr4").Return(model.StorageJSON{OriginalURL: "https://go.dev"}, nil).Once()
 			},
 		},
 	}
@@ -458,20 +626,324 @@ func (suite *GRPCSuite) TestDeleteUserURLs() {
 		if t.mockFunc != nil {
 			t.mockFunc()
 		}
-		_, err := suite.gs.DeleteUserURLs(t.ctxReq, (t.req).(*pb.DelUserRequest))
+
+		resp, err := suite.gs.QRCode(ctx, (t.req).(*pb.QRCodeRequest))
 		if !t.wantError {
+			suite.Require().NoError(err, t.name)
+			suite.EqualValues("image/svg+xml", resp.ContentType, t.name)
+			suite.Contains(string(resp.Image), `fill="#ff0000"`, t.name)
 			continue
 		}
 		suite.Error(err, t.name)
-		if t.wantErrorStatus > 0 {
-			if e, ok := status.FromError(err); ok {
-				suite.EqualValues(t.wantErrorStatus, e.Code(), t.name)
-			} else {
-				suite.Fail("должна содержаться ошибка", t.name)
-			}
+		if e, ok := status.FromError(err); ok {
+			suite.EqualValues(t.wantErrorStatus, e.Code(), t.name)
+		} else {
+			suite.Fail("должна содержаться ошибка")
 		}
+		suite.NotEmpty(apierror.CodeOf(err), "код ошибки передается в деталях статуса")
 	}
 }
+
+func (suite *GRPCSuite) TestIdempotent() {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxDuration)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	gs := NewGRPCServer(store, slog.Default(), WithIdempotencyWindow(time.Hour))
+	info := &grpc.UnaryServerInfo{FullMethod: pb.Shortener_EncodeURL_FullMethodName}
+	handler := func(ctx context.Context, req any) (any, error) {
+		return gs.EncodeURL(ctx, req.(*pb.EncodeURLRequest))
+	}
+	callCtx := func(key string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(keyUserID, uuid.NewSHA1(uuid.Nil, []byte("user")).String(), idempotency.MetadataKey, key))
+	}
+
+	// повтор получает первый ответ без конфликта
+	first, err := gs.Idempotent(callCtx("key"), &pb.EncodeURLRequest{OriginalUrl: "https://go.dev"}, info, handler)
+	suite.Require().NoError(err)
+	suite.Empty(first.(*pb.EncodeURLResponse).Error)
+	retry, err := gs.Idempotent(callCtx("key"), &pb.EncodeURLRequest{OriginalUrl: "https://go.dev"}, info, handler)
+	suite.Require().NoError(err)
+	suite.True(proto.Equal(first.(proto.Message), retry.(proto.Message)))
+
+	// с новым ключом - конфликт
+	resp, err := gs.Idempotent(callCtx("other"), &pb.EncodeURLRequest{OriginalUrl: "https://go.dev"}, info, handler)
+	suite.Require().NoError(err)
+	suite.NotEmpty(resp.(*pb.EncodeURLResponse).Error)
+
+	// ключ использован для другого запроса
+	_, err = gs.Idempotent(callCtx("key"), &pb.EncodeURLRequest{OriginalUrl: "https://pkg.go.dev"}, info, handler)
+	suite.Equal(codes.FailedPrecondition, status.Code(err))
+	suite.Equal(apierror.CodeIdemKeyReused, apierror.CodeOf(err))
+
+	// недопустимый ключ
+	_, err = gs.Idempotent(callCtx(""), &pb.EncodeURLRequest{OriginalUrl: "https://go.dev"}, info, handler)
+	suite.Equal(apierror.CodeBadIdemKey, apierror.CodeOf(err))
+
+	// без пользователя ключ не принимается
+	_, err = gs.Idempotent(metadata.NewIncomingContext(ctx, metadata.Pairs(idempotency.MetadataKey, "key")), &pb.EncodeURLRequest{OriginalUrl: "https://go.dev"}, info, handler)
+	suite.Equal(codes.InvalidArgument, status.Code(err))
+	suite.Equal(apierror.CodeIdemKeyNoUser, apierror.CodeOf(err))
+}
+
+func (suite *GRPCSuite) TestRateLimit() {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxDuration)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	gs := NewGRPCServer(store, slog.Default(), WithRateLimiter(ratelimit.New(ratelimit.Limits{ratelimit.Create: 1})))
+	calls := 0
+	handler := func(ctx context.Context, req any) (any, error) {
+		calls++
+		return nil, nil
+	}
+	callCtx := func(user, ip string) context.Context {
+		ctx := metadata.NewIncomingContext(ctx, metadata.Pairs(keyUserID, user))
+		return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	}
+	create := &grpc.UnaryServerInfo{FullMethod: pb.Shortener_EncodeURL_FullMethodName}
+	user := uuid.New().String()
+
+	_, err = gs.RateLimit(callCtx(user, "10.0.0.1"), nil, create, handler)
+	suite.NoError(err)
+
+	// тот же пользователь с другого адреса
+	_, err = gs.RateLimit(callCtx(user, "10.0.0.2"), nil, create, handler)
+	suite.Equal(codes.ResourceExhausted, status.Code(err))
+	suite.Equal(apierror.CodeRateLimited, apierror.CodeOf(err))
+
+	// другой пользователь с того же адреса
+	_, err = gs.RateLimit(callCtx(uuid.New().String(), "10.0.0.1"), nil, &grpc.UnaryServerInfo{FullMethod: pb.Shortener_Batch_FullMethodName}, handler)
+	suite.Equal(codes.ResourceExhausted, status.Code(err))
+
+	// остальные методы не ограничены
+	_, err = gs.RateLimit(callCtx(user, "10.0.0.1"), nil, &grpc.UnaryServerInfo{FullMethod: pb.Shortener_DecodeURL_FullMethodName}, handler)
+	suite.NoError(err)
+	suite.Equal(2, calls)
+}
+
+func (suite *GRPCSuite) TestWebhooks() {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxDuration)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	userCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(keyUserID, uuid.New().String()))
+
+	// подписки не настроены
+	_, err = NewGRPCServer(store, slog.Default()).ListWebhooks(userCtx, &pb.ListWebhooksRequest{})
+	suite.Equal(codes.Unavailable, status.Code(err))
+
+	gs := NewGRPCServer(store, slog.Default(), WithWebhooks(webhook.New(store, webhook.Options{})))
+	_, err = gs.CreateWebhook(userCtx, &pb.CreateWebhookRequest{Url: "https://example.com/hook"})
+	suite.Equal(apierror.CodeInvalidWebhook, apierror.CodeOf(err))
+	created, err := gs.CreateWebhook(userCtx, &pb.CreateWebhookRequest{
+		Url:    "https://example.com/hook",
+		Events: []string{string(model.EventLinkCreated), string(model.EventLinkDeleted)},
+	})
+	suite.Require().NoError(err)
+	suite.NotEmpty(created.Webhook.Secret)
+	suite.Equal([]string{string(model.EventLinkCreated), string(model.EventLinkDeleted)}, created.Webhook.Events)
+
+	list, err := gs.ListWebhooks(userCtx, &pb.ListWebhooksRequest{})
+	suite.Require().NoError(err)
+	suite.Require().Len(list.Webhooks, 1)
+	suite.Equal(created.Webhook.Id, list.Webhooks[0].Id)
+	suite.Empty(list.Webhooks[0].Secret)
+
+	deliveries, err := gs.WebhookDeliveries(userCtx, &pb.WebhookDeliveriesRequest{WebhookId: created.Webhook.Id, Status: string(model.DeliveryDead)})
+	suite.Require().NoError(err)
+	suite.Empty(deliveries.Deliveries)
+	_, err = gs.WebhookDeliveries(userCtx, &pb.WebhookDeliveriesRequest{WebhookId: created.Webhook.Id, Status: "unknown"})
+	suite.Equal(apierror.CodeBadRequest, apierror.CodeOf(err))
+	_, err = gs.RedeliverWebhook(userCtx, &pb.RedeliverWebhookRequest{WebhookId: created.Webhook.Id, DeliveryId: uuid.NewString()})
+	suite.Equal(apierror.CodeDeliveryNotFound, apierror.CodeOf(err))
+
+	// чужие подписки недоступны
+	otherCtx := metadata.NewIncomingContext(ctx, metadata.Pairs(keyUserID, uuid.New().String()))
+	_, err = gs.DeleteWebhook(otherCtx, &pb.DeleteWebhookRequest{Id: created.Webhook.Id})
+	suite.Equal(codes.NotFound, status.Code(err))
+	suite.Equal(apierror.CodeWebhookNotFound, apierror.CodeOf(err))
+	_, err = gs.DeleteWebhook(userCtx, &pb.DeleteWebhookRequest{Id: created.Webhook.Id})
+	suite.NoError(err)
+	_, err = gs.DeleteWebhook(userCtx, &pb.DeleteWebhookRequest{Id: "bad"})
+	suite.Equal(apierror.CodeWebhookNotFound, apierror.CodeOf(err))
+}
+
+func (suite *GRPCSuite) TestDecodeEvents() {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxDuration)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	user := uuid.New()
+	_, err = store.SaveURL(ctx, user, "https://go.dev", "go", model.LinkOptions{})
+	suite.Require().NoError(err)
+	bus := events.NewBus(store, events.BusOptions{})
+	sub := bus.Subscribe(user, 0)
+	defer sub.Close()
+	go bus.Run(ctx)
+
+	// переход через gRPC учитывается так же, как переход по HTTP
+	gs := NewGRPCServer(store, slog.Default(), WithEvents(bus))
+	for i := int64(1); i <= 2; i++ {
+		_, err = gs.DecodeURL(ctx, &pb.DecodeURLRequest{ShortUrl: "go"})
+		suite.Require().NoError(err)
+		select {
+		case e := <-sub.Events():
+			suite.Equal(model.EventLinkClicked, e.Type)
+			suite.Equal("go", e.ShortURL)
+			suite.Equal(i, e.Clicks)
+		case <-ctx.Done():
+			suite.FailNow("нет события перехода")
+		}
+	}
+	_, err = gs.DecodeURL(ctx, &pb.DecodeURLRequest{ShortUrl: "missing"})
+	suite.Equal(apierror.CodeURLNotFound, apierror.CodeOf(err))
+	clicks, err := store.CountClick(ctx, "go")
+	suite.Require().NoError(err)
+	suite.EqualValues(3, clicks.Clicks)
+}
+
+func (suite *GRPCSuite) TestAdmin() {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxDuration)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	owner := uuid.New()
+	_, err = store.SaveURL(ctx, owner, "https://go.dev", "go", model.LinkOptions{})
+	suite.Require().NoError(err)
+	subnets, err := clientip.ParseSubnets("10.0.0.0/8,fd00::/8")
+	suite.Require().NoError(err)
+	proxies, err := clientip.ParseSubnets("172.16.0.1")
+	suite.Require().NoError(err)
+	gs := NewGRPCServer(store, slog.Default(), WithAdmin(admin.New(store, 0, nil), subnets), WithClientIP(clientip.NewResolver(proxies)))
+	as := gs.Admin()
+	suite.Require().NotNil(as)
+	suite.Nil(NewGRPCServer(store, slog.Default()).Admin(), "без настройки сервис не регистрируется")
+	peerCtx := func(ip string) context.Context {
+		return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}})
+	}
+	adminCtx := peerCtx("10.0.0.1")
+
+	// клиент не из доверенной подсети
+	_, err = as.Link(peerCtx("192.168.0.1"), &pb.AdminLinkRequest{ShortUrl: "go"})
+	suite.Equal(codes.PermissionDenied, status.Code(err))
+	_, err = as.Link(ctx, &pb.AdminLinkRequest{ShortUrl: "go"})
+	suite.Equal(codes.PermissionDenied, status.Code(err))
+	_, err = NewAdminServer(admin.New(store, 0, nil), nil, slog.Default()).Link(adminCtx, &pb.AdminLinkRequest{ShortUrl: "go"})
+	suite.Equal(codes.PermissionDenied, status.Code(err), "подсеть не задана")
+	// адрес из метаданных учитывается только от доверенного прокси
+	forwarded := func(ctx context.Context) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(keyForwardedFor, "10.0.0.1"))
+	}
+	_, err = as.Link(forwarded(peerCtx("192.168.0.1")), &pb.AdminLinkRequest{ShortUrl: "go"})
+	suite.Equal(codes.PermissionDenied, status.Code(err))
+	_, err = as.Link(forwarded(peerCtx("172.16.0.1")), &pb.AdminLinkRequest{ShortUrl: "go"})
+	suite.NoError(err)
+	_, err = as.Link(peerCtx("fd00::1"), &pb.AdminLinkRequest{ShortUrl: "go"})
+	suite.NoError(err, "подсеть IPv6")
+
+	link, err := as.Link(adminCtx, &pb.AdminLinkRequest{ShortUrl: "go"})
+	suite.Require().NoError(err)
+	suite.Equal(owner.String(), link.Link.UserId)
+	_, err = as.Link(adminCtx, &pb.AdminLinkRequest{ShortUrl: "missing"})
+	suite.Equal(codes.NotFound, status.Code(err))
+
+	disabled, err := as.SetLinkDisabled(adminCtx, &pb.SetLinkDisabledRequest{ShortUrl: "go", Disabled: true})
+	suite.Require().NoError(err)
+	suite.True(disabled.Link.IsBlocked)
+	_, err = gs.DecodeURL(adminCtx, &pb.DecodeURLRequest{ShortUrl: "go"})
+	suite.Equal(apierror.CodeURLBlocked, apierror.CodeOf(err))
+
+	links, err := as.UserLinks(adminCtx, &pb.AdminUserLinksRequest{UserId: owner.String()})
+	suite.Require().NoError(err)
+	suite.Require().Len(links.Links, 1)
+	suite.Equal("go", links.Links[0].ShortUrl)
+	_, err = as.UserLinks(adminCtx, &pb.AdminUserLinksRequest{UserId: "bad"})
+	suite.Equal(apierror.CodeInvalidUserID, apierror.CodeOf(err))
+
+	_, err = as.SetUserBlocked(adminCtx, &pb.SetUserBlockedRequest{UserId: owner.String(), Blocked: true, Reason: "спам"})
+	suite.Require().NoError(err)
+	blocked, err := store.UserBlocked(ctx, owner)
+	suite.Require().NoError(err)
+	suite.True(blocked)
+
+	purged, err := as.Purge(adminCtx, &pb.PurgeRequest{ShortUrls: []string{"go", "missing"}})
+	suite.Require().NoError(err)
+	suite.Equal([]string{"go"}, purged.Purged)
+	suite.Equal([]string{"missing"}, purged.NotFound)
+	_, err = as.Purge(adminCtx, &pb.PurgeRequest{})
+	suite.Equal(apierror.CodeEmptyRequest, apierror.CodeOf(err))
+
+	audit, err := as.AuditLog(adminCtx, &pb.AuditLogRequest{Limit: 2})
+	suite.Require().NoError(err)
+	suite.Require().Len(audit.Records, 2)
+	suite.Equal(string(model.AuditLinksPurge), audit.Records[0].Action)
+	suite.Equal("grpc 10.0.0.1", audit.Records[0].Actor)
+	suite.Equal(string(apierror.CodeEmptyRequest), audit.Records[0].Result)
+}
+
+func (suite *GRPCSuite) TestAuthenticate() {
+	ctx, cancel := context.WithTimeout(context.Background(), ctxDuration)
+	defer cancel()
+
+	store, err := memory.NewStorage("")
+	suite.Require().NoError(err)
+	tokens := apitoken.New(store, slog.Default())
+	owner := uuid.New()
+	suite.Require().NoError(store.CreateAccount(ctx, model.Account{ID: owner, Login: "alice", CreatedAt: time.Now()}))
+	token, err := tokens.Create(ctx, owner, model.APITokenRequest{Name: "ci", Scopes: []model.Scope{model.ScopeLinksRead}})
+	suite.Require().NoError(err)
+
+	gs := NewGRPCServer(store, slog.Default(), WithAPITokens(tokens))
+	var gotUser string
+	handler := func(ctx context.Context, req any) (any, error) {
+		userID, err := userIDFromContext(ctx)
+		gotUser = userID.String()
+		return nil, err
+	}
+	callCtx := func(pairs ...string) context.Context {
+		return metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...))
+	}
+	info := func(method string) *grpc.UnaryServerInfo {
+		return &grpc.UnaryServerInfo{FullMethod: method}
+	}
+	bearer := "Bearer " + token.Token
+
+	// пользователь вызова - владелец токена, даже если передан другой userid
+	_, err = gs.Authenticate(callCtx(keyAuthorization, bearer, keyUserID, uuid.New().String()), nil, info(pb.Shortener_UserURLs_FullMethodName), handler)
+	suite.Require().NoError(err)
+	suite.Equal(owner.String(), gotUser)
+
+	// методы без права токена и недоступные по токенам методы
+	for _, method := range []string{pb.Shortener_EncodeURL_FullMethodName, pb.Shortener_DeleteUserURLs_FullMethodName, pb.Shortener_Stats_FullMethodName, pb.Shortener_CreateWebhook_FullMethodName, pb.Admin_Purge_FullMethodName} {
+		_, err = gs.Authenticate(callCtx(keyAuthorization, bearer), nil, info(method), handler)
+		suite.Equal(codes.PermissionDenied, status.Code(err), method)
+		suite.Equal(apierror.CodeInsufficientScope, apierror.CodeOf(err), method)
+	}
+	_, err = gs.Authenticate(callCtx(keyAuthorization, bearer), nil, info(pb.Shortener_DecodeURL_FullMethodName), func(ctx context.Context, req any) (any, error) { return nil, nil })
+	suite.NoError(err)
+
+	// вызовы без токена не изменяются
+	anonymous := uuid.New().String()
+	_, err = gs.Authenticate(callCtx(keyUserID, anonymous), nil, info(pb.Shortener_EncodeURL_FullMethodName), handler)
+	suite.Require().NoError(err)
+	suite.Equal(anonymous, gotUser)
+
+	// неизвестный, отозванный токен и токены без настройки
+	_, err = gs.Authenticate(callCtx(keyAuthorization, "Bearer "+apitoken.Prefix+"unknown"), nil, info(pb.Shortener_UserURLs_FullMethodName), handler)
+	suite.Equal(codes.Unauthenticated, status.Code(err))
+	_, err = NewGRPCServer(store, slog.Default()).Authenticate(callCtx(keyAuthorization, bearer), nil, info(pb.Shortener_UserURLs_FullMethodName), handler)
+	suite.Equal(codes.Unauthenticated, status.Code(err))
+	suite.Require().NoError(tokens.Revoke(ctx, owner, token.ID))
+	_, err = gs.Authenticate(callCtx(keyAuthorization, bearer), nil, info(pb.Shortener_UserURLs_FullMethodName), handler)
+	suite.Equal(codes.Unauthenticated, status.Code(err))
+}
+
 func TestAppSuite(t *testing.T) {
 	suite.Run(t, new(GRPCSuite))
 }
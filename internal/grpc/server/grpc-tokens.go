@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/apitoken"
+	pb "github.com/kTowkA/shortener/internal/grpc/proto"
+	"github.com/kTowkA/shortener/internal/model"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+// keyAuthorization ключ метаданных с API токеном в виде "Bearer <токен>"
+const keyAuthorization = "authorization"
+
+// methodScopes права API токена, необходимые для вызова методов. пустое право - метод доступен любому токену,
+// методы не из списка (веб-хуки, сервис Admin) по API токену недоступны. Stats, кроме права,
+// проверяет доверенную подсеть клиента
+var methodScopes = map[string]model.Scope{
+	pb.Shortener_EncodeURL_FullMethodName:      model.ScopeLinksWrite,
+	pb.Shortener_Batch_FullMethodName:          model.ScopeLinksWrite,
+	pb.Shortener_UserURLs_FullMethodName:       model.ScopeLinksRead,
+	pb.Shortener_DeleteUserURLs_FullMethodName: model.ScopeLinksDelete,
+	pb.Shortener_Deletion_FullMethodName:       model.ScopeLinksDelete,
+	pb.Shortener_Stats_FullMethodName:          model.ScopeStatsRead,
+	pb.Shortener_DecodeURL_FullMethodName:      "",
+	pb.Shortener_QRCode_FullMethodName:         "",
+	pb.Shortener_Ping_FullMethodName:           "",
+	healthpb.Health_Check_FullMethodName:       "",
+}
+
+// WithAPITokens включает аутентификацию по персональным API токенам
+func WithAPITokens(svc *apitoken.Service) Option {
+	return func(s *ShortenerServer) {
+		s.tokens = svc
+	}
+}
+
+// Authenticate перехватчик, аутентифицирующий вызовы с API токеном в метаданных authorization.
+// пользователем вызова становится владелец токена, а метод проверяется по правам токена.
+// вызовы без токена не изменяются
+func (s *ShortenerServer) Authenticate(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(keyAuthorization)
+	if len(values) == 0 {
+		return handler(ctx, req)
+	}
+	value, ok := apitoken.Bearer(values[0])
+	if !ok || s.tokens == nil {
+		return nil, apierror.New(apierror.CodeUnauthorized)
+	}
+	token, err := s.tokens.Authenticate(ctx, value)
+	if err != nil {
+		return nil, err
+	}
+	ctx = apitoken.WithToken(ctx, token)
+	scope, ok := methodScopes[info.FullMethod]
+	if !ok {
+		return nil, apitoken.Deny(ctx)
+	}
+	if scope != "" {
+		if err := apitoken.Check(ctx, scope); err != nil {
+			return nil, err
+		}
+	}
+	// пользователь вызова - владелец токена, а не переданный в метаданных userid
+	md = md.Copy()
+	md.Set(keyUserID, token.UserID.String())
+	return handler(metadata.NewIncomingContext(ctx, md), req)
+}
@@ -2,12 +2,10 @@ package server
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/google/uuid"
-	"google.golang.org/grpc/codes"
+	"github.com/kTowkA/shortener/internal/apierror"
 	"google.golang.org/grpc/metadata"
-	"google.golang.org/grpc/status"
 )
 
 const (
@@ -17,15 +15,15 @@ const (
 func userIDFromContext(ctx context.Context) (uuid.UUID, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return uuid.UUID{}, status.Error(codes.Unauthenticated, "ID пользователя не содержится в запросе")
+		return uuid.UUID{}, apierror.New(apierror.CodeUnauthorized)
 	}
 	values := md.Get(keyUserID)
 	if len(values) == 0 {
-		return uuid.UUID{}, status.Error(codes.Unauthenticated, "ID пользователя не содержится в запросе")
+		return uuid.UUID{}, apierror.New(apierror.CodeUnauthorized)
 	}
 	userID, err := uuid.Parse(values[0])
 	if err != nil {
-		return uuid.UUID{}, status.Error(codes.InvalidArgument, fmt.Sprintf("переданное значение \"%s\" не явялется валидным uuid", values[0]))
+		return uuid.UUID{}, apierror.New(apierror.CodeInvalidUserID).WithDetails(values[0])
 	}
 	return userID, nil
 }
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	pb "github.com/kTowkA/shortener/internal/grpc/proto"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/webhook"
+)
+
+// maxDeliveriesLimit наибольшее количество записей журнала доставок в ответе WebhookDeliveries
+const maxDeliveriesLimit = 1000
+
+// WithWebhooks устанавливает диспетчер подписок на события ссылок
+func WithWebhooks(d *webhook.Dispatcher) Option {
+	return func(s *ShortenerServer) {
+		s.webhooks = d
+	}
+}
+
+// webhookUser проверяет, что подписки доступны, и возвращает пользователя запроса
+func (s *ShortenerServer) webhookUser(ctx context.Context) (uuid.UUID, error) {
+	if s.webhooks == nil {
+		return uuid.UUID{}, apierror.New(apierror.CodeUnavailable)
+	}
+	userID, err := userIDFromContext(ctx)
+	if err != nil {
+		s.logger.Error("получение ID пользователя", slog.String("ошибка", err.Error()))
+		return uuid.UUID{}, apierror.From(err)
+	}
+	return userID, nil
+}
+
+// CreateWebhook реализация gRPC сервиса Shortener
+func (s *ShortenerServer) CreateWebhook(ctx context.Context, r *pb.CreateWebhookRequest) (*pb.CreateWebhookResponse, error) {
+	userID, err := s.webhookUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req := model.WebhookRequest{URL: r.Url, ClickThreshold: r.ClickThreshold}
+	for _, e := range r.Events {
+		req.Events = append(req.Events, model.EventType(e))
+	}
+	hook, err := s.webhooks.Subscribe(ctx, userID, req)
+	if err != nil {
+		s.logger.Error("создание подписки", slog.String("ошибка", err.Error()))
+		return nil, apierror.From(err)
+	}
+	return &pb.CreateWebhookResponse{Webhook: modelWebhookToWebhook(hook)}, nil
+}
+
+// ListWebhooks реализация gRPC сервиса Shortener
+func (s *ShortenerServer) ListWebhooks(ctx context.Context, r *pb.ListWebhooksRequest) (*pb.ListWebhooksResponse, error) {
+	userID, err := s.webhookUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hooks, err := s.webhooks.Webhooks(ctx, userID)
+	if err != nil {
+		s.logger.Error("получение подписок", slog.String("ошибка", err.Error()))
+		return nil, apierror.From(err)
+	}
+	resp := &pb.ListWebhooksResponse{Webhooks: make([]*pb.Webhook, 0, len(hooks))}
+	for _, hook := range hooks {
+		resp.Webhooks = append(resp.Webhooks, modelWebhookToWebhook(hook))
+	}
+	return resp, nil
+}
+
+// DeleteWebhook реализация gRPC сервиса Shortener
+func (s *ShortenerServer) DeleteWebhook(ctx context.Context, r *pb.DeleteWebhookRequest) (*pb.DeleteWebhookResponse, error) {
+	userID, err := s.webhookUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(r.Id)
+	if err != nil {
+		return nil, apierror.Wrap(apierror.CodeWebhookNotFound, err).WithDetails(r.Id)
+	}
+	if err = s.webhooks.Unsubscribe(ctx, userID, id); err != nil {
+		return nil, apierror.From(err)
+	}
+	return &pb.DeleteWebhookResponse{}, nil
+}
+
+// WebhookDeliveries реализация gRPC сервиса Shortener
+func (s *ShortenerServer) WebhookDeliveries(ctx context.Context, r *pb.WebhookDeliveriesRequest) (*pb.WebhookDeliveriesResponse, error) {
+	userID, err := s.webhookUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(r.WebhookId)
+	if err != nil {
+		return nil, apierror.Wrap(apierror.CodeWebhookNotFound, err).WithDetails(r.WebhookId)
+	}
+	status := model.DeliveryStatus(r.Status)
+	switch status {
+	case "", model.DeliveryPending, model.DeliveryDelivered, model.DeliveryDead:
+	default:
+		return nil, apierror.New(apierror.CodeBadRequest).WithDetails("status")
+	}
+	limit := maxDeliveriesLimit
+	if r.Limit > 0 {
+		limit = min(int(r.Limit), maxDeliveriesLimit)
+	}
+	deliveries, err := s.webhooks.Deliveries(ctx, userID, id, status, limit)
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	resp := &pb.WebhookDeliveriesResponse{Deliveries: make([]*pb.WebhookDelivery, 0, len(deliveries))}
+	for _, d := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, modelDeliveryToWebhookDelivery(d))
+	}
+	return resp, nil
+}
+
+// RedeliverWebhook реализация gRPC сервиса Shortener
+func (s *ShortenerServer) RedeliverWebhook(ctx context.Context, r *pb.RedeliverWebhookRequest) (*pb.RedeliverWebhookResponse, error) {
+	userID, err := s.webhookUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(r.WebhookId)
+	if err != nil {
+		return nil, apierror.Wrap(apierror.CodeWebhookNotFound, err).WithDetails(r.WebhookId)
+	}
+	deliveryID, err := uuid.Parse(r.DeliveryId)
+	if err != nil {
+		return nil, apierror.Wrap(apierror.CodeDeliveryNotFound, err).WithDetails(r.DeliveryId)
+	}
+	delivery, err := s.webhooks.Redeliver(ctx, userID, id, deliveryID)
+	if err != nil {
+		return nil, apierror.From(err)
+	}
+	return &pb.RedeliverWebhookResponse{Delivery: modelDeliveryToWebhookDelivery(delivery)}, nil
+}
+
+func modelWebhookToWebhook(hook model.Webhook) *pb.Webhook {
+	events := make([]string, 0, len(hook.Events))
+	for _, e := range hook.Events {
+		events = append(events, string(e))
+	}
+	return &pb.Webhook{
+		Id:             hook.ID.String(),
+		Url:            hook.URL,
+		Events:         events,
+		ClickThreshold: hook.ClickThreshold,
+		Secret:         hook.Secret,
+		CreatedAt:      hook.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func modelDeliveryToWebhookDelivery(d model.WebhookDelivery) *pb.WebhookDelivery {
+	return &pb.WebhookDelivery{
+		Id:             d.ID.String(),
+		WebhookId:      d.WebhookID.String(),
+		EventId:        d.Event.ID.String(),
+		EventType:      string(d.Event.Type),
+		ShortUrl:       d.Event.ShortURL,
+		Clicks:         d.Event.Clicks,
+		Status:         string(d.Status),
+		Attempts:       int32(d.Attempts),
+		NextAttemptAt:  d.NextAttemptAt.Format(time.RFC3339),
+		ResponseStatus: int32(d.ResponseStatus),
+		Error:          d.Error,
+		CreatedAt:      d.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      d.UpdatedAt.Format(time.RFC3339),
+	}
+}
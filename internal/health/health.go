@@ -0,0 +1,175 @@
+// пакет health реализует проверки живости и готовности сервиса. живость означает только то, что процесс
+// отвечает на запросы. готовность - что доступны все зависимости (хранилище, миграции, очередь удаления,
+// gRPC-сервер) и на сервис можно направлять трафик. при недоступности зависимости сервис остается живым,
+// и оркестратор перестает направлять на него запросы, не перезапуская его
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+const (
+	// defaultTimeout время выполнения одной проверки по умолчанию
+	defaultTimeout = 2 * time.Second
+	// defaultInterval периодичность проверок в Watch по умолчанию
+	defaultInterval = 5 * time.Second
+)
+
+// имена проверок готовности
+const (
+	CheckStorage       = "storage"
+	CheckMigrations    = "migrations"
+	CheckDeletionQueue = "deletion_queue"
+	CheckGRPC          = "grpc"
+)
+
+// Check проверка готовности зависимости. nil - зависимость доступна
+type Check func(ctx context.Context) error
+
+// Options настройки проверок
+type Options struct {
+	// Timeout время выполнения одной проверки, по истечении которого она не пройдена
+	Timeout time.Duration
+	// Interval периодичность проверок в Watch
+	Interval time.Duration
+}
+
+// Checker набор именованных проверок готовности
+type Checker struct {
+	opts   Options
+	mu     sync.RWMutex
+	checks map[string]Check
+}
+
+// New создает новый экземпляр Checker без проверок.
+// Незаполненные настройки заменяются значениями по умолчанию
+func New(opts Options) *Checker {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	return &Checker{
+		opts:   opts,
+		checks: make(map[string]Check),
+	}
+}
+
+// Add добавляет проверку name. проверка с тем же именем заменяется
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// Live состояние живости: процесс отвечает, зависимости не проверяются
+func Live() model.HealthResponse {
+	return model.HealthResponse{Status: model.HealthOK}
+}
+
+// Ready выполняет все проверки одновременно и возвращает результат по каждой в порядке имен
+func (c *Checker) Ready(ctx context.Context) model.HealthResponse {
+	c.mu.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.RUnlock()
+
+	resp := model.HealthResponse{
+		Status: model.HealthOK,
+		Checks: make([]model.HealthCheck, 0, len(checks)),
+	}
+	results := make(chan model.HealthCheck, len(checks))
+	for name, check := range checks {
+		go func(name string, check Check) {
+			results <- c.run(ctx, name, check)
+		}(name, check)
+	}
+	for range checks {
+		result := <-results
+		if result.Status != model.HealthOK {
+			resp.Status = model.HealthFail
+		}
+		resp.Checks = append(resp.Checks, result)
+	}
+	sort.Slice(resp.Checks, func(i, j int) bool { return resp.Checks[i].Name < resp.Checks[j].Name })
+	return resp
+}
+
+// Watch выполняет проверки сразу и затем с периодичностью Options.Interval до отмены ctx,
+// передавая каждый результат в report
+func (c *Checker) Watch(ctx context.Context, report func(model.HealthResponse)) {
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		report(c.Ready(ctx))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run выполняет проверку check с ограничением времени
+func (c *Checker) run(ctx context.Context, name string, check Check) model.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	// проверка, не учитывающая контекст, не задерживает ответ дольше Options.Timeout
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result := model.HealthCheck{
+		Name:       name,
+		Status:     model.HealthOK,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Status = model.HealthFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Flag признак готовности, который выставляет сам компонент (например, сервер после начала прослушивания порта)
+type Flag struct {
+	up  atomic.Bool
+	err error
+}
+
+// NewFlag создает новый экземпляр Flag в состоянии "не готов". reason - ошибка проверки в этом состоянии
+func NewFlag(reason string) *Flag {
+	return &Flag{err: errors.New(reason)}
+}
+
+// Set выставляет состояние готовности
+func (f *Flag) Set(up bool) {
+	f.up.Store(up)
+}
+
+// Check проверка готовности по состоянию флага
+func (f *Flag) Check(ctx context.Context) error {
+	if !f.up.Load() {
+		return f.err
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReady(t *testing.T) {
+	c := New(Options{Timeout: 50 * time.Millisecond})
+	resp := c.Ready(context.Background())
+	assert.Equal(t, model.HealthOK, resp.Status, "без проверок сервис готов")
+	assert.Empty(t, resp.Checks)
+
+	flag := NewFlag("не запущен")
+	c.Add("b", func(ctx context.Context) error { return nil })
+	c.Add("a", flag.Check)
+	// проверка, не учитывающая контекст
+	c.Add("c", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	resp = c.Ready(context.Background())
+	assert.Equal(t, model.HealthFail, resp.Status)
+	require.Len(t, resp.Checks, 3)
+	assert.Equal(t, "a", resp.Checks[0].Name)
+	assert.Equal(t, model.HealthFail, resp.Checks[0].Status)
+	assert.Equal(t, "не запущен", resp.Checks[0].Error)
+	assert.Equal(t, model.HealthCheck{Name: "b", Status: model.HealthOK, DurationMS: resp.Checks[1].DurationMS}, resp.Checks[1])
+	assert.Equal(t, model.HealthFail, resp.Checks[2].Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), resp.Checks[2].Error)
+
+	flag.Set(true)
+	c.Add("c", func(ctx context.Context) error { return nil })
+	resp = c.Ready(context.Background())
+	assert.Equal(t, model.HealthOK, resp.Status)
+	for _, check := range resp.Checks {
+		assert.Equal(t, model.HealthOK, check.Status, check.Name)
+	}
+
+	c.Add("b", func(ctx context.Context) error { return errors.New("нет подключения") })
+	resp = c.Ready(context.Background())
+	assert.Equal(t, model.HealthFail, resp.Status)
+	assert.Equal(t, "нет подключения", resp.Checks[1].Error)
+}
+
+func TestWatch(t *testing.T) {
+	c := New(Options{Interval: 10 * time.Millisecond})
+	flag := NewFlag("не запущен")
+	c.Add(CheckGRPC, flag.Check)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reports := make(chan model.HealthStatus, 100)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Watch(ctx, func(resp model.HealthResponse) {
+			reports <- resp.Status
+		})
+	}()
+	assert.Equal(t, model.HealthFail, <-reports, "первая проверка выполняется сразу")
+	flag.Set(true)
+	require.Eventually(t, func() bool {
+		return <-reports == model.HealthOK
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+}
@@ -0,0 +1,99 @@
+// пакет i18n содержит каталог сообщений, которые видят клиенты сервиса, и выбор языка по запросу клиента
+package i18n
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lang язык сообщений (базовый тег BCP 47)
+type Lang string
+
+const (
+	RU Lang = "ru"
+	EN Lang = "en"
+)
+
+// Default язык, используемый если клиент не указал поддерживаемый язык
+const Default = RU
+
+// Supported поддерживаемые языки
+var Supported = []Lang{RU, EN}
+
+// Parse выбирает поддерживаемый язык по значению заголовка Accept-Language (или метаданных gRPC accept-language).
+// учитываются веса q, региональные варианты сводятся к базовому языку (en-US -> en)
+func Parse(acceptLanguage string) Lang {
+	type candidate struct {
+		lang Lang
+		q    float64
+	}
+	candidates := make([]candidate, 0)
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		lang := Lang(base)
+		if base == "*" {
+			lang = Default
+		}
+		if !lang.supported() {
+			continue
+		}
+		candidates = append(candidates, candidate{lang: lang, q: q})
+	}
+	if len(candidates) == 0 {
+		return Default
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// supported проверяет что язык поддерживается
+func (l Lang) supported() bool {
+	_, ok := catalogue[l]
+	return ok
+}
+
+// T возвращает сообщение key на языке lang. Если перевода нет - сообщение на языке по умолчанию, если нет и его - сам ключ
+func T(lang Lang, key string) string {
+	if msg, ok := catalogue[lang][key]; ok {
+		return msg
+	}
+	if msg, ok := catalogue[Default][key]; ok {
+		return msg
+	}
+	return key
+}
+
+// Has проверяет что сообщение key есть в каталоге
+func Has(key string) bool {
+	_, ok := catalogue[Default][key]
+	return ok
+}
+
+type ctxKey struct{}
+
+// WithLang сохраняет язык клиента в контексте
+func WithLang(ctx context.Context, lang Lang) context.Context {
+	return context.WithValue(ctx, ctxKey{}, lang)
+}
+
+// FromContext возвращает язык клиента из контекста или язык по умолчанию
+func FromContext(ctx context.Context) Lang {
+	if lang, ok := ctx.Value(ctxKey{}).(Lang); ok {
+		return lang
+	}
+	return Default
+}
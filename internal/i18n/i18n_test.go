@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   Lang
+	}{
+		{name: "пустой заголовок", header: "", want: Default},
+		{name: "английский", header: "en", want: EN},
+		{name: "региональный вариант", header: "en-US", want: EN},
+		{name: "веса", header: "ru;q=0.5, en-GB;q=0.8", want: EN},
+		{name: "первый при равных весах", header: "ru, en", want: RU},
+		{name: "неподдерживаемые пропускаются", header: "de, fr;q=0.9, en;q=0.1", want: EN},
+		{name: "нулевой вес", header: "en;q=0, ru;q=0.1", want: RU},
+		{name: "любой язык", header: "*", want: Default},
+		{name: "ошибка в весе", header: "en;q=abc", want: Default},
+		{name: "только неподдерживаемые", header: "de-DE", want: Default},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Parse(tt.header), tt.name)
+	}
+}
+
+func TestT(t *testing.T) {
+	assert.Equal(t, "URL not found", T(EN, "url_not_found"))
+	assert.Equal(t, "ссылка не найдена", T(RU, "url_not_found"))
+	assert.Equal(t, "ссылка не найдена", T("de", "url_not_found"), "неизвестный язык - язык по умолчанию")
+	assert.Equal(t, "unknown.key", T(EN, "unknown.key"))
+	assert.False(t, Has("unknown.key"))
+}
+
+// TestCatalogue все сообщения переведены на все поддерживаемые языки
+func TestCatalogue(t *testing.T) {
+	for _, lang := range Supported {
+		for key := range catalogue[Default] {
+			assert.NotEmpty(t, catalogue[lang][key], "%s: нет перевода %s", lang, key)
+		}
+		assert.Len(t, catalogue[lang], len(catalogue[Default]), "%s: лишние ключи", lang)
+	}
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, Default, FromContext(ctx))
+	assert.Equal(t, EN, FromContext(WithLang(ctx, EN)))
+}
@@ -0,0 +1,125 @@
+package i18n
+
+// catalogue сообщения по языкам. Ключи ошибок совпадают с кодами apierror, ключи страниц начинаются с page.
+var catalogue = map[Lang]map[string]string{
+	RU: {
+		// ошибки
+		"bad_request":            "некорректный запрос",
+		"empty_request":          "пустой запрос",
+		"empty_batch":            "передали пустой batch",
+		"invalid_url":            "невалидная ссылка",
+		"invalid_content_type":   "недопустимый тип контента",
+		"unsupported_media_type": "тип контента не поддерживается",
+		"invalid_link_options":   "недопустимые настройки ссылки",
+		"invalid_qr_options":     "недопустимые параметры QR кода",
+		"invalid_user_id":        "ID пользователя не является валидным uuid",
+		"unauthorized":           "пользователь не авторизован",
+		"forbidden":              "доступ запрещен",
+		"threat_url":             "ссылка ведет на ресурс из списка угроз",
+		"url_blocked":            "ссылка заблокирована",
+		"url_not_found":          "ссылка не найдена",
+		"url_deleted":            "ссылка удалена",
+		"url_conflict":           "ссылка уже была сокращена",
+		"url_exists":             "короткая ссылка уже существует",
+		"job_not_found":          "задание не найдено",
+		"bad_idempotency_key":    "ключ идемпотентности должен содержать от 1 до 255 печатных символов ASCII",
+		"idempotency_key_reused": "ключ идемпотентности уже использован для другого запроса",
+		"idempotency_no_user":    "ключ идемпотентности принимается только в запросах с cookie пользователя или API токеном",
+		"rate_limited":           "слишком много запросов, повторите позже",
+		"quota_exceeded":         "исчерпана суточная квота создания ссылок",
+		"deletion_not_found":     "задача удаления не найдена",
+		"delete_queue_full":      "очередь удаления переполнена, повторите позже",
+		"invalid_webhook":        "некорректная подписка на события",
+		"webhook_not_found":      "подписка на события не найдена",
+		"delivery_not_found":     "доставка события не найдена",
+		"user_blocked":           "пользователю запрещено создавать ссылки",
+		"invalid_account":        "некорректный логин или пароль для учетной записи",
+		"account_exists":         "логин уже занят",
+		"invalid_credentials":    "неверный логин или пароль",
+		"invalid_api_token":      "некорректный запрос на создание API токена",
+		"api_token_not_found":    "API токен не найден",
+		"insufficient_scope":     "API токен не дает права на эту операцию",
+		"sso_failed":             "не удалось войти через провайдера входа",
+		"unavailable":            "сервис временно недоступен",
+		"internal":               "внутренняя ошибка сервиса",
+
+		// страница предупреждения
+		"page.warning.title":       "Опасная ссылка",
+		"page.warning.header":      "Переход по ссылке заблокирован",
+		"page.warning.reason":      "Ссылка ведет на ресурс, который находится в списке угроз (фишинг или вредоносное ПО).",
+		"page.warning.destination": "Адрес назначения:",
+
+		// страница предпросмотра
+		"page.preview.title":     "Предпросмотр ссылки",
+		"page.preview.short":     "Короткая ссылка",
+		"page.preview.leads_to":  "ведет на:",
+		"page.preview.attention": "Внимание:",
+		"page.preview.broken":    "при последней проверке ресурс был недоступен.",
+		"page.preview.continue":  "Продолжить",
+
+		// страница документации
+		"page.docs.parameters":  "Параметры",
+		"page.docs.body":        "Тело запроса",
+		"page.docs.responses":   "Ответы",
+		"page.docs.schemas":     "Схемы",
+		"page.docs.load_failed": "Не удалось загрузить спецификацию:",
+	},
+	EN: {
+		"bad_request":            "bad request",
+		"empty_request":          "empty request",
+		"empty_batch":            "batch is empty",
+		"invalid_url":            "invalid URL",
+		"invalid_content_type":   "invalid content type",
+		"unsupported_media_type": "unsupported content type",
+		"invalid_link_options":   "invalid link options",
+		"invalid_qr_options":     "invalid QR code options",
+		"invalid_user_id":        "user ID is not a valid uuid",
+		"unauthorized":           "user is not authorized",
+		"forbidden":              "access denied",
+		"threat_url":             "URL points to a resource from the threat list",
+		"url_blocked":            "URL is blocked",
+		"url_not_found":          "URL not found",
+		"url_deleted":            "URL has been deleted",
+		"url_conflict":           "URL has already been shortened",
+		"url_exists":             "short URL already exists",
+		"job_not_found":          "job not found",
+		"bad_idempotency_key":    "idempotency key must contain 1 to 255 printable ASCII characters",
+		"idempotency_key_reused": "idempotency key has already been used for a different request",
+		"idempotency_no_user":    "idempotency key requires a user cookie or an API token",
+		"rate_limited":           "too many requests, try again later",
+		"quota_exceeded":         "daily link creation quota exceeded",
+		"deletion_not_found":     "deletion task not found",
+		"delete_queue_full":      "deletion queue is full, try again later",
+		"invalid_webhook":        "invalid webhook subscription",
+		"webhook_not_found":      "webhook not found",
+		"delivery_not_found":     "webhook delivery not found",
+		"user_blocked":           "user is not allowed to create links",
+		"invalid_account":        "invalid account login or password",
+		"account_exists":         "login is already taken",
+		"invalid_credentials":    "invalid login or password",
+		"invalid_api_token":      "invalid API token request",
+		"api_token_not_found":    "API token not found",
+		"insufficient_scope":     "API token does not grant this operation",
+		"sso_failed":             "single sign-on failed",
+		"unavailable":            "service is temporarily unavailable",
+		"internal":               "internal service error",
+
+		"page.warning.title":       "Dangerous link",
+		"page.warning.header":      "Redirect blocked",
+		"page.warning.reason":      "The link points to a resource from the threat list (phishing or malware).",
+		"page.warning.destination": "Destination:",
+
+		"page.preview.title":     "Link preview",
+		"page.preview.short":     "Short link",
+		"page.preview.leads_to":  "leads to:",
+		"page.preview.attention": "Warning:",
+		"page.preview.broken":    "the resource was unavailable during the last check.",
+		"page.preview.continue":  "Continue",
+
+		"page.docs.parameters":  "Parameters",
+		"page.docs.body":        "Request body",
+		"page.docs.responses":   "Responses",
+		"page.docs.schemas":     "Schemas",
+		"page.docs.load_failed": "Failed to load the specification:",
+	},
+}
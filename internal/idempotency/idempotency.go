@@ -0,0 +1,146 @@
+// пакет idempotency реализует повтор ответов на запросы создания с ключом идемпотентности:
+// первый ответ на запрос с ключом сохраняется в хранилище на заданное время, повторный запрос с тем же ключом
+// получает сохраненный ответ без повторного выполнения. ключи уникальны в пределах пользователя
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+const (
+	// Header заголовок HTTP запроса с ключом идемпотентности
+	Header = "Idempotency-Key"
+	// ReplayedHeader заголовок, которым отмечается повторенный ответ
+	ReplayedHeader = "Idempotent-Replayed"
+	// MetadataKey ключ метаданных gRPC с ключом идемпотентности
+	MetadataKey = "idempotency-key"
+	// MaxKeyLength максимальная длина ключа
+	MaxKeyLength = 255
+)
+
+// ValidKey проверяет, что ключ содержит от 1 до MaxKeyLength печатных символов ASCII
+func ValidKey(key string) bool {
+	if key == "" || len(key) > MaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Fingerprint отпечаток запроса из его частей (метод, путь, тело и т.п.)
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	size := make([]byte, 8)
+	for _, p := range parts {
+		// длина части исключает совпадение отпечатков при разном делении на части
+		binary.BigEndian.PutUint64(size, uint64(len(p)))
+		h.Write(size)
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Guard выполняет запросы с ключами идемпотентности
+type Guard struct {
+	store  storage.Storager
+	window time.Duration
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	locks map[lockKey]*keyLock
+}
+
+type lockKey struct {
+	userID uuid.UUID
+	key    string
+}
+
+// keyLock блокировка ключа с количеством ожидающих ее запросов
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// New создает новый экземпляр Guard, хранящий ответы в store в течение window
+func New(store storage.Storager, window time.Duration, logger *slog.Logger) *Guard {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Guard{
+		store:  store,
+		window: window,
+		logger: logger,
+		locks:  make(map[lockKey]*keyLock),
+	}
+}
+
+// Do возвращает ответ, сохраненный для ключа key пользователя userID, либо выполняет запрос run и сохраняет его ответ,
+// если run разрешает сохранение. replayed - ответ взят из хранилища. Запросы с одинаковым ключом выполняются по очереди,
+// поэтому одновременный повтор дожидается первого запроса и получает его ответ.
+// Если ключ уже использован для запроса с другим отпечатком fingerprint - ошибка с кодом idempotency_key_reused
+func (g *Guard) Do(ctx context.Context, userID uuid.UUID, key, fingerprint string, run func() (model.IdempotentResponse, bool)) (resp model.IdempotentResponse, replayed bool, err error) {
+	unlock := g.lock(lockKey{userID: userID, key: key})
+	defer unlock()
+
+	stored, err := g.store.IdempotentResponse(ctx, userID, key)
+	switch {
+	case err == nil && stored.Fingerprint != fingerprint:
+		return model.IdempotentResponse{}, false, apierror.New(apierror.CodeIdemKeyReused).WithDetails(key)
+	case err == nil:
+		return stored, true, nil
+	case !errors.Is(err, storage.ErrKeyNotFound):
+		return model.IdempotentResponse{}, false, fmt.Errorf("получение ответа по ключу идемпотентности. %w", err)
+	}
+
+	resp, save := run()
+	if !save {
+		return resp, false, nil
+	}
+	resp.Fingerprint = fingerprint
+	resp.ExpiresAt = time.Now().Add(g.window)
+	// ответ уже получен - ошибка сохранения не должна его потерять
+	if err = g.store.SaveIdempotentResponse(context.WithoutCancel(ctx), userID, key, resp); err != nil {
+		g.logger.Error("сохранение ответа по ключу идемпотентности", slog.String("ошибка", err.Error()))
+	}
+	return resp, false, nil
+}
+
+// lock блокирует ключ k и возвращает функцию снятия блокировки
+func (g *Guard) lock(k lockKey) func() {
+	g.mu.Lock()
+	l, ok := g.locks[k]
+	if !ok {
+		l = &keyLock{}
+		g.locks[k] = l
+	}
+	l.refs++
+	g.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		g.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(g.locks, k)
+		}
+		g.mu.Unlock()
+	}
+}
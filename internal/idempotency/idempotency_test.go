@@ -0,0 +1,98 @@
+package idempotency
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidKey(t *testing.T) {
+	assert.True(t, ValidKey("7d0c3b0e-4f2a-4a43-9c1e-2b7a5f0d9e11"))
+	assert.True(t, ValidKey(strings.Repeat("a", MaxKeyLength)))
+	assert.False(t, ValidKey(""))
+	assert.False(t, ValidKey(strings.Repeat("a", MaxKeyLength+1)))
+	assert.False(t, ValidKey("ключ"))
+	assert.False(t, ValidKey("a\nb"))
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, Fingerprint([]byte("POST"), []byte("/")), Fingerprint([]byte("POST"), []byte("/")))
+	assert.NotEqual(t, Fingerprint([]byte("ab"), []byte("c")), Fingerprint([]byte("a"), []byte("bc")))
+}
+
+func TestGuard(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	g := New(store, time.Hour, nil)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	calls := atomic.Int32{}
+	run := func() (model.IdempotentResponse, bool) {
+		calls.Add(1)
+		// пока выполняется первый запрос, повторы ждут
+		time.Sleep(10 * time.Millisecond)
+		return model.IdempotentResponse{Status: 201, Body: []byte("short")}, true
+	}
+
+	// одновременные повторы получают ответ первого запроса
+	wg := sync.WaitGroup{}
+	replays := atomic.Int32{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, replayed, err := g.Do(ctx, userID, "key", "fp", run)
+			assert.NoError(t, err)
+			assert.Equal(t, 201, resp.Status)
+			assert.Equal(t, "short", string(resp.Body))
+			if replayed {
+				replays.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.EqualValues(t, 1, calls.Load())
+	assert.EqualValues(t, 4, replays.Load())
+	assert.Empty(t, g.locks, "блокировки освобождены")
+
+	// ключ использован для другого запроса
+	_, _, err = g.Do(ctx, userID, "key", "other", run)
+	assert.Equal(t, apierror.CodeIdemKeyReused, apierror.CodeOf(err))
+
+	// ключи других пользователей независимы
+	_, replayed, err := g.Do(ctx, uuid.New(), "key", "other", run)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+
+	// ответ, который нельзя сохранять, не повторяется
+	noSave := func() (model.IdempotentResponse, bool) {
+		calls.Add(1)
+		return model.IdempotentResponse{Status: 500}, false
+	}
+	before := calls.Load()
+	_, _, err = g.Do(ctx, userID, "fail", "fp", noSave)
+	require.NoError(t, err)
+	_, replayed, err = g.Do(ctx, userID, "fail", "fp", noSave)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+	assert.EqualValues(t, before+2, calls.Load())
+
+	// по истечении срока хранения ключ можно использовать заново
+	expired := New(store, -time.Second, nil)
+	_, _, err = expired.Do(ctx, userID, "expired", "fp", run)
+	require.NoError(t, err)
+	_, replayed, err = expired.Do(ctx, userID, "expired", "other", run)
+	require.NoError(t, err)
+	assert.False(t, replayed)
+}
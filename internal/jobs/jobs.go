@@ -0,0 +1,217 @@
+// пакет jobs реализует фоновые задания массового сокращения ссылок: задание сохраняется в хранилище,
+// обрабатывается пулом обработчиков частями через bulk.Shortener, результаты каждой части сохраняются сразу,
+// поэтому прерванное остановкой сервиса задание продолжается после перезапуска с первого необработанного элемента
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/bulk"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+const (
+	defaultWorkers      = 2
+	defaultChunkSize    = 100
+	defaultPollInterval = 5 * time.Second
+	defaultRetention    = 24 * time.Hour
+	// maxPurgeInterval наибольшая периодичность удаления завершенных заданий
+	maxPurgeInterval = time.Hour
+)
+
+// Options настройки обработки заданий
+type Options struct {
+	// Workers количество одновременно обрабатываемых заданий
+	Workers int
+	// ChunkSize количество элементов, результаты которых сохраняются за одно обращение к хранилищу
+	ChunkSize int
+	// PollInterval периодичность проверки очереди свободными обработчиками.
+	// новые задания этого экземпляра сервиса начинают обрабатываться сразу
+	PollInterval time.Duration
+	// Retention время хранения завершенного задания вместе с результатами, после него задание удаляется
+	Retention time.Duration
+	// Logger логгер ошибок обработки. если не задан - slog.Default()
+	Logger *slog.Logger
+}
+
+// Manager очередь заданий массового сокращения ссылок
+type Manager struct {
+	opts      Options
+	shortener bulk.Shortener
+	wake      chan struct{}
+}
+
+// New создает новый экземпляр Manager, сохраняющий ссылки через shortener.
+// Незаполненные настройки заменяются значениями по умолчанию
+func New(shortener bulk.Shortener, opts Options) *Manager {
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = defaultChunkSize
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	shortener.ChunkSize = opts.ChunkSize
+	return &Manager{
+		opts:      opts,
+		shortener: shortener,
+		wake:      make(chan struct{}, opts.Workers),
+	}
+}
+
+// Submit сохраняет задание сокращения ссылок batch пользователя userID и ставит его в очередь
+func (m *Manager) Submit(ctx context.Context, userID uuid.UUID, batch model.BatchRequest) (model.Job, error) {
+	now := time.Now().UTC()
+	job := model.Job{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Status:    model.JobQueued,
+		Total:     len(batch),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Request:   batch,
+	}
+	if err := m.shortener.Store.CreateJob(ctx, job); err != nil {
+		return model.Job{}, fmt.Errorf("создание задания. %w", err)
+	}
+	// будим свободный обработчик. если все заняты - задание возьмет первый освободившийся
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Run возвращает в очередь задания, прерванные предыдущей остановкой сервиса, и обрабатывает очередь до отмены ctx.
+// завершенные задания удаляются по истечении времени хранения
+func (m *Manager) Run(ctx context.Context) {
+	count, err := m.shortener.Store.RequeueJobs(ctx)
+	if err != nil {
+		m.opts.Logger.Error("возврат прерванных заданий в очередь", slog.String("ошибка", err.Error()))
+	}
+	if count > 0 {
+		m.opts.Logger.Info("прерванные задания возвращены в очередь", slog.Int("количество", count))
+	}
+
+	done := make(chan struct{})
+	for i := 0; i < m.opts.Workers; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			m.work(ctx)
+		}()
+	}
+	go func() {
+		defer func() { done <- struct{}{} }()
+		m.purge(ctx)
+	}()
+	for i := 0; i <= m.opts.Workers; i++ {
+		<-done
+	}
+}
+
+// purge периодически удаляет завершенные задания старше времени хранения
+func (m *Manager) purge(ctx context.Context) {
+	ticker := time.NewTicker(min(m.opts.Retention, maxPurgeInterval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		count, err := m.shortener.Store.PurgeJobs(ctx, time.Now().Add(-m.opts.Retention))
+		if err != nil {
+			if ctx.Err() == nil {
+				m.opts.Logger.Error("удаление завершенных заданий", slog.String("ошибка", err.Error()))
+			}
+			continue
+		}
+		if count > 0 {
+			m.opts.Logger.Debug("удалены завершенные задания", slog.Int("количество", count))
+		}
+	}
+}
+
+// work обработчик: берет задания из очереди, пока они есть, затем ждет нового задания
+func (m *Manager) work(ctx context.Context) {
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := m.shortener.Store.ClaimJob(ctx)
+		if err == nil {
+			m.process(ctx, job)
+			continue
+		}
+		if !errors.Is(err, storage.ErrJobNotFound) && ctx.Err() == nil {
+			m.opts.Logger.Error("получение задания из очереди", slog.String("ошибка", err.Error()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// process обрабатывает необработанные элементы задания job
+func (m *Manager) process(ctx context.Context, job model.Job) {
+	offset := min(len(job.Results), len(job.Request))
+	w := &progressWriter{ctx: ctx, store: m.shortener.Store, id: job.ID}
+	_, err := m.shortener.Run(ctx, job.UserID, bulk.NewBatchReader(job.Request[offset:]), w)
+	if ctx.Err() != nil {
+		// задание остается в обработке и будет возвращено в очередь при следующем запуске
+		return
+	}
+
+	progress := model.JobProgress{Status: model.JobDone}
+	if err != nil {
+		progress = model.JobProgress{Status: model.JobFailed, Error: string(apierror.From(err).Code)}
+		m.opts.Logger.Error("обработка задания", slog.String("задание", job.ID.String()), slog.String("ошибка", err.Error()))
+	}
+	if err = m.shortener.Store.UpdateJob(ctx, job.ID, progress); err != nil {
+		m.opts.Logger.Error("завершение задания", slog.String("задание", job.ID.String()), slog.String("ошибка", err.Error()))
+	}
+}
+
+// progressWriter bulk.Writer, сохраняющий результаты каждой части в задание
+type progressWriter struct {
+	ctx     context.Context
+	store   storage.Storager
+	id      uuid.UUID
+	results model.BatchResponse
+}
+
+// Write реализация bulk.Writer
+func (pw *progressWriter) Write(res model.BulkResult) error {
+	pw.results = append(pw.results, res.BatchResponseElement())
+	return nil
+}
+
+// Flush реализация bulk.Writer
+func (pw *progressWriter) Flush() error {
+	if len(pw.results) == 0 {
+		return nil
+	}
+	err := pw.store.UpdateJob(pw.ctx, pw.id, model.JobProgress{Status: model.JobRunning, Results: pw.results})
+	if err != nil {
+		return fmt.Errorf("сохранение результатов задания. %w", err)
+	}
+	pw.results = nil
+	return nil
+}
@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/bulk"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitJob ожидает завершения обработки задания id
+func waitJob(t *testing.T, store *memory.Storage, id uuid.UUID) model.Job {
+	t.Helper()
+	var job model.Job
+	require.Eventually(t, func() bool {
+		var err error
+		job, err = store.Job(context.Background(), id)
+		require.NoError(t, err)
+		return job.Status.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestManager(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	m := New(bulk.Shortener{Store: store, BaseAddress: "http://localhost/"}, Options{Workers: 2, ChunkSize: 2, PollInterval: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	userID := uuid.New()
+	batch := model.BatchRequest{
+		{CorrelationID: "1", OriginalURL: "https://go.dev"},
+		{CorrelationID: "2", OriginalURL: "go.dev"},
+		{CorrelationID: "3", OriginalURL: "https://go.dev"},
+	}
+	for i := 0; i < 5; i++ {
+		batch = append(batch, model.BatchRequestElement{OriginalURL: fmt.Sprintf("https://go.dev/%d", i)})
+	}
+	job, err := m.Submit(ctx, userID, batch)
+	require.NoError(t, err)
+	assert.Equal(t, model.JobQueued, job.Status)
+	assert.Equal(t, len(batch), job.Total)
+
+	job = waitJob(t, store, job.ID)
+	assert.Equal(t, model.JobDone, job.Status)
+	assert.Equal(t, userID, job.UserID)
+	assert.Equal(t, len(batch), job.Processed)
+	assert.Equal(t, 6, job.Created)
+	assert.Equal(t, 1, job.Existing)
+	assert.Equal(t, 1, job.Invalid)
+	require.Len(t, job.Results, len(batch))
+	assert.Equal(t, "1", job.Results[0].CorrelationID, "результаты в порядке запроса")
+	assert.Equal(t, string(apierror.CodeInvalidURL), job.Results[1].Reason)
+	assert.Equal(t, job.Results[0].ShortURL, job.Results[2].ShortURL)
+
+	urls, err := store.UserURLs(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 6)
+}
+
+func TestManagerResume(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+
+	// задание прервано после обработки первого элемента
+	job := model.Job{
+		ID:      uuid.New(),
+		UserID:  uuid.New(),
+		Status:  model.JobRunning,
+		Total:   2,
+		Request: model.BatchRequest{{OriginalURL: "https://go.dev"}, {OriginalURL: "https://pkg.go.dev"}},
+	}
+	require.NoError(t, store.CreateJob(context.Background(), job))
+	require.NoError(t, store.UpdateJob(context.Background(), job.ID, model.JobProgress{Results: model.BatchResponse{
+		{ShortURL: "http://localhost/abc", Status: model.BatchStatusCreated},
+	}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go New(bulk.Shortener{Store: store}, Options{PollInterval: time.Hour}).Run(ctx)
+
+	job = waitJob(t, store, job.ID)
+	assert.Equal(t, model.JobDone, job.Status)
+	assert.Equal(t, 2, job.Processed)
+	assert.Equal(t, 2, job.Created)
+	require.Len(t, job.Results, 2)
+	assert.Equal(t, "http://localhost/abc", job.Results[0].ShortURL)
+
+	urls, err := store.UserURLs(ctx, job.UserID)
+	require.NoError(t, err)
+	require.Len(t, urls, 1, "обработанный элемент не сохраняется повторно")
+	assert.Equal(t, "https://pkg.go.dev", urls[0].OriginalURL)
+}
+
+func TestManagerRetention(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	m := New(bulk.Shortener{Store: store, BaseAddress: "http://localhost/"}, Options{PollInterval: time.Hour, Retention: 50 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		m.Run(ctx)
+		close(stopped)
+	}()
+	defer func() {
+		cancel()
+		<-stopped
+	}()
+
+	job, err := m.Submit(ctx, uuid.New(), model.BatchRequest{{OriginalURL: "https://go.dev"}})
+	require.NoError(t, err)
+	waitJob(t, store, job.ID)
+
+	// завершенное задание удаляется по истечении времени хранения
+	require.Eventually(t, func() bool {
+		_, err := store.Job(ctx, job.ID)
+		return errors.Is(err, storage.ErrJobNotFound)
+	}, 5*time.Second, 10*time.Millisecond)
+}
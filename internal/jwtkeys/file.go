@@ -0,0 +1,138 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// File содержимое файла ключей (JWT_KEYS). пример:
+//
+//	{
+//	  "active": "2024-06",
+//	  "keys": [
+//	    {"kid": "2024-06", "alg": "EdDSA", "private_key": "ed25519.pem"},
+//	    {"kid": "2024-01", "alg": "RS256", "public_key": "rsa.pub.pem"},
+//	    {"kid": "2023", "alg": "HS256", "secret": "старый общий ключ"}
+//	  ]
+//	}
+type File struct {
+	// Active идентификатор ключа подписи новых токенов, по умолчанию первый ключ
+	Active string    `json:"active"`
+	Keys   []FileKey `json:"keys"`
+}
+
+// FileKey ключ в файле. для HS256 задается Secret, для RS256 и EdDSA - путь к файлу PEM закрытого ключа
+// или, если ключ только проверяет выданные ранее токены, открытого. относительные пути - от каталога файла ключей
+type FileKey struct {
+	ID         string `json:"kid"`
+	Alg        string `json:"alg"`
+	Secret     string `json:"secret,omitempty"`
+	PrivateKey string `json:"private_key,omitempty"`
+	PublicKey  string `json:"public_key,omitempty"`
+}
+
+// Load создает набор ключей из файла filename. secret (SECRET_KEY) добавляется в набор ключом LegacyID,
+// чтобы принимались токены, выданные до появления файла. без файла secret - активный ключ
+func Load(filename, secret string) (*Set, error) {
+	legacy := HMAC(LegacyID, []byte(secret))
+	if filename == "" {
+		return New(legacy)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("чтение файла ключей. %w", err)
+	}
+	file := File{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("разбор файла ключей %s. %w", filename, err)
+	}
+	if len(file.Keys) == 0 {
+		return nil, fmt.Errorf("в файле ключей %s нет ключей", filename)
+	}
+	if file.Active == "" {
+		file.Active = file.Keys[0].ID
+	}
+	var (
+		active *Key
+		verify = make([]Key, 0, len(file.Keys))
+	)
+	for _, fk := range file.Keys {
+		key, err := fk.key(filepath.Dir(filename))
+		if err != nil {
+			return nil, fmt.Errorf("ключ %q. %w", fk.ID, err)
+		}
+		if key.id == file.Active {
+			active = &key
+			continue
+		}
+		verify = append(verify, key)
+	}
+	if active == nil {
+		return nil, fmt.Errorf("активный ключ %q не найден в файле ключей", file.Active)
+	}
+	if secret != "" {
+		verify = append(verify, legacy)
+	}
+	return New(*active, verify...)
+}
+
+// key ключ по описанию из файла. dir - каталог файла ключей
+func (fk FileKey) key(dir string) (Key, error) {
+	if fk.ID == LegacyID {
+		return Key{}, fmt.Errorf("не задан идентификатор ключа")
+	}
+	if fk.Alg == AlgHS256 {
+		if fk.Secret == "" {
+			return Key{}, fmt.Errorf("для %s нужен secret", fk.Alg)
+		}
+		return HMAC(fk.ID, []byte(fk.Secret)), nil
+	}
+	if fk.Alg != AlgRS256 && fk.Alg != AlgEdDSA {
+		return Key{}, fmt.Errorf("неподдерживаемый алгоритм %q", fk.Alg)
+	}
+	path, public := fk.PrivateKey, false
+	if path == "" {
+		path, public = fk.PublicKey, true
+	}
+	if path == "" {
+		return Key{}, fmt.Errorf("для %s нужен private_key или public_key", fk.Alg)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, fmt.Errorf("чтение ключа. %w", err)
+	}
+	switch {
+	case fk.Alg == AlgRS256 && public:
+		key, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return Key{}, fmt.Errorf("разбор ключа %s. %w", path, err)
+		}
+		return RSAPublic(fk.ID, key), nil
+	case fk.Alg == AlgRS256:
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return Key{}, fmt.Errorf("разбор ключа %s. %w", path, err)
+		}
+		return RSA(fk.ID, key), nil
+	case public:
+		key, err := jwt.ParseEdPublicKeyFromPEM(pem)
+		if err != nil {
+			return Key{}, fmt.Errorf("разбор ключа %s. %w", path, err)
+		}
+		return Ed25519Public(fk.ID, key.(ed25519.PublicKey)), nil
+	default:
+		key, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return Key{}, fmt.Errorf("разбор ключа %s. %w", path, err)
+		}
+		return Ed25519(fk.ID, key.(ed25519.PrivateKey)), nil
+	}
+}
@@ -0,0 +1,170 @@
+// пакет jwtkeys хранит ключи подписи JWT пользователей. ключи различаются идентификатором kid в заголовке
+// токена: активным ключом подписываются новые токены, остальные только проверяют выданные ранее. так ключ
+// можно сменить, не завершив сессии пользователей: токены со старым ключом принимаются, а при продлении
+// подписываются активным. поддерживаются HS256, RS256 и EdDSA (Ed25519), открытые ключи публикуются в JWKS
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// алгоритмы подписи
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// LegacyID идентификатор ключа SECRET_KEY. им проверяются токены без kid, выданные до появления набора ключей
+const LegacyID = ""
+
+// minRSABits наименьший допустимый размер ключа RSA
+const minRSABits = 2048
+
+// ErrUnknownKey токен подписан ключом, которого нет в наборе
+var ErrUnknownKey = errors.New("неизвестный ключ подписи")
+
+// Key ключ подписи или проверки токенов
+type Key struct {
+	id     string
+	method jwt.SigningMethod
+	// sign ключ подписи, nil - ключ только проверяет токены
+	sign   any
+	verify any
+}
+
+// HMAC общий ключ HS256
+func HMAC(id string, secret []byte) Key {
+	return Key{id: id, method: jwt.SigningMethodHS256, sign: secret, verify: secret}
+}
+
+// RSA закрытый ключ RS256
+func RSA(id string, key *rsa.PrivateKey) Key {
+	return Key{id: id, method: jwt.SigningMethodRS256, sign: key, verify: &key.PublicKey}
+}
+
+// RSAPublic открытый ключ RS256, только для проверки
+func RSAPublic(id string, key *rsa.PublicKey) Key {
+	return Key{id: id, method: jwt.SigningMethodRS256, verify: key}
+}
+
+// Ed25519 закрытый ключ EdDSA
+func Ed25519(id string, key ed25519.PrivateKey) Key {
+	return Key{id: id, method: jwt.SigningMethodEdDSA, sign: key, verify: key.Public()}
+}
+
+// Ed25519Public открытый ключ EdDSA, только для проверки
+func Ed25519Public(id string, key ed25519.PublicKey) Key {
+	return Key{id: id, method: jwt.SigningMethodEdDSA, verify: key}
+}
+
+// ID идентификатор ключа (kid)
+func (k Key) ID() string {
+	return k.id
+}
+
+// Set набор ключей с одним активным ключом подписи
+type Set struct {
+	active Key
+	keys   map[string]Key
+	// order ключи в порядке добавления для JWKS
+	order []string
+}
+
+// New создает набор из активного ключа active и ключей проверки verify. идентификаторы ключей не должны повторяться
+func New(active Key, verify ...Key) (*Set, error) {
+	if active.sign == nil {
+		return nil, fmt.Errorf("ключ %q не может подписывать токены", active.id)
+	}
+	s := &Set{active: active, keys: make(map[string]Key, len(verify)+1)}
+	for _, key := range append([]Key{active}, verify...) {
+		if _, ok := s.keys[key.id]; ok {
+			return nil, fmt.Errorf("повторяющийся идентификатор ключа %q", key.id)
+		}
+		if pub, ok := key.verify.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("ключ %q: размер ключа RSA меньше %d бит", key.id, minRSABits)
+		}
+		s.keys[key.id] = key
+		s.order = append(s.order, key.id)
+	}
+	return s, nil
+}
+
+// ActiveID идентификатор активного ключа
+func (s *Set) ActiveID() string {
+	return s.active.id
+}
+
+// Sign подписывает claims активным ключом. идентификатор ключа передается в заголовке kid
+func (s *Set) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.active.method, claims)
+	if s.active.id != LegacyID {
+		token.Header["kid"] = s.active.id
+	}
+	tokenString, err := token.SignedString(s.active.sign)
+	if err != nil {
+		return "", fmt.Errorf("подпись токена ключом %q. %w", s.active.id, err)
+	}
+	return tokenString, nil
+}
+
+// Keyfunc выбирает ключ проверки подписи по заголовку kid. алгоритм токена должен совпадать с алгоритмом ключа,
+// иначе, например, открытый ключ RSA можно было бы использовать как общий ключ HS256
+func (s *Set) Keyfunc(t *jwt.Token) (interface{}, error) {
+	id, err := KeyID(t)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	if t.Method == nil || t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("неожиданный метод подписи: %v", t.Header["alg"])
+	}
+	return key.verify, nil
+}
+
+// KeyID идентификатор ключа из заголовка токена. токены без kid подписаны ключом LegacyID
+func KeyID(t *jwt.Token) (string, error) {
+	v, ok := t.Header["kid"]
+	if !ok {
+		return LegacyID, nil
+	}
+	id, ok := v.(string)
+	if !ok || id == LegacyID {
+		return "", fmt.Errorf("некорректный заголовок kid: %v", v)
+	}
+	return id, nil
+}
+
+// JWKS открытые ключи набора. общие ключи HS256 не публикуются
+func (s *Set) JWKS() model.JWKS {
+	jwks := model.JWKS{Keys: make([]model.JWK, 0, len(s.order))}
+	for _, id := range s.order {
+		key := s.keys[id]
+		jwk := model.JWK{Kid: key.id, Use: "sig", Alg: key.method.Alg()}
+		switch pub := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
@@ -0,0 +1,170 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parse проверяет токен набором keys
+func parse(keys *Set, token string) error {
+	_, err := jwt.Parse(token, keys.Keyfunc)
+	return err
+}
+
+func TestRotation(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	old, err := New(HMAC(LegacyID, []byte("secret")))
+	require.NoError(t, err)
+	legacyToken, err := old.Sign(jwt.MapClaims{"sub": "user"})
+	require.NoError(t, err)
+	rsaSet, err := New(RSA("rsa", rsaKey), HMAC(LegacyID, []byte("secret")))
+	require.NoError(t, err)
+	rsaToken, err := rsaSet.Sign(jwt.MapClaims{"sub": "user"})
+	require.NoError(t, err)
+
+	// после смены ключа принимаются токены всех ключей набора
+	keys, err := New(Ed25519("ed", edKey), RSAPublic("rsa", &rsaKey.PublicKey), HMAC(LegacyID, []byte("secret")))
+	require.NoError(t, err)
+	assert.Equal(t, "ed", keys.ActiveID())
+	edToken, err := keys.Sign(jwt.MapClaims{"sub": "user"})
+	require.NoError(t, err)
+	for _, token := range []string{legacyToken, rsaToken, edToken} {
+		assert.NoError(t, parse(keys, token))
+	}
+	parsed, err := jwt.Parse(edToken, keys.Keyfunc)
+	require.NoError(t, err)
+	kid, err := KeyID(parsed)
+	require.NoError(t, err)
+	assert.Equal(t, "ed", kid)
+
+	// без старого ключа токены старого ключа не принимаются
+	only, err := New(Ed25519("ed", edKey))
+	require.NoError(t, err)
+	assert.ErrorIs(t, parse(only, legacyToken), ErrUnknownKey)
+	assert.ErrorIs(t, parse(only, rsaToken), ErrUnknownKey)
+
+	// открытый ключ RSA нельзя использовать как общий ключ HS256
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "user"})
+	forged.Header["kid"] = "rsa"
+	forgedToken, err := forged.SignedString(x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+	require.NoError(t, err)
+	assert.Error(t, parse(keys, forgedToken))
+}
+
+func TestNew(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	_, err = New(RSAPublic("rsa", &rsaKey.PublicKey))
+	assert.Error(t, err, "ключ проверки не может быть активным")
+	_, err = New(HMAC("a", []byte("1")), HMAC("a", []byte("2")))
+	assert.Error(t, err, "повторяющийся идентификатор")
+	_, err = New(RSA("small", smallKey))
+	assert.Error(t, err, "короткий ключ RSA")
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPublic, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keys, err := New(Ed25519("ed", edKey), RSAPublic("rsa", &rsaKey.PublicKey), HMAC(LegacyID, []byte("secret")))
+	require.NoError(t, err)
+
+	jwks := keys.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	ed, rsaJWK := jwks.Keys[0], jwks.Keys[1]
+	assert.Equal(t, "ed", ed.Kid)
+	assert.Equal(t, AlgEdDSA, ed.Alg)
+	assert.Equal(t, "Ed25519", ed.Crv)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(edPublic), ed.X)
+	assert.Equal(t, "rsa", rsaJWK.Kid)
+	assert.Equal(t, AlgRS256, rsaJWK.Alg)
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	require.NoError(t, err)
+	assert.Zero(t, rsaKey.PublicKey.N.Cmp(new(big.Int).SetBytes(n)))
+	assert.Equal(t, "AQAB", rsaJWK.E)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	writePEM := func(name, typ string, der []byte) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600))
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(edKey)
+	require.NoError(t, err)
+	writePEM("ed25519.pem", "PRIVATE KEY", der)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err = x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	writePEM("rsa.pub.pem", "PUBLIC KEY", der)
+	writeFile := func(content string) string {
+		name := filepath.Join(dir, "keys.json")
+		require.NoError(t, os.WriteFile(name, []byte(content), 0o600))
+		return name
+	}
+
+	// без файла ключ - SECRET_KEY
+	keys, err := Load("", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, LegacyID, keys.ActiveID())
+	legacyToken, err := keys.Sign(jwt.MapClaims{})
+	require.NoError(t, err)
+
+	name := writeFile(`{"active": "2024-06", "keys": [
+		{"kid": "2023", "alg": "HS256", "secret": "old"},
+		{"kid": "2024-01", "alg": "RS256", "public_key": "rsa.pub.pem"},
+		{"kid": "2024-06", "alg": "EdDSA", "private_key": "ed25519.pem"}
+	]}`)
+	keys, err = Load(name, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "2024-06", keys.ActiveID())
+	assert.Len(t, keys.JWKS().Keys, 2)
+	// токены, выданные до появления файла, принимаются
+	assert.NoError(t, parse(keys, legacyToken))
+	rsaSet, err := New(RSA("2024-01", rsaKey))
+	require.NoError(t, err)
+	rsaToken, err := rsaSet.Sign(jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.NoError(t, parse(keys, rsaToken))
+
+	// по умолчанию активный - первый ключ
+	keys, err = Load(writeFile(`{"keys": [{"kid": "2023", "alg": "HS256", "secret": "old"}]}`), "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "2023", keys.ActiveID())
+
+	for _, content := range []string{
+		`{"keys": []}`,
+		`{"keys": [{"alg": "HS256", "secret": "old"}]}`,
+		`{"keys": [{"kid": "a", "alg": "ES256", "secret": "old"}]}`,
+		`{"keys": [{"kid": "a", "alg": "HS256"}]}`,
+		`{"keys": [{"kid": "a", "alg": "EdDSA", "private_key": "missing.pem"}]}`,
+		`{"active": "b", "keys": [{"kid": "a", "alg": "HS256", "secret": "old"}]}`,
+		`{"keys": [{"kid": "a", "alg": "RS256", "public_key": "rsa.pub.pem"}]}`,
+		`{"keys": [{"kid": "a", "alg": "RS256", "private_key": "ed25519.pem"}]}`,
+	} {
+		_, err = Load(writeFile(content), "secret")
+		assert.Error(t, err, content)
+	}
+}
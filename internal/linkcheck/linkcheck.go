@@ -0,0 +1,270 @@
+// пакет linkcheck реализует фоновую проверку доступности оригинальных ссылок.
+// проверка выполняется HEAD запросом (с переходом на GET, если HEAD не поддерживается),
+// с ограничением количества одновременных запросов, паузой между запросами к одному хосту
+// и экспоненциальным откладыванием повторных проверок недоступных ссылок.
+// адреса внутренней сети не проверяются (netguard), перенаправления выполняются только в пределах хоста
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/netguard"
+)
+
+const (
+	defaultConcurrency = 4
+	defaultHostDelay   = time.Second
+	defaultTimeout     = 10 * time.Second
+	defaultMaxBackoff  = 24 * time.Hour
+
+	// maxBodyRead сколько байт тела читаем при GET запросе (чтобы можно было переиспользовать соединение)
+	maxBodyRead = 4 << 10
+	// maxRedirects наибольшее количество перенаправлений в пределах хоста
+	maxRedirects = 10
+
+	userAgent = "shortener-linkcheck/1.0"
+)
+
+// Options настройки проверки
+type Options struct {
+	// Concurrency максимальное количество одновременных запросов
+	Concurrency int
+	// HostDelay минимальный интервал между запросами к одному хосту. 0 - без паузы
+	HostDelay time.Duration
+	// Timeout время ожидания ответа для одного запроса
+	Timeout time.Duration
+	// Interval базовая периодичность проверок. используется для расчета откладывания повторных проверок недоступных ссылок
+	Interval time.Duration
+	// MaxBackoff максимальное время, на которое откладывается повторная проверка недоступной ссылки
+	MaxBackoff time.Duration
+	// AllowPrivate разрешает проверять ссылки на адреса внутренней сети (loopback, частные сети). только для тестов
+	AllowPrivate bool
+}
+
+// Checker проверка доступности ссылок. Хранит состояние между проверками (отложенные ссылки и хосты)
+type Checker struct {
+	opts   Options
+	client *http.Client
+
+	mu       sync.Mutex
+	hostNext map[string]time.Time
+	hostWait map[string]time.Duration
+	links    map[string]linkState
+}
+
+type linkState struct {
+	failures  int
+	nextCheck time.Time
+}
+
+// New создает новый экземпляр Checker. Незаполненные настройки заменяются значениями по умолчанию
+func New(opts Options) *Checker {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+	client := &http.Client{Timeout: opts.Timeout, CheckRedirect: sameHost}
+	if !opts.AllowPrivate {
+		client.Transport = netguard.Transport()
+	}
+	return &Checker{
+		opts:     opts,
+		client:   client,
+		hostNext: make(map[string]time.Time),
+		hostWait: make(map[string]time.Duration),
+		links:    make(map[string]linkState),
+	}
+}
+
+// Check проверяет ссылки links и возвращает результаты проверки.
+// ссылки, проверка которых отложена из-за предыдущих неудач, и ссылки не на http(s) пропускаются
+func (c *Checker) Check(ctx context.Context, links []model.StorageJSONWithUserID) []model.LinkCheck {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make([]model.LinkCheck, 0, len(links))
+		sem     = make(chan struct{}, c.opts.Concurrency)
+	)
+
+	// ссылки проверяются очередями по хостам: пауза перед запросом к хосту выдерживается до того,
+	// как занять место среди одновременных запросов, и не задерживает проверку других хостов
+	hosts := make(map[string][]model.StorageJSONWithUserID)
+	for _, l := range links {
+		if !c.due(l.ShortURL, time.Now()) {
+			continue
+		}
+		u, err := url.Parse(l.OriginalURL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		hosts[u.Host] = append(hosts[u.Host], l)
+	}
+	for host, queue := range hosts {
+		wg.Add(1)
+		go func(host string, queue []model.StorageJSONWithUserID) {
+			defer wg.Done()
+			for _, l := range queue {
+				if err := c.waitHost(ctx, host); err != nil {
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case sem <- struct{}{}:
+				}
+				result, ok := c.checkOne(ctx, host, l)
+				<-sem
+				if !ok {
+					return
+				}
+				c.record(result)
+
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}
+		}(host, queue)
+	}
+	wg.Wait()
+	return results
+}
+
+// checkOne проверяет одну ссылку на хост host. false - проверка прервана отменой контекста
+func (c *Checker) checkOne(ctx context.Context, host string, l model.StorageJSONWithUserID) (model.LinkCheck, bool) {
+	result := model.LinkCheck{ShortURL: l.ShortURL}
+
+	status, err := c.request(ctx, http.MethodHead, l.OriginalURL)
+	if err == nil && (status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented || status == http.StatusForbidden) {
+		// часть серверов не поддерживает HEAD - пробуем GET
+		status, err = c.request(ctx, http.MethodGet, l.OriginalURL)
+	}
+	if ctx.Err() != nil {
+		return result, false
+	}
+	result.CheckedAt = time.Now()
+	result.Status = status
+	if err != nil {
+		result.Error = err.Error()
+	}
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		c.slowDownHost(host)
+	}
+	return result, true
+}
+
+func (c *Checker) request(ctx context.Context, method, link string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		return 0, fmt.Errorf("создание запроса. %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.CopyN(io.Discard, resp.Body, maxBodyRead)
+	return resp.StatusCode, nil
+}
+
+// sameHost выполняет перенаправления только в пределах хоста проверяемой ссылки.
+// перенаправление на другой хост не выполняется, результатом проверки становится ответ 3xx
+func sameHost(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("больше %d перенаправлений", maxRedirects)
+	}
+	if req.URL.Host != via[0].URL.Host {
+		return http.ErrUseLastResponse
+	}
+	return nil
+}
+
+// waitHost ожидает своей очереди для запроса к хосту host
+func (c *Checker) waitHost(ctx context.Context, host string) error {
+	c.mu.Lock()
+	now := time.Now()
+	slot := c.hostNext[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	delay := c.opts.HostDelay
+	if wait, ok := c.hostWait[host]; ok && wait > delay {
+		delay = wait
+	}
+	c.hostNext[host] = slot.Add(delay)
+	c.mu.Unlock()
+
+	wait := time.Until(slot)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// slowDownHost увеличивает интервал между запросами к хосту, который просит снизить нагрузку
+func (c *Checker) slowDownHost(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wait := c.hostWait[host]
+	if wait < c.opts.HostDelay {
+		wait = c.opts.HostDelay
+	}
+	if wait <= 0 {
+		wait = defaultHostDelay
+	}
+	wait *= 2
+	if wait > c.opts.MaxBackoff {
+		wait = c.opts.MaxBackoff
+	}
+	c.hostWait[host] = wait
+}
+
+// due проверяет, пора ли проверять ссылку short
+func (c *Checker) due(short string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.links[short]
+	return !ok || !now.Before(state.nextCheck)
+}
+
+// record запоминает результат проверки. для недоступных ссылок следующая проверка откладывается
+func (c *Checker) record(result model.LinkCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !result.Broken() {
+		delete(c.links, result.ShortURL)
+		return
+	}
+	state := c.links[result.ShortURL]
+	state.failures++
+	backoff := c.opts.Interval << (state.failures - 1)
+	if backoff <= 0 || backoff > c.opts.MaxBackoff {
+		backoff = c.opts.MaxBackoff
+	}
+	state.nextCheck = result.CheckedAt.Add(backoff)
+	c.links[result.ShortURL] = state
+}
@@ -0,0 +1,252 @@
+package linkcheck
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/netguard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func link(short, original string) model.StorageJSONWithUserID {
+	return model.StorageJSONWithUserID{StorageJSON: model.StorageJSON{ShortURL: short, OriginalURL: original}}
+}
+
+func byShort(results []model.LinkCheck) map[string]model.LinkCheck {
+	m := make(map[string]model.LinkCheck, len(results))
+	for _, r := range results {
+		m[r.ShortURL] = r
+	}
+	return m
+}
+
+func TestCheck(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusOK)
+		case "/nohead":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			_, _ = w.Write([]byte("body"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := New(Options{Interval: time.Hour, AllowPrivate: true})
+	results := byShort(c.Check(ctx, []model.StorageJSONWithUserID{
+		link("ok", ts.URL+"/ok"),
+		link("nohead", ts.URL+"/nohead"),
+		link("missing", ts.URL+"/missing"),
+		link("down", "http://127.0.0.1:1/"),
+		link("ftp", "ftp://example.com/file"),
+	}))
+
+	require.Len(t, results, 4, "не http ссылки не проверяются")
+	assert.Equal(t, http.StatusOK, results["ok"].Status)
+	assert.False(t, results["ok"].Broken())
+	assert.Equal(t, http.StatusOK, results["nohead"].Status)
+	assert.Equal(t, http.StatusNotFound, results["missing"].Status)
+	assert.True(t, results["missing"].Broken())
+	assert.Zero(t, results["down"].Status)
+	assert.NotEmpty(t, results["down"].Error)
+	assert.True(t, results["down"].Broken())
+	assert.False(t, results["ok"].CheckedAt.IsZero())
+}
+
+func TestBackoff(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c := New(Options{Interval: time.Hour, AllowPrivate: true})
+	links := []model.StorageJSONWithUserID{link("broken", ts.URL)}
+
+	require.Len(t, c.Check(ctx, links), 1)
+	// повторная проверка отложена
+	assert.Empty(t, c.Check(ctx, links))
+	assert.EqualValues(t, 1, calls.Load())
+
+	// после истечения откладывания - снова проверяем, а откладывание увеличивается
+	state := c.links["broken"]
+	assert.Equal(t, 1, state.failures)
+	assert.False(t, c.due("broken", time.Now().Add(59*time.Minute)))
+	assert.True(t, c.due("broken", time.Now().Add(61*time.Minute)))
+
+	c.links["broken"] = linkState{failures: 1}
+	require.Len(t, c.Check(ctx, links), 1)
+	assert.False(t, c.due("broken", time.Now().Add(119*time.Minute)))
+	assert.True(t, c.due("broken", time.Now().Add(121*time.Minute)))
+}
+
+func TestConcurrencyAndPoliteness(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		current int
+		maxSeen int
+		times   []time.Time
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		current++
+		if current > maxSeen {
+			maxSeen = current
+		}
+		times = append(times, time.Now())
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		current--
+		mu.Unlock()
+	})
+	// хосты различаются портом
+	servers := make([]*httptest.Server, 4)
+	for i := range servers {
+		servers[i] = httptest.NewServer(handler)
+		defer servers[i].Close()
+	}
+	ts := servers[0]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// без паузы между запросами - ограничено только количество одновременных запросов
+	c := New(Options{Concurrency: 2, AllowPrivate: true})
+	links := make([]model.StorageJSONWithUserID, 0, 8)
+	for i, short := range []string{"1", "2", "3", "4", "5", "6", "7", "8"} {
+		links = append(links, link(short, servers[i%len(servers)].URL+"/"+short))
+	}
+	require.Len(t, c.Check(ctx, links), len(links))
+	assert.Equal(t, 2, maxSeen)
+	links = links[:0]
+	for _, short := range []string{"1", "2", "3"} {
+		links = append(links, link(short, ts.URL+"/"+short))
+	}
+
+	// с паузой запросы к одному хосту разнесены во времени
+	times = nil
+	delay := 50 * time.Millisecond
+	c = New(Options{Concurrency: 4, HostDelay: delay, AllowPrivate: true})
+	require.Len(t, c.Check(ctx, links), 3)
+	require.Len(t, times, 3)
+	first, last := times[0], times[0]
+	for _, tm := range times {
+		if tm.Before(first) {
+			first = tm
+		}
+		if tm.After(last) {
+			last = tm
+		}
+	}
+	assert.GreaterOrEqual(t, last.Sub(first), 2*delay-10*time.Millisecond)
+}
+
+func TestHostQueue(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		times = make(map[string][]time.Time)
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times[r.Host] = append(times[r.Host], time.Now())
+		mu.Unlock()
+	})
+	slow := httptest.NewServer(handler)
+	defer slow.Close()
+	fast := httptest.NewServer(handler)
+	defer fast.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// пауза перед запросами к одному хосту не занимает единственное место в пуле:
+	// ссылка на другой хост проверяется, не дожидаясь очереди первого
+	c := New(Options{Concurrency: 1, HostDelay: 200 * time.Millisecond, AllowPrivate: true})
+	require.Len(t, c.Check(ctx, []model.StorageJSONWithUserID{
+		link("s1", slow.URL+"/1"),
+		link("s2", slow.URL+"/2"),
+		link("s3", slow.URL+"/3"),
+		link("f1", fast.URL+"/1"),
+	}), 4)
+	slowHost := strings.TrimPrefix(slow.URL, "http://")
+	fastHost := strings.TrimPrefix(fast.URL, "http://")
+	require.Len(t, times[slowHost], 3)
+	require.Len(t, times[fastHost], 1)
+	assert.True(t, times[fastHost][0].Before(times[slowHost][1]))
+}
+
+func TestRedirect(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+		case "/away":
+			http.Redirect(w, r, other.URL+"/missing", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// перенаправление в пределах хоста выполняется, на другой хост - нет
+	c := New(Options{AllowPrivate: true})
+	results := byShort(c.Check(ctx, []model.StorageJSONWithUserID{
+		link("moved", ts.URL+"/moved"),
+		link("away", ts.URL+"/away"),
+	}))
+	assert.Equal(t, http.StatusOK, results["moved"].Status)
+	assert.Equal(t, http.StatusFound, results["away"].Status)
+}
+
+func TestPrivateAddress(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// ссылки во внутреннюю сеть не запрашиваются, а результат не зависит от того, доступен ли адрес
+	c := New(Options{Interval: time.Hour})
+	results := byShort(c.Check(ctx, []model.StorageJSONWithUserID{
+		link("up", ts.URL),
+		link("down", "http://127.0.0.1:1/"),
+	}))
+	require.Len(t, results, 2)
+	assert.Zero(t, calls.Load())
+	assert.Equal(t, results["up"].Status, results["down"].Status)
+	assert.Contains(t, results["up"].Error, netguard.ErrForbiddenAddress.Error())
+	assert.Contains(t, results["down"].Error, netguard.ErrForbiddenAddress.Error())
+}
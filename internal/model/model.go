@@ -1,9 +1,77 @@
 // пакет model служит для представления используемых моделей приложения
 package model
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
 // RequestShortURL запрос с ссылкой для сокращения
 type RequestShortURL struct {
 	URL string `json:"url,omitempty"`
+	LinkOptions
+}
+
+// LinkOptions настройки отдельной сокращенной ссылки, задаваемые владельцем
+type LinkOptions struct {
+	// Title заголовок ссылки, показывается на странице предпросмотра
+	Title string `json:"title,omitempty"`
+	// Interstitial вместо перенаправления всегда показывать страницу предпросмотра
+	Interstitial bool `json:"interstitial,omitempty"`
+	// RedirectCode код ответа при перенаправлении (301, 302, 307 или 308). 0 - значение из конфигурации
+	RedirectCode int `json:"redirect_code,omitempty"`
+	// Passthrough передавать дополнительный путь и параметры запроса из короткой ссылки в оригинальную
+	Passthrough bool `json:"passthrough,omitempty"`
+}
+
+// Valid проверяет корректность настроек
+func (o LinkOptions) Valid() bool {
+	return o.RedirectCode == 0 || ValidRedirectCode(o.RedirectCode)
+}
+
+// ValidRedirectCode проверяет, что code является допустимым кодом перенаправления
+func ValidRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// PermanentRedirect возвращает true для кодов постоянного перенаправления
+func PermanentRedirect(code int) bool {
+	return code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect
+}
+
+// UpdateLinkRequest запрос на изменение настроек ссылки. Незаполненные поля не изменяются
+type UpdateLinkRequest struct {
+	Title        *string `json:"title,omitempty"`
+	Interstitial *bool   `json:"interstitial,omitempty"`
+	RedirectCode *int    `json:"redirect_code,omitempty"`
+	Passthrough  *bool   `json:"passthrough,omitempty"`
+}
+
+// Apply применяет изменения из запроса к настройкам opts
+func (r UpdateLinkRequest) Apply(opts LinkOptions) LinkOptions {
+	if r.Title != nil {
+		opts.Title = *r.Title
+	}
+	if r.Interstitial != nil {
+		opts.Interstitial = *r.Interstitial
+	}
+	if r.RedirectCode != nil {
+		opts.RedirectCode = *r.RedirectCode
+	}
+	if r.Passthrough != nil {
+		opts.Passthrough = *r.Passthrough
+	}
+	return opts
 }
 
 // ResponseShortURL запрос получения оригинальной ссылки
@@ -17,6 +85,17 @@ type StorageJSON struct {
 	ShortURL    string `json:"short_url,omitempty"`
 	OriginalURL string `json:"original_url,omitempty"`
 	IsDeleted   bool   `json:"is_deleted"`
+	IsBlocked   bool   `json:"is_blocked,omitempty"`
+	LinkOptions
+
+	LastStatus     int        `json:"last_status,omitempty"`
+	LastCheckError string     `json:"last_check_error,omitempty"`
+	LastChecked    *time.Time `json:"last_checked,omitempty"`
+}
+
+// Broken возвращает true, если последняя проверка показала, что оригинальная ссылка недоступна
+func (s StorageJSON) Broken() bool {
+	return s.LastChecked != nil && isBrokenStatus(s.LastStatus)
 }
 
 // StorageJSONWithUserID структура для хранения в файле с добавлением функицональности разделения пользователей
@@ -39,6 +118,7 @@ type BatchRequestElement struct {
 	CorrelationID string `json:"correlation_id,omitempty"`
 	OriginalURL   string `json:"original_url"`
 	ShortURL      string `json:"-"`
+	LinkOptions
 }
 
 // BatchResponse ответ на массовый запрос сокращения ссылок
@@ -48,13 +128,589 @@ type BatchResponse []BatchResponseElement
 type BatchResponseElement struct {
 	CorrelationID string `json:"correlation_id,omitempty"`
 	ShortURL      string `json:"short_url,omitempty"`
-	OriginalURL   string `json:"-"`
-	Collision     bool   `json:"-"`
-	Error         error  `json:"-"`
+	// Status результат обработки элемента, Reason - код ошибки для невалидных и несохраненных элементов
+	Status      BatchStatus `json:"status,omitempty"`
+	Reason      string      `json:"reason,omitempty"`
+	OriginalURL string      `json:"-"`
+	Collision   bool        `json:"-"`
+	Error       error       `json:"-"`
+}
+
+// BatchStatus результат обработки отдельного элемента массового запроса
+type BatchStatus string
+
+const (
+	// BatchStatusCreated создана новая короткая ссылка
+	BatchStatusCreated BatchStatus = "created"
+	// BatchStatusExisting ссылка уже была сокращена, возвращается существующая короткая ссылка
+	BatchStatusExisting BatchStatus = "existing"
+	// BatchStatusInvalid элемент не прошел проверку, причина в коде ошибки
+	BatchStatusInvalid BatchStatus = "invalid"
+	// BatchStatusFailed элемент не удалось сохранить
+	BatchStatusFailed BatchStatus = "failed"
+)
+
+// BulkResult результат обработки строки потоковой загрузки ссылок
+type BulkResult struct {
+	Line          int         `json:"line"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	OriginalURL   string      `json:"original_url,omitempty"`
+	ShortURL      string      `json:"short_url,omitempty"`
+	Status        BatchStatus `json:"status"`
+	Reason        string      `json:"reason,omitempty"`
+}
+
+// BatchResponseElement результат строки в виде элемента ответа на массовый запрос
+func (r BulkResult) BatchResponseElement() BatchResponseElement {
+	return BatchResponseElement{
+		CorrelationID: r.CorrelationID,
+		ShortURL:      r.ShortURL,
+		Status:        r.Status,
+		Reason:        r.Reason,
+		OriginalURL:   r.OriginalURL,
+	}
+}
+
+// JobStatus состояние фонового задания массового сокращения ссылок
+type JobStatus string
+
+const (
+	// JobQueued задание ожидает обработки
+	JobQueued JobStatus = "queued"
+	// JobRunning задание обрабатывается
+	JobRunning JobStatus = "running"
+	// JobDone задание обработано, результаты доступны
+	JobDone JobStatus = "done"
+	// JobFailed обработка задания прервана ошибкой
+	JobFailed JobStatus = "failed"
+)
+
+// Finished возвращает true, если обработка задания завершена
+func (s JobStatus) Finished() bool {
+	return s == JobDone || s == JobFailed
+}
+
+// Job фоновое задание массового сокращения ссылок
+type Job struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"-"`
+	Status JobStatus `json:"status"`
+	// Total количество элементов запроса, Processed - количество обработанных элементов
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Created   int `json:"created"`
+	Existing  int `json:"existing"`
+	Invalid   int `json:"invalid"`
+	Failed    int `json:"failed"`
+	// Error причина прерывания обработки задания
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Request элементы запроса, Results - результаты обработанных элементов в порядке запроса
+	Request BatchRequest  `json:"-"`
+	Results BatchResponse `json:"results,omitempty"`
+}
+
+// JobProgress очередная часть результатов обработки задания
+type JobProgress struct {
+	Status  JobStatus
+	Results BatchResponse
+	Error   string
+}
+
+// Apply добавляет к заданию результаты p, пересчитывает счетчики и обновляет состояние
+func (j *Job) Apply(p JobProgress, now time.Time) {
+	for _, res := range p.Results {
+		switch res.Status {
+		case BatchStatusCreated:
+			j.Created++
+		case BatchStatusExisting:
+			j.Existing++
+		case BatchStatusInvalid:
+			j.Invalid++
+		default:
+			j.Failed++
+		}
+	}
+	j.Processed += len(p.Results)
+	j.Results = append(j.Results, p.Results...)
+	if p.Status != "" {
+		j.Status = p.Status
+	}
+	if p.Error != "" {
+		j.Error = p.Error
+	}
+	j.UpdatedAt = now
+}
+
+// IdempotentResponse сохраненный ответ на запрос с ключом идемпотентности.
+// повторный запрос с тем же ключом получает этот ответ вместо повторного выполнения
+type IdempotentResponse struct {
+	// Fingerprint отпечаток запроса: ключ нельзя использовать для другого запроса
+	Fingerprint string
+	// Status HTTP статус ответа (0 для gRPC)
+	Status int
+	// Header заголовки ответа, которые нужно повторить
+	Header map[string]string
+	Body   []byte
+	// ExpiresAt после этого момента ключ можно использовать заново
+	ExpiresAt time.Time
+}
+
+// StatsGranularity шаг ряда статистики созданных ссылок
+type StatsGranularity string
+
+const (
+	StatsHour StatsGranularity = "hour"
+	StatsDay  StatsGranularity = "day"
+)
+
+// Step длительность шага
+func (g StatsGranularity) Step() time.Duration {
+	if g == StatsHour {
+		return time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Truncate начало шага (в UTC), в который попадает t
+func (g StatsGranularity) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	if g == StatsHour {
+		return t.Truncate(time.Hour)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// StatsRequest параметры статистики: период [From, To) ряда созданных ссылок с шагом Granularity
+// (границы совпадают с началами шагов) и количество записей в списках лидеров Top
+type StatsRequest struct {
+	From        time.Time
+	To          time.Time
+	Granularity StatsGranularity
+	Top         int
+}
+
+// Series ряд созданных ссылок за период запроса по количеству ссылок, созданных в каждый час (ключ - начало часа в UTC).
+// шаги без созданных ссылок заполняются нулями
+func (r StatsRequest) Series(hourly map[time.Time]int) StatsSeries {
+	series := StatsSeries{Granularity: r.Granularity, From: r.From, To: r.To, Points: make([]StatsPoint, 0)}
+	index := make(map[time.Time]int)
+	for t := r.From; t.Before(r.To); t = t.Add(r.Granularity.Step()) {
+		index[t] = len(series.Points)
+		series.Points = append(series.Points, StatsPoint{Time: t})
+	}
+	for hour, links := range hourly {
+		if i, ok := index[r.Granularity.Truncate(hour)]; ok {
+			series.Points[i].Links += links
+		}
+	}
+	return series
 }
 
-// StatsResponse статистика сервиса (корличество пользователей и запросов) для внутреннего использования
+// StatsResponse статистика сервиса для внутреннего использования. счетчики ведутся при изменении ссылок,
+// окончательно удаленные ссылки не учитываются нигде, кроме ряда созданных ссылок
 type StatsResponse struct {
+	// TotalUsers количество пользователей, у которых есть ссылки
 	TotalUsers int `json:"users"`
-	TotalURLs  int `json:"urls"`
+	// TotalURLs количество ссылок, включая удаленные пользователями (DeletedURLs), ActiveURLs - без них
+	TotalURLs   int `json:"urls"`
+	ActiveURLs  int `json:"active_urls"`
+	DeletedURLs int `json:"deleted_urls"`
+	// Created количество ссылок, созданных за период запроса
+	Created StatsSeries `json:"created"`
+	// TopDomains домены оригинальных ссылок и TopUsers пользователи с наибольшим количеством ссылок (включая удаленные).
+	// пользователи обезличены: ключ - StatsUserKey, а не идентификатор
+	TopDomains []StatsCount `json:"top_domains"`
+	TopUsers   []StatsCount `json:"top_users"`
+}
+
+// StatsSeries ряд количества созданных ссылок за период [From, To) с шагом Granularity
+type StatsSeries struct {
+	Granularity StatsGranularity `json:"granularity"`
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Points      []StatsPoint     `json:"points"`
+}
+
+// StatsPoint количество ссылок, созданных за шаг ряда, начинающийся в Time
+type StatsPoint struct {
+	Time  time.Time `json:"time"`
+	Links int       `json:"links"`
+}
+
+// StatsCount количество ссылок для домена или пользователя Key
+type StatsCount struct {
+	Key   string `json:"key"`
+	Links int    `json:"links"`
+}
+
+// StatsUserKey обезличенный ключ пользователя userID в статистике: первые 16 байт SHA-256 идентификатора в hex.
+// ключ постоянен, поэтому позволяет сравнивать статистику за разное время, но не раскрывает идентификатор
+func StatsUserKey(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Domain домен оригинальной ссылки в нижнем регистре, по которому ведется статистика. для некорректной ссылки - пустая строка
+func Domain(originalURL string) string {
+	u, err := url.Parse(originalURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// LinkCheck результат проверки доступности оригинальной ссылки
+type LinkCheck struct {
+	ShortURL  string    `json:"short_url"`
+	Status    int       `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Broken возвращает true, если ссылка признана недоступной
+func (c LinkCheck) Broken() bool {
+	return isBrokenStatus(c.Status)
+}
+
+// isBrokenStatus недоступной считаем ссылку без ответа или с ответом 4xx/5xx
+func isBrokenStatus(status int) bool {
+	return status == 0 || status >= 400
+}
+
+// DeleteStatus результат удаления отдельной ссылки
+type DeleteStatus string
+
+const (
+	// DeletePending ссылка ожидает удаления
+	DeletePending DeleteStatus = "pending"
+	// DeleteDone ссылка удалена
+	DeleteDone DeleteStatus = "deleted"
+	// DeleteNotFound ссылка не найдена или принадлежит другому пользователю
+	DeleteNotFound DeleteStatus = "not_found"
+	// DeleteFailed ссылку не удалось удалить, причина в Reason
+	DeleteFailed DeleteStatus = "failed"
+)
+
+// DeleteResult результат удаления ссылки из запроса на удаление
+type DeleteResult struct {
+	ShortURL string       `json:"short_url"`
+	Status   DeleteStatus `json:"status"`
+	Reason   string       `json:"reason,omitempty"`
+}
+
+// DeleteTask запрос пользователя на удаление ссылок, ожидающий в очереди удаления
+type DeleteTask struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"-"`
+	// Status queued - ссылки ожидают удаления, done - результаты по каждой ссылке получены
+	Status    JobStatus `json:"status"`
+	Total     int       `json:"total"`
+	Deleted   int       `json:"deleted"`
+	NotFound  int       `json:"not_found"`
+	Failed    int       `json:"failed"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Results результаты по ссылкам в порядке запроса
+	Results []DeleteResult `json:"results"`
+}
+
+// Messages запросы на удаление ссылок задачи для Storager.DeleteURLs
+func (t DeleteTask) Messages() []DeleteURLMessage {
+	messages := make([]DeleteURLMessage, 0, len(t.Results))
+	for _, res := range t.Results {
+		messages = append(messages, DeleteURLMessage{UserID: t.UserID.String(), ShortURL: res.ShortURL})
+	}
+	return messages
+}
+
+// Complete сохраняет в задаче результаты удаления results, пересчитывает счетчики и завершает задачу
+func (t *DeleteTask) Complete(results []DeleteResult, now time.Time) {
+	t.Deleted, t.NotFound, t.Failed = 0, 0, 0
+	for _, res := range results {
+		switch res.Status {
+		case DeleteDone:
+			t.Deleted++
+		case DeleteNotFound:
+			t.NotFound++
+		default:
+			t.Failed++
+		}
+	}
+	t.Results = results
+	t.Status = JobDone
+	t.UpdatedAt = now
+}
+
+// EventType тип события жизненного цикла ссылки
+type EventType string
+
+const (
+	// EventLinkCreated создана новая короткая ссылка
+	EventLinkCreated EventType = "link.created"
+	// EventLinkDeleted ссылка удалена владельцем
+	EventLinkDeleted EventType = "link.deleted"
+	// EventLinkClicked переход по короткой ссылке
+	EventLinkClicked EventType = "link.clicked"
+	// EventClickThreshold количество переходов по ссылке достигло порога, заданного в подписке.
+	// по каждой ссылке подписка получает это событие один раз
+	EventClickThreshold EventType = "link.click_threshold"
+)
+
+// Event событие жизненного цикла ссылки
+type Event struct {
+	ID     uuid.UUID `json:"id"`
+	Type   EventType `json:"type"`
+	UserID uuid.UUID `json:"-"`
+	// ShortURL ключ короткой ссылки, OriginalURL - оригинальная ссылка (если известна)
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url,omitempty"`
+	// Clicks количество переходов по ссылке
+	Clicks    int64     `json:"clicks,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LinkClicks количество переходов по ссылке и ее владелец
+type LinkClicks struct {
+	ShortURL string
+	UserID   uuid.UUID
+	Clicks   int64
+}
+
+// WebhookRequest запрос на подписку на события ссылок пользователя
+type WebhookRequest struct {
+	// URL адрес, на который отправляются события
+	URL    string      `json:"url"`
+	Events []EventType `json:"events"`
+	// ClickThreshold порог переходов для события link.click_threshold
+	ClickThreshold int64 `json:"click_threshold,omitempty"`
+}
+
+// Webhook подписка пользователя на события его ссылок
+type Webhook struct {
+	ID             uuid.UUID   `json:"id"`
+	UserID         uuid.UUID   `json:"-"`
+	URL            string      `json:"url"`
+	Events         []EventType `json:"events"`
+	ClickThreshold int64       `json:"click_threshold,omitempty"`
+	// Secret ключ подписи событий (HMAC-SHA256). возвращается только при создании подписки
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Subscribed возвращает true, если подписка получает события типа t
+func (w Webhook) Subscribed(t EventType) bool {
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus состояние доставки события подписке
+type DeliveryStatus string
+
+const (
+	// DeliveryPending событие ожидает доставки (в том числе повторной)
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered событие доставлено
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead попытки доставки исчерпаны, событие в списке недоставленных
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery доставка события подписке
+type WebhookDelivery struct {
+	ID        uuid.UUID      `json:"id"`
+	WebhookID uuid.UUID      `json:"webhook_id"`
+	Event     Event          `json:"event"`
+	Status    DeliveryStatus `json:"status"`
+	// Attempts количество выполненных попыток, NextAttemptAt - время следующей попытки
+	Attempts      int       `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// ResponseStatus HTTP статус ответа на последнюю попытку, Error - ошибка последней попытки
+	ResponseStatus int       `json:"response_status,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// UserBlockRequest запрос администратора на блокировку пользователя
+type UserBlockRequest struct {
+	// Reason причина блокировки, сохраняется в журнале действий администраторов
+	Reason string `json:"reason,omitempty"`
+}
+
+// PurgeResponse результат окончательного удаления ссылок администратором
+type PurgeResponse struct {
+	// Purged удаленные ссылки, NotFound - ссылки, которых нет в хранилище
+	Purged   []string `json:"purged"`
+	NotFound []string `json:"not_found,omitempty"`
+}
+
+// AuditAction действие администратора
+type AuditAction string
+
+const (
+	AuditLinkLookup  AuditAction = "link.lookup"
+	AuditLinkDisable AuditAction = "link.disable"
+	AuditLinkEnable  AuditAction = "link.enable"
+	AuditLinksPurge  AuditAction = "links.purge"
+	AuditUserLinks   AuditAction = "user.links"
+	AuditUserBlock   AuditAction = "user.block"
+	AuditUserUnblock AuditAction = "user.unblock"
+)
+
+// AuditRecord запись журнала действий администраторов
+type AuditRecord struct {
+	ID     uuid.UUID   `json:"id"`
+	Action AuditAction `json:"action"`
+	// Actor кто выполнил действие: протокол и адрес клиента
+	Actor string `json:"actor"`
+	// Target ссылка или пользователь, над которыми выполнено действие, Details - подробности (причина, список ссылок)
+	Target  string `json:"target"`
+	Details string `json:"details,omitempty"`
+	// Result "ok" или код ошибки API, с которой действие не выполнено
+	Result    string    `json:"result"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// HealthStatus результат проверки готовности
+type HealthStatus string
+
+const (
+	HealthOK   HealthStatus = "ok"
+	HealthFail HealthStatus = "fail"
+)
+
+// HealthResponse состояние сервиса. Status - ok, если пройдены все проверки
+type HealthResponse struct {
+	Status HealthStatus  `json:"status"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck результат отдельной проверки готовности
+type HealthCheck struct {
+	Name   string       `json:"name"`
+	Status HealthStatus `json:"status"`
+	// Error причина, по которой проверка не пройдена
+	Error string `json:"error,omitempty"`
+	// DurationMS время выполнения проверки в миллисекундах
+	DurationMS int64 `json:"duration_ms"`
+}
+
+// Account учетная запись пользователя. ID совпадает с ID пользователя, которому принадлежат ссылки
+type Account struct {
+	ID    uuid.UUID `json:"user_id"`
+	Login string    `json:"login"`
+	// PasswordHash хэш пароля (bcrypt), в ответы не попадает
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Credentials логин и пароль для регистрации и входа
+type Credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// ClaimRequest запрос на перенос ссылок анонимного пользователя в учетную запись.
+// Token - значение cookie анонимного пользователя (например, из другого браузера), срок его действия не проверяется
+type ClaimRequest struct {
+	Token string `json:"token"`
+}
+
+// ClaimResponse результат переноса ссылок анонимного пользователя
+type ClaimResponse struct {
+	// From ID анонимного пользователя, Claimed - количество перенесенных ссылок
+	From    uuid.UUID `json:"from"`
+	Claimed int       `json:"claimed"`
+}
+
+// LoginResponse результат входа: учетная запись и количество ссылок, перенесенных из анонимной сессии
+type LoginResponse struct {
+	Account
+	Claimed int `json:"claimed"`
+}
+
+// Scope право доступа персонального API токена
+type Scope string
+
+const (
+	// ScopeLinksRead просмотр своих ссылок
+	ScopeLinksRead Scope = "links:read"
+	// ScopeLinksWrite создание и изменение ссылок
+	ScopeLinksWrite Scope = "links:write"
+	// ScopeLinksDelete удаление своих ссылок
+	ScopeLinksDelete Scope = "links:delete"
+	// ScopeStatsRead статистика сервиса. не заменяет проверку доверенной подсети
+	ScopeStatsRead Scope = "stats:read"
+)
+
+// APITokenRequest запрос на создание персонального API токена
+type APITokenRequest struct {
+	// Name название токена для пользователя (например, имя скрипта)
+	Name   string  `json:"name"`
+	Scopes []Scope `json:"scopes"`
+	// ExpiresAt время окончания действия токена, без него токен действует до отзыва
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIToken персональный API токен пользователя. хранится только хэш токена
+type APIToken struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"-"`
+	Name   string    `json:"name"`
+	Scopes []Scope   `json:"scopes"`
+	// Token значение токена. возвращается только при создании
+	Token string `json:"token,omitempty"`
+	// Hash хэш SHA-256 значения токена в hex, по которому токен ищется в хранилище
+	Hash      string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Allows возвращает true, если токен дает право scope
+func (t APIToken) Allows(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired возвращает true, если срок действия токена истек к моменту now
+func (t APIToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// JWKS набор открытых ключей проверки подписи токенов (JSON Web Key Set, RFC 7517)
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK открытый ключ проверки подписи. для RSA заполнены N и E, для Ed25519 - Crv и X,
+// для ключей EC провайдеров входа - Crv, X и Y (base64url без дополнения)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// OIDCIdentity пользователь, подтвержденный провайдером входа OpenID Connect. Subject (sub) не меняется
+// у провайдера Issuer, поэтому по нему определяется учетная запись
+type OIDCIdentity struct {
+	Issuer  string
+	Subject string
+	Email   string
+	Name    string
 }
@@ -0,0 +1,81 @@
+// пакет netguard защищает исходящие запросы по адресам, заданным пользователями (подписки, оригинальные ссылки),
+// от обращения к внутренней сети сервиса (SSRF). запрещены адреса loopback, частных сетей, link-local,
+// групповые и неопределенные адреса. адрес проверяется при подключении, поэтому изменение DNS записи
+// после проверки при создании не позволяет обойти запрет
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress адрес во внутренней сети, запросы к которому запрещены
+var ErrForbiddenAddress = errors.New("адрес во внутренней сети запрещен")
+
+// Allowed возвращает true, если к адресу ip можно обращаться по адресу, заданному пользователем
+func Allowed(ip net.IP) bool {
+	return ip != nil &&
+		!ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified()
+}
+
+// CheckHost проверяет хост host (без порта): имя localhost и адреса, на которые указывает хост, не должны быть запрещены.
+// если имя не удалось разрешить, хост не отклоняется - адрес все равно проверяется при подключении
+func CheckHost(ctx context.Context, host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%s. %w", host, ErrForbiddenAddress)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if !Allowed(ip) {
+			return fmt.Errorf("%s. %w", host, ErrForbiddenAddress)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, addr := range addrs {
+		if !Allowed(addr.IP) {
+			return fmt.Errorf("%s (%s). %w", host, addr.IP, ErrForbiddenAddress)
+		}
+	}
+	return nil
+}
+
+// Transport HTTP транспорт, отказывающийся подключаться к запрещенным адресам.
+// прокси из окружения не используется, иначе проверялся бы адрес прокси, а не сервера назначения
+func Transport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}).DialContext
+	return t
+}
+
+// control проверяет адрес после разрешения имени, непосредственно перед подключением
+func control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("адрес подключения %s. %w", address, err)
+	}
+	if !Allowed(net.ParseIP(host)) {
+		return fmt.Errorf("%s. %w", host, ErrForbiddenAddress)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package netguard
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "8.8.8.8", want: true},
+		{ip: "2001:4860:4860::8888", want: true},
+		{ip: "127.0.0.1", want: false},
+		{ip: "::1", want: false},
+		{ip: "10.1.2.3", want: false},
+		{ip: "172.16.0.1", want: false},
+		{ip: "192.168.1.1", want: false},
+		{ip: "fd00::1", want: false},
+		{ip: "169.254.169.254", want: false},
+		{ip: "fe80::1", want: false},
+		{ip: "0.0.0.0", want: false},
+		{ip: "::", want: false},
+		{ip: "224.0.0.1", want: false},
+		{ip: "::ffff:127.0.0.1", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Allowed(net.ParseIP(tt.ip)), tt.ip)
+	}
+	assert.False(t, Allowed(nil))
+}
+
+func TestCheckHost(t *testing.T) {
+	ctx := context.Background()
+	for _, host := range []string{"localhost", "LOCALHOST.", "api.localhost", "127.0.0.1", "169.254.169.254", "::1", "10.0.0.1"} {
+		assert.ErrorIs(t, CheckHost(ctx, host), ErrForbiddenAddress, host)
+	}
+	assert.NoError(t, CheckHost(ctx, "8.8.8.8"))
+	assert.NoError(t, CheckHost(ctx, "2001:4860:4860::8888"))
+}
+
+func TestTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	// подключение к loopback отклоняется при подключении
+	_, err := (&http.Client{Transport: Transport()}).Get(ts.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	resp, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+}
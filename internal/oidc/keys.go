@@ -0,0 +1,93 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// parseJWK открытый ключ провайдера: RSA, EC (P-256, P-384, P-521) или Ed25519
+func parseJWK(jwk model.JWK) (any, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeInt(jwk.N)
+		if err != nil {
+			return nil, fmt.Errorf("n. %w", err)
+		}
+		e, err := decodeInt(jwk.E)
+		if err != nil {
+			return nil, fmt.Errorf("e. %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("некорректная экспонента RSA")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("неподдерживаемая кривая %q", jwk.Crv)
+		}
+		x, err := decodeInt(jwk.X)
+		if err != nil {
+			return nil, fmt.Errorf("x. %w", err)
+		}
+		y, err := decodeInt(jwk.Y)
+		if err != nil {
+			return nil, fmt.Errorf("y. %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("точка не лежит на кривой %s", jwk.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("неподдерживаемая кривая %q", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("некорректный ключ Ed25519")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("неподдерживаемый тип ключа %q", jwk.Kty)
+}
+
+// decodeInt число в base64url без дополнения
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("пустое значение")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// matches проверяет, что алгоритм токена alg соответствует типу ключа key. иначе, например,
+// открытый ключ RSA можно было бы использовать как общий ключ HS256
+func matches(key any, alg string) bool {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	case *ecdsa.PublicKey:
+		return strings.HasPrefix(alg, "ES")
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	}
+	return false
+}
@@ -0,0 +1,430 @@
+// пакет oidc реализует вход через провайдера OpenID Connect компании: код авторизации с PKCE (RFC 7636).
+// настройки провайдера (discovery) и его ключи загружаются при первом входе и кэшируются, поэтому сервис
+// запускается и при недоступном провайдере. пользователь определяется по claim sub токена ID
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// keysRefreshInterval ключи провайдера перечитываются при неизвестном kid не чаще этого интервала
+	keysRefreshInterval = time.Minute
+	// maxResponse наибольший размер ответа провайдера
+	maxResponse   = 1 << 20
+	discoveryPath = "/.well-known/openid-configuration"
+)
+
+// defaultScopes запрашиваемые права, если Options.Scopes не заданы
+var defaultScopes = []string{"openid", "email", "profile"}
+
+// signingMethods алгоритмы подписи токена ID, которые принимаются от провайдера
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Options настройки провайдера
+type Options struct {
+	// Issuer адрес провайдера, совпадает с claim iss токенов
+	Issuer   string
+	ClientID string
+	// ClientSecret секрет клиента. пустой - публичный клиент, защищенный только PKCE
+	ClientSecret string
+	// RedirectURL адрес возврата после входа у провайдера
+	RedirectURL string
+	// AllowedDomains домены почты, пользователям которых разрешен вход. пустой список - любые
+	AllowedDomains []string
+	// Scopes запрашиваемые права. по умолчанию openid, email и profile
+	Scopes []string
+	// Timeout время ожидания ответа провайдера
+	Timeout time.Duration
+	// Client HTTP клиент запросов к провайдеру. если не задан - клиент с ожиданием Timeout
+	Client *http.Client
+	// Logger логгер входов. если не задан - slog.Default()
+	Logger *slog.Logger
+}
+
+// Flow параметры одного входа. хранятся у клиента до возврата от провайдера
+type Flow struct {
+	// State связывает возврат от провайдера с начавшим вход браузером
+	State string
+	// Nonce связывает токен ID с этим входом
+	Nonce string
+	// Verifier проверочный код PKCE, провайдер получает только его хэш
+	Verifier string
+}
+
+// NewFlow создает параметры нового входа
+func NewFlow() (Flow, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return Flow{}, fmt.Errorf("генерация параметров входа. %w", err)
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return Flow{State: values[0], Nonce: values[1], Verifier: values[2]}, nil
+}
+
+// String параметры входа одной строкой для cookie
+func (f Flow) String() string {
+	return f.State + "." + f.Nonce + "." + f.Verifier
+}
+
+// ParseFlow разбирает параметры входа, записанные Flow.String
+func ParseFlow(s string) (Flow, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return Flow{}, errors.New("некорректные параметры входа")
+	}
+	return Flow{State: parts[0], Nonce: parts[1], Verifier: parts[2]}, nil
+}
+
+// Challenge хэш проверочного кода PKCE для метода S256
+func (f Flow) Challenge() string {
+	sum := sha256.Sum256([]byte(f.Verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// CheckState проверяет, что state возврата от провайдера совпадает с state входа
+func (f Flow) CheckState(state string) bool {
+	return f.State != "" && subtle.ConstantTimeCompare([]byte(f.State), []byte(state)) == 1
+}
+
+// metadata настройки провайдера из discovery
+type metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// Provider провайдер входа OpenID Connect
+type Provider struct {
+	opts Options
+
+	mu   sync.Mutex
+	meta *metadata
+	keys map[string]any
+	// keysLoaded время последней загрузки ключей
+	keysLoaded time.Time
+}
+
+// New создает провайдера с настройками opts. обращений к провайдеру при этом нет
+func New(opts Options) (*Provider, error) {
+	issuer, err := url.Parse(opts.Issuer)
+	if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" {
+		return nil, fmt.Errorf("некорректный адрес провайдера входа %q", opts.Issuer)
+	}
+	if opts.ClientID == "" {
+		return nil, errors.New("не задан идентификатор клиента провайдера входа")
+	}
+	if opts.RedirectURL == "" {
+		return nil, errors.New("не задан адрес возврата от провайдера входа")
+	}
+	if len(opts.Scopes) == 0 {
+		opts.Scopes = defaultScopes
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: opts.Timeout}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	domains := make([]string, 0, len(opts.AllowedDomains))
+	for _, domain := range opts.AllowedDomains {
+		domains = append(domains, strings.ToLower(strings.TrimSpace(domain)))
+	}
+	opts.AllowedDomains = domains
+	return &Provider{opts: opts}, nil
+}
+
+// AuthURL адрес провайдера, на который перенаправляется браузер для входа с параметрами flow
+func (p *Provider) AuthURL(ctx context.Context, flow Flow) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", apierror.Wrap(apierror.CodeUnavailable, fmt.Errorf("адрес входа провайдера. %w", err))
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.opts.ClientID)
+	q.Set("redirect_uri", p.opts.RedirectURL)
+	q.Set("scope", strings.Join(p.opts.Scopes, " "))
+	q.Set("state", flow.State)
+	q.Set("nonce", flow.Nonce)
+	q.Set("code_challenge", flow.Challenge())
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange обменивает код авторизации code на токен ID входа flow и возвращает подтвержденного провайдером пользователя
+func (p *Provider) Exchange(ctx context.Context, code string, flow Flow) (model.OIDCIdentity, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return model.OIDCIdentity{}, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.opts.RedirectURL},
+		"code_verifier": {flow.Verifier},
+	}
+	if p.opts.ClientSecret == "" {
+		form.Set("client_id", p.opts.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return model.OIDCIdentity{}, fmt.Errorf("запрос токена. %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.opts.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.opts.ClientID), url.QueryEscape(p.opts.ClientSecret))
+	}
+	resp := struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}{}
+	status, err := p.do(req, &resp)
+	switch {
+	case err != nil:
+		return model.OIDCIdentity{}, apierror.Wrap(apierror.CodeUnavailable, fmt.Errorf("запрос токена. %w", err))
+	case resp.Error != "":
+		// код недействителен или уже использован - вход нужно начать заново
+		return model.OIDCIdentity{}, apierror.Wrap(apierror.CodeSSOFailed, fmt.Errorf("провайдер отклонил код: %s %s", resp.Error, resp.ErrorDescription)).WithDetails(resp.Error)
+	case status != http.StatusOK:
+		return model.OIDCIdentity{}, apierror.Wrap(apierror.CodeUnavailable, fmt.Errorf("запрос токена: статус %d", status))
+	case resp.IDToken == "":
+		return model.OIDCIdentity{}, apierror.Wrap(apierror.CodeSSOFailed, errors.New("провайдер не вернул токен ID"))
+	}
+	identity, err := p.verify(ctx, resp.IDToken, flow.Nonce)
+	if err != nil {
+		return model.OIDCIdentity{}, err
+	}
+	p.opts.Logger.Info("вход через провайдера", slog.String("sub", identity.Subject), slog.String("email", identity.Email))
+	return identity, nil
+}
+
+// idClaims claims токена ID
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce string `json:"nonce"`
+	Email string `json:"email"`
+	// EmailVerified некоторые провайдеры передают строкой "true"
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+	AZP           string `json:"azp"`
+}
+
+// verify проверяет подпись и claims токена ID и ограничение доменов почты
+func (p *Provider) verify(ctx context.Context, idToken, nonce string) (model.OIDCIdentity, error) {
+	claims := &idClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(signingMethods))
+	if _, err := parser.ParseWithClaims(idToken, claims, p.keyfunc(ctx)); err != nil {
+		if apiErr := apierror.From(err); apiErr.Code == apierror.CodeUnavailable {
+			return model.OIDCIdentity{}, apiErr
+		}
+		return model.OIDCIdentity{}, apierror.Wrap(apierror.CodeSSOFailed, fmt.Errorf("проверка токена ID. %w", err))
+	}
+	invalid := func(details string) (model.OIDCIdentity, error) {
+		return model.OIDCIdentity{}, apierror.Wrap(apierror.CodeSSOFailed, errors.New("токен ID: "+details)).WithDetails(details)
+	}
+	switch {
+	case !claims.VerifyIssuer(p.opts.Issuer, true):
+		return invalid("iss")
+	case !claims.VerifyAudience(p.opts.ClientID, true):
+		return invalid("aud")
+	case len(claims.Audience) > 1 && claims.AZP != p.opts.ClientID:
+		return invalid("azp")
+	case !claims.VerifyExpiresAt(time.Now(), true):
+		return invalid("exp")
+	case nonce == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return invalid("nonce")
+	case claims.Subject == "":
+		return invalid("sub")
+	}
+	identity := model.OIDCIdentity{
+		Issuer:  p.opts.Issuer,
+		Subject: claims.Subject,
+		Email:   strings.ToLower(claims.Email),
+		Name:    claims.Name,
+	}
+	if len(p.opts.AllowedDomains) == 0 {
+		return identity, nil
+	}
+	if identity.Email == "" || !verified(claims.EmailVerified) {
+		return model.OIDCIdentity{}, apierror.New(apierror.CodeForbidden).WithDetails("провайдер не подтвердил почту пользователя")
+	}
+	domain := identity.Email[strings.LastIndex(identity.Email, "@")+1:]
+	if !slices.Contains(p.opts.AllowedDomains, domain) {
+		p.opts.Logger.Warn("вход с почтой неразрешенного домена", slog.String("sub", identity.Subject), slog.String("email", identity.Email))
+		return model.OIDCIdentity{}, apierror.New(apierror.CodeForbidden).WithDetails("вход с почтой домена " + domain + " не разрешен")
+	}
+	return identity, nil
+}
+
+// verified значение email_verified
+func verified(v any) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
+
+// metadata настройки провайдера. загружаются при первом обращении, ошибка загрузки не кэшируется
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(p.opts.Issuer, "/")+discoveryPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("запрос настроек провайдера. %w", err)
+	}
+	meta := &metadata{}
+	status, err := p.do(req, meta)
+	if err == nil && status != http.StatusOK {
+		err = fmt.Errorf("статус %d", status)
+	}
+	if err == nil {
+		err = meta.validate(p.opts.Issuer)
+	}
+	if err != nil {
+		return nil, apierror.Wrap(apierror.CodeUnavailable, fmt.Errorf("загрузка настроек провайдера входа. %w", err))
+	}
+	p.meta = meta
+	return meta, nil
+}
+
+// validate проверяет настройки провайдера issuer
+func (m *metadata) validate(issuer string) error {
+	switch {
+	case m.Issuer != issuer:
+		return fmt.Errorf("провайдер %q вместо %q", m.Issuer, issuer)
+	case m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "":
+		return errors.New("не заданы адреса провайдера")
+	case len(m.CodeChallengeMethods) > 0 && !slices.Contains(m.CodeChallengeMethods, "S256"):
+		return errors.New("провайдер не поддерживает PKCE S256")
+	}
+	return nil
+}
+
+// keyfunc выбирает ключ проверки токена по kid. при неизвестном kid ключи провайдера перечитываются:
+// провайдер мог сменить ключ подписи
+func (p *Provider) keyfunc(ctx context.Context) jwt.Keyfunc {
+	return func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		key, ok := p.key(kid)
+		if !ok && time.Since(p.keysLoaded) >= keysRefreshInterval {
+			if err := p.loadKeys(ctx); err != nil {
+				return nil, err
+			}
+			key, ok = p.key(kid)
+		}
+		if !ok {
+			return nil, fmt.Errorf("неизвестный ключ подписи провайдера %q", kid)
+		}
+		if !matches(key, t.Method.Alg()) {
+			return nil, fmt.Errorf("неожиданный метод подписи: %v", t.Header["alg"])
+		}
+		return key, nil
+	}
+}
+
+// key ключ провайдера kid. токен без kid проверяется единственным ключом провайдера
+func (p *Provider) key(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+// loadKeys загружает ключи провайдера. вызывается под p.mu после загрузки настроек
+func (p *Provider) loadKeys(ctx context.Context) error {
+	if p.meta == nil {
+		return errors.New("настройки провайдера не загружены")
+	}
+	p.keysLoaded = time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.meta.JWKSURI, nil)
+	if err != nil {
+		return fmt.Errorf("запрос ключей провайдера. %w", err)
+	}
+	jwks := model.JWKS{}
+	status, err := p.do(req, &jwks)
+	if err == nil && status != http.StatusOK {
+		err = fmt.Errorf("статус %d", status)
+	}
+	if err != nil {
+		return apierror.Wrap(apierror.CodeUnavailable, fmt.Errorf("загрузка ключей провайдера входа. %w", err))
+	}
+	keys := make(map[string]any, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := parseJWK(jwk)
+		if err != nil {
+			// ключи неизвестных типов пропускаются, токены подписанные ими не принимаются
+			p.opts.Logger.Warn("ключ провайдера входа", slog.String("kid", jwk.Kid), slog.String("ошибка", err.Error()))
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	return nil
+}
+
+// do выполняет запрос к провайдеру и разбирает ответ JSON в v. возвращает статус ответа
+func (p *Provider) do(req *http.Request, v any) (int, error) {
+	resp, err := p.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("чтение ответа. %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("разбор ответа. %w", err)
+	}
+	return resp.StatusCode, nil
+}
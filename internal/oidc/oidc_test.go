@@ -0,0 +1,200 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://localhost:8080/api/user/oidc/callback"
+
+// authorize проходит вход у провайдера и возвращает код авторизации и state из адреса возврата
+func authorize(t *testing.T, p *Provider, flow Flow) (string, string) {
+	authURL, err := p.AuthURL(context.Background(), flow)
+	require.NoError(t, err)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	back, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, redirectURL, back.Scheme+"://"+back.Host+back.Path)
+	return back.Query().Get("code"), back.Query().Get("state")
+}
+
+func newProvider(t *testing.T, issuer *oidctest.Issuer, domains ...string) *Provider {
+	p, err := New(Options{Issuer: issuer.URL, ClientID: issuer.ClientID, RedirectURL: redirectURL, AllowedDomains: domains})
+	require.NoError(t, err)
+	return p
+}
+
+func TestNew(t *testing.T) {
+	for _, opts := range []Options{
+		{Issuer: "", ClientID: "shortener", RedirectURL: redirectURL},
+		{Issuer: "ftp://idp.example.com", ClientID: "shortener", RedirectURL: redirectURL},
+		{Issuer: "https://idp.example.com", RedirectURL: redirectURL},
+		{Issuer: "https://idp.example.com", ClientID: "shortener"},
+	} {
+		_, err := New(opts)
+		assert.Error(t, err, opts)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	ctx := context.Background()
+	issuer, err := oidctest.New("shortener")
+	require.NoError(t, err)
+	defer issuer.Close()
+	p := newProvider(t, issuer, "Example.com")
+
+	issuer.SetUser(oidctest.User{Subject: "42", Email: "Alice@example.com", EmailVerified: true, Name: "Alice"})
+	flow, err := NewFlow()
+	require.NoError(t, err)
+	code, state := authorize(t, p, flow)
+	assert.True(t, flow.CheckState(state))
+	assert.False(t, flow.CheckState(state+"x"))
+	parsed, err := ParseFlow(flow.String())
+	require.NoError(t, err)
+	assert.Equal(t, flow, parsed)
+	_, err = ParseFlow("a..b")
+	assert.Error(t, err)
+	identity, err := p.Exchange(ctx, code, flow)
+	require.NoError(t, err)
+	assert.Equal(t, issuer.URL, identity.Issuer)
+	assert.Equal(t, "42", identity.Subject)
+	assert.Equal(t, "alice@example.com", identity.Email)
+	assert.Equal(t, "Alice", identity.Name)
+
+	// код одноразовый
+	_, err = p.Exchange(ctx, code, flow)
+	assert.Equal(t, apierror.CodeSSOFailed, apierror.From(err).Code)
+
+	// без проверочного кода PKCE провайдер код не обменивает
+	code, _ = authorize(t, p, flow)
+	other, err := NewFlow()
+	require.NoError(t, err)
+	other.Nonce = flow.Nonce
+	_, err = p.Exchange(ctx, code, other)
+	assert.Equal(t, apierror.CodeSSOFailed, apierror.From(err).Code)
+
+	// токен выдан для другого входа
+	code, _ = authorize(t, p, flow)
+	flow2 := flow
+	flow2.Nonce = "other"
+	_, err = p.Exchange(ctx, code, flow2)
+	assert.Equal(t, apierror.CodeSSOFailed, apierror.From(err).Code)
+}
+
+func TestDomains(t *testing.T) {
+	ctx := context.Background()
+	issuer, err := oidctest.New("shortener")
+	require.NoError(t, err)
+	defer issuer.Close()
+	p := newProvider(t, issuer, "example.com")
+	flow, err := NewFlow()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		user     oidctest.User
+		wantCode apierror.Code
+	}{
+		{name: "разрешенный домен", user: oidctest.User{Subject: "1", Email: "bob@example.com", EmailVerified: true}},
+		{name: "другой домен", user: oidctest.User{Subject: "2", Email: "eve@example.org", EmailVerified: true}, wantCode: apierror.CodeForbidden},
+		{name: "поддомен", user: oidctest.User{Subject: "3", Email: "eve@evil.example.com", EmailVerified: true}, wantCode: apierror.CodeForbidden},
+		{name: "почта не подтверждена", user: oidctest.User{Subject: "4", Email: "eve@example.com"}, wantCode: apierror.CodeForbidden},
+		{name: "без почты", user: oidctest.User{Subject: "5", EmailVerified: true}, wantCode: apierror.CodeForbidden},
+	}
+	for _, tt := range tests {
+		issuer.SetUser(tt.user)
+		code, _ := authorize(t, p, flow)
+		_, err := p.Exchange(ctx, code, flow)
+		if tt.wantCode == "" {
+			assert.NoError(t, err, tt.name)
+			continue
+		}
+		assert.Equal(t, tt.wantCode, apierror.From(err).Code, tt.name)
+	}
+
+	// без ограничения доменов почта не обязательна
+	issuer.SetUser(oidctest.User{Subject: "5"})
+	p = newProvider(t, issuer)
+	code, _ := authorize(t, p, flow)
+	_, err = p.Exchange(ctx, code, flow)
+	assert.NoError(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	issuer, err := oidctest.New("shortener")
+	require.NoError(t, err)
+	defer issuer.Close()
+	p := newProvider(t, issuer)
+	_, err = p.metadata(ctx)
+	require.NoError(t, err)
+	user := oidctest.User{Subject: "42"}
+
+	tests := []struct {
+		name   string
+		claims func(jwt.MapClaims)
+	}{
+		{name: "другой провайдер", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "другой клиент", claims: func(c jwt.MapClaims) { c["aud"] = "other" }},
+		{name: "несколько клиентов без azp", claims: func(c jwt.MapClaims) { c["aud"] = []string{"other", "shortener"} }},
+		{name: "истек", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }},
+		{name: "без срока", claims: func(c jwt.MapClaims) { delete(c, "exp") }},
+		{name: "без sub", claims: func(c jwt.MapClaims) { c["sub"] = "" }},
+	}
+	for _, tt := range tests {
+		issuer.Claims = tt.claims
+		token, err := issuer.IDToken(user, "nonce")
+		require.NoError(t, err)
+		_, err = p.verify(ctx, token, "nonce")
+		assert.Equal(t, apierror.CodeSSOFailed, apierror.From(err).Code, tt.name)
+	}
+
+	issuer.Claims = func(c jwt.MapClaims) {
+		c["aud"] = []string{"other", "shortener"}
+		c["azp"] = "shortener"
+	}
+	token, err := issuer.IDToken(user, "nonce")
+	require.NoError(t, err)
+	_, err = p.verify(ctx, token, "nonce")
+	assert.NoError(t, err)
+
+	// токен, подписанный не ключом провайдера
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": issuer.URL, "sub": "42", "aud": "shortener", "nonce": "nonce"})
+	token, err = forged.SignedString([]byte("secret"))
+	require.NoError(t, err)
+	_, err = p.verify(ctx, token, "nonce")
+	assert.Equal(t, apierror.CodeSSOFailed, apierror.From(err).Code)
+}
+
+func TestDiscovery(t *testing.T) {
+	issuer, err := oidctest.New("shortener")
+	require.NoError(t, err)
+	defer issuer.Close()
+	flow, err := NewFlow()
+	require.NoError(t, err)
+
+	// адрес провайдера должен совпадать с issuer из discovery
+	p, err := New(Options{Issuer: issuer.URL + "/", ClientID: "shortener", RedirectURL: redirectURL})
+	require.NoError(t, err)
+	_, err = p.AuthURL(context.Background(), flow)
+	assert.Equal(t, apierror.CodeUnavailable, apierror.From(err).Code)
+
+	// провайдер недоступен
+	issuer.Close()
+	p = newProvider(t, issuer)
+	_, err = p.AuthURL(context.Background(), flow)
+	assert.Equal(t, apierror.CodeUnavailable, apierror.From(err).Code)
+}
@@ -0,0 +1,187 @@
+// пакет oidctest локальный провайдер OpenID Connect для тестов входа: discovery, код авторизации
+// с проверкой PKCE, выдача токена ID и ключи подписи (JWKS)
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/jwtkeys"
+)
+
+// User пользователь, который входит у провайдера при следующей авторизации
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// grant выданный код авторизации
+type grant struct {
+	user        User
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+}
+
+// Issuer провайдер входа. адрес провайдера - URL
+type Issuer struct {
+	*httptest.Server
+	ClientID string
+	// Claims изменяет claims токена ID перед подписью, например, чтобы выдать недействительный токен
+	Claims func(jwt.MapClaims)
+
+	keys  *jwtkeys.Set
+	mu    sync.Mutex
+	user  User
+	codes map[string]grant
+}
+
+// New запускает провайдера для клиента clientID. ключ подписи RS256 создается заново
+func New(clientID string) (*Issuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := jwtkeys.New(jwtkeys.RSA("test", key))
+	if err != nil {
+		return nil, err
+	}
+	i := &Issuer{
+		ClientID: clientID,
+		keys:     keys,
+		codes:    make(map[string]grant),
+		user:     User{Subject: "user", Email: "user@example.com", EmailVerified: true},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("/authorize", i.authorize)
+	mux.HandleFunc("/token", i.token)
+	mux.HandleFunc("/jwks", i.jwks)
+	i.Server = httptest.NewServer(mux)
+	return i, nil
+}
+
+// SetUser устанавливает пользователя, который входит при следующих авторизациях
+func (i *Issuer) SetUser(user User) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.user = user
+}
+
+// IDToken подписывает токен ID с claims пользователя user
+func (i *Issuer) IDToken(user User, nonce string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            i.URL,
+		"sub":            user.Subject,
+		"aud":            i.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"name":           user.Name,
+	}
+	if i.Claims != nil {
+		i.Claims(claims)
+	}
+	return i.keys.Sign(claims)
+}
+
+func (i *Issuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                i.URL,
+		"authorization_endpoint":                i.URL + "/authorize",
+		"token_endpoint":                        i.URL + "/token",
+		"jwks_uri":                              i.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"id_token_signing_alg_values_supported": []string{jwtkeys.AlgRS256},
+	})
+}
+
+// authorize сразу "входит" текущим пользователем и возвращает браузер на redirect_uri с кодом
+func (i *Issuer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("client_id") != i.ClientID ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil || redirect.Host == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	code := uuid.NewString()
+	i.mu.Lock()
+	i.codes[code] = grant{
+		user:        i.user,
+		clientID:    q.Get("client_id"),
+		redirectURI: redirect.String(),
+		nonce:       q.Get("nonce"),
+		challenge:   q.Get("code_challenge"),
+	}
+	i.mu.Unlock()
+	back := redirect.Query()
+	back.Set("code", code)
+	back.Set("state", q.Get("state"))
+	redirect.RawQuery = back.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token обменивает код на токен ID. код одноразовый
+func (i *Issuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	i.mu.Lock()
+	g, ok := i.codes[r.PostForm.Get("code")]
+	delete(i.codes, r.PostForm.Get("code"))
+	i.mu.Unlock()
+	clientID := r.PostForm.Get("client_id")
+	if user, _, basic := r.BasicAuth(); basic {
+		clientID, _ = url.QueryUnescape(user)
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || clientID != g.clientID || r.PostForm.Get("redirect_uri") != g.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	idToken, err := i.IDToken(g.user, g.nonce)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": uuid.NewString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (i *Issuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, i.keys.JWKS())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,212 @@
+// пакет qrcode формирует изображения QR кодов в форматах PNG и SVG
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// Format формат изображения
+type Format string
+
+const (
+	PNG Format = "png"
+	SVG Format = "svg"
+)
+
+const (
+	defaultSize   = 256
+	defaultMargin = 4
+
+	minSize   = 32
+	maxSize   = 2048
+	maxMargin = 16
+)
+
+// ErrOptions ошибка в параметрах QR кода
+var ErrOptions = errors.New("недопустимые параметры QR кода")
+
+// Options параметры изображения QR кода
+type Options struct {
+	// Format формат изображения
+	Format Format
+	// Size примерный размер стороны изображения в пикселях. Итоговый размер кратен количеству модулей
+	Size int
+	// Level уровень коррекции ошибок: L, M, Q или H
+	Level string
+	// Margin размер отступа в модулях
+	Margin int
+	// Foreground цвет модулей
+	Foreground color.RGBA
+	// Background цвет фона
+	Background color.RGBA
+}
+
+// DefaultOptions параметры по умолчанию
+var DefaultOptions = Options{
+	Format:     PNG,
+	Size:       defaultSize,
+	Level:      "M",
+	Margin:     defaultMargin,
+	Foreground: color.RGBA{A: 0xff},
+	Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+}
+
+// ParseOptions получает параметры из параметров запроса values (format, size, level, margin, fg, bg).
+// незаданные параметры берутся из DefaultOptions
+func ParseOptions(values url.Values) (Options, error) {
+	opts := DefaultOptions
+	var err error
+	if v := values.Get("format"); v != "" {
+		opts.Format = Format(strings.ToLower(v))
+	}
+	if v := values.Get("size"); v != "" {
+		if opts.Size, err = strconv.Atoi(v); err != nil {
+			return Options{}, fmt.Errorf("%w: размер \"%s\"", ErrOptions, v)
+		}
+	}
+	if v := values.Get("level"); v != "" {
+		opts.Level = strings.ToUpper(v)
+	}
+	if v := values.Get("margin"); v != "" {
+		if opts.Margin, err = strconv.Atoi(v); err != nil {
+			return Options{}, fmt.Errorf("%w: отступ \"%s\"", ErrOptions, v)
+		}
+	}
+	if v := values.Get("fg"); v != "" {
+		if opts.Foreground, err = ParseColor(v); err != nil {
+			return Options{}, err
+		}
+	}
+	if v := values.Get("bg"); v != "" {
+		if opts.Background, err = ParseColor(v); err != nil {
+			return Options{}, err
+		}
+	}
+	return opts, opts.Validate()
+}
+
+// Validate проверяет параметры
+func (o Options) Validate() error {
+	if o.Format != PNG && o.Format != SVG {
+		return fmt.Errorf("%w: формат \"%s\"", ErrOptions, o.Format)
+	}
+	if o.Size < minSize || o.Size > maxSize {
+		return fmt.Errorf("%w: размер должен быть от %d до %d", ErrOptions, minSize, maxSize)
+	}
+	if o.Margin < 0 || o.Margin > maxMargin {
+		return fmt.Errorf("%w: отступ должен быть от 0 до %d", ErrOptions, maxMargin)
+	}
+	if _, err := level(o.Level); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ContentType возвращает тип содержимого для формата изображения
+func (o Options) ContentType() string {
+	if o.Format == SVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// ParseColor разбирает цвет в формате RRGGBB (допускается # в начале)
+func ParseColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("%w: цвет \"%s\"", ErrOptions, s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("%w: цвет \"%s\"", ErrOptions, s)
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// Encode формирует изображение QR кода для text
+func Encode(text string, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	l, _ := level(opts.Level)
+	code, err := qr.Encode(text, l)
+	if err != nil {
+		return nil, fmt.Errorf("формирование QR кода. %w", err)
+	}
+	modules := code.Size + 2*opts.Margin
+	scale := opts.Size / modules
+	if scale < 1 {
+		scale = 1
+	}
+	if opts.Format == SVG {
+		return encodeSVG(code, opts, modules), nil
+	}
+	return encodePNG(code, opts, modules, scale)
+}
+
+func encodePNG(code *qr.Code, opts Options, modules, scale int) ([]byte, error) {
+	side := modules * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{opts.Background, opts.Foreground})
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Black(x, y) {
+				continue
+			}
+			x0, y0 := (x+opts.Margin)*scale, (y+opts.Margin)*scale
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(x0+dx, y0+dy, 1)
+				}
+			}
+		}
+	}
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("кодирование PNG. %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeSVG формирует векторное изображение, в котором один модуль - единица координат
+func encodeSVG(code *qr.Code, opts Options, modules int) []byte {
+	buf := bytes.Buffer{}
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, opts.Size, opts.Size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/><path fill="%s" d="`, hexColor(opts.Background), hexColor(opts.Foreground))
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if code.Black(x, y) {
+				fmt.Fprintf(&buf, "M%d %dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes()
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func level(s string) (qr.Level, error) {
+	switch s {
+	case "L":
+		return qr.L, nil
+	case "M":
+		return qr.M, nil
+	case "Q":
+		return qr.Q, nil
+	case "H":
+		return qr.H, nil
+	}
+	return 0, fmt.Errorf("%w: уровень коррекции \"%s\"", ErrOptions, s)
+}
@@ -0,0 +1,95 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    Options
+		wantErr bool
+	}{
+		{
+			name:  "по умолчанию",
+			query: "",
+			want:  DefaultOptions,
+		},
+		{
+			name:  "все параметры",
+			query: "format=SVG&size=512&level=h&margin=2&fg=%23ff0000&bg=00ff00",
+			want: Options{
+				Format:     SVG,
+				Size:       512,
+				Level:      "H",
+				Margin:     2,
+				Foreground: color.RGBA{R: 0xff, A: 0xff},
+				Background: color.RGBA{G: 0xff, A: 0xff},
+			},
+		},
+		{name: "формат", query: "format=gif", wantErr: true},
+		{name: "размер", query: "size=10000", wantErr: true},
+		{name: "размер не число", query: "size=big", wantErr: true},
+		{name: "уровень", query: "level=X", wantErr: true},
+		{name: "отступ", query: "margin=-1", wantErr: true},
+		{name: "цвет", query: "fg=red", wantErr: true},
+	}
+	for _, tt := range tests {
+		values, err := url.ParseQuery(tt.query)
+		require.NoError(t, err, tt.name)
+		opts, err := ParseOptions(values)
+		if tt.wantErr {
+			assert.ErrorIs(t, err, ErrOptions, tt.name)
+			continue
+		}
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, opts, tt.name)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	const text = "http://localhost:8080/abcdef"
+
+	// PNG: размер кратен количеству модулей, углы - цвет фона
+	data, err := Encode(text, DefaultOptions)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	size := img.Bounds().Dx()
+	assert.LessOrEqual(t, size, DefaultOptions.Size)
+	assert.Greater(t, size, DefaultOptions.Size/2)
+	r, g, b, _ := img.At(0, 0).RGBA()
+	assert.EqualValues(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, b})
+	// по диагонали проходит левый верхний поисковый узор
+	found := false
+	for x := 0; x < size && !found; x++ {
+		r, _, _, _ := img.At(x, x).RGBA()
+		found = r == 0
+	}
+	assert.True(t, found, "есть темные модули")
+
+	// SVG
+	opts := DefaultOptions
+	opts.Format = SVG
+	opts.Foreground = color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}
+	data, err = Encode(text, opts)
+	require.NoError(t, err)
+	svg := string(data)
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Contains(t, svg, `fill="#123456"`)
+	assert.Contains(t, svg, `width="256"`)
+	assert.Equal(t, "image/svg+xml", opts.ContentType())
+
+	opts.Level = "Z"
+	_, err = Encode(text, opts)
+	assert.ErrorIs(t, err, ErrOptions)
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// quotaStore хранилище, ограничивающее количество ссылок, создаваемых пользователем за сутки (UTC)
+type quotaStore struct {
+	storage.Storager
+	limit int
+	now   func() time.Time
+}
+
+// WithDailyQuota возвращает хранилище, которое перед сохранением ссылок расходует суточную квоту пользователя limit.
+// учитывается каждая ссылка, переданная на сохранение, включая уже сокращенные ранее.
+// если квоты не хватает - ни одна ссылка запроса не сохраняется и возвращается storage.ErrQuotaExceeded.
+// limit <= 0 - без ограничений
+func WithDailyQuota(store storage.Storager, limit int) storage.Storager {
+	if limit <= 0 {
+		return store
+	}
+	return &quotaStore{Storager: store, limit: limit, now: time.Now}
+}
+
+// SaveURL реализация интерфейса Storager
+func (q *quotaStore) SaveURL(ctx context.Context, userID uuid.UUID, real, short string, opts model.LinkOptions) (string, error) {
+	if err := q.ConsumeLinkQuota(ctx, userID, Day(q.now()), 1, q.limit); err != nil {
+		return "", err
+	}
+	return q.Storager.SaveURL(ctx, userID, real, short, opts)
+}
+
+// Batch реализация интерфейса Storager
+func (q *quotaStore) Batch(ctx context.Context, userID uuid.UUID, values model.BatchRequest) (model.BatchResponse, error) {
+	if len(values) > 0 {
+		if err := q.ConsumeLinkQuota(ctx, userID, Day(q.now()), len(values), q.limit); err != nil {
+			return nil, err
+		}
+	}
+	return q.Storager.Batch(ctx, userID, values)
+}
+
+// Day сутки (UTC), к которым относится момент t
+func Day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// UntilNextDay время до начала следующих суток (UTC) - когда квота будет восстановлена
+func UntilNextDay(t time.Time) time.Duration {
+	return Day(t).Add(24 * time.Hour).Sub(t)
+}
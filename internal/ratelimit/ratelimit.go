@@ -0,0 +1,140 @@
+// пакет ratelimit реализует ограничение частоты запросов алгоритмом token bucket.
+// для каждой группы операций (создание, переход, удаление) ведутся отдельные корзины по пользователю и по IP клиента:
+// запрос проходит, только если токен есть во всех его корзинах
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Class группа операций с общим ограничением
+type Class string
+
+const (
+	// Create создание коротких ссылок
+	Create Class = "create"
+	// Redirect переходы по коротким ссылкам
+	Redirect Class = "redirect"
+	// Delete удаление ссылок
+	Delete Class = "delete"
+)
+
+// sweepInterval периодичность удаления корзин, которые успели полностью наполниться
+const sweepInterval = time.Minute
+
+// Limits допустимое количество запросов в минуту для групп операций. 0 или отсутствие группы - без ограничений.
+// в пределах минуты запросы можно делать подряд (размер корзины равен минутному лимиту)
+type Limits map[Class]int
+
+// Limiter ограничитель частоты запросов
+type Limiter struct {
+	limits Limits
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	class Class
+	key   string
+}
+
+// bucket корзина токенов на момент last
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// New создает новый экземпляр Limiter с ограничениями limits
+func New(limits Limits) *Limiter {
+	return &Limiter{
+		limits:  limits,
+		now:     time.Now,
+		buckets: make(map[bucketKey]*bucket),
+	}
+}
+
+// Enabled возвращает true, если для группы class задано ограничение
+func (l *Limiter) Enabled(class Class) bool {
+	return l != nil && l.limits[class] > 0
+}
+
+// Allow проверяет, можно ли выполнить операцию группы class для ключей keys (пользователь, IP клиента),
+// и если можно - расходует по токену из каждой корзины. Если нельзя - возвращает время, через которое можно повторить
+func (l *Limiter) Allow(class Class, keys ...string) (bool, time.Duration) {
+	if !l.Enabled(class) {
+		return true, 0
+	}
+	perMinute := l.limits[class]
+	rate := float64(perMinute) / time.Minute.Seconds()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	buckets := make([]*bucket, 0, len(keys))
+	wait := time.Duration(0)
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		k := bucketKey{class: class, key: key}
+		b, ok := l.buckets[k]
+		if !ok {
+			b = &bucket{tokens: float64(perMinute), last: now}
+			l.buckets[k] = b
+		}
+		b.tokens = math.Min(float64(perMinute), b.tokens+now.Sub(b.last).Seconds()*rate)
+		b.last = now
+		if b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/rate*float64(time.Second)))
+		}
+		buckets = append(buckets, b)
+	}
+	if wait > 0 {
+		return false, wait
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0
+}
+
+// sweep удаляет корзины, которые к моменту now полностью наполнились - они не отличаются от новых
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for k, b := range l.buckets {
+		if now.Sub(b.last) >= time.Minute {
+			delete(l.buckets, k)
+		}
+	}
+}
+
+// UserKey ключ корзины пользователя
+func UserKey(userID string) string {
+	if userID == "" {
+		return ""
+	}
+	return "user:" + userID
+}
+
+// IPKey ключ корзины IP адреса клиента
+func IPKey(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return "ip:" + ip
+}
+
+// RetryAfter значение заголовка Retry-After: целое число секунд, не меньше 1
+func RetryAfter(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
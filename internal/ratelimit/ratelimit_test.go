@@ -0,0 +1,112 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(Limits{Create: 2, Redirect: 60})
+	l.now = func() time.Time { return now }
+
+	user, ip := UserKey("u1"), IPKey("10.0.0.1")
+	ok, _ := l.Allow(Create, user, ip)
+	assert.True(t, ok)
+	ok, _ = l.Allow(Create, user, ip)
+	assert.True(t, ok)
+
+	// корзина пользователя пуста - токен из корзины IP не расходуется
+	ok, wait := l.Allow(Create, user, ip)
+	assert.False(t, ok)
+	assert.Equal(t, 30*time.Second, wait)
+	ok, _ = l.Allow(Create, UserKey("u2"), IPKey("10.0.0.2"))
+	assert.True(t, ok)
+
+	// с того же IP другой пользователь тоже ограничен
+	ok, _ = l.Allow(Create, UserKey("u3"), ip)
+	assert.False(t, ok)
+
+	// группы операций независимы
+	ok, _ = l.Allow(Redirect, user, ip)
+	assert.True(t, ok)
+
+	// для группы без ограничения запросы всегда проходят
+	assert.False(t, l.Enabled(Delete))
+	for i := 0; i < 10; i++ {
+		ok, _ = l.Allow(Delete, user, ip)
+		assert.True(t, ok)
+	}
+
+	// токены восстанавливаются со временем
+	now = now.Add(30 * time.Second)
+	ok, _ = l.Allow(Create, user, ip)
+	assert.True(t, ok)
+
+	// полностью наполнившиеся корзины удаляются
+	now = now.Add(2 * time.Minute)
+	ok, _ = l.Allow(Redirect, user)
+	assert.True(t, ok)
+	assert.Len(t, l.buckets, 1)
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter
+	assert.False(t, l.Enabled(Create))
+	ok, _ := l.Allow(Create, UserKey("u1"))
+	assert.True(t, ok)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, 1, RetryAfter(0))
+	assert.Equal(t, 1, RetryAfter(100*time.Millisecond))
+	assert.Equal(t, 31, RetryAfter(30*time.Second+time.Millisecond))
+}
+
+func TestDay(t *testing.T) {
+	now := time.Date(2024, 1, 1, 23, 30, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Day(now))
+	assert.Equal(t, 30*time.Minute, UntilNextDay(now))
+}
+
+func TestWithDailyQuota(t *testing.T) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// без квоты хранилище не оборачивается
+	assert.Equal(t, store, WithDailyQuota(store, 0))
+
+	q := WithDailyQuota(store, 3)
+	user := uuid.New()
+	_, err = q.SaveURL(ctx, user, "https://go.dev", "a", model.LinkOptions{})
+	require.NoError(t, err)
+
+	batch := model.BatchRequest{
+		{CorrelationID: "1", OriginalURL: "https://go.dev/doc"},
+		{CorrelationID: "2", OriginalURL: "https://go.dev/blog"},
+		{CorrelationID: "3", OriginalURL: "https://go.dev/play"},
+	}
+	_, err = q.Batch(ctx, user, batch)
+	assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+	urls, err := store.UserURLs(ctx, user)
+	require.NoError(t, err)
+	assert.Len(t, urls, 1, "ссылки запроса, превысившего квоту, не сохраняются")
+
+	_, err = q.Batch(ctx, user, batch[:2])
+	require.NoError(t, err)
+	_, err = q.SaveURL(ctx, user, "https://go.dev/help", "c", model.LinkOptions{})
+	assert.ErrorIs(t, err, storage.ErrQuotaExceeded)
+
+	// квоты пользователей независимы
+	_, err = q.SaveURL(ctx, uuid.New(), "https://go.dev/help", "d", model.LinkOptions{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,131 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// accountsSuffix окончание имени файла учетных записей рядом с файлом ссылок
+const accountsSuffix = ".accounts"
+
+// accountRecord учетная запись в файле. в отличие от model.Account сохраняет хэш пароля
+type accountRecord struct {
+	ID           uuid.UUID `json:"user_id"`
+	Login        string    `json:"login"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// CreateAccount memory реализация интерфейса Storager
+func (s *Storage) CreateAccount(ctx context.Context, account model.Account) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if _, ok := s.logins[account.Login]; ok {
+		return storage.ErrAccountExists
+	}
+	if _, ok := s.accounts[account.ID]; ok {
+		return storage.ErrAccountExists
+	}
+	if s.storageFile != "" {
+		if err := saveAccount(s.storageFile+accountsSuffix, account); err != nil {
+			return fmt.Errorf("сохранение учетной записи в файл. %w", err)
+		}
+	}
+	s.accounts[account.ID] = account
+	s.logins[account.Login] = account.ID
+	return nil
+}
+
+// AccountByLogin memory реализация интерфейса Storager
+func (s *Storage) AccountByLogin(ctx context.Context, login string) (model.Account, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	id, ok := s.logins[login]
+	if !ok {
+		return model.Account{}, storage.ErrAccountNotFound
+	}
+	return s.accounts[id], nil
+}
+
+// Account memory реализация интерфейса Storager
+func (s *Storage) Account(ctx context.Context, id uuid.UUID) (model.Account, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	account, ok := s.accounts[id]
+	if !ok {
+		return model.Account{}, storage.ErrAccountNotFound
+	}
+	return account, nil
+}
+
+// ClaimURLs memory реализация интерфейса Storager
+func (s *Storage) ClaimURLs(ctx context.Context, from, to uuid.UUID) (int, error) {
+	s.Mutex.Lock()
+	claimed := 0
+	for short, link := range s.pairs {
+		if link.UserID != from.String() {
+			continue
+		}
+		s.stats.remove(link)
+		link.UserID = to.String()
+		s.stats.add(link)
+		s.pairs[short] = link
+		claimed++
+	}
+	s.Mutex.Unlock()
+
+	if s.storageFile == "" || claimed == 0 {
+		return claimed, nil
+	}
+	return claimed, s.rewriteFile()
+}
+
+// saveAccount дописывает учетную запись в файл fileName
+func saveAccount(fileName string, account model.Account) error {
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("открытие файла %s. %w", fileName, err)
+	}
+	defer file.Close()
+	body, err := json.Marshal(accountRecord(account))
+	if err != nil {
+		return fmt.Errorf("кодирование в JSON. %w", err)
+	}
+	_, err = file.Write(append(body, '\n'))
+	return err
+}
+
+// restoreAccounts восстанавливает учетные записи из файла fileName. если файла нет - учетных записей нет
+func restoreAccounts(fileName string) (map[uuid.UUID]model.Account, error) {
+	accounts := make(map[uuid.UUID]model.Account)
+	file, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return accounts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("восстановление учетных записей из файла. %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		raw := scanner.Bytes()
+		if len(raw) == 0 {
+			continue
+		}
+		record := accountRecord{}
+		if err := json.Unmarshal(raw, &record); err != nil {
+			continue
+		}
+		accounts[record.ID] = model.Account(record)
+	}
+	return accounts, scanner.Err()
+}
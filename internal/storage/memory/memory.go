@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/kTowkA/shortener/internal/model"
@@ -18,6 +20,39 @@ import (
 // Storage memory хранилище для реализации интерфейса Storager
 type Storage struct {
 	pairs map[string]model.StorageJSONWithUserID
+	// jobs задания массового сокращения хранятся только в памяти и не переживают перезапуск
+	jobs map[uuid.UUID]*model.Job
+	// idempotency ответы на запросы с ключами идемпотентности, хранятся только в памяти
+	idempotency map[idempotencyKey]model.IdempotentResponse
+	// quotas количество ссылок, созданных пользователями за сутки
+	quotas map[quotaKey]int
+	// deletes задачи удаления ссылок, хранятся только в памяти и в отличие от БД не переживают перезапуск.
+	// deleteOrder - ожидающие задачи в порядке постановки в очередь
+	deletes     map[uuid.UUID]*model.DeleteTask
+	deleteOrder []uuid.UUID
+	// clicks количество переходов по ссылкам, хранится только в памяти
+	clicks map[string]int64
+	// webhooks подписки на события и их доставки, хранятся только в памяти. порядок - порядок создания
+	webhooks      map[uuid.UUID]model.Webhook
+	webhookOrder  []uuid.UUID
+	deliveries    map[uuid.UUID]*model.WebhookDelivery
+	deliveryOrder []uuid.UUID
+	// thresholds срабатывания подписок на порог переходов по ссылкам
+	thresholds map[thresholdKey]struct{}
+	// blockedUsers пользователи, которым запрещено создавать ссылки, с причиной запрета. audit - журнал действий
+	// администраторов в порядке выполнения. хранятся только в памяти
+	blockedUsers map[uuid.UUID]string
+	audit        []model.AuditRecord
+	// accounts учетные записи пользователей, logins - ID учетной записи по логину.
+	// при работе с файлом сохраняются в отдельном файле рядом с файлом ссылок
+	accounts map[uuid.UUID]model.Account
+	logins   map[string]uuid.UUID
+	// tokens персональные API токены, tokenOrder - порядок создания.
+	// при работе с файлом сохраняются в отдельном файле рядом с файлом ссылок
+	tokens     map[uuid.UUID]model.APIToken
+	tokenOrder []uuid.UUID
+	// stats счетчики статистики сервиса
+	stats *stats
 	sync.Mutex
 	storageFile string
 }
@@ -27,22 +62,56 @@ type Storage struct {
 // возвращает экземпляр Storage и ошибку
 func NewStorage(storageFile string) (*Storage, error) {
 	var (
-		links map[string]model.StorageJSONWithUserID
-		err   error
+		links    map[string]model.StorageJSONWithUserID
+		accounts = make(map[uuid.UUID]model.Account)
+		tokens   = make([]model.APIToken, 0)
+		err      error
 	)
 	if storageFile != "" {
 		links, err = restoreFromFile(storageFile)
 		if err != nil {
 			return nil, fmt.Errorf("создание хранилища. %w", err)
 		}
+		accounts, err = restoreAccounts(storageFile + accountsSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("создание хранилища. %w", err)
+		}
+		tokens, err = restoreTokens(storageFile + tokensSuffix)
+		if err != nil {
+			return nil, fmt.Errorf("создание хранилища. %w", err)
+		}
 	}
 	if links == nil {
 		links = make(map[string]model.StorageJSONWithUserID)
 	}
+	logins := make(map[string]uuid.UUID, len(accounts))
+	for id, account := range accounts {
+		logins[account.Login] = id
+	}
+	tokensByID := make(map[uuid.UUID]model.APIToken, len(tokens))
+	tokenOrder := make([]uuid.UUID, 0, len(tokens))
+	for _, token := range tokens {
+		tokensByID[token.ID] = token
+		tokenOrder = append(tokenOrder, token.ID)
+	}
 	return &Storage{
-		pairs:       links,
-		Mutex:       sync.Mutex{},
-		storageFile: storageFile,
+		pairs:        links,
+		jobs:         make(map[uuid.UUID]*model.Job),
+		idempotency:  make(map[idempotencyKey]model.IdempotentResponse),
+		quotas:       make(map[quotaKey]int),
+		deletes:      make(map[uuid.UUID]*model.DeleteTask),
+		clicks:       make(map[string]int64),
+		webhooks:     make(map[uuid.UUID]model.Webhook),
+		deliveries:   make(map[uuid.UUID]*model.WebhookDelivery),
+		thresholds:   make(map[thresholdKey]struct{}),
+		blockedUsers: make(map[uuid.UUID]string),
+		accounts:     accounts,
+		logins:       logins,
+		tokens:       tokensByID,
+		tokenOrder:   tokenOrder,
+		stats:        newStats(links),
+		Mutex:        sync.Mutex{},
+		storageFile:  storageFile,
 	}, nil
 }
 
@@ -52,7 +121,7 @@ func (s *Storage) Close() error {
 }
 
 // SaveURL memory реализация интерфейса Storager
-func (s *Storage) SaveURL(ctx context.Context, userID uuid.UUID, real, short string) (string, error) {
+func (s *Storage) SaveURL(ctx context.Context, userID uuid.UUID, real, short string, opts model.LinkOptions) (string, error) {
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
 	if _, ok := s.pairs[short]; ok {
@@ -68,8 +137,10 @@ func (s *Storage) SaveURL(ctx context.Context, userID uuid.UUID, real, short str
 			UUID:        uuid.New().String(),
 			ShortURL:    short,
 			OriginalURL: real,
+			LinkOptions: opts,
 		},
 	}
+	s.stats.create(s.pairs[short], time.Now())
 	if s.storageFile == "" {
 		return short, nil
 	}
@@ -88,6 +159,12 @@ func (s *Storage) RealURL(ctx context.Context, short string) (model.StorageJSON,
 		return model.StorageJSON{
 			OriginalURL: real.OriginalURL,
 			IsDeleted:   real.IsDeleted,
+			IsBlocked:   real.IsBlocked,
+			LinkOptions: real.LinkOptions,
+
+			LastStatus:     real.LastStatus,
+			LastCheckError: real.LastCheckError,
+			LastChecked:    real.LastChecked,
 		}, nil
 	}
 	return model.StorageJSON{}, storage.ErrURLNotFound
@@ -150,9 +227,12 @@ func (s *Storage) Batch(ctx context.Context, userID uuid.UUID, values model.Batc
 						UUID:        uuid.New().String(),
 						ShortURL:    v.ShortURL,
 						OriginalURL: v.OriginalURL,
+						LinkOptions: v.LinkOptions,
 					},
 				}
 
+				s.stats.create(s.pairs[v.ShortURL], time.Now())
+				e.ShortURL = v.ShortURL
 				valuesForFile = append(valuesForFile, s.pairs[v.ShortURL])
 			}
 		}
@@ -170,6 +250,25 @@ func (s *Storage) Batch(ctx context.Context, userID uuid.UUID, values model.Batc
 	return result, nil
 }
 
+// UpdateURLOptions memory реализация интерфейса Storager
+func (s *Storage) UpdateURLOptions(ctx context.Context, userID uuid.UUID, short string, opts model.LinkOptions) error {
+	s.Mutex.Lock()
+	val, ok := s.pairs[short]
+	if !ok || val.UserID != userID.String() {
+		s.Mutex.Unlock()
+		return storage.ErrURLNotFound
+	}
+	val.LinkOptions = opts
+	s.pairs[short] = val
+	s.Mutex.Unlock()
+
+	if s.storageFile == "" {
+		return nil
+	}
+
+	return s.rewriteFile()
+}
+
 // UserURLs memory реализация интерфейса Storager
 func (s *Storage) UserURLs(ctx context.Context, userID uuid.UUID) ([]model.StorageJSON, error) {
 	s.Mutex.Lock()
@@ -191,10 +290,72 @@ func (s *Storage) UserURLs(ctx context.Context, userID uuid.UUID) ([]model.Stora
 func (s *Storage) DeleteURLs(ctx context.Context, deleteLinks []model.DeleteURLMessage) error {
 	s.Mutex.Lock()
 	change := false
+	grpErrors := make([]error, 0)
 	for _, v := range deleteLinks {
-		if val, ok := s.pairs[v.ShortURL]; ok && val.UserID == v.UserID {
-			val.IsDeleted = true
-			s.pairs[v.ShortURL] = val
+		val, ok := s.pairs[v.ShortURL]
+		if !ok || val.UserID != v.UserID {
+			grpErrors = append(grpErrors, &storage.DeleteError{Link: v, Err: storage.ErrURLNotFound})
+			continue
+		}
+		if !val.IsDeleted {
+			s.stats.deleted++
+		}
+		val.IsDeleted = true
+		s.pairs[v.ShortURL] = val
+		change = true
+	}
+	s.Mutex.Unlock()
+
+	if s.storageFile != "" && change {
+		if err := s.rewriteFile(); err != nil {
+			return err
+		}
+	}
+	return errors.Join(grpErrors...)
+}
+
+// AllURLs memory реализация интерфейса Storager
+func (s *Storage) AllURLs(ctx context.Context) ([]model.StorageJSONWithUserID, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	results := make([]model.StorageJSONWithUserID, 0, len(s.pairs))
+	for _, v := range s.pairs {
+		results = append(results, v)
+	}
+	return results, nil
+}
+
+// SaveLinkChecks memory реализация интерфейса Storager
+func (s *Storage) SaveLinkChecks(ctx context.Context, checks []model.LinkCheck) error {
+	s.Mutex.Lock()
+	change := false
+	for _, c := range checks {
+		if val, ok := s.pairs[c.ShortURL]; ok {
+			checkedAt := c.CheckedAt
+			val.LastStatus = c.Status
+			val.LastCheckError = c.Error
+			val.LastChecked = &checkedAt
+			s.pairs[c.ShortURL] = val
+			change = true
+		}
+	}
+	s.Mutex.Unlock()
+
+	if s.storageFile == "" || !change {
+		return nil
+	}
+
+	return s.rewriteFile()
+}
+
+// BlockURLs memory реализация интерфейса Storager
+func (s *Storage) BlockURLs(ctx context.Context, shorts []string, blocked bool) error {
+	s.Mutex.Lock()
+	change := false
+	for _, short := range shorts {
+		if val, ok := s.pairs[short]; ok && val.IsBlocked != blocked {
+			val.IsBlocked = blocked
+			s.pairs[short] = val
 			change = true
 		}
 	}
@@ -261,16 +422,492 @@ func (s *Storage) rewriteFile() error {
 	return nil
 }
 
-// Stats memory реализация интерфейса Storager
-func (s *Storage) Stats(ctx context.Context) (model.StatsResponse, error) {
+// CreateJob memory реализация интерфейса Storager
+func (s *Storage) CreateJob(ctx context.Context, job model.Job) error {
 	s.Mutex.Lock()
 	defer s.Mutex.Unlock()
-	result := model.StatsResponse{}
-	result.TotalURLs = len(s.pairs)
-	users := make(map[string]bool)
-	for _, v := range s.pairs {
-		users[v.UserID] = true
+	job.Request = slices.Clone(job.Request)
+	job.Results = slices.Clone(job.Results)
+	s.jobs[job.ID] = &job
+	return nil
+}
+
+// Job memory реализация интерфейса Storager
+func (s *Storage) Job(ctx context.Context, id uuid.UUID) (model.Job, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return model.Job{}, storage.ErrJobNotFound
+	}
+	return copyJob(job), nil
+}
+
+// ClaimJob memory реализация интерфейса Storager
+func (s *Storage) ClaimJob(ctx context.Context) (model.Job, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	var next *model.Job
+	for _, job := range s.jobs {
+		if job.Status != model.JobQueued {
+			continue
+		}
+		if next == nil || job.CreatedAt.Before(next.CreatedAt) {
+			next = job
+		}
+	}
+	if next == nil {
+		return model.Job{}, storage.ErrJobNotFound
+	}
+	next.Status = model.JobRunning
+	next.UpdatedAt = time.Now()
+	return copyJob(next), nil
+}
+
+// UpdateJob memory реализация интерфейса Storager
+func (s *Storage) UpdateJob(ctx context.Context, id uuid.UUID, progress model.JobProgress) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return storage.ErrJobNotFound
+	}
+	job.Apply(progress, time.Now())
+	return nil
+}
+
+// RequeueJobs memory реализация интерфейса Storager
+func (s *Storage) RequeueJobs(ctx context.Context) (int, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	count := 0
+	for _, job := range s.jobs {
+		if job.Status == model.JobRunning {
+			job.Status = model.JobQueued
+			count++
+		}
+	}
+	return count, nil
+}
+
+// PurgeJobs memory реализация интерфейса Storager
+func (s *Storage) PurgeJobs(ctx context.Context, before time.Time) (int, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	count := 0
+	for id, job := range s.jobs {
+		if job.Status.Finished() && job.UpdatedAt.Before(before) {
+			delete(s.jobs, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// idempotencyKey ключ идемпотентности уникален в пределах пользователя
+type idempotencyKey struct {
+	userID uuid.UUID
+	key    string
+}
+
+// IdempotentResponse memory реализация интерфейса Storager
+func (s *Storage) IdempotentResponse(ctx context.Context, userID uuid.UUID, key string) (model.IdempotentResponse, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	resp, ok := s.idempotency[idempotencyKey{userID: userID, key: key}]
+	if !ok || !resp.ExpiresAt.After(time.Now()) {
+		return model.IdempotentResponse{}, storage.ErrKeyNotFound
+	}
+	return resp, nil
+}
+
+// SaveIdempotentResponse memory реализация интерфейса Storager. заодно удаляются ключи с истекшим сроком хранения
+func (s *Storage) SaveIdempotentResponse(ctx context.Context, userID uuid.UUID, key string, resp model.IdempotentResponse) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	now := time.Now()
+	for k, v := range s.idempotency {
+		if !v.ExpiresAt.After(now) {
+			delete(s.idempotency, k)
+		}
+	}
+	s.idempotency[idempotencyKey{userID: userID, key: key}] = resp
+	return nil
+}
+
+// quotaKey учет квоты ведется по пользователю и суткам
+type quotaKey struct {
+	userID uuid.UUID
+	day    time.Time
+}
+
+// ConsumeLinkQuota memory реализация интерфейса Storager. учет за предыдущие сутки удаляется
+func (s *Storage) ConsumeLinkQuota(ctx context.Context, userID uuid.UUID, day time.Time, n, limit int) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	for k := range s.quotas {
+		if k.day.Before(day) {
+			delete(s.quotas, k)
+		}
+	}
+	k := quotaKey{userID: userID, day: day}
+	if s.quotas[k]+n > limit {
+		return storage.ErrQuotaExceeded
+	}
+	s.quotas[k] += n
+	return nil
+}
+
+// EnqueueDelete memory реализация интерфейса Storager
+func (s *Storage) EnqueueDelete(ctx context.Context, task model.DeleteTask) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	task.Results = slices.Clone(task.Results)
+	s.deletes[task.ID] = &task
+	s.deleteOrder = append(s.deleteOrder, task.ID)
+	return nil
+}
+
+// PendingDeletes memory реализация интерфейса Storager
+func (s *Storage) PendingDeletes(ctx context.Context, limit int) ([]model.DeleteTask, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	result := make([]model.DeleteTask, 0)
+	links := 0
+	for _, id := range s.deleteOrder {
+		task := s.deletes[id]
+		if limit > 0 && len(result) > 0 && links >= limit {
+			break
+		}
+		res := *task
+		res.Results = slices.Clone(task.Results)
+		result = append(result, res)
+		links += len(task.Results)
 	}
-	result.TotalUsers = len(users)
 	return result, nil
 }
+
+// CompleteDelete memory реализация интерфейса Storager
+func (s *Storage) CompleteDelete(ctx context.Context, id uuid.UUID, results []model.DeleteResult) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	task, ok := s.deletes[id]
+	if !ok {
+		return storage.ErrTaskNotFound
+	}
+	task.Complete(slices.Clone(results), time.Now())
+	s.deleteOrder = slices.DeleteFunc(s.deleteOrder, func(queued uuid.UUID) bool { return queued == id })
+	return nil
+}
+
+// DeleteTask memory реализация интерфейса Storager
+func (s *Storage) DeleteTask(ctx context.Context, id uuid.UUID) (model.DeleteTask, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	task, ok := s.deletes[id]
+	if !ok {
+		return model.DeleteTask{}, storage.ErrTaskNotFound
+	}
+	res := *task
+	res.Results = slices.Clone(task.Results)
+	return res, nil
+}
+
+// PurgeDeletes memory реализация интерфейса Storager
+func (s *Storage) PurgeDeletes(ctx context.Context, before time.Time) (int, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	count := 0
+	for id, task := range s.deletes {
+		if task.Status == model.JobDone && task.UpdatedAt.Before(before) {
+			delete(s.deletes, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CountClick memory реализация интерфейса Storager
+func (s *Storage) CountClick(ctx context.Context, short string) (model.LinkClicks, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	val, ok := s.pairs[short]
+	if !ok {
+		return model.LinkClicks{}, storage.ErrURLNotFound
+	}
+	userID, err := uuid.Parse(val.UserID)
+	if err != nil {
+		return model.LinkClicks{}, fmt.Errorf("владелец ссылки %s. %w", short, err)
+	}
+	s.clicks[short]++
+	return model.LinkClicks{ShortURL: short, UserID: userID, Clicks: s.clicks[short]}, nil
+}
+
+// SaveWebhook memory реализация интерфейса Storager
+func (s *Storage) SaveWebhook(ctx context.Context, hook model.Webhook) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if _, ok := s.webhooks[hook.ID]; !ok {
+		s.webhookOrder = append(s.webhookOrder, hook.ID)
+	}
+	hook.Events = slices.Clone(hook.Events)
+	s.webhooks[hook.ID] = hook
+	return nil
+}
+
+// Webhook memory реализация интерфейса Storager
+func (s *Storage) Webhook(ctx context.Context, id uuid.UUID) (model.Webhook, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	hook, ok := s.webhooks[id]
+	if !ok {
+		return model.Webhook{}, storage.ErrHookNotFound
+	}
+	hook.Events = slices.Clone(hook.Events)
+	return hook, nil
+}
+
+// UserWebhooks memory реализация интерфейса Storager
+func (s *Storage) UserWebhooks(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	result := make([]model.Webhook, 0)
+	for _, id := range s.webhookOrder {
+		hook := s.webhooks[id]
+		if hook.UserID != userID {
+			continue
+		}
+		hook.Events = slices.Clone(hook.Events)
+		result = append(result, hook)
+	}
+	return result, nil
+}
+
+// DeleteWebhook memory реализация интерфейса Storager
+func (s *Storage) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if _, ok := s.webhooks[id]; !ok {
+		return storage.ErrHookNotFound
+	}
+	delete(s.webhooks, id)
+	for key := range s.thresholds {
+		if key.webhookID == id {
+			delete(s.thresholds, key)
+		}
+	}
+	s.webhookOrder = slices.DeleteFunc(s.webhookOrder, func(v uuid.UUID) bool { return v == id })
+	s.deliveryOrder = slices.DeleteFunc(s.deliveryOrder, func(v uuid.UUID) bool {
+		if s.deliveries[v].WebhookID != id {
+			return false
+		}
+		delete(s.deliveries, v)
+		return true
+	})
+	return nil
+}
+
+// thresholdKey срабатывание подписки webhookID на порог переходов по ссылке short
+type thresholdKey struct {
+	webhookID uuid.UUID
+	short     string
+}
+
+// MarkThreshold memory реализация интерфейса Storager
+func (s *Storage) MarkThreshold(ctx context.Context, webhookID uuid.UUID, short string) (bool, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	key := thresholdKey{webhookID: webhookID, short: short}
+	if _, ok := s.thresholds[key]; ok {
+		return false, nil
+	}
+	s.thresholds[key] = struct{}{}
+	return true, nil
+}
+
+// EnqueueDeliveries memory реализация интерфейса Storager
+func (s *Storage) EnqueueDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	for i := range deliveries {
+		d := deliveries[i]
+		s.deliveries[d.ID] = &d
+		s.deliveryOrder = append(s.deliveryOrder, d.ID)
+	}
+	return nil
+}
+
+// ClaimDeliveries memory реализация интерфейса Storager
+func (s *Storage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	now := time.Now()
+	due := make([]*model.WebhookDelivery, 0)
+	for _, id := range s.deliveryOrder {
+		d := s.deliveries[id]
+		if d.Status == model.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	slices.SortStableFunc(due, func(a, b *model.WebhookDelivery) int {
+		return a.NextAttemptAt.Compare(b.NextAttemptAt)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	result := make([]model.WebhookDelivery, 0, len(due))
+	for _, d := range due {
+		d.NextAttemptAt = now.Add(lease)
+		result = append(result, *d)
+	}
+	return result, nil
+}
+
+// UpdateDelivery memory реализация интерфейса Storager
+func (s *Storage) UpdateDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if _, ok := s.deliveries[delivery.ID]; !ok {
+		return storage.ErrDeliveryNotFound
+	}
+	s.deliveries[delivery.ID] = &delivery
+	return nil
+}
+
+// WebhookDelivery memory реализация интерфейса Storager
+func (s *Storage) WebhookDelivery(ctx context.Context, id uuid.UUID) (model.WebhookDelivery, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return model.WebhookDelivery{}, storage.ErrDeliveryNotFound
+	}
+	return *d, nil
+}
+
+// WebhookDeliveries memory реализация интерфейса Storager
+func (s *Storage) WebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status model.DeliveryStatus, limit int) ([]model.WebhookDelivery, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	result := make([]model.WebhookDelivery, 0)
+	for i := len(s.deliveryOrder) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		d := s.deliveries[s.deliveryOrder[i]]
+		if d.WebhookID != webhookID || (status != "" && d.Status != status) {
+			continue
+		}
+		result = append(result, *d)
+	}
+	return result, nil
+}
+
+// PurgeDeliveries memory реализация интерфейса Storager
+func (s *Storage) PurgeDeliveries(ctx context.Context, before time.Time) (int, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	count := 0
+	s.deliveryOrder = slices.DeleteFunc(s.deliveryOrder, func(v uuid.UUID) bool {
+		d := s.deliveries[v]
+		if d.Status == model.DeliveryPending || !d.UpdatedAt.Before(before) {
+			return false
+		}
+		delete(s.deliveries, v)
+		count++
+		return true
+	})
+	return count, nil
+}
+
+// Link memory реализация интерфейса Storager
+func (s *Storage) Link(ctx context.Context, short string) (model.StorageJSONWithUserID, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	link, ok := s.pairs[short]
+	if !ok {
+		return model.StorageJSONWithUserID{}, storage.ErrURLNotFound
+	}
+	return link, nil
+}
+
+// PurgeURLs memory реализация интерфейса Storager
+func (s *Storage) PurgeURLs(ctx context.Context, shorts []string) ([]model.StorageJSONWithUserID, error) {
+	s.Mutex.Lock()
+	purged := make([]model.StorageJSONWithUserID, 0, len(shorts))
+	for _, short := range shorts {
+		link, ok := s.pairs[short]
+		if !ok {
+			continue
+		}
+		delete(s.pairs, short)
+		delete(s.clicks, short)
+		s.stats.remove(link)
+		purged = append(purged, link)
+	}
+	s.Mutex.Unlock()
+
+	if s.storageFile == "" || len(purged) == 0 {
+		return purged, nil
+	}
+	return purged, s.rewriteFile()
+}
+
+// SetUserBlocked memory реализация интерфейса Storager
+func (s *Storage) SetUserBlocked(ctx context.Context, userID uuid.UUID, blocked bool, reason string) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	if blocked {
+		s.blockedUsers[userID] = reason
+		return nil
+	}
+	delete(s.blockedUsers, userID)
+	return nil
+}
+
+// UserBlocked memory реализация интерфейса Storager
+func (s *Storage) UserBlocked(ctx context.Context, userID uuid.UUID) (bool, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	_, ok := s.blockedUsers[userID]
+	return ok, nil
+}
+
+// SaveAudit memory реализация интерфейса Storager
+func (s *Storage) SaveAudit(ctx context.Context, record model.AuditRecord) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	s.audit = append(s.audit, record)
+	return nil
+}
+
+// AuditLog memory реализация интерфейса Storager
+func (s *Storage) AuditLog(ctx context.Context, limit int) ([]model.AuditRecord, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	result := make([]model.AuditRecord, 0)
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		result = append(result, s.audit[i])
+	}
+	return result, nil
+}
+
+// PurgeAudit memory реализация интерфейса Storager
+func (s *Storage) PurgeAudit(ctx context.Context, before time.Time) (int, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	count := len(s.audit)
+	s.audit = slices.DeleteFunc(s.audit, func(r model.AuditRecord) bool { return r.CreatedAt.Before(before) })
+	return count - len(s.audit), nil
+}
+
+// copyJob копия задания, не разделяющая срезы с хранилищем
+func copyJob(job *model.Job) model.Job {
+	res := *job
+	res.Request = slices.Clone(job.Request)
+	res.Results = slices.Clone(job.Results)
+	return res
+}
@@ -2,6 +2,8 @@ package memory
 
 import (
 	"context"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -83,7 +85,7 @@ func (suite *memorySuite) TestSaveURL() {
 		},
 	}
 	for _, tt := range tests {
-		r, err := suite.SaveURL(ctx, tt.userID, tt.real, tt.short)
+		r, err := suite.SaveURL(ctx, tt.userID, tt.real, tt.short, model.LinkOptions{})
 		suite.EqualValues(tt.expectedError, err, tt.name)
 		suite.EqualValues(tt.expectedValue, r, tt.name)
 	}
@@ -98,7 +100,7 @@ func (suite *memorySuite) TestRealURL() {
 	user := uuid.New()
 
 	// сохраняем
-	_, err := suite.SaveURL(ctx, user, real, short)
+	_, err := suite.SaveURL(ctx, user, real, short, model.LinkOptions{})
 	suite.NoError(err)
 
 	tests := []struct {
@@ -163,6 +165,7 @@ func (suite *memorySuite) TestBatch() {
 			model.BatchResponse{
 				{
 					CorrelationID: "TestBatch_1_1",
+					ShortURL:      "TestBatch_1_2",
 					OriginalURL:   "TestBatch_1_3",
 				},
 			},
@@ -432,19 +435,661 @@ func (suite *memorySuite) TestDeleteURLs() {
 		}
 	}
 }
+func (suite *memorySuite) TestBlockURLs() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := uuid.New()
+	_, err := suite.SaveURL(ctx, user, "TestBlockURLs_1_1", "TestBlockURLs_1_2", model.LinkOptions{})
+	suite.NoError(err)
+	_, err = suite.SaveURL(ctx, user, "TestBlockURLs_2_1", "TestBlockURLs_2_2", model.LinkOptions{})
+	suite.NoError(err)
+
+	// блокируем одну ссылку
+	err = suite.BlockURLs(ctx, []string{"TestBlockURLs_1_2", "TestBlockURLs_not_exist"}, true)
+	suite.NoError(err)
+	resp, err := suite.RealURL(ctx, "TestBlockURLs_1_2")
+	suite.NoError(err)
+	suite.True(resp.IsBlocked)
+	resp, err = suite.RealURL(ctx, "TestBlockURLs_2_2")
+	suite.NoError(err)
+	suite.False(resp.IsBlocked)
+
+	// блокировка видна при получении всех ссылок
+	all, err := suite.AllURLs(ctx)
+	suite.NoError(err)
+	found := false
+	for _, v := range all {
+		if v.ShortURL == "TestBlockURLs_1_2" {
+			found = true
+			suite.True(v.IsBlocked)
+			suite.EqualValues(user.String(), v.UserID)
+		}
+	}
+	suite.True(found)
+
+	// разблокируем
+	err = suite.BlockURLs(ctx, []string{"TestBlockURLs_1_2"}, false)
+	suite.NoError(err)
+	resp, err = suite.RealURL(ctx, "TestBlockURLs_1_2")
+	suite.NoError(err)
+	suite.False(resp.IsBlocked)
+}
+
+func (suite *memorySuite) TestSaveLinkChecks() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := uuid.New()
+	_, err := suite.SaveURL(ctx, user, "TestSaveLinkChecks_1_1", "TestSaveLinkChecks_1_2", model.LinkOptions{})
+	suite.NoError(err)
+
+	checkedAt := time.Now().UTC().Truncate(time.Second)
+	err = suite.SaveLinkChecks(ctx, []model.LinkCheck{
+		{ShortURL: "TestSaveLinkChecks_1_2", Status: 404, CheckedAt: checkedAt},
+		{ShortURL: "TestSaveLinkChecks_not_exist", Status: 200, CheckedAt: checkedAt},
+	})
+	suite.NoError(err)
+
+	resp, err := suite.RealURL(ctx, "TestSaveLinkChecks_1_2")
+	suite.NoError(err)
+	suite.EqualValues(404, resp.LastStatus)
+	suite.Require().NotNil(resp.LastChecked)
+	suite.True(checkedAt.Equal(*resp.LastChecked))
+	suite.True(resp.Broken())
+
+	urls, err := suite.UserURLs(ctx, user)
+	suite.NoError(err)
+	suite.Require().Len(urls, 1)
+	suite.True(urls[0].Broken())
+}
+
+func (suite *memorySuite) TestUpdateURLOptions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := uuid.New()
+	_, err := suite.SaveURL(ctx, user, "TestUpdateURLOptions_1_1", "TestUpdateURLOptions_1_2", model.LinkOptions{})
+	suite.NoError(err)
+
+	opts := model.LinkOptions{Title: "заголовок", Interstitial: true, RedirectCode: 308, Passthrough: true}
+	err = suite.UpdateURLOptions(ctx, user, "TestUpdateURLOptions_1_2", opts)
+	suite.NoError(err)
+	resp, err := suite.RealURL(ctx, "TestUpdateURLOptions_1_2")
+	suite.NoError(err)
+	suite.EqualValues(opts, resp.LinkOptions)
+
+	// чужая или несуществующая ссылка
+	err = suite.UpdateURLOptions(ctx, uuid.New(), "TestUpdateURLOptions_1_2", model.LinkOptions{})
+	suite.ErrorIs(err, storage.ErrURLNotFound)
+	err = suite.UpdateURLOptions(ctx, user, "TestUpdateURLOptions_not_exist", opts)
+	suite.ErrorIs(err, storage.ErrURLNotFound)
+
+	// настройки сохраняются вместе со ссылкой
+	_, err = suite.SaveURL(ctx, user, "TestUpdateURLOptions_2_1", "TestUpdateURLOptions_2_2", opts)
+	suite.NoError(err)
+	resp, err = suite.RealURL(ctx, "TestUpdateURLOptions_2_2")
+	suite.NoError(err)
+	suite.EqualValues(opts, resp.LinkOptions)
+}
+
 func (suite *memorySuite) TestStats() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// тут добавляем одно сохранение, чтобы в базе точно было одно значение
-	_, err := suite.SaveURL(ctx, uuid.New(), "test_stat_1", "test_stat_2")
+	now := time.Now()
+	req := model.StatsRequest{
+		From:        model.StatsHour.Truncate(now).Add(-time.Hour),
+		To:          model.StatsHour.Truncate(now).Add(2 * time.Hour),
+		Granularity: model.StatsHour,
+		Top:         1000,
+	}
+	created := func(stats model.StatsResponse) int {
+		sum := 0
+		for _, p := range stats.Created.Points {
+			sum += p.Links
+		}
+		return sum
+	}
+	before, err := suite.Stats(ctx, req)
+	suite.Require().NoError(err)
+	suite.Len(before.Created.Points, 3)
+
+	// ссылки пользователя на уникальный домен: одна остается, одна удаляется пользователем, одна - окончательно
+	user := uuid.New()
+	domain := user.String() + ".example"
+	for i := 1; i <= 3; i++ {
+		_, err = suite.SaveURL(ctx, user, fmt.Sprintf("https://%s/%d", strings.ToUpper(domain), i), fmt.Sprintf("TestStats_%s_%d", user, i), model.LinkOptions{})
+		suite.Require().NoError(err)
+	}
+	suite.Require().NoError(suite.DeleteURLs(ctx, []model.DeleteURLMessage{{UserID: user.String(), ShortURL: fmt.Sprintf("TestStats_%s_2", user)}}))
+	_, err = suite.PurgeURLs(ctx, []string{fmt.Sprintf("TestStats_%s_3", user)})
+	suite.Require().NoError(err)
+
+	after, err := suite.Stats(ctx, req)
+	suite.Require().NoError(err)
+	suite.Equal(before.TotalUsers+1, after.TotalUsers)
+	suite.Equal(before.TotalURLs+2, after.TotalURLs)
+	suite.Equal(before.DeletedURLs+1, after.DeletedURLs)
+	suite.Equal(before.ActiveURLs+1, after.ActiveURLs)
+	suite.Equal(created(before)+3, created(after), "окончательно удаленные ссылки остаются в ряде созданных")
+	suite.Contains(after.TopUsers, model.StatsCount{Key: model.StatsUserKey(user.String()), Links: 2})
+	suite.Contains(after.TopDomains, model.StatsCount{Key: domain, Links: 2})
+
+	// списки лидеров ограничены и упорядочены
+	req.Top = 1
+	after, err = suite.Stats(ctx, req)
+	suite.Require().NoError(err)
+	suite.Len(after.TopUsers, 1)
+	suite.Len(after.TopDomains, 1)
+}
+func (suite *memorySuite) TestJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := uuid.New()
+	now := time.Now().UTC().Truncate(time.Second)
+	first := model.Job{
+		ID:        uuid.New(),
+		UserID:    user,
+		Status:    model.JobQueued,
+		Total:     3,
+		CreatedAt: now.Add(-time.Minute),
+		UpdatedAt: now.Add(-time.Minute),
+		Request: model.BatchRequest{
+			{CorrelationID: "1", OriginalURL: "https://go.dev"},
+			{CorrelationID: "2", OriginalURL: "go.dev"},
+			{CorrelationID: "3", OriginalURL: "https://pkg.go.dev", LinkOptions: model.LinkOptions{Title: "pkg"}},
+		},
+	}
+	second := first
+	second.ID = uuid.New()
+	second.CreatedAt = now
+	suite.NoError(suite.CreateJob(ctx, first))
+	suite.NoError(suite.CreateJob(ctx, second))
+
+	_, err := suite.Job(ctx, uuid.New())
+	suite.ErrorIs(err, storage.ErrJobNotFound)
+
+	// первым обрабатывается самое раннее задание
+	job, err := suite.ClaimJob(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(first.ID, job.ID)
+	suite.Equal(user, job.UserID)
+	suite.Equal(model.JobRunning, job.Status)
+	suite.Equal(first.Request, job.Request)
+	suite.Empty(job.Results)
+
+	err = suite.UpdateJob(ctx, first.ID, model.JobProgress{Status: model.JobRunning, Results: model.BatchResponse{
+		{CorrelationID: "1", ShortURL: "http://localhost/abc", Status: model.BatchStatusCreated},
+		{CorrelationID: "2", Status: model.BatchStatusInvalid, Reason: "invalid_url"},
+	}})
 	suite.NoError(err)
+	job, err = suite.Job(ctx, first.ID)
+	suite.Require().NoError(err)
+	suite.Equal(2, job.Processed)
+	suite.Equal(1, job.Created)
+	suite.Equal(1, job.Invalid)
+	suite.Require().Len(job.Results, 2)
+	suite.Equal("invalid_url", job.Results[1].Reason)
 
-	stats, err := suite.Stats(ctx)
+	// прерванное задание возвращается в очередь и продолжается с сохраненными результатами
+	count, err := suite.RequeueJobs(ctx)
+	suite.NoError(err)
+	suite.GreaterOrEqual(count, 1)
+	job, err = suite.ClaimJob(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(first.ID, job.ID)
+	suite.Len(job.Results, 2)
+
+	err = suite.UpdateJob(ctx, first.ID, model.JobProgress{Status: model.JobDone, Results: model.BatchResponse{
+		{CorrelationID: "3", ShortURL: "http://localhost/abc", Status: model.BatchStatusExisting},
+	}})
+	suite.NoError(err)
+	job, err = suite.Job(ctx, first.ID)
+	suite.Require().NoError(err)
+	suite.Equal(model.JobDone, job.Status)
+	suite.Equal(3, job.Processed)
+	suite.Equal(1, job.Existing)
+	suite.Equal("3", job.Results[2].CorrelationID)
+
+	job, err = suite.ClaimJob(ctx)
+	suite.Require().NoError(err)
+	suite.Equal(second.ID, job.ID)
+	_, err = suite.ClaimJob(ctx)
+	suite.ErrorIs(err, storage.ErrJobNotFound)
+
+	err = suite.UpdateJob(ctx, uuid.New(), model.JobProgress{Status: model.JobDone})
+	suite.ErrorIs(err, storage.ErrJobNotFound)
+
+	// удаляются только завершенные задания старше срока хранения
+	_, err = suite.PurgeJobs(ctx, time.Now().Add(-time.Hour))
+	suite.NoError(err)
+	_, err = suite.Job(ctx, first.ID)
 	suite.NoError(err)
-	suite.GreaterOrEqual(stats.TotalURLs, 1)
-	suite.GreaterOrEqual(stats.TotalUsers, 1)
+	count, err = suite.PurgeJobs(ctx, time.Now().Add(time.Hour))
+	suite.NoError(err)
+	suite.GreaterOrEqual(count, 1)
+	_, err = suite.Job(ctx, first.ID)
+	suite.ErrorIs(err, storage.ErrJobNotFound)
+	_, err = suite.Job(ctx, second.ID)
+	suite.NoError(err, "задание в обработке не удаляется")
+}
+
+func (suite *memorySuite) TestIdempotentResponse() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := uuid.New()
+	_, err := suite.IdempotentResponse(ctx, user, "key")
+	suite.ErrorIs(err, storage.ErrKeyNotFound)
+
+	resp := model.IdempotentResponse{
+		Fingerprint: "fp",
+		Status:      201,
+		Header:      map[string]string{"Content-Type": "application/json"},
+		Body:        []byte(`{"result":"http://localhost/abc"}`),
+		ExpiresAt:   time.Now().Add(time.Hour).UTC().Truncate(time.Second),
+	}
+	suite.NoError(suite.SaveIdempotentResponse(ctx, user, "key", resp))
+	got, err := suite.IdempotentResponse(ctx, user, "key")
+	suite.Require().NoError(err)
+	suite.Equal(resp.Fingerprint, got.Fingerprint)
+	suite.Equal(resp.Status, got.Status)
+	suite.Equal(resp.Header, got.Header)
+	suite.Equal(resp.Body, got.Body)
+	suite.True(resp.ExpiresAt.Equal(got.ExpiresAt))
+
+	// ключ другого пользователя
+	_, err = suite.IdempotentResponse(ctx, uuid.New(), "key")
+	suite.ErrorIs(err, storage.ErrKeyNotFound)
+
+	// ключ с истекшим сроком хранения
+	resp.ExpiresAt = time.Now().Add(-time.Second)
+	suite.NoError(suite.SaveIdempotentResponse(ctx, user, "expired", resp))
+	_, err = suite.IdempotentResponse(ctx, user, "expired")
+	suite.ErrorIs(err, storage.ErrKeyNotFound)
 }
+
 func TestMemorySuite(t *testing.T) {
 	suite.Run(t, new(memorySuite))
 }
+
+func (suite *memorySuite) TestConsumeLinkQuota() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := uuid.New()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	suite.NoError(suite.ConsumeLinkQuota(ctx, user, today, 2, 3))
+	suite.NoError(suite.ConsumeLinkQuota(ctx, user, today, 1, 3))
+	suite.ErrorIs(suite.ConsumeLinkQuota(ctx, user, today, 1, 3), storage.ErrQuotaExceeded)
+
+	// запрос больше остатка квоты не расходует ее
+	other := uuid.New()
+	suite.ErrorIs(suite.ConsumeLinkQuota(ctx, other, today, 4, 3), storage.ErrQuotaExceeded)
+	suite.NoError(suite.ConsumeLinkQuota(ctx, other, today, 3, 3))
+
+	// на следующие сутки квота восстанавливается
+	suite.NoError(suite.ConsumeLinkQuota(ctx, user, today.Add(24*time.Hour), 3, 3))
+}
+
+func (suite *memorySuite) TestDeleteTasks() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := suite.DeleteTask(ctx, uuid.New())
+	suite.ErrorIs(err, storage.ErrTaskNotFound)
+
+	user := uuid.New()
+	newTask := func(shorts ...string) model.DeleteTask {
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		task := model.DeleteTask{ID: uuid.New(), UserID: user, Status: model.JobQueued, Total: len(shorts), CreatedAt: now, UpdatedAt: now}
+		for _, short := range shorts {
+			task.Results = append(task.Results, model.DeleteResult{ShortURL: short, Status: model.DeletePending})
+		}
+		suite.Require().NoError(suite.EnqueueDelete(ctx, task))
+		return task
+	}
+	first := newTask("TestDeleteTasks_1", "TestDeleteTasks_2")
+	second := newTask("TestDeleteTasks_3")
+
+	// задачи возвращаются в порядке постановки, пока не наберется limit ссылок
+	pending, err := suite.PendingDeletes(ctx, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(pending, 1)
+	suite.Equal(first.ID, pending[0].ID)
+	suite.Equal(user, pending[0].UserID)
+	suite.Equal(first.Results, pending[0].Results)
+	pending, err = suite.PendingDeletes(ctx, 0)
+	suite.Require().NoError(err)
+	ids := make([]uuid.UUID, 0)
+	for _, task := range pending {
+		ids = append(ids, task.ID)
+	}
+	suite.Contains(ids, first.ID)
+	suite.Contains(ids, second.ID)
+
+	results := []model.DeleteResult{
+		{ShortURL: "TestDeleteTasks_1", Status: model.DeleteDone},
+		{ShortURL: "TestDeleteTasks_2", Status: model.DeleteFailed, Reason: "internal"},
+	}
+	suite.Require().NoError(suite.CompleteDelete(ctx, first.ID, results))
+	got, err := suite.DeleteTask(ctx, first.ID)
+	suite.Require().NoError(err)
+	suite.Equal(model.JobDone, got.Status)
+	suite.Equal(1, got.Deleted)
+	suite.Equal(1, got.Failed)
+	suite.Equal(results, got.Results)
+
+	pending, err = suite.PendingDeletes(ctx, 0)
+	suite.Require().NoError(err)
+	for _, task := range pending {
+		suite.NotEqual(first.ID, task.ID)
+	}
+	suite.ErrorIs(suite.CompleteDelete(ctx, uuid.New(), nil), storage.ErrTaskNotFound)
+
+	// удаляются только выполненные задачи старше срока хранения
+	_, err = suite.PurgeDeletes(ctx, time.Now().Add(-time.Hour))
+	suite.NoError(err)
+	_, err = suite.DeleteTask(ctx, first.ID)
+	suite.NoError(err)
+	count, err := suite.PurgeDeletes(ctx, time.Now().Add(time.Hour))
+	suite.NoError(err)
+	suite.GreaterOrEqual(count, 1)
+	_, err = suite.DeleteTask(ctx, first.ID)
+	suite.ErrorIs(err, storage.ErrTaskNotFound)
+	_, err = suite.DeleteTask(ctx, second.ID)
+	suite.NoError(err, "ожидающая задача не удаляется")
+}
+
+func (suite *memorySuite) TestWebhooks() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// переходы считаются по существующим ссылкам
+	_, err := suite.CountClick(ctx, "TestWebhooks_missing")
+	suite.ErrorIs(err, storage.ErrURLNotFound)
+	user := uuid.New()
+	_, err = suite.SaveURL(ctx, user, "https://go.dev/TestWebhooks", "TestWebhooks", model.LinkOptions{})
+	suite.Require().NoError(err)
+	for i := int64(1); i <= 2; i++ {
+		clicks, err := suite.CountClick(ctx, "TestWebhooks")
+		suite.Require().NoError(err)
+		suite.Equal(model.LinkClicks{ShortURL: "TestWebhooks", UserID: user, Clicks: i}, clicks)
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	hook := model.Webhook{
+		ID:        uuid.New(),
+		UserID:    user,
+		URL:       "https://example.com/hook",
+		Events:    []model.EventType{model.EventLinkCreated, model.EventClickThreshold},
+		Secret:    "secret",
+		CreatedAt: now,
+	}
+	_, err = suite.Webhook(ctx, hook.ID)
+	suite.ErrorIs(err, storage.ErrHookNotFound)
+	suite.Require().NoError(suite.SaveWebhook(ctx, hook))
+	got, err := suite.Webhook(ctx, hook.ID)
+	suite.Require().NoError(err)
+	suite.Equal(hook.Events, got.Events)
+	suite.Equal(hook.Secret, got.Secret)
+	hooks, err := suite.UserWebhooks(ctx, user)
+	suite.Require().NoError(err)
+	suite.Require().Len(hooks, 1)
+	suite.Equal(hook.ID, hooks[0].ID)
+
+	// подписка срабатывает на порог переходов по каждой ссылке один раз
+	first, err := suite.MarkThreshold(ctx, hook.ID, "TestWebhooks")
+	suite.Require().NoError(err)
+	suite.True(first)
+	first, err = suite.MarkThreshold(ctx, hook.ID, "TestWebhooks")
+	suite.Require().NoError(err)
+	suite.False(first)
+	first, err = suite.MarkThreshold(ctx, hook.ID, "TestWebhooks_other")
+	suite.Require().NoError(err)
+	suite.True(first)
+
+	newDelivery := func(next time.Time) model.WebhookDelivery {
+		return model.WebhookDelivery{
+			ID:            uuid.New(),
+			WebhookID:     hook.ID,
+			Event:         model.Event{ID: uuid.New(), Type: model.EventLinkCreated, ShortURL: "TestWebhooks", CreatedAt: now},
+			Status:        model.DeliveryPending,
+			NextAttemptAt: next,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+	}
+	due := newDelivery(now.Add(-time.Minute))
+	later := newDelivery(now.Add(time.Hour))
+	suite.Require().NoError(suite.EnqueueDeliveries(ctx, []model.WebhookDelivery{due, later}))
+
+	// выданная доставка не выдается повторно до истечения аренды
+	claimed, err := suite.ClaimDeliveries(ctx, 10, time.Minute)
+	suite.Require().NoError(err)
+	ids := make([]uuid.UUID, 0)
+	for _, d := range claimed {
+		ids = append(ids, d.ID)
+	}
+	suite.Contains(ids, due.ID)
+	suite.NotContains(ids, later.ID)
+	claimed, err = suite.ClaimDeliveries(ctx, 10, time.Minute)
+	suite.Require().NoError(err)
+	for _, d := range claimed {
+		suite.NotEqual(due.ID, d.ID)
+	}
+
+	due.Status = model.DeliveryDead
+	due.Attempts = 3
+	due.ResponseStatus = 500
+	due.Error = "500 Internal Server Error"
+	suite.Require().NoError(suite.UpdateDelivery(ctx, due))
+	suite.ErrorIs(suite.UpdateDelivery(ctx, newDelivery(now)), storage.ErrDeliveryNotFound)
+	delivery, err := suite.WebhookDelivery(ctx, due.ID)
+	suite.Require().NoError(err)
+	suite.Equal(model.DeliveryDead, delivery.Status)
+	suite.Equal(3, delivery.Attempts)
+	suite.Equal(due.Event.ID, delivery.Event.ID)
+
+	// журнал начиная с последних, с отбором по состоянию
+	deliveries, err := suite.WebhookDeliveries(ctx, hook.ID, "", 0)
+	suite.Require().NoError(err)
+	suite.Require().Len(deliveries, 2)
+	suite.Equal(later.ID, deliveries[0].ID)
+	deliveries, err = suite.WebhookDeliveries(ctx, hook.ID, model.DeliveryDead, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(deliveries, 1)
+	suite.Equal(due.ID, deliveries[0].ID)
+
+	// удаляются только завершенные доставки старше срока хранения
+	_, err = suite.PurgeDeliveries(ctx, time.Now().Add(-time.Hour))
+	suite.Require().NoError(err)
+	_, err = suite.WebhookDelivery(ctx, due.ID)
+	suite.NoError(err)
+	count, err := suite.PurgeDeliveries(ctx, time.Now().Add(time.Hour))
+	suite.Require().NoError(err)
+	suite.GreaterOrEqual(count, 1)
+	_, err = suite.WebhookDelivery(ctx, due.ID)
+	suite.ErrorIs(err, storage.ErrDeliveryNotFound)
+	_, err = suite.WebhookDelivery(ctx, later.ID)
+	suite.NoError(err)
+
+	// журнал удаляется вместе с подпиской
+	suite.Require().NoError(suite.DeleteWebhook(ctx, hook.ID))
+	suite.ErrorIs(suite.DeleteWebhook(ctx, hook.ID), storage.ErrHookNotFound)
+	_, err = suite.WebhookDelivery(ctx, due.ID)
+	suite.ErrorIs(err, storage.ErrDeliveryNotFound)
+}
+
+func (suite *memorySuite) TestAdmin() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user := uuid.New()
+	_, err := suite.SaveURL(ctx, user, "https://go.dev/TestAdmin", "TestAdmin", model.LinkOptions{})
+	suite.Require().NoError(err)
+	_, err = suite.SaveURL(ctx, user, "https://go.dev/TestAdmin_purge", "TestAdmin_purge", model.LinkOptions{})
+	suite.Require().NoError(err)
+
+	link, err := suite.Link(ctx, "TestAdmin")
+	suite.Require().NoError(err)
+	suite.Equal(user.String(), link.UserID)
+	suite.Equal("https://go.dev/TestAdmin", link.OriginalURL)
+	_, err = suite.Link(ctx, "TestAdmin_missing")
+	suite.ErrorIs(err, storage.ErrURLNotFound)
+
+	// окончательно удаляются только существующие ссылки
+	purged, err := suite.PurgeURLs(ctx, []string{"TestAdmin_purge", "TestAdmin_missing"})
+	suite.Require().NoError(err)
+	suite.Require().Len(purged, 1)
+	suite.Equal("TestAdmin_purge", purged[0].ShortURL)
+	suite.Equal(user.String(), purged[0].UserID)
+	_, err = suite.Link(ctx, "TestAdmin_purge")
+	suite.ErrorIs(err, storage.ErrURLNotFound)
+
+	blocked, err := suite.UserBlocked(ctx, user)
+	suite.Require().NoError(err)
+	suite.False(blocked)
+	suite.Require().NoError(suite.SetUserBlocked(ctx, user, true, "спам"))
+	suite.Require().NoError(suite.SetUserBlocked(ctx, user, true, "повторно"))
+	blocked, err = suite.UserBlocked(ctx, user)
+	suite.Require().NoError(err)
+	suite.True(blocked)
+	suite.Require().NoError(suite.SetUserBlocked(ctx, user, false, ""))
+	blocked, err = suite.UserBlocked(ctx, user)
+	suite.Require().NoError(err)
+	suite.False(blocked)
+
+	// журнал начиная с последних
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	first := model.AuditRecord{ID: uuid.New(), Action: model.AuditUserBlock, Actor: "test", Target: user.String(), Details: "спам", Result: "ok", CreatedAt: now}
+	second := model.AuditRecord{ID: uuid.New(), Action: model.AuditLinksPurge, Actor: "test", Target: "TestAdmin_purge", Result: "ok", CreatedAt: now}
+	suite.Require().NoError(suite.SaveAudit(ctx, first))
+	suite.Require().NoError(suite.SaveAudit(ctx, second))
+	records, err := suite.AuditLog(ctx, 2)
+	suite.Require().NoError(err)
+	suite.Require().Len(records, 2)
+	suite.Equal(second.ID, records[0].ID)
+	suite.Equal(first.ID, records[1].ID)
+	suite.Equal(first.Details, records[1].Details)
+	suite.True(first.CreatedAt.Equal(records[1].CreatedAt))
+
+	// удаляются только записи старше срока хранения
+	old := model.AuditRecord{ID: uuid.New(), Action: model.AuditLinkLookup, Actor: "test", Target: "TestAdmin_old", Result: "ok", CreatedAt: now.Add(-48 * time.Hour)}
+	suite.Require().NoError(suite.SaveAudit(ctx, old))
+	count, err := suite.PurgeAudit(ctx, now.Add(-24*time.Hour))
+	suite.Require().NoError(err)
+	suite.GreaterOrEqual(count, 1)
+	records, err = suite.AuditLog(ctx, 0)
+	suite.Require().NoError(err)
+	ids := make([]uuid.UUID, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+	suite.NotContains(ids, old.ID)
+	suite.Contains(ids, first.ID)
+	suite.Contains(ids, second.ID)
+}
+
+func (suite *memorySuite) TestAccounts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	file := suite.T().TempDir() + "/links.json"
+	st, err := NewStorage(file)
+	suite.Require().NoError(err)
+
+	account := model.Account{ID: uuid.New(), Login: "alice", PasswordHash: "hash", CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	suite.Require().NoError(st.CreateAccount(ctx, account))
+	suite.ErrorIs(st.CreateAccount(ctx, model.Account{ID: uuid.New(), Login: "alice"}), storage.ErrAccountExists)
+	suite.ErrorIs(st.CreateAccount(ctx, model.Account{ID: account.ID, Login: "bob"}), storage.ErrAccountExists)
+	_, err = st.AccountByLogin(ctx, "bob")
+	suite.ErrorIs(err, storage.ErrAccountNotFound)
+	_, err = st.Account(ctx, uuid.New())
+	suite.ErrorIs(err, storage.ErrAccountNotFound)
+
+	// ссылки анонимного пользователя передаются вместе со статистикой
+	anonymous := uuid.New()
+	_, err = st.SaveURL(ctx, anonymous, "https://go.dev", "TestAccounts_1", model.LinkOptions{})
+	suite.Require().NoError(err)
+	_, err = st.SaveURL(ctx, anonymous, "https://go.dev/doc", "TestAccounts_2", model.LinkOptions{})
+	suite.Require().NoError(err)
+	claimed, err := st.ClaimURLs(ctx, anonymous, account.ID)
+	suite.Require().NoError(err)
+	suite.Equal(2, claimed)
+	claimed, err = st.ClaimURLs(ctx, anonymous, account.ID)
+	suite.Require().NoError(err)
+	suite.Zero(claimed)
+	stats, err := st.Stats(ctx, model.StatsRequest{Top: 10})
+	suite.Require().NoError(err)
+	suite.Equal(1, stats.TotalUsers)
+	suite.Equal([]model.StatsCount{{Key: model.StatsUserKey(account.ID.String()), Links: 2}}, stats.TopUsers)
+
+	// учетные записи и владельцы ссылок восстанавливаются из файла
+	restored, err := NewStorage(file)
+	suite.Require().NoError(err)
+	got, err := restored.AccountByLogin(ctx, "alice")
+	suite.Require().NoError(err)
+	suite.Equal(account.ID, got.ID)
+	suite.Equal("hash", got.PasswordHash)
+	suite.True(account.CreatedAt.Equal(got.CreatedAt))
+	got, err = restored.Account(ctx, account.ID)
+	suite.Require().NoError(err)
+	suite.Equal("alice", got.Login)
+	links, err := restored.UserURLs(ctx, account.ID)
+	suite.Require().NoError(err)
+	suite.Len(links, 2)
+}
+
+func (suite *memorySuite) TestAPITokens() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	file := suite.T().TempDir() + "/links.json"
+	st, err := NewStorage(file)
+	suite.Require().NoError(err)
+
+	userID := uuid.New()
+	expires := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+	first := model.APIToken{ID: uuid.New(), UserID: userID, Name: "ci", Scopes: []model.Scope{model.ScopeLinksRead}, Token: "secret", Hash: "hash_1", CreatedAt: time.Now().UTC().Truncate(time.Second), ExpiresAt: &expires}
+	second := model.APIToken{ID: uuid.New(), UserID: userID, Name: "backup", Scopes: []model.Scope{model.ScopeLinksRead, model.ScopeLinksDelete}, Hash: "hash_2", CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	suite.Require().NoError(st.SaveAPIToken(ctx, first))
+	suite.Require().NoError(st.SaveAPIToken(ctx, second))
+	suite.Require().NoError(st.SaveAPIToken(ctx, model.APIToken{ID: uuid.New(), UserID: uuid.New(), Name: "other", Hash: "hash_3"}))
+
+	got, err := st.APIToken(ctx, "hash_1")
+	suite.Require().NoError(err)
+	suite.Equal(first.ID, got.ID)
+	suite.Equal(userID, got.UserID)
+	// значение токена не хранится
+	suite.Empty(got.Token)
+	_, err = st.APIToken(ctx, "hash_missing")
+	suite.ErrorIs(err, storage.ErrAPITokenNotFound)
+
+	tokens, err := st.UserAPITokens(ctx, userID)
+	suite.Require().NoError(err)
+	suite.Require().Len(tokens, 2)
+	suite.Equal(first.ID, tokens[0].ID)
+	suite.Equal(second.ID, tokens[1].ID)
+
+	// чужой токен не удаляется
+	suite.ErrorIs(st.DeleteAPIToken(ctx, uuid.New(), first.ID), storage.ErrAPITokenNotFound)
+	suite.Require().NoError(st.DeleteAPIToken(ctx, userID, first.ID))
+	suite.ErrorIs(st.DeleteAPIToken(ctx, userID, first.ID), storage.ErrAPITokenNotFound)
+	_, err = st.APIToken(ctx, "hash_1")
+	suite.ErrorIs(err, storage.ErrAPITokenNotFound)
+
+	// токены восстанавливаются из файла без отозванных
+	restored, err := NewStorage(file)
+	suite.Require().NoError(err)
+	tokens, err = restored.UserAPITokens(ctx, userID)
+	suite.Require().NoError(err)
+	suite.Require().Len(tokens, 1)
+	suite.Equal(second.ID, tokens[0].ID)
+	suite.Equal("hash_2", tokens[0].Hash)
+	suite.Equal(second.Scopes, tokens[0].Scopes)
+	suite.Nil(tokens[0].ExpiresAt)
+	suite.True(second.CreatedAt.Equal(tokens[0].CreatedAt))
+}
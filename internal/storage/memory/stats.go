@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// stats счетчики статистики, изменяемые вместе со ссылками под блокировкой хранилища
+type stats struct {
+	urls    int
+	deleted int
+	// users и domains количество ссылок пользователей и доменов оригинальных ссылок, без нулевых значений
+	users   map[string]int
+	domains map[string]int
+	// created количество ссылок, созданных в каждый час (ключ - начало часа в UTC). хранится только в памяти
+	created map[time.Time]int
+}
+
+func newStats(links map[string]model.StorageJSONWithUserID) *stats {
+	st := &stats{
+		users:   make(map[string]int),
+		domains: make(map[string]int),
+		created: make(map[time.Time]int),
+	}
+	// время создания восстановленных из файла ссылок неизвестно, в ряд созданных ссылок они не попадают
+	for _, link := range links {
+		st.add(link)
+	}
+	return st
+}
+
+// add учитывает сохраненную ссылку
+func (st *stats) add(link model.StorageJSONWithUserID) {
+	st.urls++
+	if link.IsDeleted {
+		st.deleted++
+	}
+	st.users[link.UserID]++
+	st.domains[model.Domain(link.OriginalURL)]++
+}
+
+// create учитывает новую ссылку, созданную в момент now
+func (st *stats) create(link model.StorageJSONWithUserID, now time.Time) {
+	st.add(link)
+	st.created[model.StatsHour.Truncate(now)]++
+}
+
+// remove учитывает окончательное удаление ссылки
+func (st *stats) remove(link model.StorageJSONWithUserID) {
+	st.urls--
+	if link.IsDeleted {
+		st.deleted--
+	}
+	decrement(st.users, link.UserID)
+	decrement(st.domains, model.Domain(link.OriginalURL))
+}
+
+func decrement(counts map[string]int, key string) {
+	if counts[key] <= 1 {
+		delete(counts, key)
+		return
+	}
+	counts[key]--
+}
+
+// top не более n ключей с наибольшим количеством ссылок
+func top(counts map[string]int, n int) []model.StatsCount {
+	result := make([]model.StatsCount, 0, len(counts))
+	for key, links := range counts {
+		result = append(result, model.StatsCount{Key: key, Links: links})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Links != result[j].Links {
+			return result[i].Links > result[j].Links
+		}
+		return result[i].Key < result[j].Key
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// Stats memory реализация интерфейса Storager
+func (s *Storage) Stats(ctx context.Context, req model.StatsRequest) (model.StatsResponse, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	hourly := make(map[time.Time]int)
+	for hour, links := range s.stats.created {
+		if !hour.Before(req.From) && hour.Before(req.To) {
+			hourly[hour] = links
+		}
+	}
+	users := top(s.stats.users, req.Top)
+	for i := range users {
+		users[i].Key = model.StatsUserKey(users[i].Key)
+	}
+	return model.StatsResponse{
+		TotalUsers:  len(s.stats.users),
+		TotalURLs:   s.stats.urls,
+		ActiveURLs:  s.stats.urls - s.stats.deleted,
+		DeletedURLs: s.stats.deleted,
+		Created:     req.Series(hourly),
+		TopDomains:  top(s.stats.domains, req.Top),
+		TopUsers:    users,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// tokensSuffix окончание имени файла API токенов рядом с файлом ссылок
+const tokensSuffix = ".tokens"
+
+// tokenRecord API токен в файле. в отличие от model.APIToken сохраняет владельца и хэш
+type tokenRecord struct {
+	ID        uuid.UUID     `json:"id"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Name      string        `json:"name"`
+	Scopes    []model.Scope `json:"scopes"`
+	Token     string        `json:"-"`
+	Hash      string        `json:"hash"`
+	CreatedAt time.Time     `json:"created_at"`
+	ExpiresAt *time.Time    `json:"expires_at,omitempty"`
+}
+
+// SaveAPIToken memory реализация интерфейса Storager
+func (s *Storage) SaveAPIToken(ctx context.Context, token model.APIToken) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	token.Token = ""
+	token.Scopes = slices.Clone(token.Scopes)
+	if _, ok := s.tokens[token.ID]; !ok {
+		s.tokenOrder = append(s.tokenOrder, token.ID)
+	}
+	s.tokens[token.ID] = token
+	return s.saveTokens()
+}
+
+// APIToken memory реализация интерфейса Storager
+func (s *Storage) APIToken(ctx context.Context, hash string) (model.APIToken, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	for _, id := range s.tokenOrder {
+		if token := s.tokens[id]; token.Hash == hash {
+			token.Scopes = slices.Clone(token.Scopes)
+			return token, nil
+		}
+	}
+	return model.APIToken{}, storage.ErrAPITokenNotFound
+}
+
+// UserAPITokens memory реализация интерфейса Storager
+func (s *Storage) UserAPITokens(ctx context.Context, userID uuid.UUID) ([]model.APIToken, error) {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	result := make([]model.APIToken, 0)
+	for _, id := range s.tokenOrder {
+		if token := s.tokens[id]; token.UserID == userID {
+			token.Scopes = slices.Clone(token.Scopes)
+			result = append(result, token)
+		}
+	}
+	return result, nil
+}
+
+// DeleteAPIToken memory реализация интерфейса Storager
+func (s *Storage) DeleteAPIToken(ctx context.Context, userID, id uuid.UUID) error {
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+	token, ok := s.tokens[id]
+	if !ok || token.UserID != userID {
+		return storage.ErrAPITokenNotFound
+	}
+	delete(s.tokens, id)
+	s.tokenOrder = slices.DeleteFunc(s.tokenOrder, func(v uuid.UUID) bool { return v == id })
+	return s.saveTokens()
+}
+
+// saveTokens перезаписывает файл API токенов. вызывается под блокировкой хранилища
+func (s *Storage) saveTokens() error {
+	if s.storageFile == "" {
+		return nil
+	}
+	fileName := s.storageFile + tokensSuffix
+	file, err := os.OpenFile(fileName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("открытие файла %s. %w", fileName, err)
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	for _, id := range s.tokenOrder {
+		body, err := json.Marshal(tokenRecord(s.tokens[id]))
+		if err != nil {
+			return fmt.Errorf("кодирование в JSON. %w", err)
+		}
+		_, _ = w.Write(append(body, '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("сохранение API токенов в файл. %w", err)
+	}
+	return nil
+}
+
+// restoreTokens восстанавливает API токены из файла fileName в порядке создания. если файла нет - токенов нет
+func restoreTokens(fileName string) ([]model.APIToken, error) {
+	tokens := make([]model.APIToken, 0)
+	file, err := os.Open(fileName)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("восстановление API токенов из файла. %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		raw := scanner.Bytes()
+		if len(raw) == 0 {
+			continue
+		}
+		record := tokenRecord{}
+		if err := json.Unmarshal(raw, &record); err != nil {
+			continue
+		}
+		tokens = append(tokens, model.APIToken(record))
+	}
+	return tokens, scanner.Err()
+}
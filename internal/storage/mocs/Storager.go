@@ -8,6 +8,8 @@ import (
 	model "github.com/kTowkA/shortener/internal/model"
 	mock "github.com/stretchr/testify/mock"
 
+	time "time"
+
 	uuid "github.com/google/uuid"
 )
 
@@ -16,6 +18,150 @@ type Storager struct {
 	mock.Mock
 }
 
+// APIToken provides a mock function with given fields: ctx, hash
+func (_m *Storager) APIToken(ctx context.Context, hash string) (model.APIToken, error) {
+	ret := _m.Called(ctx, hash)
+
+	if len(ret) == 0 {
+		panic("no return value specified for APIToken")
+	}
+
+	var r0 model.APIToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (model.APIToken, error)); ok {
+		return rf(ctx, hash)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) model.APIToken); ok {
+		r0 = rf(ctx, hash)
+	} else {
+		r0 = ret.Get(0).(model.APIToken)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, hash)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Account provides a mock function with given fields: ctx, id
+func (_m *Storager) Account(ctx context.Context, id uuid.UUID) (model.Account, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Account")
+	}
+
+	var r0 model.Account
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (model.Account, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) model.Account); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(model.Account)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AccountByLogin provides a mock function with given fields: ctx, login
+func (_m *Storager) AccountByLogin(ctx context.Context, login string) (model.Account, error) {
+	ret := _m.Called(ctx, login)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AccountByLogin")
+	}
+
+	var r0 model.Account
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (model.Account, error)); ok {
+		return rf(ctx, login)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) model.Account); ok {
+		r0 = rf(ctx, login)
+	} else {
+		r0 = ret.Get(0).(model.Account)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, login)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AllURLs provides a mock function with given fields: ctx
+func (_m *Storager) AllURLs(ctx context.Context) ([]model.StorageJSONWithUserID, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AllURLs")
+	}
+
+	var r0 []model.StorageJSONWithUserID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) ([]model.StorageJSONWithUserID, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) []model.StorageJSONWithUserID); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.StorageJSONWithUserID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AuditLog provides a mock function with given fields: ctx, limit
+func (_m *Storager) AuditLog(ctx context.Context, limit int) ([]model.AuditRecord, error) {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for AuditLog")
+	}
+
+	var r0 []model.AuditRecord
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]model.AuditRecord, error)); ok {
+		return rf(ctx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []model.AuditRecord); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.AuditRecord)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Batch provides a mock function with given fields: ctx, userID, values
 func (_m *Storager) Batch(ctx context.Context, userID uuid.UUID, values model.BatchRequest) (model.BatchResponse, error) {
 	ret := _m.Called(ctx, userID, values)
@@ -46,6 +192,110 @@ func (_m *Storager) Batch(ctx context.Context, userID uuid.UUID, values model.Ba
 	return r0, r1
 }
 
+// BlockURLs provides a mock function with given fields: ctx, shorts, blocked
+func (_m *Storager) BlockURLs(ctx context.Context, shorts []string, blocked bool) error {
+	ret := _m.Called(ctx, shorts, blocked)
+
+	if len(ret) == 0 {
+		panic("no return value specified for BlockURLs")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string, bool) error); ok {
+		r0 = rf(ctx, shorts, blocked)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ClaimDeliveries provides a mock function with given fields: ctx, limit, lease
+func (_m *Storager) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	ret := _m.Called(ctx, limit, lease)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimDeliveries")
+	}
+
+	var r0 []model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Duration) ([]model.WebhookDelivery, error)); ok {
+		return rf(ctx, limit, lease)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int, time.Duration) []model.WebhookDelivery); ok {
+		r0 = rf(ctx, limit, lease)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int, time.Duration) error); ok {
+		r1 = rf(ctx, limit, lease)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimJob provides a mock function with given fields: ctx
+func (_m *Storager) ClaimJob(ctx context.Context) (model.Job, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimJob")
+	}
+
+	var r0 model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (model.Job, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) model.Job); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(model.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimURLs provides a mock function with given fields: ctx, from, to
+func (_m *Storager) ClaimURLs(ctx context.Context, from uuid.UUID, to uuid.UUID) (int, error) {
+	ret := _m.Called(ctx, from, to)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ClaimURLs")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) (int, error)); ok {
+		return rf(ctx, from, to)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) int); ok {
+		r0 = rf(ctx, from, to)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r1 = rf(ctx, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Close provides a mock function with given fields:
 func (_m *Storager) Close() error {
 	ret := _m.Called()
@@ -64,17 +314,17 @@ func (_m *Storager) Close() error {
 	return r0
 }
 
-// DeleteURLs provides a mock function with given fields: ctx, deleteLinks
-func (_m *Storager) DeleteURLs(ctx context.Context, deleteLinks []model.DeleteURLMessage) error {
-	ret := _m.Called(ctx, deleteLinks)
+// CompleteDelete provides a mock function with given fields: ctx, id, results
+func (_m *Storager) CompleteDelete(ctx context.Context, id uuid.UUID, results []model.DeleteResult) error {
+	ret := _m.Called(ctx, id, results)
 
 	if len(ret) == 0 {
-		panic("no return value specified for DeleteURLs")
+		panic("no return value specified for CompleteDelete")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, []model.DeleteURLMessage) error); ok {
-		r0 = rf(ctx, deleteLinks)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, []model.DeleteResult) error); ok {
+		r0 = rf(ctx, id, results)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -82,17 +332,17 @@ func (_m *Storager) DeleteURLs(ctx context.Context, deleteLinks []model.DeleteUR
 	return r0
 }
 
-// Ping provides a mock function with given fields: ctx
-func (_m *Storager) Ping(ctx context.Context) error {
-	ret := _m.Called(ctx)
+// ConsumeLinkQuota provides a mock function with given fields: ctx, userID, day, n, limit
+func (_m *Storager) ConsumeLinkQuota(ctx context.Context, userID uuid.UUID, day time.Time, n int, limit int) error {
+	ret := _m.Called(ctx, userID, day, n, limit)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Ping")
+		panic("no return value specified for ConsumeLinkQuota")
 	}
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, time.Time, int, int) error); ok {
+		r0 = rf(ctx, userID, day, n, limit)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -100,23 +350,23 @@ func (_m *Storager) Ping(ctx context.Context) error {
 	return r0
 }
 
-// RealURL provides a mock function with given fields: ctx, short
-func (_m *Storager) RealURL(ctx context.Context, short string) (model.StorageJSON, error) {
+// CountClick provides a mock function with given fields: ctx, short
+func (_m *Storager) CountClick(ctx context.Context, short string) (model.LinkClicks, error) {
 	ret := _m.Called(ctx, short)
 
 	if len(ret) == 0 {
-		panic("no return value specified for RealURL")
+		panic("no return value specified for CountClick")
 	}
 
-	var r0 model.StorageJSON
+	var r0 model.LinkClicks
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, string) (model.StorageJSON, error)); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, string) (model.LinkClicks, error)); ok {
 		return rf(ctx, short)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, string) model.StorageJSON); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, string) model.LinkClicks); ok {
 		r0 = rf(ctx, short)
 	} else {
-		r0 = ret.Get(0).(model.StorageJSON)
+		r0 = ret.Get(0).(model.LinkClicks)
 	}
 
 	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
@@ -128,55 +378,81 @@ func (_m *Storager) RealURL(ctx context.Context, short string) (model.StorageJSO
 	return r0, r1
 }
 
-// SaveURL provides a mock function with given fields: ctx, userID, real, short
-func (_m *Storager) SaveURL(ctx context.Context, userID uuid.UUID, real string, short string) (string, error) {
-	ret := _m.Called(ctx, userID, real, short)
+// CreateAccount provides a mock function with given fields: ctx, account
+func (_m *Storager) CreateAccount(ctx context.Context, account model.Account) error {
+	ret := _m.Called(ctx, account)
 
 	if len(ret) == 0 {
-		panic("no return value specified for SaveURL")
+		panic("no return value specified for CreateAccount")
 	}
 
-	var r0 string
-	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, string) (string, error)); ok {
-		return rf(ctx, userID, real, short)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Account) error); ok {
+		r0 = rf(ctx, account)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// CreateJob provides a mock function with given fields: ctx, job
+func (_m *Storager) CreateJob(ctx context.Context, job model.Job) error {
+	ret := _m.Called(ctx, job)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CreateJob")
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, string) string); ok {
-		r0 = rf(ctx, userID, real, short)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Job) error); ok {
+		r0 = rf(ctx, job)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteAPIToken provides a mock function with given fields: ctx, userID, id
+func (_m *Storager) DeleteAPIToken(ctx context.Context, userID uuid.UUID, id uuid.UUID) error {
+	ret := _m.Called(ctx, userID, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteAPIToken")
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string, string) error); ok {
-		r1 = rf(ctx, userID, real, short)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, uuid.UUID) error); ok {
+		r0 = rf(ctx, userID, id)
 	} else {
-		r1 = ret.Error(1)
+		r0 = ret.Error(0)
 	}
 
-	return r0, r1
+	return r0
 }
 
-// Stats provides a mock function with given fields: ctx
-func (_m *Storager) Stats(ctx context.Context) (model.StatsResponse, error) {
-	ret := _m.Called(ctx)
+// DeleteTask provides a mock function with given fields: ctx, id
+func (_m *Storager) DeleteTask(ctx context.Context, id uuid.UUID) (model.DeleteTask, error) {
+	ret := _m.Called(ctx, id)
 
 	if len(ret) == 0 {
-		panic("no return value specified for Stats")
+		panic("no return value specified for DeleteTask")
 	}
 
-	var r0 model.StatsResponse
+	var r0 model.DeleteTask
 	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context) (model.StatsResponse, error)); ok {
-		return rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (model.DeleteTask, error)); ok {
+		return rf(ctx, id)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context) model.StatsResponse); ok {
-		r0 = rf(ctx)
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) model.DeleteTask); ok {
+		r0 = rf(ctx, id)
 	} else {
-		r0 = ret.Get(0).(model.StatsResponse)
+		r0 = ret.Get(0).(model.DeleteTask)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
-		r1 = rf(ctx)
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -184,29 +460,851 @@ func (_m *Storager) Stats(ctx context.Context) (model.StatsResponse, error) {
 	return r0, r1
 }
 
-// UserURLs provides a mock function with given fields: ctx, userID
-func (_m *Storager) UserURLs(ctx context.Context, userID uuid.UUID) ([]model.StorageJSON, error) {
-	ret := _m.Called(ctx, userID)
+// DeleteURLs provides a mock function with given fields: ctx, deleteLinks
+func (_m *Storager) DeleteURLs(ctx context.Context, deleteLinks []model.DeleteURLMessage) error {
+	ret := _m.Called(ctx, deleteLinks)
 
 	if len(ret) == 0 {
-		panic("no return value specified for UserURLs")
+		panic("no return value specified for DeleteURLs")
 	}
 
-	var r0 []model.StorageJSON
-	var r1 error
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]model.StorageJSON, error)); ok {
-		return rf(ctx, userID)
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []model.DeleteURLMessage) error); ok {
+		r0 = rf(ctx, deleteLinks)
+	} else {
+		r0 = ret.Error(0)
 	}
-	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []model.StorageJSON); ok {
-		r0 = rf(ctx, userID)
+
+	return r0
+}
+
+// DeleteWebhook provides a mock function with given fields: ctx, id
+func (_m *Storager) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for DeleteWebhook")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) error); ok {
+		r0 = rf(ctx, id)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.StorageJSON)
-		}
+		r0 = ret.Error(0)
 	}
 
-	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
-		r1 = rf(ctx, userID)
+	return r0
+}
+
+// EnqueueDelete provides a mock function with given fields: ctx, task
+func (_m *Storager) EnqueueDelete(ctx context.Context, task model.DeleteTask) error {
+	ret := _m.Called(ctx, task)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnqueueDelete")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.DeleteTask) error); ok {
+		r0 = rf(ctx, task)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EnqueueDeliveries provides a mock function with given fields: ctx, deliveries
+func (_m *Storager) EnqueueDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	ret := _m.Called(ctx, deliveries)
+
+	if len(ret) == 0 {
+		panic("no return value specified for EnqueueDeliveries")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []model.WebhookDelivery) error); ok {
+		r0 = rf(ctx, deliveries)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// IdempotentResponse provides a mock function with given fields: ctx, userID, key
+func (_m *Storager) IdempotentResponse(ctx context.Context, userID uuid.UUID, key string) (model.IdempotentResponse, error) {
+	ret := _m.Called(ctx, userID, key)
+
+	if len(ret) == 0 {
+		panic("no return value specified for IdempotentResponse")
+	}
+
+	var r0 model.IdempotentResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) (model.IdempotentResponse, error)); ok {
+		return rf(ctx, userID, key)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) model.IdempotentResponse); ok {
+		r0 = rf(ctx, userID, key)
+	} else {
+		r0 = ret.Get(0).(model.IdempotentResponse)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string) error); ok {
+		r1 = rf(ctx, userID, key)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Job provides a mock function with given fields: ctx, id
+func (_m *Storager) Job(ctx context.Context, id uuid.UUID) (model.Job, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Job")
+	}
+
+	var r0 model.Job
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (model.Job, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) model.Job); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(model.Job)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Link provides a mock function with given fields: ctx, short
+func (_m *Storager) Link(ctx context.Context, short string) (model.StorageJSONWithUserID, error) {
+	ret := _m.Called(ctx, short)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Link")
+	}
+
+	var r0 model.StorageJSONWithUserID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (model.StorageJSONWithUserID, error)); ok {
+		return rf(ctx, short)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) model.StorageJSONWithUserID); ok {
+		r0 = rf(ctx, short)
+	} else {
+		r0 = ret.Get(0).(model.StorageJSONWithUserID)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, short)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkThreshold provides a mock function with given fields: ctx, webhookID, short
+func (_m *Storager) MarkThreshold(ctx context.Context, webhookID uuid.UUID, short string) (bool, error) {
+	ret := _m.Called(ctx, webhookID, short)
+
+	if len(ret) == 0 {
+		panic("no return value specified for MarkThreshold")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) (bool, error)); ok {
+		return rf(ctx, webhookID, short)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string) bool); ok {
+		r0 = rf(ctx, webhookID, short)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string) error); ok {
+		r1 = rf(ctx, webhookID, short)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PendingDeletes provides a mock function with given fields: ctx, limit
+func (_m *Storager) PendingDeletes(ctx context.Context, limit int) ([]model.DeleteTask, error) {
+	ret := _m.Called(ctx, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PendingDeletes")
+	}
+
+	var r0 []model.DeleteTask
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int) ([]model.DeleteTask, error)); ok {
+		return rf(ctx, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int) []model.DeleteTask); ok {
+		r0 = rf(ctx, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.DeleteTask)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int) error); ok {
+		r1 = rf(ctx, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Ping provides a mock function with given fields: ctx
+func (_m *Storager) Ping(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Ping")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// PurgeAudit provides a mock function with given fields: ctx, before
+func (_m *Storager) PurgeAudit(ctx context.Context, before time.Time) (int, error) {
+	ret := _m.Called(ctx, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeAudit")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int, error)); ok {
+		return rf(ctx, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeletes provides a mock function with given fields: ctx, before
+func (_m *Storager) PurgeDeletes(ctx context.Context, before time.Time) (int, error) {
+	ret := _m.Called(ctx, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeDeletes")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int, error)); ok {
+		return rf(ctx, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeDeliveries provides a mock function with given fields: ctx, before
+func (_m *Storager) PurgeDeliveries(ctx context.Context, before time.Time) (int, error) {
+	ret := _m.Called(ctx, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeDeliveries")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int, error)); ok {
+		return rf(ctx, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeJobs provides a mock function with given fields: ctx, before
+func (_m *Storager) PurgeJobs(ctx context.Context, before time.Time) (int, error) {
+	ret := _m.Called(ctx, before)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeJobs")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) (int, error)); ok {
+		return rf(ctx, before)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time) int); ok {
+		r0 = rf(ctx, before)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time) error); ok {
+		r1 = rf(ctx, before)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PurgeURLs provides a mock function with given fields: ctx, shorts
+func (_m *Storager) PurgeURLs(ctx context.Context, shorts []string) ([]model.StorageJSONWithUserID, error) {
+	ret := _m.Called(ctx, shorts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PurgeURLs")
+	}
+
+	var r0 []model.StorageJSONWithUserID
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []string) ([]model.StorageJSONWithUserID, error)); ok {
+		return rf(ctx, shorts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []string) []model.StorageJSONWithUserID); ok {
+		r0 = rf(ctx, shorts)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.StorageJSONWithUserID)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = rf(ctx, shorts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RealURL provides a mock function with given fields: ctx, short
+func (_m *Storager) RealURL(ctx context.Context, short string) (model.StorageJSON, error) {
+	ret := _m.Called(ctx, short)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RealURL")
+	}
+
+	var r0 model.StorageJSON
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (model.StorageJSON, error)); ok {
+		return rf(ctx, short)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) model.StorageJSON); ok {
+		r0 = rf(ctx, short)
+	} else {
+		r0 = ret.Get(0).(model.StorageJSON)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, short)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RequeueJobs provides a mock function with given fields: ctx
+func (_m *Storager) RequeueJobs(ctx context.Context) (int, error) {
+	ret := _m.Called(ctx)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RequeueJobs")
+	}
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context) (int, error)); ok {
+		return rf(ctx)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context) int); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveAPIToken provides a mock function with given fields: ctx, token
+func (_m *Storager) SaveAPIToken(ctx context.Context, token model.APIToken) error {
+	ret := _m.Called(ctx, token)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveAPIToken")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.APIToken) error); ok {
+		r0 = rf(ctx, token)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveAudit provides a mock function with given fields: ctx, record
+func (_m *Storager) SaveAudit(ctx context.Context, record model.AuditRecord) error {
+	ret := _m.Called(ctx, record)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveAudit")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.AuditRecord) error); ok {
+		r0 = rf(ctx, record)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveIdempotentResponse provides a mock function with given fields: ctx, userID, key, resp
+func (_m *Storager) SaveIdempotentResponse(ctx context.Context, userID uuid.UUID, key string, resp model.IdempotentResponse) error {
+	ret := _m.Called(ctx, userID, key, resp)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveIdempotentResponse")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, model.IdempotentResponse) error); ok {
+		r0 = rf(ctx, userID, key, resp)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveLinkChecks provides a mock function with given fields: ctx, checks
+func (_m *Storager) SaveLinkChecks(ctx context.Context, checks []model.LinkCheck) error {
+	ret := _m.Called(ctx, checks)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveLinkChecks")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []model.LinkCheck) error); ok {
+		r0 = rf(ctx, checks)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SaveURL provides a mock function with given fields: ctx, userID, real, short, opts
+func (_m *Storager) SaveURL(ctx context.Context, userID uuid.UUID, real string, short string, opts model.LinkOptions) (string, error) {
+	ret := _m.Called(ctx, userID, real, short, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveURL")
+	}
+
+	var r0 string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, string, model.LinkOptions) (string, error)); ok {
+		return rf(ctx, userID, real, short, opts)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, string, model.LinkOptions) string); ok {
+		r0 = rf(ctx, userID, real, short, opts)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, string, string, model.LinkOptions) error); ok {
+		r1 = rf(ctx, userID, real, short, opts)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// SaveWebhook provides a mock function with given fields: ctx, hook
+func (_m *Storager) SaveWebhook(ctx context.Context, hook model.Webhook) error {
+	ret := _m.Called(ctx, hook)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveWebhook")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.Webhook) error); ok {
+		r0 = rf(ctx, hook)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// SetUserBlocked provides a mock function with given fields: ctx, userID, blocked, reason
+func (_m *Storager) SetUserBlocked(ctx context.Context, userID uuid.UUID, blocked bool, reason string) error {
+	ret := _m.Called(ctx, userID, blocked, reason)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SetUserBlocked")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, bool, string) error); ok {
+		r0 = rf(ctx, userID, blocked, reason)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Stats provides a mock function with given fields: ctx, req
+func (_m *Storager) Stats(ctx context.Context, req model.StatsRequest) (model.StatsResponse, error) {
+	ret := _m.Called(ctx, req)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Stats")
+	}
+
+	var r0 model.StatsResponse
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.StatsRequest) (model.StatsResponse, error)); ok {
+		return rf(ctx, req)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, model.StatsRequest) model.StatsResponse); ok {
+		r0 = rf(ctx, req)
+	} else {
+		r0 = ret.Get(0).(model.StatsResponse)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, model.StatsRequest) error); ok {
+		r1 = rf(ctx, req)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateDelivery provides a mock function with given fields: ctx, delivery
+func (_m *Storager) UpdateDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	ret := _m.Called(ctx, delivery)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateDelivery")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, model.WebhookDelivery) error); ok {
+		r0 = rf(ctx, delivery)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateJob provides a mock function with given fields: ctx, id, progress
+func (_m *Storager) UpdateJob(ctx context.Context, id uuid.UUID, progress model.JobProgress) error {
+	ret := _m.Called(ctx, id, progress)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateJob")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, model.JobProgress) error); ok {
+		r0 = rf(ctx, id, progress)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateURLOptions provides a mock function with given fields: ctx, userID, short, opts
+func (_m *Storager) UpdateURLOptions(ctx context.Context, userID uuid.UUID, short string, opts model.LinkOptions) error {
+	ret := _m.Called(ctx, userID, short, opts)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateURLOptions")
+	}
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, string, model.LinkOptions) error); ok {
+		r0 = rf(ctx, userID, short, opts)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UserAPITokens provides a mock function with given fields: ctx, userID
+func (_m *Storager) UserAPITokens(ctx context.Context, userID uuid.UUID) ([]model.APIToken, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UserAPITokens")
+	}
+
+	var r0 []model.APIToken
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]model.APIToken, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []model.APIToken); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.APIToken)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserBlocked provides a mock function with given fields: ctx, userID
+func (_m *Storager) UserBlocked(ctx context.Context, userID uuid.UUID) (bool, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UserBlocked")
+	}
+
+	var r0 bool
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (bool, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) bool); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserURLs provides a mock function with given fields: ctx, userID
+func (_m *Storager) UserURLs(ctx context.Context, userID uuid.UUID) ([]model.StorageJSON, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UserURLs")
+	}
+
+	var r0 []model.StorageJSON
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]model.StorageJSON, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []model.StorageJSON); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.StorageJSON)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UserWebhooks provides a mock function with given fields: ctx, userID
+func (_m *Storager) UserWebhooks(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error) {
+	ret := _m.Called(ctx, userID)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UserWebhooks")
+	}
+
+	var r0 []model.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) ([]model.Webhook, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) []model.Webhook); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.Webhook)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Webhook provides a mock function with given fields: ctx, id
+func (_m *Storager) Webhook(ctx context.Context, id uuid.UUID) (model.Webhook, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Webhook")
+	}
+
+	var r0 model.Webhook
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (model.Webhook, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) model.Webhook); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(model.Webhook)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WebhookDeliveries provides a mock function with given fields: ctx, webhookID, status, limit
+func (_m *Storager) WebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status model.DeliveryStatus, limit int) ([]model.WebhookDelivery, error) {
+	ret := _m.Called(ctx, webhookID, status, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WebhookDeliveries")
+	}
+
+	var r0 []model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, model.DeliveryStatus, int) ([]model.WebhookDelivery, error)); ok {
+		return rf(ctx, webhookID, status, limit)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID, model.DeliveryStatus, int) []model.WebhookDelivery); ok {
+		r0 = rf(ctx, webhookID, status, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID, model.DeliveryStatus, int) error); ok {
+		r1 = rf(ctx, webhookID, status, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WebhookDelivery provides a mock function with given fields: ctx, id
+func (_m *Storager) WebhookDelivery(ctx context.Context, id uuid.UUID) (model.WebhookDelivery, error) {
+	ret := _m.Called(ctx, id)
+
+	if len(ret) == 0 {
+		panic("no return value specified for WebhookDelivery")
+	}
+
+	var r0 model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) (model.WebhookDelivery, error)); ok {
+		return rf(ctx, id)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, uuid.UUID) model.WebhookDelivery); ok {
+		r0 = rf(ctx, id)
+	} else {
+		r0 = ret.Get(0).(model.WebhookDelivery)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, uuid.UUID) error); ok {
+		r1 = rf(ctx, id)
 	} else {
 		r1 = ret.Error(1)
 	}
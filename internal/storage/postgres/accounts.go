@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// CreateAccount реализация интерфейса Storager
+func (p *PostgresStorage) CreateAccount(ctx context.Context, account model.Account) error {
+	tag, err := p.Exec(
+		ctx,
+		"INSERT INTO accounts(user_id,login,password_hash,created_at) VALUES($1,$2,$3,$4) ON CONFLICT DO NOTHING",
+		account.ID,
+		account.Login,
+		account.PasswordHash,
+		account.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("сохранение учетной записи. %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrAccountExists
+	}
+	return nil
+}
+
+// AccountByLogin реализация интерфейса Storager
+func (p *PostgresStorage) AccountByLogin(ctx context.Context, login string) (model.Account, error) {
+	return scanAccount(p.QueryRow(ctx, "SELECT user_id,login,password_hash,created_at FROM accounts WHERE login=$1", login))
+}
+
+// Account реализация интерфейса Storager
+func (p *PostgresStorage) Account(ctx context.Context, id uuid.UUID) (model.Account, error) {
+	return scanAccount(p.QueryRow(ctx, "SELECT user_id,login,password_hash,created_at FROM accounts WHERE user_id=$1", id))
+}
+
+// ClaimURLs реализация интерфейса Storager. счетчики статистики пересчитывает триггер url_list
+func (p *PostgresStorage) ClaimURLs(ctx context.Context, from, to uuid.UUID) (int, error) {
+	tag, err := p.Exec(ctx, "UPDATE url_list SET user_id=$2 WHERE user_id=$1", from, to)
+	if err != nil {
+		return 0, fmt.Errorf("передача ссылок пользователя. %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// scanAccount сканирует строку учетной записи
+func scanAccount(row pgx.Row) (model.Account, error) {
+	a := model.Account{}
+	err := row.Scan(&a.ID, &a.Login, &a.PasswordHash, &a.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Account{}, storage.ErrAccountNotFound
+	}
+	if err != nil {
+		return model.Account{}, fmt.Errorf("получение учетной записи. %w", err)
+	}
+	return a, nil
+}
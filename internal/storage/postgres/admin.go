@@ -0,0 +1,134 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// linkColumns колонки ссылки в порядке сканирования scanLink
+const linkColumns = "uuid,user_id,short_url,original_url,is_deleted,is_blocked,title,interstitial,redirect_code,passthrough,last_status,last_check_error,last_checked"
+
+// Link реализация интерфейса Storager
+func (p *PostgresStorage) Link(ctx context.Context, short string) (model.StorageJSONWithUserID, error) {
+	link, err := scanLink(p.QueryRow(ctx, "SELECT "+linkColumns+" FROM url_list WHERE short_url=$1", short))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.StorageJSONWithUserID{}, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return model.StorageJSONWithUserID{}, fmt.Errorf("получение ссылки. %w", err)
+	}
+	return link, nil
+}
+
+// PurgeURLs реализация интерфейса Storager
+func (p *PostgresStorage) PurgeURLs(ctx context.Context, shorts []string) ([]model.StorageJSONWithUserID, error) {
+	rows, err := p.Query(ctx, "DELETE FROM url_list WHERE short_url=ANY($1) RETURNING "+linkColumns, shorts)
+	if err != nil {
+		return nil, fmt.Errorf("окончательное удаление ссылок. %w", err)
+	}
+	defer rows.Close()
+	purged := make([]model.StorageJSONWithUserID, 0, len(shorts))
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("получение удаленной ссылки. %w", err)
+		}
+		purged = append(purged, link)
+	}
+	return purged, rows.Err()
+}
+
+// SetUserBlocked реализация интерфейса Storager
+func (p *PostgresStorage) SetUserBlocked(ctx context.Context, userID uuid.UUID, blocked bool, reason string) error {
+	var err error
+	if blocked {
+		_, err = p.Exec(
+			ctx,
+			"INSERT INTO blocked_users(user_id,reason) VALUES($1,$2) ON CONFLICT (user_id) DO UPDATE SET reason=EXCLUDED.reason",
+			userID,
+			reason,
+		)
+	} else {
+		_, err = p.Exec(ctx, "DELETE FROM blocked_users WHERE user_id=$1", userID)
+	}
+	if err != nil {
+		return fmt.Errorf("изменение блокировки пользователя. %w", err)
+	}
+	return nil
+}
+
+// UserBlocked реализация интерфейса Storager
+func (p *PostgresStorage) UserBlocked(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var blocked bool
+	err := p.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM blocked_users WHERE user_id=$1)", userID).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("проверка блокировки пользователя. %w", err)
+	}
+	return blocked, nil
+}
+
+// SaveAudit реализация интерфейса Storager
+func (p *PostgresStorage) SaveAudit(ctx context.Context, record model.AuditRecord) error {
+	_, err := p.Exec(
+		ctx,
+		"INSERT INTO admin_audit(id,action,actor,target,details,result,created_at) VALUES($1,$2,$3,$4,$5,$6,$7)",
+		record.ID,
+		record.Action,
+		record.Actor,
+		record.Target,
+		record.Details,
+		record.Result,
+		record.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("сохранение записи журнала действий администраторов. %w", err)
+	}
+	return nil
+}
+
+// AuditLog реализация интерфейса Storager
+func (p *PostgresStorage) AuditLog(ctx context.Context, limit int) ([]model.AuditRecord, error) {
+	query := "SELECT id,action,actor,target,details,result,created_at FROM admin_audit ORDER BY seq DESC"
+	args := []any{}
+	if limit > 0 {
+		query += " LIMIT $1"
+		args = append(args, limit)
+	}
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("получение журнала действий администраторов. %w", err)
+	}
+	defer rows.Close()
+	result := make([]model.AuditRecord, 0)
+	for rows.Next() {
+		r := model.AuditRecord{}
+		if err = rows.Scan(&r.ID, &r.Action, &r.Actor, &r.Target, &r.Details, &r.Result, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("получение записи журнала действий администраторов. %w", err)
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// PurgeAudit реализация интерфейса Storager
+func (p *PostgresStorage) PurgeAudit(ctx context.Context, before time.Time) (int, error) {
+	tag, err := p.Exec(ctx, "DELETE FROM admin_audit WHERE created_at<$1", before)
+	if err != nil {
+		return 0, fmt.Errorf("удаление журнала действий администраторов. %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// scanLink сканирует строку с колонками linkColumns
+func scanLink(row pgx.Row) (model.StorageJSONWithUserID, error) {
+	r := model.StorageJSONWithUserID{}
+	err := row.Scan(&r.UUID, &r.UserID, &r.ShortURL, &r.OriginalURL, &r.IsDeleted, &r.IsBlocked, &r.Title, &r.Interstitial, &r.RedirectCode, &r.Passthrough, &r.LastStatus, &r.LastCheckError, &r.LastChecked)
+	return r, err
+}
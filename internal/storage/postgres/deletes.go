@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// deleteTaskColumns колонки задачи удаления в порядке сканирования scanDeleteTask
+const deleteTaskColumns = "id,user_id,status,total,deleted,not_found,failed,created_at,updated_at"
+
+// EnqueueDelete реализация интерфейса Storager
+func (p *PostgresStorage) EnqueueDelete(ctx context.Context, task model.DeleteTask) error {
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("создание транзакции. %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	b := pgx.Batch{}
+	b.Queue(
+		"INSERT INTO delete_tasks(id,user_id,status,total,created_at,updated_at) VALUES($1,$2,$3,$4,$5,$6)",
+		task.ID,
+		task.UserID,
+		task.Status,
+		task.Total,
+		task.CreatedAt,
+		task.UpdatedAt,
+	)
+	for i, res := range task.Results {
+		b.Queue("INSERT INTO delete_task_links(task_id,position,short_url,status) VALUES($1,$2,$3,$4)", task.ID, i, res.ShortURL, res.Status)
+	}
+	if err = tx.SendBatch(ctx, &b).Close(); err != nil {
+		return fmt.Errorf("сохранение задачи удаления. %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("подтверждение транзакции. %w", err)
+	}
+	return nil
+}
+
+// PendingDeletes реализация интерфейса Storager
+func (p *PostgresStorage) PendingDeletes(ctx context.Context, limit int) ([]model.DeleteTask, error) {
+	rows, err := p.Query(ctx, "SELECT "+deleteTaskColumns+" FROM delete_tasks WHERE status=$1 ORDER BY seq", model.JobQueued)
+	if err != nil {
+		return nil, fmt.Errorf("получение задач удаления. %w", err)
+	}
+	tasks := make([]model.DeleteTask, 0)
+	links := 0
+	for rows.Next() {
+		if limit > 0 && len(tasks) > 0 && links >= limit {
+			break
+		}
+		task, err := scanDeleteTask(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tasks = append(tasks, task)
+		links += task.Total
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("получение задач удаления. %w", err)
+	}
+
+	for i := range tasks {
+		if tasks[i].Results, err = p.deleteTaskLinks(ctx, tasks[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
+
+// CompleteDelete реализация интерфейса Storager
+func (p *PostgresStorage) CompleteDelete(ctx context.Context, id uuid.UUID, results []model.DeleteResult) error {
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("создание транзакции. %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	task, err := scanDeleteTask(tx.QueryRow(ctx, "SELECT "+deleteTaskColumns+" FROM delete_tasks WHERE id=$1 FOR UPDATE", id))
+	if err != nil {
+		return err
+	}
+	task.Complete(results, time.Now())
+
+	b := pgx.Batch{}
+	for i, res := range results {
+		b.Queue("UPDATE delete_task_links SET status=$1,reason=$2 WHERE task_id=$3 AND position=$4", res.Status, res.Reason, id, i)
+	}
+	b.Queue(
+		"UPDATE delete_tasks SET status=$1,deleted=$2,not_found=$3,failed=$4,updated_at=$5 WHERE id=$6",
+		task.Status,
+		task.Deleted,
+		task.NotFound,
+		task.Failed,
+		task.UpdatedAt,
+		id,
+	)
+	if err = tx.SendBatch(ctx, &b).Close(); err != nil {
+		return fmt.Errorf("сохранение результатов удаления. %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("подтверждение транзакции. %w", err)
+	}
+	return nil
+}
+
+// DeleteTask реализация интерфейса Storager
+func (p *PostgresStorage) DeleteTask(ctx context.Context, id uuid.UUID) (model.DeleteTask, error) {
+	task, err := scanDeleteTask(p.QueryRow(ctx, "SELECT "+deleteTaskColumns+" FROM delete_tasks WHERE id=$1", id))
+	if err != nil {
+		return model.DeleteTask{}, err
+	}
+	task.Results, err = p.deleteTaskLinks(ctx, id)
+	if err != nil {
+		return model.DeleteTask{}, err
+	}
+	return task, nil
+}
+
+// PurgeDeletes реализация интерфейса Storager. результаты по ссылкам удаляются каскадно
+func (p *PostgresStorage) PurgeDeletes(ctx context.Context, before time.Time) (int, error) {
+	tag, err := p.Exec(ctx, "DELETE FROM delete_tasks WHERE status=$1 AND updated_at<$2", model.JobDone, before)
+	if err != nil {
+		return 0, fmt.Errorf("удаление выполненных задач удаления. %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// deleteTaskLinks результаты по ссылкам задачи удаления id в порядке запроса
+func (p *PostgresStorage) deleteTaskLinks(ctx context.Context, id uuid.UUID) ([]model.DeleteResult, error) {
+	rows, err := p.Query(ctx, "SELECT short_url,status,reason FROM delete_task_links WHERE task_id=$1 ORDER BY position", id)
+	if err != nil {
+		return nil, fmt.Errorf("получение ссылок задачи удаления. %w", err)
+	}
+	defer rows.Close()
+	results := make([]model.DeleteResult, 0)
+	for rows.Next() {
+		r := model.DeleteResult{}
+		if err = rows.Scan(&r.ShortURL, &r.Status, &r.Reason); err != nil {
+			return nil, fmt.Errorf("получение ссылок задачи удаления. %w", err)
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// scanDeleteTask сканирует строку с колонками deleteTaskColumns
+func scanDeleteTask(row pgx.Row) (model.DeleteTask, error) {
+	task := model.DeleteTask{}
+	err := row.Scan(
+		&task.ID,
+		&task.UserID,
+		&task.Status,
+		&task.Total,
+		&task.Deleted,
+		&task.NotFound,
+		&task.Failed,
+		&task.CreatedAt,
+		&task.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.DeleteTask{}, storage.ErrTaskNotFound
+	}
+	if err != nil {
+		return model.DeleteTask{}, fmt.Errorf("получение задачи удаления. %w", err)
+	}
+	return task, nil
+}
@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// IdempotentResponse реализация интерфейса Storager
+func (p *PostgresStorage) IdempotentResponse(ctx context.Context, userID uuid.UUID, key string) (model.IdempotentResponse, error) {
+	resp := model.IdempotentResponse{}
+	err := p.QueryRow(
+		ctx,
+		"SELECT fingerprint,status,header,body,expires_at FROM idempotency_keys WHERE user_id=$1 AND key=$2 AND expires_at>now()",
+		userID,
+		key,
+	).Scan(
+		&resp.Fingerprint,
+		&resp.Status,
+		&resp.Header,
+		&resp.Body,
+		&resp.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.IdempotentResponse{}, storage.ErrKeyNotFound
+	}
+	if err != nil {
+		return model.IdempotentResponse{}, fmt.Errorf("получение ответа по ключу идемпотентности. %w", err)
+	}
+	return resp, nil
+}
+
+// SaveIdempotentResponse реализация интерфейса Storager. заодно удаляются ключи с истекшим сроком хранения,
+// действующий ключ не перезаписывается
+func (p *PostgresStorage) SaveIdempotentResponse(ctx context.Context, userID uuid.UUID, key string, resp model.IdempotentResponse) error {
+	b := pgx.Batch{}
+	b.Queue("DELETE FROM idempotency_keys WHERE expires_at<=now()")
+	b.Queue(
+		`INSERT INTO idempotency_keys(user_id,key,fingerprint,status,header,body,expires_at) VALUES($1,$2,$3,$4,$5,$6,$7)
+		ON CONFLICT (user_id,key) DO NOTHING`,
+		userID,
+		key,
+		resp.Fingerprint,
+		resp.Status,
+		resp.Header,
+		resp.Body,
+		resp.ExpiresAt,
+	)
+	if err := p.SendBatch(ctx, &b).Close(); err != nil {
+		return fmt.Errorf("сохранение ответа по ключу идемпотентности. %w", err)
+	}
+	return nil
+}
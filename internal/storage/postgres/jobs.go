@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// jobColumns колонки задания в порядке сканирования scanJob
+const jobColumns = "id,user_id,status,total,processed,created,existing,invalid,failed,error,request,created_at,updated_at"
+
+// CreateJob реализация интерфейса Storager
+func (p *PostgresStorage) CreateJob(ctx context.Context, job model.Job) error {
+	_, err := p.Exec(
+		ctx,
+		"INSERT INTO jobs(id,user_id,status,total,request,created_at,updated_at) VALUES($1,$2,$3,$4,$5,$6,$7)",
+		job.ID,
+		job.UserID,
+		job.Status,
+		job.Total,
+		job.Request,
+		job.CreatedAt,
+		job.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("сохранение задания. %w", err)
+	}
+	return nil
+}
+
+// Job реализация интерфейса Storager
+func (p *PostgresStorage) Job(ctx context.Context, id uuid.UUID) (model.Job, error) {
+	job, err := scanJob(p.QueryRow(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id=$1", id))
+	if err != nil {
+		return model.Job{}, err
+	}
+	job.Results, err = p.jobResults(ctx, id)
+	if err != nil {
+		return model.Job{}, err
+	}
+	return job, nil
+}
+
+// ClaimJob реализация интерфейса Storager. задание, захваченное другим экземпляром сервиса, пропускается
+func (p *PostgresStorage) ClaimJob(ctx context.Context) (model.Job, error) {
+	job, err := scanJob(p.QueryRow(
+		ctx,
+		`UPDATE jobs SET status=$1,updated_at=now() WHERE id=(
+			SELECT id FROM jobs WHERE status=$2 ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING `+jobColumns,
+		model.JobRunning,
+		model.JobQueued,
+	))
+	if err != nil {
+		return model.Job{}, err
+	}
+	job.Results, err = p.jobResults(ctx, job.ID)
+	if err != nil {
+		return model.Job{}, err
+	}
+	return job, nil
+}
+
+// UpdateJob реализация интерфейса Storager
+func (p *PostgresStorage) UpdateJob(ctx context.Context, id uuid.UUID, progress model.JobProgress) error {
+	tx, err := p.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("создание транзакции. %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	job, err := scanJob(tx.QueryRow(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id=$1 FOR UPDATE", id))
+	if err != nil {
+		return err
+	}
+	offset := job.Processed
+	job.Apply(progress, time.Now())
+
+	b := pgx.Batch{}
+	for i, res := range progress.Results {
+		b.Queue("INSERT INTO job_results(job_id,position,result) VALUES($1,$2,$3)", id, offset+i, res)
+	}
+	b.Queue(
+		"UPDATE jobs SET status=$1,processed=$2,created=$3,existing=$4,invalid=$5,failed=$6,error=$7,updated_at=$8 WHERE id=$9",
+		job.Status,
+		job.Processed,
+		job.Created,
+		job.Existing,
+		job.Invalid,
+		job.Failed,
+		job.Error,
+		job.UpdatedAt,
+		id,
+	)
+	if err = tx.SendBatch(ctx, &b).Close(); err != nil {
+		return fmt.Errorf("сохранение результатов задания. %w", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("подтверждение транзакции. %w", err)
+	}
+	return nil
+}
+
+// RequeueJobs реализация интерфейса Storager
+func (p *PostgresStorage) RequeueJobs(ctx context.Context) (int, error) {
+	tag, err := p.Exec(ctx, "UPDATE jobs SET status=$1,updated_at=now() WHERE status=$2", model.JobQueued, model.JobRunning)
+	if err != nil {
+		return 0, fmt.Errorf("возврат заданий в очередь. %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// PurgeJobs реализация интерфейса Storager. результаты заданий удаляются каскадно
+func (p *PostgresStorage) PurgeJobs(ctx context.Context, before time.Time) (int, error) {
+	tag, err := p.Exec(ctx, "DELETE FROM jobs WHERE status IN ($1,$2) AND updated_at<$3", model.JobDone, model.JobFailed, before)
+	if err != nil {
+		return 0, fmt.Errorf("удаление завершенных заданий. %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// jobResults результаты задания id в порядке запроса
+func (p *PostgresStorage) jobResults(ctx context.Context, id uuid.UUID) (model.BatchResponse, error) {
+	rows, err := p.Query(ctx, "SELECT result FROM job_results WHERE job_id=$1 ORDER BY position", id)
+	if err != nil {
+		return nil, fmt.Errorf("получение результатов задания. %w", err)
+	}
+	defer rows.Close()
+	results := make(model.BatchResponse, 0)
+	for rows.Next() {
+		r := model.BatchResponseElement{}
+		if err = rows.Scan(&r); err != nil {
+			return nil, fmt.Errorf("получение результатов задания. %w", err)
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// scanJob сканирует строку с колонками jobColumns
+func scanJob(row pgx.Row) (model.Job, error) {
+	job := model.Job{}
+	err := row.Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Status,
+		&job.Total,
+		&job.Processed,
+		&job.Created,
+		&job.Existing,
+		&job.Invalid,
+		&job.Failed,
+		&job.Error,
+		&job.Request,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Job{}, storage.ErrJobNotFound
+	}
+	if err != nil {
+		return model.Job{}, fmt.Errorf("получение задания. %w", err)
+	}
+	return job, nil
+}
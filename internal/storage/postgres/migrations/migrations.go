@@ -4,7 +4,9 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
 	"strings"
+	"sync"
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
@@ -12,13 +14,13 @@ import (
 )
 
 //go:embed migrations/*.sql
-var fs embed.FS
+var migrationsFS embed.FS
 
 // MigrationsUP проведение начальной инициализации БД типа postgres.
 // connString строка-подключение.
 // возвращает возможную ошибку или nil
 func MigrationsUP(connString string) error {
-	d, err := iofs.New(fs, "migrations")
+	d, err := iofs.New(migrationsFS, "migrations")
 	if err != nil {
 		return fmt.Errorf("создание драйвера для считывания миграций. %w", err)
 	}
@@ -35,3 +37,26 @@ func MigrationsUP(connString string) error {
 	}
 	return nil
 }
+
+// Latest номер последней миграции, встроенной в приложение
+var Latest = sync.OnceValues(func() (uint, error) {
+	d, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		return 0, fmt.Errorf("создание драйвера для считывания миграций. %w", err)
+	}
+	defer d.Close()
+	version, err := d.First()
+	if err != nil {
+		return 0, fmt.Errorf("поиск первой миграции. %w", err)
+	}
+	for {
+		next, err := d.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("поиск миграции после %d. %w", version, err)
+		}
+		version = next
+	}
+})
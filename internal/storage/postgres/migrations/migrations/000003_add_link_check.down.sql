@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE url_list DROP COLUMN IF EXISTS last_checked;
+ALTER TABLE url_list DROP COLUMN IF EXISTS last_check_error;
+ALTER TABLE url_list DROP COLUMN IF EXISTS last_status;
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+ALTER TABLE url_list ADD COLUMN IF NOT EXISTS last_status integer NOT NULL DEFAULT 0;
+ALTER TABLE url_list ADD COLUMN IF NOT EXISTS last_check_error text NOT NULL DEFAULT '';
+ALTER TABLE url_list ADD COLUMN IF NOT EXISTS last_checked timestamptz;
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE url_list DROP COLUMN IF EXISTS interstitial;
+ALTER TABLE url_list DROP COLUMN IF EXISTS title;
+COMMIT;
\ No newline at end of file
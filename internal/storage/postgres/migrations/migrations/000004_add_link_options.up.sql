@@ -0,0 +1,4 @@
+BEGIN;
+ALTER TABLE url_list ADD COLUMN IF NOT EXISTS title text NOT NULL DEFAULT '';
+ALTER TABLE url_list ADD COLUMN IF NOT EXISTS interstitial boolean NOT NULL DEFAULT false;
+COMMIT;
\ No newline at end of file
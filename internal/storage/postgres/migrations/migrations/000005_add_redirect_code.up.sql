@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE url_list ADD COLUMN IF NOT EXISTS redirect_code integer NOT NULL DEFAULT 0;
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE url_list DROP COLUMN IF EXISTS passthrough;
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,3 @@
+BEGIN;
+ALTER TABLE url_list ADD COLUMN IF NOT EXISTS passthrough boolean NOT NULL DEFAULT false;
+COMMIT;
\ No newline at end of file
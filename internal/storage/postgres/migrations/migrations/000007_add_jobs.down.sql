@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE IF EXISTS job_results;
+DROP TABLE IF EXISTS jobs;
+COMMIT;
\ No newline at end of file
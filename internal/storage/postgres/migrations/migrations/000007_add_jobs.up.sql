@@ -0,0 +1,24 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS jobs (
+    id uuid PRIMARY KEY,
+    user_id uuid NOT NULL,
+    status text NOT NULL,
+    total integer NOT NULL DEFAULT 0,
+    processed integer NOT NULL DEFAULT 0,
+    created integer NOT NULL DEFAULT 0,
+    existing integer NOT NULL DEFAULT 0,
+    invalid integer NOT NULL DEFAULT 0,
+    failed integer NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    request jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS jobs_status_idx ON jobs (status, created_at);
+CREATE TABLE IF NOT EXISTS job_results (
+    job_id uuid NOT NULL REFERENCES jobs (id) ON DELETE CASCADE,
+    position integer NOT NULL,
+    result jsonb NOT NULL,
+    PRIMARY KEY (job_id, position)
+);
+COMMIT;
@@ -0,0 +1,3 @@
+BEGIN;
+DROP TABLE IF EXISTS link_quotas;
+COMMIT;
\ No newline at end of file
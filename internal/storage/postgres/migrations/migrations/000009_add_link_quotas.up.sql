@@ -0,0 +1,9 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS link_quotas (
+    user_id uuid NOT NULL,
+    day date NOT NULL,
+    used integer NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+CREATE INDEX IF NOT EXISTS link_quotas_day_idx ON link_quotas (day);
+COMMIT;
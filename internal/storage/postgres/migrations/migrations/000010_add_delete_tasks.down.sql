@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE IF EXISTS delete_task_links;
+DROP TABLE IF EXISTS delete_tasks;
+COMMIT;
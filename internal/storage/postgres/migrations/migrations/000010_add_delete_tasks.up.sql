@@ -0,0 +1,23 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS delete_tasks (
+    id uuid PRIMARY KEY,
+    seq bigserial NOT NULL,
+    user_id uuid NOT NULL,
+    status text NOT NULL,
+    total integer NOT NULL DEFAULT 0,
+    deleted integer NOT NULL DEFAULT 0,
+    not_found integer NOT NULL DEFAULT 0,
+    failed integer NOT NULL DEFAULT 0,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS delete_tasks_status_idx ON delete_tasks (status, seq);
+CREATE TABLE IF NOT EXISTS delete_task_links (
+    task_id uuid NOT NULL REFERENCES delete_tasks (id) ON DELETE CASCADE,
+    position integer NOT NULL,
+    short_url text NOT NULL,
+    status text NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    PRIMARY KEY (task_id, position)
+);
+COMMIT;
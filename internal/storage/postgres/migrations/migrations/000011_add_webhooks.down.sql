@@ -0,0 +1,5 @@
+BEGIN;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+ALTER TABLE url_list DROP COLUMN IF EXISTS clicks;
+COMMIT;
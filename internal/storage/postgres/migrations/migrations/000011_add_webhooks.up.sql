@@ -0,0 +1,28 @@
+BEGIN;
+ALTER TABLE url_list ADD COLUMN IF NOT EXISTS clicks bigint NOT NULL DEFAULT 0;
+CREATE TABLE IF NOT EXISTS webhooks (
+    id uuid PRIMARY KEY,
+    user_id uuid NOT NULL,
+    url text NOT NULL,
+    events text[] NOT NULL,
+    click_threshold bigint NOT NULL DEFAULT 0,
+    secret text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS webhooks_user_idx ON webhooks (user_id, created_at);
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id uuid PRIMARY KEY,
+    seq bigserial NOT NULL,
+    webhook_id uuid NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    event jsonb NOT NULL,
+    status text NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    response_status integer NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_idx ON webhook_deliveries (webhook_id, seq);
+COMMIT;
@@ -0,0 +1,4 @@
+BEGIN;
+DROP TABLE IF EXISTS admin_audit;
+DROP TABLE IF EXISTS blocked_users;
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+CREATE TABLE IF NOT EXISTS blocked_users (
+    user_id uuid PRIMARY KEY,
+    reason text NOT NULL DEFAULT '',
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS admin_audit (
+    id uuid PRIMARY KEY,
+    seq bigserial NOT NULL,
+    action text NOT NULL,
+    actor text NOT NULL,
+    target text NOT NULL,
+    details text NOT NULL DEFAULT '',
+    result text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS admin_audit_seq_idx ON admin_audit (seq);
+COMMIT;
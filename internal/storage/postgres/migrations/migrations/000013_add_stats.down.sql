@@ -0,0 +1,10 @@
+BEGIN;
+DROP TRIGGER IF EXISTS stats_url_list ON url_list;
+DROP FUNCTION IF EXISTS stats_url_list();
+DROP FUNCTION IF EXISTS stats_link_add(url_list, integer);
+DROP FUNCTION IF EXISTS url_domain(text);
+DROP TABLE IF EXISTS stats_created;
+DROP TABLE IF EXISTS stats_domains;
+DROP TABLE IF EXISTS stats_users;
+DROP TABLE IF EXISTS stats_totals;
+COMMIT;
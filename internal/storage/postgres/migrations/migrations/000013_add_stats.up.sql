@@ -0,0 +1,95 @@
+BEGIN;
+-- счетчики статистики ведутся триггером при изменении url_list
+CREATE TABLE IF NOT EXISTS stats_totals (
+    id boolean PRIMARY KEY DEFAULT true CHECK (id),
+    urls bigint NOT NULL DEFAULT 0,
+    deleted bigint NOT NULL DEFAULT 0,
+    users bigint NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS stats_users (
+    user_id uuid PRIMARY KEY,
+    links bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS stats_users_links_idx ON stats_users (links DESC, user_id);
+CREATE TABLE IF NOT EXISTS stats_domains (
+    domain text PRIMARY KEY,
+    links bigint NOT NULL
+);
+CREATE INDEX IF NOT EXISTS stats_domains_links_idx ON stats_domains (links DESC, domain);
+-- количество ссылок, созданных в каждый час (начало часа в UTC)
+CREATE TABLE IF NOT EXISTS stats_created (
+    bucket timestamp PRIMARY KEY,
+    links bigint NOT NULL
+);
+
+-- домен оригинальной ссылки в нижнем регистре, как model.Domain
+CREATE OR REPLACE FUNCTION url_domain(original_url text) RETURNS text AS $$
+    SELECT lower(coalesce(m[1], m[2], ''))
+    FROM (SELECT regexp_match(original_url, '^[A-Za-z][A-Za-z0-9+.-]*://(?:[^/?#@]*@)?(?:\[([^]]*)\]|([^/?#:]*))') AS m) AS t
+$$ LANGUAGE sql IMMUTABLE;
+
+-- stats_link_add учитывает ссылку (sign = 1) или ее окончательное удаление (sign = -1)
+CREATE OR REPLACE FUNCTION stats_link_add(link url_list, sign integer) RETURNS void AS $$
+DECLARE
+    user_links bigint;
+    new_users integer := 0;
+BEGIN
+    IF link.user_id IS NOT NULL THEN
+        INSERT INTO stats_users(user_id, links) VALUES (link.user_id, sign)
+        ON CONFLICT (user_id) DO UPDATE SET links = stats_users.links + sign
+        RETURNING links INTO user_links;
+        IF sign > 0 AND user_links = 1 THEN
+            new_users := 1;
+        ELSIF sign < 0 AND user_links <= 0 THEN
+            DELETE FROM stats_users WHERE user_id = link.user_id;
+            IF user_links = 0 THEN
+                new_users := -1;
+            END IF;
+        END IF;
+    END IF;
+
+    INSERT INTO stats_domains(domain, links) VALUES (url_domain(link.original_url), sign)
+    ON CONFLICT (domain) DO UPDATE SET links = stats_domains.links + sign;
+    DELETE FROM stats_domains WHERE domain = url_domain(link.original_url) AND links <= 0;
+
+    UPDATE stats_totals SET
+        urls = urls + sign,
+        deleted = deleted + CASE WHEN link.is_deleted THEN sign ELSE 0 END,
+        users = users + new_users;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION stats_url_list() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM stats_link_add(NEW, 1);
+        INSERT INTO stats_created(bucket, links) VALUES (date_trunc('hour', now() AT TIME ZONE 'UTC'), 1)
+        ON CONFLICT (bucket) DO UPDATE SET links = stats_created.links + 1;
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM stats_link_add(OLD, -1);
+    ELSIF NEW.user_id IS DISTINCT FROM OLD.user_id OR NEW.original_url IS DISTINCT FROM OLD.original_url THEN
+        PERFORM stats_link_add(OLD, -1);
+        PERFORM stats_link_add(NEW, 1);
+    ELSIF NEW.is_deleted IS DISTINCT FROM OLD.is_deleted THEN
+        UPDATE stats_totals SET deleted = deleted + CASE WHEN NEW.is_deleted THEN 1 ELSE -1 END;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- начальные значения по уже сохраненным ссылкам. время их создания неизвестно, в ряд созданных ссылок они не попадают
+LOCK TABLE url_list IN SHARE MODE;
+INSERT INTO stats_totals(id, urls, deleted, users)
+SELECT true, count(*), count(*) FILTER (WHERE is_deleted), count(DISTINCT user_id) FROM url_list
+ON CONFLICT (id) DO NOTHING;
+INSERT INTO stats_users(user_id, links)
+SELECT user_id, count(*) FROM url_list WHERE user_id IS NOT NULL GROUP BY user_id
+ON CONFLICT (user_id) DO NOTHING;
+INSERT INTO stats_domains(domain, links)
+SELECT url_domain(original_url), count(*) FROM url_list GROUP BY url_domain(original_url)
+ON CONFLICT (domain) DO NOTHING;
+
+DROP TRIGGER IF EXISTS stats_url_list ON url_list;
+CREATE TRIGGER stats_url_list AFTER INSERT OR UPDATE OF user_id, original_url, is_deleted OR DELETE ON url_list
+    FOR EACH ROW EXECUTE FUNCTION stats_url_list();
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+-- учетные записи пользователей. user_id совпадает с владельцем ссылок в url_list
+CREATE TABLE IF NOT EXISTS accounts (
+    user_id uuid PRIMARY KEY,
+    login text NOT NULL UNIQUE,
+    password_hash text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+COMMIT;
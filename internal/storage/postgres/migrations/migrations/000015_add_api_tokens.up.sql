@@ -0,0 +1,13 @@
+BEGIN;
+-- персональные API токены. хранится только хэш SHA-256 значения токена
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id uuid PRIMARY KEY,
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    scopes text[] NOT NULL,
+    hash text NOT NULL UNIQUE,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    expires_at timestamptz
+);
+CREATE INDEX IF NOT EXISTS api_tokens_user_idx ON api_tokens (user_id, created_at);
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+-- срабатывания подписок на порог переходов: по каждой ссылке подписка срабатывает один раз
+CREATE TABLE IF NOT EXISTS webhook_thresholds (
+    webhook_id uuid NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+    short_url text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (webhook_id, short_url)
+);
+COMMIT;
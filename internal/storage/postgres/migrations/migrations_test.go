@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatest(t *testing.T) {
+	ups, err := fs.Glob(migrationsFS, "migrations/*.up.sql")
+	require.NoError(t, err)
+	require.NotEmpty(t, ups)
+
+	latest, err := Latest()
+	require.NoError(t, err)
+	require.EqualValues(t, len(ups), latest, "миграции нумеруются подряд с 1")
+}
@@ -4,15 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/kTowkA/shortener/internal/model"
 	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/postgres/migrations"
 )
 
 // PostgresStorage структура дря реализации интерфейса Storager
@@ -33,7 +31,7 @@ func NewStorage(ctx context.Context, dsn string) (*PostgresStorage, error) {
 }
 
 // SaveURL реализация интерфейса Storager
-func (p *PostgresStorage) SaveURL(ctx context.Context, userID uuid.UUID, real, short string) (string, error) {
+func (p *PostgresStorage) SaveURL(ctx context.Context, userID uuid.UUID, real, short string, opts model.LinkOptions) (string, error) {
 	resp, err := p.Batch(
 		ctx,
 		userID,
@@ -41,6 +39,7 @@ func (p *PostgresStorage) SaveURL(ctx context.Context, userID uuid.UUID, real, s
 			model.BatchRequestElement{
 				OriginalURL: real,
 				ShortURL:    short,
+				LinkOptions: opts,
 			},
 		},
 	)
@@ -61,11 +60,19 @@ func (p *PostgresStorage) RealURL(ctx context.Context, short string) (model.Stor
 	answ := model.StorageJSON{}
 	err := p.QueryRow(
 		ctx,
-		"SELECT original_url,is_deleted FROM url_list WHERE short_url=$1",
+		"SELECT original_url,is_deleted,is_blocked,title,interstitial,redirect_code,passthrough,last_status,last_check_error,last_checked FROM url_list WHERE short_url=$1",
 		short,
 	).Scan(
 		&answ.OriginalURL,
 		&answ.IsDeleted,
+		&answ.IsBlocked,
+		&answ.Title,
+		&answ.Interstitial,
+		&answ.RedirectCode,
+		&answ.Passthrough,
+		&answ.LastStatus,
+		&answ.LastCheckError,
+		&answ.LastChecked,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return model.StorageJSON{}, storage.ErrURLNotFound
@@ -81,101 +88,120 @@ func (p *PostgresStorage) Ping(ctx context.Context) error {
 	return p.Pool.Ping(ctx)
 }
 
+// CheckMigrations проверка готовности: ошибка, если к БД применены не все миграции приложения
+// или последняя миграция применена не полностью
+func (p *PostgresStorage) CheckMigrations(ctx context.Context) error {
+	latest, err := migrations.Latest()
+	if err != nil {
+		return err
+	}
+	var (
+		version int64
+		dirty   bool
+	)
+	err = p.QueryRow(ctx, "SELECT version,dirty FROM schema_migrations").Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("миграции не применены, последняя миграция %d", latest)
+	}
+	if err != nil {
+		return fmt.Errorf("получение версии миграций из БД. %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("миграция %d применена не полностью", version)
+	}
+	if version < int64(latest) {
+		return fmt.Errorf("применены миграции до %d, последняя миграция %d", version, latest)
+	}
+	return nil
+}
+
 // Close реализация интерфейса Storager
 func (p *PostgresStorage) Close() error {
 	p.Pool.Close()
 	return nil
 }
 
-// Batch реализация интерфейса Storager
+// Batch реализация интерфейса Storager. Строки, совпадающие с уже сохраненными, пропускаются и не отменяют
+// сохранение остальных: для них определяется причина - ссылка уже сокращена (конфликт) или совпала короткая ссылка (коллизия)
 func (p *PostgresStorage) Batch(ctx context.Context, userID uuid.UUID, values model.BatchRequest) (model.BatchResponse, error) {
 	tx, err := p.Begin(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("создание транзакции. %w", err)
 	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
 
 	// проходим по нашим значениям и создаем batch
 	b := pgx.Batch{}
 	for _, v := range values {
 		b.Queue(
-			"INSERT INTO url_list(uuid,user_id,original_url,short_url,is_deleted) VALUES($1,$2,$3,$4,$5)",
+			"INSERT INTO url_list(uuid,user_id,original_url,short_url,is_deleted,title,interstitial,redirect_code,passthrough) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9) ON CONFLICT DO NOTHING",
 			uuid.New(),
 			userID,
 			v.OriginalURL,
 			v.ShortURL,
 			false,
+			v.Title,
+			v.Interstitial,
+			v.RedirectCode,
+			v.Passthrough,
 		)
 	}
 	// отправляем весь batch
 	br := tx.SendBatch(ctx, &b)
 
-	// результирующая переменная все ли было хорошо
-	ok := true
 	// заполняем результат,для этого проходим по переданным значениям и вызываем Exec у BatchResult
 	result := make([]model.BatchResponseElement, 0, len(values))
-	for _, v := range values {
-		// e содержит наш результат
+	// номера пропущенных строк
+	skipped := make([]int, 0)
+	for i, v := range values {
 		e := model.BatchResponseElement{
 			CorrelationID: v.CorrelationID,
 			OriginalURL:   v.OriginalURL,
 		}
-
-		// смотрим как выполнилось
-		// возможные варианты:
-		// 1. была ошибка
-		// 2. ошибки не было, но строку не вставили (маловероятное, но вдруг и так быват)
-		// 3. все прошло успешно
 		tc, err := br.Exec()
-
-		switch {
-		case err != nil:
-			ok = false
-			e.Error = err
-			var pgErr *pgconn.PgError
-			// если это не внутренняя ошибка postgres, то выходим
-			if !errors.As(err, &pgErr) {
-				break
-			}
-			// если это не ошибка UniqueViolation то нам неинтересно
-			if pgErr.Code != pgerrcode.UniqueViolation {
-				break
-			}
-			if strings.Contains(err.Error(), "original_url") { //оригинальный урл. надо получить уже имеющиеся совпадение
-				// ищем ранее сохраненный url
-				short, err := p.short(ctx, v.OriginalURL, userID)
-				if err != nil {
-					e.Error = err
-					break
-				}
-				e.ShortURL = short
-				e.Error = storage.ErrURLConflict
-			} else { // а в этом случае коллизия, т.е. у нас уже есть такой же ключ в колонке short_url
-				e.Collision = true
-				e.Error = storage.ErrURLIsExist
-			}
-		case tc.RowsAffected() != 1:
-			ok = false
-			e.Error = fmt.Errorf("не было сохранено")
-		default:
+		if err != nil {
+			br.Close()
+			return nil, fmt.Errorf("сохранение ссылки %s. %w", v.OriginalURL, err)
+		}
+		if tc.RowsAffected() == 1 {
 			e.ShortURL = v.ShortURL
+		} else {
+			skipped = append(skipped, i)
 		}
-
 		result = append(result, e)
 	}
 	// не забываем закрыть
-	br.Close()
-	if ok {
-		err = tx.Commit(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("сохранение изменений транзакции. %w", err)
-		}
-	} else {
-		err = tx.Rollback(ctx)
-		if err != nil {
-			return nil, fmt.Errorf("откат изменений транзакции. %w", err)
+	if err = br.Close(); err != nil {
+		return nil, fmt.Errorf("выполнение batch. %w", err)
+	}
+
+	for _, i := range skipped {
+		var (
+			short string
+			owner uuid.UUID
+		)
+		err = tx.QueryRow(ctx, "SELECT short_url,user_id FROM url_list WHERE original_url=$1", values[i].OriginalURL).Scan(&short, &owner)
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			// оригинальной ссылки нет, значит строка пропущена из-за короткой ссылки
+			result[i].Collision = true
+			result[i].Error = storage.ErrURLIsExist
+		case err != nil:
+			return nil, fmt.Errorf("поиск ранее сохраненной ссылки. %w", err)
+		case owner == userID:
+			result[i].ShortURL = short
+			result[i].Error = storage.ErrURLConflict
+		default:
+			// ссылка сокращена другим пользователем, ее короткую ссылку не раскрываем
+			result[i].Error = storage.ErrURLConflict
 		}
 	}
 
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("сохранение изменений транзакции. %w", err)
+	}
 	return result, nil
 }
 
@@ -203,9 +229,9 @@ func (p *PostgresStorage) DeleteURLs(ctx context.Context, deleteLinks []model.De
 		tc, err := br.Exec()
 		switch {
 		case err == pgx.ErrNoRows || (err == nil && tc.RowsAffected() == 0):
-			grpErrors = append(grpErrors, fmt.Errorf("userID %s, shortURL %s. %w", v.UserID, v.ShortURL, storage.ErrURLNotFound))
+			grpErrors = append(grpErrors, &storage.DeleteError{Link: v, Err: storage.ErrURLNotFound})
 		case err != nil:
-			grpErrors = append(grpErrors, fmt.Errorf("userID %s, shortURL %s. %w", v.UserID, v.ShortURL, err))
+			grpErrors = append(grpErrors, &storage.DeleteError{Link: v, Err: err})
 		}
 	}
 	// не забываем закрыть
@@ -243,11 +269,32 @@ func (p *PostgresStorage) short(ctx context.Context, real string, userID uuid.UU
 	return short, nil
 }
 
+// UpdateURLOptions реализация интерфейса Storager
+func (p *PostgresStorage) UpdateURLOptions(ctx context.Context, userID uuid.UUID, short string, opts model.LinkOptions) error {
+	tc, err := p.Exec(
+		ctx,
+		"UPDATE url_list SET title=$1,interstitial=$2,redirect_code=$3,passthrough=$4 WHERE user_id=$5 AND short_url=$6",
+		opts.Title,
+		opts.Interstitial,
+		opts.RedirectCode,
+		opts.Passthrough,
+		userID,
+		short,
+	)
+	if err != nil {
+		return fmt.Errorf("изменение настроек ссылки. %w", err)
+	}
+	if tc.RowsAffected() == 0 {
+		return storage.ErrURLNotFound
+	}
+	return nil
+}
+
 // UserURLs реализация интерфейса Storager
 func (p *PostgresStorage) UserURLs(ctx context.Context, userID uuid.UUID) ([]model.StorageJSON, error) {
 	rows, err := p.Query(
 		ctx,
-		"SELECT short_url,original_url,is_deleted FROM url_list WHERE user_id=$1",
+		"SELECT short_url,original_url,is_deleted,is_blocked,title,interstitial,redirect_code,passthrough,last_status,last_check_error,last_checked FROM url_list WHERE user_id=$1",
 		userID,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -260,7 +307,7 @@ func (p *PostgresStorage) UserURLs(ctx context.Context, userID uuid.UUID) ([]mod
 	results := make([]model.StorageJSON, 0)
 	for rows.Next() {
 		r := model.StorageJSON{}
-		err = rows.Scan(&r.ShortURL, &r.OriginalURL, &r.IsDeleted)
+		err = rows.Scan(&r.ShortURL, &r.OriginalURL, &r.IsDeleted, &r.IsBlocked, &r.Title, &r.Interstitial, &r.RedirectCode, &r.Passthrough, &r.LastStatus, &r.LastCheckError, &r.LastChecked)
 		if err != nil {
 			return nil, fmt.Errorf("получение отдельной записи для пользователя. %w", err)
 		}
@@ -272,12 +319,57 @@ func (p *PostgresStorage) UserURLs(ctx context.Context, userID uuid.UUID) ([]mod
 	return results, nil
 }
 
-// Stats реализация интерфейса Storager
-func (p *PostgresStorage) Stats(ctx context.Context) (model.StatsResponse, error) {
-	result := model.StatsResponse{}
-	err := p.QueryRow(ctx, "SELECT COUNT(DISTINCT(user_id)) AS c1,COUNT(DISTINCT(short_url)) AS c2 FROM url_list").Scan(&result.TotalUsers, &result.TotalURLs)
+// AllURLs реализация интерфейса Storager
+func (p *PostgresStorage) AllURLs(ctx context.Context) ([]model.StorageJSONWithUserID, error) {
+	rows, err := p.Query(
+		ctx,
+		"SELECT uuid,user_id,short_url,original_url,is_deleted,is_blocked,title,interstitial,redirect_code,passthrough,last_status,last_check_error,last_checked FROM url_list",
+	)
 	if err != nil {
-		return model.StatsResponse{}, err
+		return nil, fmt.Errorf("получение всех записей из БД. %w", err)
 	}
-	return result, nil
+	defer rows.Close()
+	results := make([]model.StorageJSONWithUserID, 0)
+	for rows.Next() {
+		r := model.StorageJSONWithUserID{}
+		err = rows.Scan(&r.UUID, &r.UserID, &r.ShortURL, &r.OriginalURL, &r.IsDeleted, &r.IsBlocked, &r.Title, &r.Interstitial, &r.RedirectCode, &r.Passthrough, &r.LastStatus, &r.LastCheckError, &r.LastChecked)
+		if err != nil {
+			return nil, fmt.Errorf("получение отдельной записи. %w", err)
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// SaveLinkChecks реализация интерфейса Storager
+func (p *PostgresStorage) SaveLinkChecks(ctx context.Context, checks []model.LinkCheck) error {
+	b := pgx.Batch{}
+	for _, c := range checks {
+		b.Queue(
+			"UPDATE url_list SET last_status=$1,last_check_error=$2,last_checked=$3 WHERE short_url=$4",
+			c.Status,
+			c.Error,
+			c.CheckedAt,
+			c.ShortURL,
+		)
+	}
+	err := p.SendBatch(ctx, &b).Close()
+	if err != nil {
+		return fmt.Errorf("сохранение результатов проверки ссылок. %w", err)
+	}
+	return nil
+}
+
+// BlockURLs реализация интерфейса Storager
+func (p *PostgresStorage) BlockURLs(ctx context.Context, shorts []string, blocked bool) error {
+	_, err := p.Exec(
+		ctx,
+		"UPDATE url_list SET is_blocked=$1 WHERE short_url=ANY($2)",
+		blocked,
+		shorts,
+	)
+	if err != nil {
+		return fmt.Errorf("изменение блокировки записей. %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// ConsumeLinkQuota реализация интерфейса Storager. учет за предыдущие сутки удаляется
+func (p *PostgresStorage) ConsumeLinkQuota(ctx context.Context, userID uuid.UUID, day time.Time, n, limit int) error {
+	if n > limit {
+		return storage.ErrQuotaExceeded
+	}
+	b := pgx.Batch{}
+	b.Queue("DELETE FROM link_quotas WHERE day<$1", day)
+	var used int
+	b.Queue(
+		`INSERT INTO link_quotas(user_id,day,used) VALUES($1,$2,$3)
+		ON CONFLICT (user_id,day) DO UPDATE SET used=link_quotas.used+EXCLUDED.used WHERE link_quotas.used+EXCLUDED.used<=$4
+		RETURNING used`,
+		userID,
+		day,
+		n,
+		limit,
+	).QueryRow(func(row pgx.Row) error {
+		return row.Scan(&used)
+	})
+	err := p.SendBatch(ctx, &b).Close()
+	if errors.Is(err, pgx.ErrNoRows) {
+		return storage.ErrQuotaExceeded
+	}
+	if err != nil {
+		return fmt.Errorf("учет квоты ссылок. %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+// Stats реализация интерфейса Storager. счетчики ведутся триггером stats_url_list
+func (p *PostgresStorage) Stats(ctx context.Context, req model.StatsRequest) (model.StatsResponse, error) {
+	result := model.StatsResponse{}
+	err := p.QueryRow(ctx, "SELECT users,urls,deleted FROM stats_totals").Scan(&result.TotalUsers, &result.TotalURLs, &result.DeletedURLs)
+	if err != nil {
+		return model.StatsResponse{}, fmt.Errorf("получение счетчиков статистики. %w", err)
+	}
+	result.ActiveURLs = result.TotalURLs - result.DeletedURLs
+
+	rows, err := p.Query(ctx, "SELECT bucket,links FROM stats_created WHERE bucket>=$1 AND bucket<$2", req.From.UTC(), req.To.UTC())
+	if err != nil {
+		return model.StatsResponse{}, fmt.Errorf("получение ряда созданных ссылок. %w", err)
+	}
+	defer rows.Close()
+	hourly := make(map[time.Time]int)
+	for rows.Next() {
+		var (
+			bucket time.Time
+			links  int
+		)
+		if err = rows.Scan(&bucket, &links); err != nil {
+			return model.StatsResponse{}, fmt.Errorf("получение ряда созданных ссылок. %w", err)
+		}
+		hourly[bucket.UTC()] = links
+	}
+	if err = rows.Err(); err != nil {
+		return model.StatsResponse{}, fmt.Errorf("получение ряда созданных ссылок. %w", err)
+	}
+	result.Created = req.Series(hourly)
+
+	result.TopDomains, err = p.top(ctx, "SELECT domain,links FROM stats_domains ORDER BY links DESC,domain LIMIT $1", req.Top)
+	if err != nil {
+		return model.StatsResponse{}, fmt.Errorf("получение доменов с наибольшим количеством ссылок. %w", err)
+	}
+	result.TopUsers, err = p.top(ctx, "SELECT user_id::text,links FROM stats_users ORDER BY links DESC,user_id LIMIT $1", req.Top)
+	if err != nil {
+		return model.StatsResponse{}, fmt.Errorf("получение пользователей с наибольшим количеством ссылок. %w", err)
+	}
+	for i := range result.TopUsers {
+		result.TopUsers[i].Key = model.StatsUserKey(result.TopUsers[i].Key)
+	}
+	return result, nil
+}
+
+// top список лидеров по запросу query, возвращающему ключ и количество ссылок
+func (p *PostgresStorage) top(ctx context.Context, query string, limit int) ([]model.StatsCount, error) {
+	rows, err := p.Query(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]model.StatsCount, 0, limit)
+	for rows.Next() {
+		c := model.StatsCount{}
+		if err = rows.Scan(&c.Key, &c.Links); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, rows.Err()
+}
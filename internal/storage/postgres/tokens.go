@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// tokenColumns колонки API токена в порядке сканирования scanToken
+const tokenColumns = "id,user_id,name,scopes,hash,created_at,expires_at"
+
+// SaveAPIToken реализация интерфейса Storager
+func (p *PostgresStorage) SaveAPIToken(ctx context.Context, token model.APIToken) error {
+	scopes := make([]string, 0, len(token.Scopes))
+	for _, s := range token.Scopes {
+		scopes = append(scopes, string(s))
+	}
+	_, err := p.Exec(
+		ctx,
+		`INSERT INTO api_tokens(id,user_id,name,scopes,hash,created_at,expires_at) VALUES($1,$2,$3,$4,$5,$6,$7)
+		ON CONFLICT (id) DO UPDATE SET name=EXCLUDED.name,scopes=EXCLUDED.scopes,expires_at=EXCLUDED.expires_at`,
+		token.ID,
+		token.UserID,
+		token.Name,
+		scopes,
+		token.Hash,
+		token.CreatedAt,
+		token.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("сохранение API токена. %w", err)
+	}
+	return nil
+}
+
+// APIToken реализация интерфейса Storager
+func (p *PostgresStorage) APIToken(ctx context.Context, hash string) (model.APIToken, error) {
+	return scanToken(p.QueryRow(ctx, "SELECT "+tokenColumns+" FROM api_tokens WHERE hash=$1", hash))
+}
+
+// UserAPITokens реализация интерфейса Storager
+func (p *PostgresStorage) UserAPITokens(ctx context.Context, userID uuid.UUID) ([]model.APIToken, error) {
+	rows, err := p.Query(ctx, "SELECT "+tokenColumns+" FROM api_tokens WHERE user_id=$1 ORDER BY created_at,id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("получение API токенов пользователя. %w", err)
+	}
+	defer rows.Close()
+	tokens := make([]model.APIToken, 0)
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// DeleteAPIToken реализация интерфейса Storager
+func (p *PostgresStorage) DeleteAPIToken(ctx context.Context, userID, id uuid.UUID) error {
+	tag, err := p.Exec(ctx, "DELETE FROM api_tokens WHERE id=$1 AND user_id=$2", id, userID)
+	if err != nil {
+		return fmt.Errorf("удаление API токена. %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrAPITokenNotFound
+	}
+	return nil
+}
+
+// scanToken сканирует строку с колонками tokenColumns
+func scanToken(row pgx.Row) (model.APIToken, error) {
+	token := model.APIToken{}
+	scopes := make([]string, 0)
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.Hash, &token.CreatedAt, &token.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.APIToken{}, storage.ErrAPITokenNotFound
+	}
+	if err != nil {
+		return model.APIToken{}, fmt.Errorf("получение API токена. %w", err)
+	}
+	token.Scopes = make([]model.Scope, 0, len(scopes))
+	for _, s := range scopes {
+		token.Scopes = append(token.Scopes, model.Scope(s))
+	}
+	return token, nil
+}
@@ -0,0 +1,263 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+const (
+	// webhookColumns колонки подписки в порядке сканирования scanWebhook
+	webhookColumns = "id,user_id,url,events,click_threshold,secret,created_at"
+	// deliveryColumns колонки доставки в порядке сканирования scanDelivery
+	deliveryColumns = "id,webhook_id,event,status,attempts,next_attempt_at,response_status,error,created_at,updated_at"
+)
+
+// CountClick реализация интерфейса Storager
+func (p *PostgresStorage) CountClick(ctx context.Context, short string) (model.LinkClicks, error) {
+	clicks := model.LinkClicks{ShortURL: short}
+	err := p.QueryRow(ctx, "UPDATE url_list SET clicks=clicks+1 WHERE short_url=$1 RETURNING user_id,clicks", short).Scan(&clicks.UserID, &clicks.Clicks)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.LinkClicks{}, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return model.LinkClicks{}, fmt.Errorf("учет перехода по ссылке. %w", err)
+	}
+	return clicks, nil
+}
+
+// SaveWebhook реализация интерфейса Storager
+func (p *PostgresStorage) SaveWebhook(ctx context.Context, hook model.Webhook) error {
+	events := make([]string, 0, len(hook.Events))
+	for _, e := range hook.Events {
+		events = append(events, string(e))
+	}
+	_, err := p.Exec(
+		ctx,
+		`INSERT INTO webhooks(id,user_id,url,events,click_threshold,secret,created_at) VALUES($1,$2,$3,$4,$5,$6,$7)
+		ON CONFLICT (id) DO UPDATE SET url=EXCLUDED.url,events=EXCLUDED.events,click_threshold=EXCLUDED.click_threshold,secret=EXCLUDED.secret`,
+		hook.ID,
+		hook.UserID,
+		hook.URL,
+		events,
+		hook.ClickThreshold,
+		hook.Secret,
+		hook.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("сохранение подписки. %w", err)
+	}
+	return nil
+}
+
+// Webhook реализация интерфейса Storager
+func (p *PostgresStorage) Webhook(ctx context.Context, id uuid.UUID) (model.Webhook, error) {
+	return scanWebhook(p.QueryRow(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id=$1", id))
+}
+
+// UserWebhooks реализация интерфейса Storager
+func (p *PostgresStorage) UserWebhooks(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error) {
+	rows, err := p.Query(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE user_id=$1 ORDER BY created_at,id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("получение подписок пользователя. %w", err)
+	}
+	defer rows.Close()
+	hooks := make([]model.Webhook, 0)
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+// DeleteWebhook реализация интерфейса Storager. доставки подписки удаляются каскадно
+func (p *PostgresStorage) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	tag, err := p.Exec(ctx, "DELETE FROM webhooks WHERE id=$1", id)
+	if err != nil {
+		return fmt.Errorf("удаление подписки. %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrHookNotFound
+	}
+	return nil
+}
+
+// MarkThreshold реализация интерфейса Storager
+func (p *PostgresStorage) MarkThreshold(ctx context.Context, webhookID uuid.UUID, short string) (bool, error) {
+	tag, err := p.Exec(ctx, "INSERT INTO webhook_thresholds(webhook_id,short_url) VALUES($1,$2) ON CONFLICT DO NOTHING", webhookID, short)
+	if err != nil {
+		return false, fmt.Errorf("отметка срабатывания подписки на порог переходов. %w", err)
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// EnqueueDeliveries реализация интерфейса Storager
+func (p *PostgresStorage) EnqueueDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	b := pgx.Batch{}
+	for _, d := range deliveries {
+		b.Queue(
+			"INSERT INTO webhook_deliveries("+deliveryColumns+") VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)",
+			d.ID,
+			d.WebhookID,
+			d.Event,
+			d.Status,
+			d.Attempts,
+			d.NextAttemptAt,
+			d.ResponseStatus,
+			d.Error,
+			d.CreatedAt,
+			d.UpdatedAt,
+		)
+	}
+	if err := p.SendBatch(ctx, &b).Close(); err != nil {
+		return fmt.Errorf("сохранение доставок событий. %w", err)
+	}
+	return nil
+}
+
+// ClaimDeliveries реализация интерфейса Storager. доставки, захваченные другим экземпляром сервиса, пропускаются
+func (p *PostgresStorage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	rows, err := p.Query(
+		ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at=now()+make_interval(secs => $1) WHERE id IN (
+			SELECT id FROM webhook_deliveries WHERE status=$2 AND next_attempt_at<=now() ORDER BY next_attempt_at LIMIT $3 FOR UPDATE SKIP LOCKED
+		) RETURNING `+deliveryColumns,
+		lease.Seconds(),
+		model.DeliveryPending,
+		limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("получение доставок событий. %w", err)
+	}
+	return scanDeliveries(rows)
+}
+
+// UpdateDelivery реализация интерфейса Storager
+func (p *PostgresStorage) UpdateDelivery(ctx context.Context, delivery model.WebhookDelivery) error {
+	tag, err := p.Exec(
+		ctx,
+		"UPDATE webhook_deliveries SET status=$1,attempts=$2,next_attempt_at=$3,response_status=$4,error=$5,updated_at=$6 WHERE id=$7",
+		delivery.Status,
+		delivery.Attempts,
+		delivery.NextAttemptAt,
+		delivery.ResponseStatus,
+		delivery.Error,
+		delivery.UpdatedAt,
+		delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("сохранение доставки события. %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return storage.ErrDeliveryNotFound
+	}
+	return nil
+}
+
+// WebhookDelivery реализация интерфейса Storager
+func (p *PostgresStorage) WebhookDelivery(ctx context.Context, id uuid.UUID) (model.WebhookDelivery, error) {
+	return scanDelivery(p.QueryRow(ctx, "SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id=$1", id))
+}
+
+// WebhookDeliveries реализация интерфейса Storager
+func (p *PostgresStorage) WebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status model.DeliveryStatus, limit int) ([]model.WebhookDelivery, error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE webhook_id=$1 AND ($2::text='' OR status=$2::text) ORDER BY seq DESC"
+	args := []any{webhookID, status}
+	if limit > 0 {
+		query += " LIMIT $3"
+		args = append(args, limit)
+	}
+	rows, err := p.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("получение журнала доставок. %w", err)
+	}
+	return scanDeliveries(rows)
+}
+
+// PurgeDeliveries реализация интерфейса Storager
+func (p *PostgresStorage) PurgeDeliveries(ctx context.Context, before time.Time) (int, error) {
+	tag, err := p.Exec(ctx, "DELETE FROM webhook_deliveries WHERE status IN ($1,$2) AND updated_at<$3", model.DeliveryDelivered, model.DeliveryDead, before)
+	if err != nil {
+		return 0, fmt.Errorf("удаление завершенных доставок. %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// scanWebhook сканирует строку с колонками webhookColumns
+func scanWebhook(row pgx.Row) (model.Webhook, error) {
+	hook := model.Webhook{}
+	events := make([]string, 0)
+	err := row.Scan(
+		&hook.ID,
+		&hook.UserID,
+		&hook.URL,
+		&events,
+		&hook.ClickThreshold,
+		&hook.Secret,
+		&hook.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Webhook{}, storage.ErrHookNotFound
+	}
+	if err != nil {
+		return model.Webhook{}, fmt.Errorf("получение подписки. %w", err)
+	}
+	hook.Events = make([]model.EventType, 0, len(events))
+	for _, e := range events {
+		hook.Events = append(hook.Events, model.EventType(e))
+	}
+	return hook, nil
+}
+
+// scanDelivery сканирует строку с колонками deliveryColumns
+func scanDelivery(row pgx.Row) (model.WebhookDelivery, error) {
+	d := model.WebhookDelivery{}
+	err := row.Scan(
+		&d.ID,
+		&d.WebhookID,
+		&d.Event,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.ResponseStatus,
+		&d.Error,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.WebhookDelivery{}, storage.ErrDeliveryNotFound
+	}
+	if err != nil {
+		return model.WebhookDelivery{}, fmt.Errorf("получение доставки события. %w", err)
+	}
+	return d, nil
+}
+
+// scanDeliveries сканирует все строки rows с колонками deliveryColumns и закрывает их
+func scanDeliveries(rows pgx.Rows) ([]model.WebhookDelivery, error) {
+	defer rows.Close()
+	deliveries := make([]model.WebhookDelivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("получение доставок событий. %w", err)
+	}
+	return deliveries, nil
+}
@@ -3,6 +3,8 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/kTowkA/shortener/internal/model"
@@ -10,15 +12,62 @@ import (
 
 // Возможные стандартные ошибки хранилища
 var (
-	ErrURLNotFound = errors.New("URL не найден")
-	ErrURLConflict = errors.New("оригинальный URL уже был добавлен")
-	ErrURLIsExist  = errors.New("такой ключ занят")
+	ErrURLNotFound      = errors.New("URL не найден")
+	ErrURLConflict      = errors.New("оригинальный URL уже был добавлен")
+	ErrURLIsExist       = errors.New("такой ключ занят")
+	ErrJobNotFound      = errors.New("задание не найдено")
+	ErrKeyNotFound      = errors.New("ключ идемпотентности не найден")
+	ErrQuotaExceeded    = errors.New("суточная квота ссылок исчерпана")
+	ErrTaskNotFound     = errors.New("задача удаления не найдена")
+	ErrHookNotFound     = errors.New("подписка на события не найдена")
+	ErrDeliveryNotFound = errors.New("доставка события не найдена")
+	ErrUserBlocked      = errors.New("пользователю запрещено создавать ссылки")
+	ErrAccountExists    = errors.New("учетная запись уже существует")
+	ErrAccountNotFound  = errors.New("учетная запись не найдена")
+	ErrAPITokenNotFound = errors.New("API токен не найден")
 )
 
+// DeleteError ошибка удаления отдельной ссылки. DeleteURLs возвращает такие ошибки объединенными через errors.Join
+type DeleteError struct {
+	Link model.DeleteURLMessage
+	Err  error
+}
+
+// Error реализация интерфейса error
+func (e *DeleteError) Error() string {
+	return fmt.Sprintf("userID %s, shortURL %s. %v", e.Link.UserID, e.Link.ShortURL, e.Err)
+}
+
+// Unwrap причина ошибки
+func (e *DeleteError) Unwrap() error {
+	return e.Err
+}
+
+// LinkErrors раскладывает ошибку Storager.DeleteURLs по ссылкам.
+// если среди объединенных ошибок есть не относящиеся к отдельной ссылке - удаление не выполнено, она возвращается
+func LinkErrors(err error) (map[model.DeleteURLMessage]error, error) {
+	failures := make(map[model.DeleteURLMessage]error)
+	if err == nil {
+		return failures, nil
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, e := range errs {
+		var linkErr *DeleteError
+		if !errors.As(e, &linkErr) {
+			return nil, err
+		}
+		failures[linkErr.Link] = linkErr.Err
+	}
+	return failures, nil
+}
+
 // Storager интерфейс для последующей реализации хранилища сокращенных ссылок
 type Storager interface {
-	// SaveURL сохраняет пару real-short url
-	SaveURL(ctx context.Context, userID uuid.UUID, real, short string) (string, error)
+	// SaveURL сохраняет пару real-short url вместе с настройками ссылки opts одной записью
+	SaveURL(ctx context.Context, userID uuid.UUID, real, short string, opts model.LinkOptions) (string, error)
 
 	// Batch пакетное сохранение всех значений values
 	Batch(ctx context.Context, userID uuid.UUID, values model.BatchRequest) (model.BatchResponse, error)
@@ -26,17 +75,163 @@ type Storager interface {
 	// RealURL получение оригинального url
 	RealURL(ctx context.Context, short string) (model.StorageJSON, error)
 
+	// UpdateURLOptions изменяет настройки короткой ссылки short, принадлежащей пользователю userID
+	UpdateURLOptions(ctx context.Context, userID uuid.UUID, short string, opts model.LinkOptions) error
+
 	// UserURLs получает все записи сохраненные пользователем
 	UserURLs(ctx context.Context, userID uuid.UUID) ([]model.StorageJSON, error)
 
-	// DeleteURLs удаляет записи сохраненные пользователями
+	// DeleteURLs удаляет записи сохраненные пользователями.
+	// ошибки по отдельным ссылкам (*DeleteError, для ненайденных - с причиной ErrURLNotFound) объединяются через errors.Join
 	DeleteURLs(ctx context.Context, deleteLinks []model.DeleteURLMessage) error
 
+	// AllURLs получает все сохраненные записи (используется фоновыми проверками)
+	AllURLs(ctx context.Context) ([]model.StorageJSONWithUserID, error)
+
+	// SaveLinkChecks сохраняет результаты проверки доступности оригинальных ссылок
+	SaveLinkChecks(ctx context.Context, checks []model.LinkCheck) error
+
+	// BlockURLs блокирует (blocked=true) или разблокирует короткие ссылки shorts независимо от владельца
+	BlockURLs(ctx context.Context, shorts []string, blocked bool) error
+
+	// CreateJob сохраняет новое задание массового сокращения ссылок
+	CreateJob(ctx context.Context, job model.Job) error
+
+	// Job получение задания id вместе с запросом и уже полученными результатами
+	Job(ctx context.Context, id uuid.UUID) (model.Job, error)
+
+	// ClaimJob переводит самое раннее ожидающее задание в обработку и возвращает его.
+	// если ожидающих заданий нет - ErrJobNotFound
+	ClaimJob(ctx context.Context) (model.Job, error)
+
+	// UpdateJob добавляет к заданию id очередную часть результатов
+	UpdateJob(ctx context.Context, id uuid.UUID, progress model.JobProgress) error
+
+	// RequeueJobs возвращает в очередь задания, обработка которых была прервана остановкой сервиса
+	RequeueJobs(ctx context.Context) (int, error)
+
+	// PurgeJobs удаляет завершенные задания, последнее изменение которых было раньше before, и возвращает их количество
+	PurgeJobs(ctx context.Context, before time.Time) (int, error)
+
+	// IdempotentResponse получение ответа, сохраненного для ключа идемпотентности key пользователя userID.
+	// если ключа нет или срок его хранения истек - ErrKeyNotFound
+	IdempotentResponse(ctx context.Context, userID uuid.UUID, key string) (model.IdempotentResponse, error)
+
+	// SaveIdempotentResponse сохраняет ответ для ключа идемпотентности key пользователя userID до resp.ExpiresAt
+	SaveIdempotentResponse(ctx context.Context, userID uuid.UUID, key string, resp model.IdempotentResponse) error
+
+	// ConsumeLinkQuota учитывает n новых ссылок пользователя userID за сутки day.
+	// если вместе с уже учтенными ссылок будет больше limit - ничего не учитывается и возвращается ErrQuotaExceeded
+	ConsumeLinkQuota(ctx context.Context, userID uuid.UUID, day time.Time, n, limit int) error
+
+	// EnqueueDelete сохраняет задачу удаления ссылок, ожидающую в очереди
+	EnqueueDelete(ctx context.Context, task model.DeleteTask) error
+
+	// PendingDeletes возвращает ожидающие задачи удаления в порядке постановки в очередь,
+	// пока количество ссылок в них не достигнет limit (хотя бы одну задачу). limit <= 0 - все задачи
+	PendingDeletes(ctx context.Context, limit int) ([]model.DeleteTask, error)
+
+	// CompleteDelete сохраняет результаты удаления ссылок задачи id и завершает ее
+	CompleteDelete(ctx context.Context, id uuid.UUID, results []model.DeleteResult) error
+
+	// DeleteTask получение задачи удаления id. если задачи нет - ErrTaskNotFound
+	DeleteTask(ctx context.Context, id uuid.UUID) (model.DeleteTask, error)
+
+	// PurgeDeletes удаляет выполненные задачи удаления, завершенные раньше before, и возвращает их количество
+	PurgeDeletes(ctx context.Context, before time.Time) (int, error)
+
+	// CountClick учитывает переход по короткой ссылке short и возвращает количество переходов вместе с владельцем ссылки.
+	// если ссылки нет - ErrURLNotFound
+	CountClick(ctx context.Context, short string) (model.LinkClicks, error)
+
+	// SaveWebhook сохраняет подписку на события ссылок
+	SaveWebhook(ctx context.Context, hook model.Webhook) error
+
+	// Webhook получение подписки id вместе с ключом подписи. если подписки нет - ErrHookNotFound
+	Webhook(ctx context.Context, id uuid.UUID) (model.Webhook, error)
+
+	// UserWebhooks получает все подписки пользователя userID в порядке создания
+	UserWebhooks(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error)
+
+	// DeleteWebhook удаляет подписку id вместе с ее доставками. если подписки нет - ErrHookNotFound
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+
+	// MarkThreshold отмечает, что подписка webhookID сработала на порог переходов по ссылке short.
+	// возвращает false, если подписка по этой ссылке уже срабатывала
+	MarkThreshold(ctx context.Context, webhookID uuid.UUID, short string) (bool, error)
+
+	// EnqueueDeliveries сохраняет доставки событий, ожидающие отправки
+	EnqueueDeliveries(ctx context.Context, deliveries []model.WebhookDelivery) error
+
+	// ClaimDeliveries возвращает до limit ожидающих доставок, время попытки которых наступило, в порядке этого времени.
+	// следующая попытка полученных доставок откладывается на lease, чтобы их не отправили повторно
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]model.WebhookDelivery, error)
+
+	// UpdateDelivery сохраняет состояние доставки delivery. если доставки нет - ErrDeliveryNotFound
+	UpdateDelivery(ctx context.Context, delivery model.WebhookDelivery) error
+
+	// WebhookDelivery получение доставки id. если доставки нет - ErrDeliveryNotFound
+	WebhookDelivery(ctx context.Context, id uuid.UUID) (model.WebhookDelivery, error)
+
+	// WebhookDeliveries журнал доставок подписки webhookID, начиная с последних, но не более limit.
+	// status - только доставки в этом состоянии, пустая строка - все доставки
+	WebhookDeliveries(ctx context.Context, webhookID uuid.UUID, status model.DeliveryStatus, limit int) ([]model.WebhookDelivery, error)
+
+	// PurgeDeliveries удаляет доставленные и недоставленные события, последнее изменение которых было раньше before,
+	// и возвращает их количество
+	PurgeDeliveries(ctx context.Context, before time.Time) (int, error)
+
+	// Link получение ссылки short вместе с владельцем независимо от ее состояния. если ссылки нет - ErrURLNotFound
+	Link(ctx context.Context, short string) (model.StorageJSONWithUserID, error)
+
+	// PurgeURLs окончательно удаляет ссылки shorts независимо от владельца и возвращает удаленные ссылки
+	PurgeURLs(ctx context.Context, shorts []string) ([]model.StorageJSONWithUserID, error)
+
+	// SetUserBlocked запрещает (blocked=true) или разрешает пользователю userID создавать ссылки. reason - причина запрета
+	SetUserBlocked(ctx context.Context, userID uuid.UUID, blocked bool, reason string) error
+
+	// UserBlocked возвращает true, если пользователю userID запрещено создавать ссылки
+	UserBlocked(ctx context.Context, userID uuid.UUID) (bool, error)
+
+	// SaveAudit сохраняет запись журнала действий администраторов
+	SaveAudit(ctx context.Context, record model.AuditRecord) error
+
+	// AuditLog журнал действий администраторов, начиная с последних, но не более limit. limit <= 0 - все записи
+	AuditLog(ctx context.Context, limit int) ([]model.AuditRecord, error)
+
+	// PurgeAudit удаляет записи журнала действий администраторов, созданные раньше before, и возвращает их количество
+	PurgeAudit(ctx context.Context, before time.Time) (int, error)
+
+	// CreateAccount сохраняет учетную запись. если логин или ID уже заняты - ErrAccountExists
+	CreateAccount(ctx context.Context, account model.Account) error
+
+	// AccountByLogin получение учетной записи по логину. если учетной записи нет - ErrAccountNotFound
+	AccountByLogin(ctx context.Context, login string) (model.Account, error)
+
+	// Account получение учетной записи id. если учетной записи нет - ErrAccountNotFound
+	Account(ctx context.Context, id uuid.UUID) (model.Account, error)
+
+	// ClaimURLs передает все ссылки пользователя from пользователю to и возвращает количество переданных ссылок
+	ClaimURLs(ctx context.Context, from, to uuid.UUID) (int, error)
+
+	// SaveAPIToken сохраняет персональный API токен
+	SaveAPIToken(ctx context.Context, token model.APIToken) error
+
+	// APIToken получение API токена по хэшу его значения. если токена нет - ErrAPITokenNotFound
+	APIToken(ctx context.Context, hash string) (model.APIToken, error)
+
+	// UserAPITokens получает все API токены пользователя userID в порядке создания
+	UserAPITokens(ctx context.Context, userID uuid.UUID) ([]model.APIToken, error)
+
+	// DeleteAPIToken удаляет API токен id пользователя userID. если токена нет - ErrAPITokenNotFound
+	DeleteAPIToken(ctx context.Context, userID, id uuid.UUID) error
+
 	// Ping проверка доступности хранилища
 	Ping(ctx context.Context) error
 
-	// Stats статистика по использованию сервиса
-	Stats(ctx context.Context) (model.StatsResponse, error)
+	// Stats статистика по использованию сервиса. счетчики ведутся при изменении ссылок, а не подсчитываются
+	// по всем ссылкам при запросе. ряд созданных ссылок - за период req, списки лидеров - не длиннее req.Top
+	Stats(ctx context.Context, req model.StatsRequest) (model.StatsResponse, error)
 
 	// Close закрытие хранилища
 	Close() error
@@ -0,0 +1,257 @@
+// пакет threat реализует офлайн проверку ссылок по локальной базе угроз.
+// база представляет собой файл со списком префиксов SHA256 хэшей в формате, близком к Safe Browsing:
+// одна строка - один префикс в шестнадцатеричном виде (от 4 до 32 байт), строки начинающиеся с # и пустые строки пропускаются.
+// хэши считаются от выражений вида host/path, полученных из канонизированной ссылки
+package threat
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minPrefixLen = 4
+	maxPrefixLen = sha256.Size
+
+	// maxHostSuffixes максимальное количество дополнительных вариантов хоста
+	maxHostSuffixes = 4
+	// maxPathPrefixes максимальное количество префиксов пути (включая корень)
+	maxPathPrefixes = 4
+)
+
+// List список префиксов хэшей опасных ресурсов.
+// безопасен для конкурентного использования. Методы можно вызывать у nil - в этом случае совпадений нет
+type List struct {
+	mu       sync.RWMutex
+	path     string
+	modTime  time.Time
+	prefixes map[int]map[string]struct{}
+	count    int
+}
+
+// Load загружает список угроз из файла path
+func Load(path string) (*List, error) {
+	l := &List{path: path}
+	if _, err := l.Reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reload перечитывает файл со списком, если он изменился с момента последней загрузки.
+// возвращает true, если список был перечитан
+func (l *List) Reload() (bool, error) {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false, fmt.Errorf("получение информации о файле %s. %w", l.path, err)
+	}
+
+	l.mu.RLock()
+	unchanged := !l.modTime.IsZero() && info.ModTime().Equal(l.modTime)
+	l.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	prefixes, count, err := readPrefixes(l.path)
+	if err != nil {
+		return false, err
+	}
+
+	l.mu.Lock()
+	l.prefixes = prefixes
+	l.count = count
+	l.modTime = info.ModTime()
+	l.mu.Unlock()
+	return true, nil
+}
+
+// Len количество загруженных префиксов
+func (l *List) Len() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.count
+}
+
+// Match проверяет находится ли ссылка rawURL в списке угроз
+func (l *List) Match(rawURL string) bool {
+	if l == nil {
+		return false
+	}
+	expressions, err := Expressions(rawURL)
+	if err != nil {
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.count == 0 {
+		return false
+	}
+	for _, e := range expressions {
+		sum := sha256.Sum256([]byte(e))
+		for length, set := range l.prefixes {
+			if _, ok := set[string(sum[:length])]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Hash возвращает полный SHA256 хэш выражения expression в шестнадцатеричном виде. Удобно для подготовки файла со списком
+func Hash(expression string) string {
+	sum := sha256.Sum256([]byte(expression))
+	return hex.EncodeToString(sum[:])
+}
+
+// Expressions возвращает набор выражений host/path для проверки ссылки rawURL по аналогии с Safe Browsing:
+// точный хост и до четырех его суффиксов, точный путь с запросом и без, а также до четырех префиксов пути
+func Expressions(rawURL string) ([]string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("разбор ссылки %s. %w", rawURL, err)
+	}
+	host := canonicalHost(u.Hostname())
+	if host == "" {
+		return nil, fmt.Errorf("в ссылке %s отсутствует хост", rawURL)
+	}
+	hosts := hostSuffixes(host)
+	paths := pathPrefixes(canonicalPath(u.EscapedPath()), u.RawQuery)
+
+	result := make([]string, 0, len(hosts)*len(paths))
+	for _, h := range hosts {
+		for _, p := range paths {
+			result = append(result, h+p)
+		}
+	}
+	return result, nil
+}
+
+func readPrefixes(path string) (map[int]map[string]struct{}, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("открытие файла %s. %w", path, err)
+	}
+	defer file.Close()
+
+	prefixes := make(map[int]map[string]struct{})
+	count := 0
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" || strings.HasPrefix(raw, "#") {
+			continue
+		}
+		prefix, err := hex.DecodeString(strings.ToLower(raw))
+		if err != nil {
+			return nil, 0, fmt.Errorf("строка %d файла %s. %w", line, path, err)
+		}
+		if len(prefix) < minPrefixLen || len(prefix) > maxPrefixLen {
+			return nil, 0, fmt.Errorf("строка %d файла %s. длина префикса должна быть от %d до %d байт", line, path, minPrefixLen, maxPrefixLen)
+		}
+		if prefixes[len(prefix)] == nil {
+			prefixes[len(prefix)] = make(map[string]struct{})
+		}
+		if _, ok := prefixes[len(prefix)][string(prefix)]; !ok {
+			prefixes[len(prefix)][string(prefix)] = struct{}{}
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("чтение файла %s. %w", path, err)
+	}
+	return prefixes, count, nil
+}
+
+// canonicalHost приводит хост к нижнему регистру, убирает лишние точки
+func canonicalHost(host string) string {
+	host = strings.ToLower(strings.Trim(host, "."))
+	for strings.Contains(host, "..") {
+		host = strings.ReplaceAll(host, "..", ".")
+	}
+	return host
+}
+
+// canonicalPath убирает из пути "/./", "/../" и повторяющиеся слэши, сохраняя завершающий слэш
+func canonicalPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segments := strings.Split(p, "/")
+	cleaned := make([]string, 0, len(segments))
+	for _, s := range segments {
+		switch s {
+		case "", ".":
+		case "..":
+			if len(cleaned) > 0 {
+				cleaned = cleaned[:len(cleaned)-1]
+			}
+		default:
+			cleaned = append(cleaned, s)
+		}
+	}
+	result := "/" + strings.Join(cleaned, "/")
+	last := segments[len(segments)-1]
+	if len(cleaned) > 0 && (last == "" || last == "." || last == "..") {
+		result += "/"
+	}
+	return result
+}
+
+func hostSuffixes(host string) []string {
+	result := []string{host}
+	if net.ParseIP(host) != nil {
+		return result
+	}
+	parts := strings.Split(host, ".")
+	start := len(parts) - maxHostSuffixes - 1
+	if start < 1 {
+		start = 1
+	}
+	for i := start; i < len(parts)-1; i++ {
+		result = append(result, strings.Join(parts[i:], "."))
+	}
+	return result
+}
+
+func pathPrefixes(p, query string) []string {
+	result := make([]string, 0, maxPathPrefixes+2)
+	seen := make(map[string]bool)
+	add := func(v string) {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	if query != "" {
+		add(p + "?" + query)
+	}
+	add(p)
+
+	// директории пути. последний сегмент без завершающего слэша является файлом и в префиксы не попадает
+	dirs := strings.Split(strings.Trim(p, "/"), "/")
+	if !strings.HasSuffix(p, "/") || dirs[0] == "" {
+		dirs = dirs[:len(dirs)-1]
+	}
+	prefix := "/"
+	add(prefix)
+	for i := 0; i < len(dirs) && i < maxPathPrefixes-1; i++ {
+		prefix += dirs[i] + "/"
+		add(prefix)
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package threat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "threats.txt")
+	err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600)
+	require.NoError(t, err)
+	return path
+}
+
+func TestExpressions(t *testing.T) {
+	expressions, err := Expressions("http://a.b.c/1/2.html?param=1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		"a.b.c/1/2.html?param=1",
+		"a.b.c/1/2.html",
+		"a.b.c/",
+		"a.b.c/1/",
+		"b.c/1/2.html?param=1",
+		"b.c/1/2.html",
+		"b.c/",
+		"b.c/1/",
+	}, expressions)
+
+	expressions, err = Expressions("http://1.2.3.4/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.2.3.4/"}, expressions)
+
+	expressions, err = Expressions("https://A.B.C.D.E.F.G/x/./y/../z")
+	require.NoError(t, err)
+	assert.Contains(t, expressions, "a.b.c.d.e.f.g/x/z")
+	assert.Contains(t, expressions, "c.d.e.f.g/x/")
+	assert.NotContains(t, expressions, "b.c.d.e.f.g/")
+
+	_, err = Expressions("/only/path")
+	assert.Error(t, err)
+}
+
+func TestMatch(t *testing.T) {
+	path := writeList(t,
+		"# тестовый список",
+		"",
+		Hash("evil.com/")[:8],
+		Hash("example.org/phishing/login.html"),
+	)
+	l, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, l.Len())
+
+	tests := []struct {
+		url   string
+		match bool
+	}{
+		{"http://evil.com", true},
+		{"https://sub.evil.com/any/page?x=1", true},
+		{"http://example.org/phishing/login.html", true},
+		{"http://example.org/phishing/other.html", false},
+		{"https://go.dev", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, l.Match(tt.url), tt.url)
+	}
+
+	var nilList *List
+	assert.False(t, nilList.Match("http://evil.com"))
+	assert.Zero(t, nilList.Len())
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "not_exist"))
+	assert.Error(t, err)
+
+	_, err = Load(writeList(t, "zz"))
+	assert.Error(t, err)
+
+	_, err = Load(writeList(t, "abcd"))
+	assert.Error(t, err, "слишком короткий префикс")
+}
+
+func TestReload(t *testing.T) {
+	path := writeList(t, Hash("evil.com/"))
+	l, err := Load(path)
+	require.NoError(t, err)
+
+	changed, err := l.Reload()
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.False(t, l.Match("http://bad.net"))
+
+	err = os.WriteFile(path, []byte(Hash("bad.net/")), 0o600)
+	require.NoError(t, err)
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, future, future))
+
+	changed, err = l.Reload()
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, l.Match("http://bad.net"))
+	assert.False(t, l.Match("http://evil.com"))
+}
@@ -3,7 +3,9 @@ package utils
 import (
 	"crypto/sha1"
 	"encoding/hex"
+	"fmt"
 	"net/url"
+	"path"
 	"strings"
 	"time"
 
@@ -59,18 +61,28 @@ func GenerateShortStringsSHA1ForBatch(batch model.BatchRequest) error {
 	return nil
 }
 
-// ValidateAndGenerateBatch проверяет переданный batch, удаляя пустые значения и невалидные ссылки. Возвращает model.BatchRequest только с валидными ссылками
-func ValidateAndGenerateBatch(batch model.BatchRequest) model.BatchRequest {
-	newBatch := make([]model.BatchRequestElement, 0, len(batch))
-	for _, v := range batch {
-		v.OriginalURL = strings.TrimSpace(v.OriginalURL)
-		if v.OriginalURL == "" {
-			continue
-		}
-		if _, err := url.ParseRequestURI(v.OriginalURL); err != nil {
-			continue
+// PassthroughURL добавляет к оригинальной ссылке original дополнительный путь extraPath и параметры запроса rawQuery.
+// параметры объединяются с собственными параметрами оригинальной ссылки (собственные идут первыми)
+func PassthroughURL(original, extraPath, rawQuery string) (string, error) {
+	u, err := url.Parse(original)
+	if err != nil {
+		return "", fmt.Errorf("разбор оригинальной ссылки. %w", err)
+	}
+	if extraPath != "" {
+		// не даем выйти за пределы пути оригинальной ссылки
+		extra := path.Clean("/" + extraPath)
+		if strings.HasSuffix(extraPath, "/") && extra != "/" {
+			extra += "/"
 		}
-		newBatch = append(newBatch, v)
+		u.Path = strings.TrimSuffix(u.Path, "/") + extra
+		u.RawPath = ""
+	}
+	switch {
+	case rawQuery == "":
+	case u.RawQuery == "":
+		u.RawQuery = rawQuery
+	default:
+		u.RawQuery += "&" + rawQuery
 	}
-	return newBatch
+	return u.String(), nil
 }
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+)
+
+const (
+	// defaultStatsTop длина списков лидеров статистики по умолчанию, maxStatsTop - наибольшая
+	defaultStatsTop = 10
+	maxStatsTop     = 100
+	// maxStatsPoints наибольшее количество шагов ряда созданных ссылок
+	maxStatsPoints = 1000
+)
+
+// defaultStatsPoints количество шагов ряда созданных ссылок по умолчанию
+var defaultStatsPoints = map[model.StatsGranularity]int{
+	model.StatsHour: 24,
+	model.StatsDay:  30,
+}
+
+// ParseStatsRequest разбирает параметры статистики. from и to - включительные границы ряда созданных ссылок
+// в формате RFC 3339 или 2006-01-02, по умолчанию ряд заканчивается шагом, в который попадает now.
+// granularity - шаг ряда (hour или day, по умолчанию day), top - длина списков лидеров (по умолчанию 10, не более 100).
+// некорректные параметры - ошибка с кодом bad_request
+func ParseStatsRequest(from, to, granularity string, top int, now time.Time) (model.StatsRequest, error) {
+	req := model.StatsRequest{Granularity: model.StatsGranularity(granularity), Top: top}
+	switch req.Granularity {
+	case "":
+		req.Granularity = model.StatsDay
+	case model.StatsHour, model.StatsDay:
+	default:
+		return model.StatsRequest{}, apierror.New(apierror.CodeBadRequest).WithDetails("granularity")
+	}
+	switch {
+	case req.Top == 0:
+		req.Top = defaultStatsTop
+	case req.Top < 0:
+		return model.StatsRequest{}, apierror.New(apierror.CodeBadRequest).WithDetails("top")
+	}
+	req.Top = min(req.Top, maxStatsTop)
+
+	step := req.Granularity.Step()
+	last := req.Granularity.Truncate(now)
+	if to != "" {
+		t, err := parseStatsTime(to)
+		if err != nil {
+			return model.StatsRequest{}, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails("to")
+		}
+		last = req.Granularity.Truncate(t)
+	}
+	req.To = last.Add(step)
+	req.From = req.To.Add(-time.Duration(defaultStatsPoints[req.Granularity]) * step)
+	if from != "" {
+		t, err := parseStatsTime(from)
+		if err != nil {
+			return model.StatsRequest{}, apierror.Wrap(apierror.CodeBadRequest, err).WithDetails("from")
+		}
+		req.From = req.Granularity.Truncate(t)
+	}
+	if !req.From.Before(req.To) {
+		return model.StatsRequest{}, apierror.New(apierror.CodeBadRequest).WithDetails("from")
+	}
+	if req.To.Sub(req.From) > time.Duration(maxStatsPoints)*step {
+		return model.StatsRequest{}, apierror.New(apierror.CodeBadRequest).WithDetails(fmt.Sprintf("не более %d шагов", maxStatsPoints))
+	}
+	return req, nil
+}
+
+// parseStatsTime разбирает время в формате RFC 3339 или дату 2006-01-02 (в UTC)
+func parseStatsTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("разбор времени %s. %w", value, err)
+	}
+	return t, nil
+}
@@ -14,6 +14,12 @@ import (
 
 // SaveLink пробует сгенерировать новую сокращенную ссылку для link за attems попыток для пользователя userID и сохранить в store.
 func SaveLink(ctx context.Context, store storage.Storager, userID uuid.UUID, link string) (string, error) {
+	return SaveLinkWithOptions(ctx, store, userID, link, model.LinkOptions{})
+}
+
+// SaveLinkWithOptions сохраняет ссылку как SaveLink вместе с настройками opts: ссылка и ее настройки записываются
+// в хранилище одной операцией. при конфликте настройки ранее сохраненной ссылки не изменяются
+func SaveLinkWithOptions(ctx context.Context, store storage.Storager, userID uuid.UUID, link string, opts model.LinkOptions) (string, error) {
 	shortLink, err := GenerateShortStringSHA1(link, defaultLenght)
 	if err != nil {
 		return "", err
@@ -21,7 +27,7 @@ func SaveLink(ctx context.Context, store storage.Storager, userID uuid.UUID, lin
 
 	// создаем короткую ссылка за attems попыток генерации
 	for i := 0; i < attempts; i++ {
-		savedLink, err := store.SaveURL(ctx, userID, link, shortLink)
+		savedLink, err := store.SaveURL(ctx, userID, link, shortLink, opts)
 		// такая ссылка уже существует
 		if errors.Is(err, storage.ErrURLIsExist) {
 			shortLink = shortLink + bonus[rand.New(rand.NewSource(time.Now().UnixNano())).Intn(len(bonus))]
@@ -52,8 +58,8 @@ func SaveBatch(ctx context.Context, store storage.Storager, userID uuid.UUID, ba
 		if err != nil {
 			return nil, err
 		}
-		// очищаем batch и будем складывать в него строки с коллизиями
-		batch = make(model.BatchRequest, 0)
+		// будем складывать строки с коллизиями и пробовать сохранить их еще раз
+		retry := make(model.BatchRequest, 0)
 		for i := range resp {
 			if resp[i].ShortURL != "" {
 				// resp[i].ShortURL = s.Config.BaseAddress() + resp[i].ShortURL
@@ -62,13 +68,19 @@ func SaveBatch(ctx context.Context, store storage.Storager, userID uuid.UUID, ba
 			}
 			// если были колизии пробуем сохранить с добавлением подстроки
 			if resp[i].Collision {
-				batch = append(batch, model.BatchRequestElement{
+				e := model.BatchRequestElement{
 					CorrelationID: resp[i].CorrelationID,
 					OriginalURL:   resp[i].OriginalURL,
 					ShortURL:      resp[i].ShortURL,
-				})
+				}
+				// ответ хранилища идет в том же порядке, что и запрос
+				if i < len(batch) {
+					e.LinkOptions = batch[i].LinkOptions
+				}
+				retry = append(retry, e)
 			}
 		}
+		batch = retry
 		if len(batch) == 0 {
 			break
 		}
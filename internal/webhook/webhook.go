@@ -0,0 +1,557 @@
+// пакет webhook реализует доставку событий ссылок на адреса, зарегистрированные пользователями.
+// по каждому событию для подходящих подписок в хранилище сохраняются доставки, которые фоновый диспетчер
+// отправляет POST запросом с подписью HMAC-SHA256. неудачные попытки повторяются с экспоненциально растущей
+// задержкой, после исчерпания попыток доставка попадает в список недоставленных и может быть отправлена повторно
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/netguard"
+	"github.com/kTowkA/shortener/internal/storage"
+)
+
+// заголовки запроса доставки события
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	// HeaderSignature подпись "sha256=<hex>" строки "<timestamp>.<тело запроса>" ключом подписки
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	defaultBuffer       = 1000
+	defaultWorkers      = 4
+	defaultPollInterval = time.Second
+	defaultTimeout      = 10 * time.Second
+	defaultMaxAttempts  = 8
+	defaultBaseDelay    = 10 * time.Second
+	defaultMaxDelay     = time.Hour
+	defaultRetention    = 7 * 24 * time.Hour
+
+	// drainTimeout время на сохранение доставок по событиям, полученным до остановки
+	drainTimeout = 10 * time.Second
+	// maxDiscard сколько байт ответа подписчика читается, чтобы переиспользовать соединение
+	maxDiscard = 4 << 10
+	// maxPurgeInterval наибольший интервал удаления завершенных доставок
+	maxPurgeInterval = time.Hour
+)
+
+// Events типы событий, на которые можно подписаться
+var Events = []model.EventType{model.EventLinkCreated, model.EventLinkDeleted, model.EventClickThreshold}
+
+// Options настройки доставки событий
+type Options struct {
+	// Buffer количество событий, ожидающих обработки. события сверх буфера отбрасываются
+	Buffer int
+	// Workers количество одновременно отправляемых доставок
+	Workers int
+	// PollInterval периодичность проверки доставок, время повторной попытки которых наступило
+	PollInterval time.Duration
+	// Timeout время ожидания ответа подписчика
+	Timeout time.Duration
+	// MaxAttempts количество попыток, после которых доставка попадает в список недоставленных
+	MaxAttempts int
+	// BaseDelay задержка перед второй попыткой, каждая следующая вдвое больше, но не больше MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Retention время хранения доставленных и недоставленных событий, после которого они удаляются из журнала
+	Retention time.Duration
+	// Client HTTP клиент доставки. если не задан - клиент с ожиданием Timeout, который не переходит по перенаправлениям
+	// и не подключается к адресам внутренней сети (netguard)
+	Client *http.Client
+	// AllowPrivate разрешает адреса подписок во внутренней сети (loopback, частные сети). только для тестов
+	AllowPrivate bool
+	// Logger логгер ошибок доставки. если не задан - slog.Default()
+	Logger *slog.Logger
+}
+
+// Dispatcher подписки пользователей на события и фоновая доставка событий
+type Dispatcher struct {
+	store  storage.Storager
+	opts   Options
+	events chan model.Event
+	wake   chan struct{}
+}
+
+// New создает новый экземпляр Dispatcher. Незаполненные настройки заменяются значениями по умолчанию
+func New(store storage.Storager, opts Options) *Dispatcher {
+	if opts.Buffer <= 0 {
+		opts.Buffer = defaultBuffer
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = defaultBaseDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = defaultMaxDelay
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = defaultRetention
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{
+			Timeout: opts.Timeout,
+			// перенаправление на другой адрес не считается доставкой и не выполняется
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		if !opts.AllowPrivate {
+			opts.Client.Transport = netguard.Transport()
+		}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Dispatcher{
+		store:  store,
+		opts:   opts,
+		events: make(chan model.Event, opts.Buffer),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Publish ставит событие e в очередь обработки. не блокирует: если очередь заполнена, событие отбрасывается.
+// для nil ничего не делает
+func (d *Dispatcher) Publish(e model.Event) {
+	if d == nil {
+		return
+	}
+	select {
+	case d.events <- e:
+	default:
+		d.opts.Logger.Warn("очередь событий заполнена, событие пропущено", slog.String("тип", string(e.Type)), slog.String("short", e.ShortURL))
+	}
+}
+
+// Subscribe создает подписку пользователя userID на события по запросу req.
+// возвращаемая подписка содержит ключ подписи, который больше не будет показан
+func (d *Dispatcher) Subscribe(ctx context.Context, userID uuid.UUID, req model.WebhookRequest) (model.Webhook, error) {
+	if err := validate(req); err != nil {
+		return model.Webhook{}, apierror.New(apierror.CodeInvalidWebhook).WithDetails(err.Error())
+	}
+	if err := d.checkAddress(ctx, req.URL); err != nil {
+		return model.Webhook{}, apierror.New(apierror.CodeInvalidWebhook).WithDetails(err.Error())
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return model.Webhook{}, fmt.Errorf("создание ключа подписи. %w", err)
+	}
+	hook := model.Webhook{
+		ID:             uuid.New(),
+		UserID:         userID,
+		URL:            req.URL,
+		Events:         req.Events,
+		ClickThreshold: req.ClickThreshold,
+		Secret:         hex.EncodeToString(secret),
+		CreatedAt:      time.Now().UTC(),
+	}
+	if err := d.store.SaveWebhook(ctx, hook); err != nil {
+		return model.Webhook{}, fmt.Errorf("сохранение подписки. %w", err)
+	}
+	return hook, nil
+}
+
+// Webhooks возвращает подписки пользователя userID без ключей подписи
+func (d *Dispatcher) Webhooks(ctx context.Context, userID uuid.UUID) ([]model.Webhook, error) {
+	hooks, err := d.store.UserWebhooks(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("получение подписок. %w", err)
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, nil
+}
+
+// Unsubscribe удаляет подписку id пользователя userID вместе с журналом доставок
+func (d *Dispatcher) Unsubscribe(ctx context.Context, userID, id uuid.UUID) error {
+	if _, err := d.webhook(ctx, userID, id); err != nil {
+		return err
+	}
+	return d.store.DeleteWebhook(ctx, id)
+}
+
+// Deliveries журнал доставок подписки id пользователя userID, начиная с последних, но не более limit.
+// status - только доставки в этом состоянии (например, model.DeliveryDead - список недоставленных)
+func (d *Dispatcher) Deliveries(ctx context.Context, userID, id uuid.UUID, status model.DeliveryStatus, limit int) ([]model.WebhookDelivery, error) {
+	if _, err := d.webhook(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	return d.store.WebhookDeliveries(ctx, id, status, limit)
+}
+
+// Redeliver ставит доставку deliveryID подписки id пользователя userID в очередь на повторную отправку
+// с новым набором попыток. ожидающая отправки доставка не изменяется
+func (d *Dispatcher) Redeliver(ctx context.Context, userID, id, deliveryID uuid.UUID) (model.WebhookDelivery, error) {
+	if _, err := d.webhook(ctx, userID, id); err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	delivery, err := d.store.WebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return model.WebhookDelivery{}, err
+	}
+	if delivery.WebhookID != id {
+		return model.WebhookDelivery{}, storage.ErrDeliveryNotFound
+	}
+	if delivery.Status == model.DeliveryPending {
+		return delivery, nil
+	}
+	now := time.Now().UTC()
+	delivery.Status = model.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	if err = d.store.UpdateDelivery(ctx, delivery); err != nil {
+		return model.WebhookDelivery{}, fmt.Errorf("повторная доставка события. %w", err)
+	}
+	d.notify()
+	return delivery, nil
+}
+
+// webhook подписка id, принадлежащая пользователю userID. чужие подписки не находятся
+func (d *Dispatcher) webhook(ctx context.Context, userID, id uuid.UUID) (model.Webhook, error) {
+	hook, err := d.store.Webhook(ctx, id)
+	if err != nil {
+		return model.Webhook{}, err
+	}
+	if hook.UserID != userID {
+		return model.Webhook{}, storage.ErrHookNotFound
+	}
+	return hook, nil
+}
+
+// Run обрабатывает события и отправляет доставки до отмены ctx.
+// события, полученные до отмены, сохраняются как доставки и будут отправлены после перезапуска
+func (d *Dispatcher) Run(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.deliverLoop(ctx)
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			d.drain()
+			<-done
+			return
+		case e := <-d.events:
+			// событие уже получено из очереди, поэтому доставки сохраняются и при одновременной остановке
+			d.handle(context.WithoutCancel(ctx), e)
+		}
+	}
+}
+
+// drain сохраняет доставки по событиям, оставшимся в очереди
+func (d *Dispatcher) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+	for {
+		select {
+		case e := <-d.events:
+			d.handle(ctx, e)
+		default:
+			return
+		}
+	}
+}
+
+// handle сохраняет доставки события e для подписок его владельца.
+// события перехода по ссылке должны содержать владельца и количество переходов (см. events.Bus)
+func (d *Dispatcher) handle(ctx context.Context, e model.Event) {
+	hooks, err := d.store.UserWebhooks(ctx, e.UserID)
+	if err != nil {
+		d.opts.Logger.Error("получение подписок на события", slog.String("ошибка", err.Error()))
+		return
+	}
+	now := time.Now().UTC()
+	deliveries := make([]model.WebhookDelivery, 0)
+	for _, hook := range hooks {
+		event, ok := match(hook, e)
+		if !ok || !d.firstThreshold(ctx, hook, event) {
+			continue
+		}
+		deliveries = append(deliveries, model.WebhookDelivery{
+			ID:            uuid.New(),
+			WebhookID:     hook.ID,
+			Event:         event,
+			Status:        model.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	if err = d.store.EnqueueDeliveries(ctx, deliveries); err != nil {
+		d.opts.Logger.Error("сохранение доставок события", slog.String("тип", string(e.Type)), slog.String("ошибка", err.Error()))
+		return
+	}
+	d.notify()
+}
+
+// match событие, которое получает подписка hook по событию e. переход по ссылке порождает событие
+// link.click_threshold, когда количество переходов достигает порога подписки или превышает его
+// (переходы, события которых были пропущены шиной, тоже учтены в количестве)
+func match(hook model.Webhook, e model.Event) (model.Event, bool) {
+	if e.Type != model.EventLinkClicked {
+		return e, hook.Subscribed(e.Type)
+	}
+	if !hook.Subscribed(model.EventClickThreshold) || e.Clicks < hook.ClickThreshold {
+		return model.Event{}, false
+	}
+	e.Type = model.EventClickThreshold
+	return e, true
+}
+
+// firstThreshold отмечает срабатывание подписки hook на порог переходов по ссылке события e и возвращает true,
+// если подписка по этой ссылке срабатывает впервые. остальные события не проверяются.
+// отметка сохраняется до доставки: при ошибке сохранения доставки событие будет потеряно, но не повторено
+func (d *Dispatcher) firstThreshold(ctx context.Context, hook model.Webhook, e model.Event) bool {
+	if e.Type != model.EventClickThreshold {
+		return true
+	}
+	first, err := d.store.MarkThreshold(ctx, hook.ID, e.ShortURL)
+	if err != nil {
+		d.opts.Logger.Error("отметка срабатывания подписки на порог переходов", slog.String("short", e.ShortURL), slog.String("ошибка", err.Error()))
+		return false
+	}
+	return first
+}
+
+// notify будит отправку доставок
+func (d *Dispatcher) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverLoop отправляет доставки, время попытки которых наступило, пока они есть, затем ждет.
+// периодически удаляет завершенные доставки старше времени хранения
+func (d *Dispatcher) deliverLoop(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+	purge := time.NewTicker(min(d.opts.Retention, maxPurgeInterval))
+	defer purge.Stop()
+	for {
+		for ctx.Err() == nil {
+			if d.deliverDue(ctx) < d.opts.Workers {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-ticker.C:
+		case <-purge.C:
+			d.purge(ctx)
+		}
+	}
+}
+
+// purge удаляет завершенные доставки старше времени хранения
+func (d *Dispatcher) purge(ctx context.Context) {
+	count, err := d.store.PurgeDeliveries(ctx, time.Now().Add(-d.opts.Retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			d.opts.Logger.Error("удаление завершенных доставок событий", slog.String("ошибка", err.Error()))
+		}
+		return
+	}
+	if count > 0 {
+		d.opts.Logger.Debug("удалены завершенные доставки событий", slog.Int("количество", count))
+	}
+}
+
+// deliverDue одновременно отправляет до Workers доставок и возвращает их количество
+func (d *Dispatcher) deliverDue(ctx context.Context) int {
+	// на время отправки доставки не выдаются другим обработчикам
+	deliveries, err := d.store.ClaimDeliveries(ctx, d.opts.Workers, 2*d.opts.Timeout)
+	if err != nil {
+		if ctx.Err() == nil {
+			d.opts.Logger.Error("получение доставок событий", slog.String("ошибка", err.Error()))
+		}
+		return 0
+	}
+	wg := sync.WaitGroup{}
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery model.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+// deliver выполняет попытку доставки и сохраняет ее результат
+func (d *Dispatcher) deliver(ctx context.Context, delivery model.WebhookDelivery) {
+	hook, err := d.store.Webhook(ctx, delivery.WebhookID)
+	if errors.Is(err, storage.ErrHookNotFound) {
+		// подписка удалена вместе с доставками
+		return
+	}
+	if err != nil {
+		d.opts.Logger.Error("получение подписки", slog.String("подписка", delivery.WebhookID.String()), slog.String("ошибка", err.Error()))
+		return
+	}
+
+	status, err := d.send(ctx, hook, delivery)
+	if ctx.Err() != nil {
+		// попытка прервана остановкой сервиса и не учитывается, доставка будет отправлена после перезапуска
+		return
+	}
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.ResponseStatus = status
+	delivery.UpdatedAt = now
+	delivery.Error = ""
+	switch {
+	case err == nil:
+		delivery.Status = model.DeliveryDelivered
+	case delivery.Attempts >= d.opts.MaxAttempts:
+		delivery.Status = model.DeliveryDead
+		delivery.Error = err.Error()
+		d.opts.Logger.Warn("событие не доставлено", slog.String("подписка", hook.ID.String()), slog.String("доставка", delivery.ID.String()), slog.String("ошибка", err.Error()))
+	default:
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = now.Add(d.backoff(delivery.Attempts))
+	}
+	if err = d.store.UpdateDelivery(ctx, delivery); err != nil && !errors.Is(err, storage.ErrDeliveryNotFound) {
+		d.opts.Logger.Error("сохранение доставки события", slog.String("доставка", delivery.ID.String()), slog.String("ошибка", err.Error()))
+	}
+}
+
+// send отправляет событие доставки подписчику hook. возвращает HTTP статус ответа и ошибку, если событие не принято
+func (d *Dispatcher) send(ctx context.Context, hook model.Webhook, delivery model.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return 0, fmt.Errorf("кодирование события. %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("создание запроса. %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "shortener-webhook")
+	req.Header.Set(HeaderEvent, string(delivery.Event.Type))
+	req.Header.Set(HeaderDelivery, delivery.ID.String())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(hook.Secret, timestamp, body))
+
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("отправка события. %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDiscard))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp.StatusCode, nil
+	}
+	// тело ответа в журнал не сохраняется: журнал доступен владельцу подписки
+	return resp.StatusCode, fmt.Errorf("подписчик ответил %d", resp.StatusCode)
+}
+
+// backoff задержка перед попыткой, следующей за попыткой attempt
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.opts.BaseDelay
+	for i := 1; i < attempt && delay < d.opts.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, d.opts.MaxDelay)
+}
+
+// Sign подпись HMAC-SHA256 (hex) тела события body с меткой времени timestamp ключом secret.
+// подписчик проверяет заголовок HeaderSignature, вычисляя подпись по заголовку HeaderTimestamp и телу запроса
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validate проверяет запрос на подписку
+func validate(req model.WebhookRequest) error {
+	u, err := url.ParseRequestURI(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("адрес подписки должен быть абсолютным http(s) адресом")
+	}
+	if len(req.Events) == 0 {
+		return fmt.Errorf("не указаны события")
+	}
+	for _, e := range req.Events {
+		if !knownEvent(e) {
+			return fmt.Errorf("неизвестное событие %s", e)
+		}
+	}
+	threshold := model.Webhook{Events: req.Events}.Subscribed(model.EventClickThreshold)
+	if threshold && req.ClickThreshold <= 0 {
+		return fmt.Errorf("для события %s нужен положительный click_threshold", model.EventClickThreshold)
+	}
+	if !threshold && req.ClickThreshold != 0 {
+		return fmt.Errorf("click_threshold используется только с событием %s", model.EventClickThreshold)
+	}
+	return nil
+}
+
+// checkAddress проверяет, что адрес подписки rawURL не ведет во внутреннюю сеть сервиса
+func (d *Dispatcher) checkAddress(ctx context.Context, rawURL string) error {
+	if d.opts.AllowPrivate {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("адрес подписки. %w", err)
+	}
+	if err = netguard.CheckHost(ctx, u.Hostname()); err != nil {
+		return fmt.Errorf("адрес подписки. %w", err)
+	}
+	return nil
+}
+
+// knownEvent возвращает true, если на события типа t можно подписаться
+func knownEvent(t model.EventType) bool {
+	for _, e := range Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
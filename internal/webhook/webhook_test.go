@@ -0,0 +1,293 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/kTowkA/shortener/internal/apierror"
+	"github.com/kTowkA/shortener/internal/events"
+	"github.com/kTowkA/shortener/internal/model"
+	"github.com/kTowkA/shortener/internal/storage"
+	"github.com/kTowkA/shortener/internal/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver подписчик, проверяющий подписи и запоминающий полученные события
+type receiver struct {
+	t      *testing.T
+	secret atomic.Value
+	status atomic.Int32
+
+	mu     sync.Mutex
+	events []model.Event
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	require.NoError(rc.t, err)
+	secret, _ := rc.secret.Load().(string)
+	assert.Equal(rc.t, "sha256="+Sign(secret, r.Header.Get(HeaderTimestamp), body), r.Header.Get(HeaderSignature))
+	assert.NotEmpty(rc.t, r.Header.Get(HeaderDelivery))
+
+	e := model.Event{}
+	require.NoError(rc.t, json.Unmarshal(body, &e))
+	assert.Equal(rc.t, string(e.Type), r.Header.Get(HeaderEvent))
+	rc.mu.Lock()
+	rc.events = append(rc.events, e)
+	rc.mu.Unlock()
+	w.WriteHeader(int(rc.status.Load()))
+	_, _ = w.Write([]byte("internal details"))
+}
+
+func (rc *receiver) received() []model.Event {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]model.Event(nil), rc.events...)
+}
+
+func newDispatcher(t *testing.T) (*Dispatcher, *receiver, string) {
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	rc := &receiver{t: t}
+	rc.status.Store(http.StatusNoContent)
+	ts := httptest.NewServer(rc)
+	t.Cleanup(ts.Close)
+	d := New(store, Options{PollInterval: 10 * time.Millisecond, MaxAttempts: 2, BaseDelay: 10 * time.Millisecond, AllowPrivate: true})
+	return d, rc, ts.URL
+}
+
+func TestSubscribe(t *testing.T) {
+	ctx := context.Background()
+	d, _, address := newDispatcher(t)
+	user := uuid.New()
+
+	tests := []struct {
+		name string
+		req  model.WebhookRequest
+	}{
+		{name: "относительный адрес", req: model.WebhookRequest{URL: "/hook", Events: []model.EventType{model.EventLinkCreated}}},
+		{name: "не http", req: model.WebhookRequest{URL: "ftp://example.com", Events: []model.EventType{model.EventLinkCreated}}},
+		{name: "без событий", req: model.WebhookRequest{URL: address}},
+		{name: "неизвестное событие", req: model.WebhookRequest{URL: address, Events: []model.EventType{model.EventLinkClicked}}},
+		{name: "без порога", req: model.WebhookRequest{URL: address, Events: []model.EventType{model.EventClickThreshold}}},
+		{name: "лишний порог", req: model.WebhookRequest{URL: address, Events: []model.EventType{model.EventLinkCreated}, ClickThreshold: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := d.Subscribe(ctx, user, tt.req)
+			assert.Equal(t, apierror.CodeInvalidWebhook, apierror.From(err).Code)
+		})
+	}
+
+	hook, err := d.Subscribe(ctx, user, model.WebhookRequest{URL: address, Events: []model.EventType{model.EventLinkCreated}})
+	require.NoError(t, err)
+	assert.Len(t, hook.Secret, 64)
+	hooks, err := d.Webhooks(ctx, user)
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+	assert.Equal(t, hook.ID, hooks[0].ID)
+	assert.Empty(t, hooks[0].Secret, "ключ подписи показывается только при создании")
+
+	// чужие подписки недоступны
+	assert.ErrorIs(t, d.Unsubscribe(ctx, uuid.New(), hook.ID), storage.ErrHookNotFound)
+	_, err = d.Deliveries(ctx, uuid.New(), hook.ID, "", 0)
+	assert.ErrorIs(t, err, storage.ErrHookNotFound)
+	require.NoError(t, d.Unsubscribe(ctx, user, hook.ID))
+	hooks, err = d.Webhooks(ctx, user)
+	require.NoError(t, err)
+	assert.Empty(t, hooks)
+}
+
+func TestDeliver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d, rc, address := newDispatcher(t)
+	user := uuid.New()
+	hook, err := d.Subscribe(ctx, user, model.WebhookRequest{
+		URL:            address,
+		Events:         []model.EventType{model.EventLinkCreated, model.EventClickThreshold},
+		ClickThreshold: 2,
+	})
+	require.NoError(t, err)
+	rc.secret.Store(hook.Secret)
+	go d.Run(ctx)
+
+	d.Publish(events.New(model.EventLinkCreated, user, "go", "https://go.dev"))
+	// удаление не входит в подписку, события чужих ссылок не доставляются
+	d.Publish(events.New(model.EventLinkDeleted, user, "go", ""))
+	d.Publish(events.New(model.EventLinkCreated, uuid.New(), "other", "https://example.com"))
+	// событие второго перехода пропущено шиной: подписка срабатывает на первом событии после порога и только один раз
+	for _, clicks := range []int64{1, 3, 4} {
+		e := events.New(model.EventLinkClicked, user, "go", "https://go.dev")
+		e.Clicks = clicks
+		d.Publish(e)
+	}
+
+	require.Eventually(t, func() bool { return len(rc.received()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	received := rc.received()
+	types := []model.EventType{received[0].Type, received[1].Type}
+	assert.ElementsMatch(t, []model.EventType{model.EventLinkCreated, model.EventClickThreshold}, types)
+	for _, e := range received {
+		if e.Type == model.EventClickThreshold {
+			assert.EqualValues(t, 3, e.Clicks, "событие отправляется при достижении порога")
+		}
+	}
+	require.Eventually(t, func() bool {
+		deliveries, err := d.Deliveries(ctx, user, hook.ID, model.DeliveryDelivered, 0)
+		return err == nil && len(deliveries) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, rc.received(), 2)
+}
+
+func TestDeadLetter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d, rc, address := newDispatcher(t)
+	rc.status.Store(http.StatusInternalServerError)
+	user := uuid.New()
+	hook, err := d.Subscribe(ctx, user, model.WebhookRequest{URL: address, Events: []model.EventType{model.EventLinkDeleted}})
+	require.NoError(t, err)
+	rc.secret.Store(hook.Secret)
+	go d.Run(ctx)
+
+	d.Publish(events.New(model.EventLinkDeleted, user, "go", ""))
+	var dead []model.WebhookDelivery
+	require.Eventually(t, func() bool {
+		dead, err = d.Deliveries(ctx, user, hook.ID, model.DeliveryDead, 10)
+		return err == nil && len(dead) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, dead[0].ResponseStatus)
+	assert.True(t, strings.Contains(dead[0].Error, "500"))
+	assert.NotContains(t, dead[0].Error, "internal details", "тело ответа не сохраняется")
+	assert.Len(t, rc.received(), 2)
+
+	// повторная отправка из списка недоставленных
+	_, err = d.Redeliver(ctx, uuid.New(), hook.ID, dead[0].ID)
+	assert.ErrorIs(t, err, storage.ErrHookNotFound)
+	rc.status.Store(http.StatusOK)
+	delivery, err := d.Redeliver(ctx, user, hook.ID, dead[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.DeliveryPending, delivery.Status)
+	assert.Zero(t, delivery.Attempts)
+	require.Eventually(t, func() bool {
+		delivered, err := d.Deliveries(ctx, user, hook.ID, model.DeliveryDelivered, 0)
+		return err == nil && len(delivered) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Len(t, rc.received(), 3)
+}
+
+func TestPrivateAddress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := memory.NewStorage("")
+	require.NoError(t, err)
+	d := New(store, Options{PollInterval: 10 * time.Millisecond, MaxAttempts: 1})
+	user := uuid.New()
+
+	// адреса внутренней сети отклоняются при создании подписки
+	for _, address := range []string{"http://localhost:8080/hook", "http://127.0.0.1/hook", "http://[::1]/hook", "http://10.0.0.1/hook", "http://169.254.169.254/latest/meta-data"} {
+		_, err = d.Subscribe(ctx, user, model.WebhookRequest{URL: address, Events: []model.EventType{model.EventLinkCreated}})
+		assert.Equal(t, apierror.CodeInvalidWebhook, apierror.From(err).Code, address)
+	}
+
+	// адрес проверяется и при подключении: имя могло начать указывать во внутреннюю сеть после создания подписки
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls.Add(1) }))
+	defer ts.Close()
+	hook := model.Webhook{ID: uuid.New(), UserID: user, URL: ts.URL, Events: []model.EventType{model.EventLinkCreated}, Secret: "secret", CreatedAt: time.Now()}
+	require.NoError(t, store.SaveWebhook(ctx, hook))
+	go d.Run(ctx)
+
+	d.Publish(events.New(model.EventLinkCreated, user, "go", "https://go.dev"))
+	var dead []model.WebhookDelivery
+	require.Eventually(t, func() bool {
+		dead, err = d.Deliveries(ctx, user, hook.ID, model.DeliveryDead, 0)
+		return err == nil && len(dead) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Zero(t, dead[0].ResponseStatus)
+	assert.Zero(t, calls.Load())
+}
+
+func TestRedirect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d, rc, address := newDispatcher(t)
+	redirect := httptest.NewServer(http.RedirectHandler(address, http.StatusFound))
+	defer redirect.Close()
+	user := uuid.New()
+	hook, err := d.Subscribe(ctx, user, model.WebhookRequest{URL: redirect.URL, Events: []model.EventType{model.EventLinkCreated}})
+	require.NoError(t, err)
+	go d.Run(ctx)
+
+	// перенаправление не выполняется и не считается доставкой
+	d.Publish(events.New(model.EventLinkCreated, user, "go", "https://go.dev"))
+	var dead []model.WebhookDelivery
+	require.Eventually(t, func() bool {
+		dead, err = d.Deliveries(ctx, user, hook.ID, model.DeliveryDead, 0)
+		return err == nil && len(dead) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, http.StatusFound, dead[0].ResponseStatus)
+	assert.Empty(t, rc.received())
+}
+
+func TestRetention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d, rc, address := newDispatcher(t)
+	d.opts.Retention = 50 * time.Millisecond
+	user := uuid.New()
+	hook, err := d.Subscribe(ctx, user, model.WebhookRequest{URL: address, Events: []model.EventType{model.EventLinkCreated}})
+	require.NoError(t, err)
+	rc.secret.Store(hook.Secret)
+	go d.Run(ctx)
+
+	d.Publish(events.New(model.EventLinkCreated, user, "go", "https://go.dev"))
+	require.Eventually(t, func() bool { return len(rc.received()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	// доставленное событие удаляется из журнала по истечении времени хранения
+	require.Eventually(t, func() bool {
+		deliveries, err := d.Deliveries(ctx, user, hook.ID, "", 0)
+		return err == nil && len(deliveries) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRunDrain(t *testing.T) {
+	d, _, address := newDispatcher(t)
+	user := uuid.New()
+	hook, err := d.Subscribe(context.Background(), user, model.WebhookRequest{URL: address, Events: []model.EventType{model.EventLinkCreated}})
+	require.NoError(t, err)
+
+	// события, полученные до остановки, сохраняются и будут доставлены после перезапуска
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.Publish(events.New(model.EventLinkCreated, user, "go", "https://go.dev"))
+	d.Run(ctx)
+	pending, err := d.Deliveries(context.Background(), user, hook.ID, model.DeliveryPending, 0)
+	require.NoError(t, err)
+	assert.Len(t, pending, 1)
+}
+
+func TestBackoff(t *testing.T) {
+	d := New(nil, Options{BaseDelay: time.Second, MaxDelay: 10 * time.Second})
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 8*time.Second, d.backoff(4))
+	assert.Equal(t, 10*time.Second, d.backoff(5))
+	assert.Equal(t, 10*time.Second, d.backoff(50))
+}
+
+func TestSign(t *testing.T) {
+	// подпись совпадает с вычисленной openssl: echo -n '1700000000.{}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163", Sign("secret", "1700000000", []byte("{}")))
+}